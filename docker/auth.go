@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultRegistry is the key docker uses for Docker Hub in config.json.
+const defaultRegistry = "https://index.docker.io/v1/"
+
+// authConfig is the credential object sent to the daemon in X-Registry-Auth
+// and to POST /auth.
+type authConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+func (a authConfig) empty() bool {
+	return a.Username == "" && a.Password == "" && a.IdentityToken == ""
+}
+
+// header encodes the credentials for the X-Registry-Auth header. The daemon
+// requires the header on push even without credentials, so an empty config
+// encodes as "{}".
+func (a authConfig) header() string {
+	data, _ := json.Marshal(a)
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+// registryForImage returns the registry host of an image reference, using
+// the same rule as the docker CLI: the first path component is a registry
+// if it contains a dot or a port, or is localhost.
+func registryForImage(ref string) string {
+	i := strings.Index(ref, "/")
+	if i < 0 {
+		return defaultRegistry
+	}
+	first := ref[:i]
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		return first
+	}
+	return defaultRegistry
+}
+
+// normalizeRegistry reduces a registry address to its host so that
+// "https://registry.example.com/v2/" and "registry.example.com" compare equal.
+func normalizeRegistry(addr string) string {
+	addr = strings.TrimPrefix(strings.TrimPrefix(addr, "https://"), "http://")
+	if i := strings.Index(addr, "/"); i >= 0 {
+		addr = addr[:i]
+	}
+	switch addr {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return "index.docker.io"
+	}
+	return addr
+}
+
+// dockerConfigFile is the part of ~/.docker/config.json the plugin reads.
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// dockerConfigPath returns the config_file parameter or the docker CLI's
+// config.json location.
+func dockerConfigPath(params map[string]interface{}) string {
+	if path, ok := params["config_file"].(string); ok && path != "" {
+		return path
+	}
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".docker")
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// resolveAuth returns credentials for registry. Explicit username/password
+// parameters win; otherwise config.json is consulted, including any
+// credential helper it names. A missing config file means anonymous access.
+func resolveAuth(ctx context.Context, params map[string]interface{}, registry string) (authConfig, error) {
+	auth := authConfig{ServerAddress: registry}
+	if username, ok := params["username"].(string); ok && username != "" {
+		auth.Username = username
+		auth.Password, _ = params["password"].(string)
+		return auth, nil
+	}
+
+	path := dockerConfigPath(params)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return auth, nil
+	} else if err != nil {
+		return auth, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var cfg dockerConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return auth, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	want := normalizeRegistry(registry)
+	for key, helper := range cfg.CredHelpers {
+		if normalizeRegistry(key) == want {
+			return credentialHelper(ctx, helper, key, auth)
+		}
+	}
+	for key, entry := range cfg.Auths {
+		if normalizeRegistry(key) != want {
+			continue
+		}
+		auth.Username, auth.Password, auth.IdentityToken = entry.Username, entry.Password, entry.IdentityToken
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return auth, fmt.Errorf("invalid auth entry for %s in %s", key, path)
+			}
+			user, pass, _ := strings.Cut(string(decoded), ":")
+			auth.Username, auth.Password = user, pass
+		}
+		if !auth.empty() {
+			return auth, nil
+		}
+	}
+	if cfg.CredsStore != "" {
+		return credentialHelper(ctx, cfg.CredsStore, registry, auth)
+	}
+	return auth, nil
+}
+
+// credentialHelper asks docker-credential-<helper> for the credentials of
+// serverURL. Helpers report unknown registries as an error containing
+// "credentials not found", which is treated as anonymous access.
+func credentialHelper(ctx context.Context, helper, serverURL string, auth authConfig) (authConfig, error) {
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stdout.String()+stderr.String(), "credentials not found") {
+			return auth, nil
+		}
+		return auth, fmt.Errorf("credential helper %s failed: %s", helper, strings.TrimSpace(stderr.String()+stdout.String()))
+	}
+
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return auth, fmt.Errorf("credential helper %s returned invalid output: %w", helper, err)
+	}
+	// Helpers store identity tokens under the username "<token>".
+	if creds.Username == "<token>" {
+		auth.IdentityToken = creds.Secret
+	} else {
+		auth.Username, auth.Password = creds.Username, creds.Secret
+	}
+	return auth, nil
+}
+
+// saveAuth records credentials for registry in config.json, keeping every
+// other setting in the file intact.
+func saveAuth(path string, auth authConfig) error {
+	raw := map[string]interface{}{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	auths, _ := raw["auths"].(map[string]interface{})
+	if auths == nil {
+		auths = map[string]interface{}{}
+	}
+	entry := map[string]interface{}{}
+	if auth.IdentityToken != "" {
+		entry["identitytoken"] = auth.IdentityToken
+	} else {
+		entry["auth"] = base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+	}
+	auths[auth.ServerAddress] = entry
+	raw["auths"] = auths
+
+	data, err := json.MarshalIndent(raw, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignorePattern is one line of a .dockerignore file.
+type ignorePattern struct {
+	re      *regexp.Regexp
+	exclude bool
+}
+
+// ignoreMatcher applies .dockerignore rules; the last matching rule wins.
+type ignoreMatcher struct {
+	patterns      []ignorePattern
+	hasExceptions bool
+}
+
+func loadDockerignore(contextDir string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{}
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		exclude := true
+		if strings.HasPrefix(line, "!") {
+			exclude = false
+			m.hasExceptions = true
+			line = strings.TrimSpace(line[1:])
+		}
+		line = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(line)), "/")
+		re, err := regexp.Compile(globToRegexp(line))
+		if err != nil {
+			return nil, fmt.Errorf("invalid .dockerignore pattern %q: %w", line, err)
+		}
+		m.patterns = append(m.patterns, ignorePattern{re: re, exclude: exclude})
+	}
+	return m, scanner.Err()
+}
+
+// globToRegexp translates a .dockerignore glob, where ** matches any number
+// of directories, into an anchored regular expression.
+func globToRegexp(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case ch == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			i++
+			if i+1 < len(pattern) && pattern[i+1] == '/' {
+				i++
+				sb.WriteString("(.*/)?")
+			} else {
+				sb.WriteString(".*")
+			}
+		case ch == '*':
+			sb.WriteString("[^/]*")
+		case ch == '?':
+			sb.WriteString("[^/]")
+		case ch == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(pattern[i : i+end+1])
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// excluded reports whether rel (slash separated, relative to the context)
+// is ignored. A rule matching a parent directory also covers its contents.
+func (m *ignoreMatcher) excluded(rel string) bool {
+	candidates := []string{rel}
+	for dir := rel; strings.Contains(dir, "/"); {
+		dir = dir[:strings.LastIndex(dir, "/")]
+		candidates = append(candidates, dir)
+	}
+
+	excluded := false
+	for _, p := range m.patterns {
+		for _, c := range candidates {
+			if p.re.MatchString(c) {
+				excluded = p.exclude
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// resolveDockerfile returns the name the Dockerfile has inside the build
+// context archive. A Dockerfile outside the context is added to the archive
+// under a generated name; its path on disk is returned as external.
+func resolveDockerfile(contextDir, dockerfile string) (name, external string) {
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	path := dockerfile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(contextDir, path)
+	}
+	if rel, err := filepath.Rel(contextDir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+		return filepath.ToSlash(rel), ""
+	}
+	return ".corynth.Dockerfile", path
+}
+
+// writeBuildContext tars contextDir into w, honouring .dockerignore. The
+// Dockerfile and .dockerignore are always sent.
+func writeBuildContext(w io.Writer, contextDir, dockerfile string) error {
+	ignore, err := loadDockerignore(contextDir)
+	if err != nil {
+		return err
+	}
+	dockerfileRel, external := resolveDockerfile(contextDir, dockerfile)
+
+	tw := tar.NewWriter(w)
+	err = filepath.Walk(contextDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(contextDir, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		keep := rel == dockerfileRel || rel == ".dockerignore"
+		if !keep && ignore.excluded(rel) {
+			if info.IsDir() && !ignore.hasExceptions {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		// Ownership on the build host is meaningless inside the image.
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			return copyFile(tw, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive build context: %w", err)
+	}
+
+	if external != "" {
+		info, err := os.Stat(external)
+		if err != nil {
+			return fmt.Errorf("failed to read Dockerfile: %w", err)
+		}
+		hdr := &tar.Header{Name: dockerfileRel, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if err := copyFile(tw, external); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// buildOptions are the query parameters of an image build.
+type buildOptions struct {
+	contextDir string
+	dockerfile string
+	tags       []string
+	buildArgs  map[string]string
+	target     string
+	labels     map[string]string
+	platform   string
+	cacheFrom  []string
+	noCache    bool
+	pull       bool
+}
+
+// buildResult is what the daemon reported while building.
+type buildResult struct {
+	imageID string
+	log     string
+}
+
+// buildLogTail is how many lines of build output are quoted when a build
+// fails, so the failing instruction's output shows up in the step error.
+const buildLogTail = 20
+
+// query encodes the options as POST /build parameters.
+func (o buildOptions) query() url.Values {
+	dockerfile, _ := resolveDockerfile(o.contextDir, o.dockerfile)
+	query := url.Values{"rm": {"1"}, "dockerfile": {dockerfile}}
+	for _, t := range o.tags {
+		query.Add("t", t)
+	}
+	if o.target != "" {
+		query.Set("target", o.target)
+	}
+	if o.platform != "" {
+		query.Set("platform", o.platform)
+	}
+	if o.noCache {
+		query.Set("nocache", "1")
+	}
+	if o.pull {
+		query.Set("pull", "1")
+	}
+	if len(o.buildArgs) > 0 {
+		data, _ := json.Marshal(o.buildArgs)
+		query.Set("buildargs", string(data))
+	}
+	if len(o.labels) > 0 {
+		data, _ := json.Marshal(o.labels)
+		query.Set("labels", string(data))
+	}
+	if len(o.cacheFrom) > 0 {
+		data, _ := json.Marshal(o.cacheFrom)
+		query.Set("cachefrom", string(data))
+	}
+	return query
+}
+
+// buildImage streams the build context to the daemon and follows the build
+// output until the image is built or the daemon reports an error.
+func (c *dockerClient) buildImage(ctx context.Context, opts buildOptions) (buildResult, error) {
+	var result buildResult
+
+	info, err := os.Stat(opts.contextDir)
+	if err != nil {
+		return result, fmt.Errorf("invalid build context: %w", err)
+	}
+	if !info.IsDir() {
+		return result, fmt.Errorf("build context %s is not a directory", opts.contextDir)
+	}
+	query := opts.query()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeBuildContext(pw, opts.contextDir, opts.dockerfile))
+	}()
+	header := http.Header{"Content-Type": {"application/x-tar"}}
+
+	resp, err := c.request(ctx, http.MethodPost, "/build", query, pr, header)
+	if err != nil {
+		pr.CloseWithError(err)
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	var log strings.Builder
+	err = readJSONMessages(resp.Body, func(msg jsonMessage) {
+		switch {
+		case msg.Stream != "":
+			log.WriteString(msg.Stream)
+		case msg.Status != "" && msg.Progress == "":
+			// Pulls of base images report per-layer status; keep the
+			// summary lines and drop the progress bars.
+			if msg.ID != "" {
+				log.WriteString(msg.ID + ": ")
+			}
+			log.WriteString(msg.Status + "\n")
+		}
+		if len(msg.Aux) > 0 {
+			var aux struct {
+				ID string `json:"ID"`
+			}
+			if json.Unmarshal(msg.Aux, &aux) == nil && aux.ID != "" {
+				result.imageID = aux.ID
+			}
+		}
+	})
+	result.log = log.String()
+	if err != nil {
+		if tail := lastLines(result.log, buildLogTail); tail != "" {
+			return result, fmt.Errorf("build failed: %w\n%s", err, tail)
+		}
+		return result, fmt.Errorf("build failed: %w", err)
+	}
+
+	// Daemons that do not send the aux message still know the image by
+	// its tag.
+	if result.imageID == "" && len(opts.tags) > 0 {
+		var image struct {
+			ID string `json:"Id"`
+		}
+		if err := c.doJSON(ctx, http.MethodGet, "/images/"+opts.tags[0]+"/json", nil, nil, &image); err != nil {
+			return result, fmt.Errorf("build finished but the image could not be inspected: %w", err)
+		}
+		result.imageID = image.ID
+	}
+	return result, nil
+}
+
+// lastLines returns at most n trailing non-empty lines of text.
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
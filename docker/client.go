@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// apiVersion is the Engine API version requested on every call. 1.41 is
+// served by Docker 20.10 and every later release.
+const apiVersion = "v1.41"
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// apiError is an error response returned by the daemon.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("docker daemon: %s", e.message)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.status == http.StatusNotFound
+}
+
+// dockerClient is a minimal Docker Engine API client.
+type dockerClient struct {
+	host string
+	base string
+	http *http.Client
+}
+
+// newClient connects to the daemon named by the host parameter, falling back
+// to DOCKER_HOST and then to the local unix socket. DOCKER_TLS_VERIFY and
+// DOCKER_CERT_PATH are honoured for tcp hosts the same way the docker CLI
+// honours them.
+func newClient(params map[string]interface{}) (*dockerClient, error) {
+	host, _ := params["host"].(string)
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = defaultDockerHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	c := &dockerClient{host: host, http: &http.Client{Transport: transport}}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		if socket == "" {
+			socket = u.Host
+		}
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		c.base = "http://docker"
+	case "tcp", "http", "https":
+		scheme := "http"
+		if u.Scheme == "https" || os.Getenv("DOCKER_TLS_VERIFY") != "" {
+			scheme = "https"
+			tlsConfig, err := dockerTLSConfig()
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsConfig
+		}
+		c.base = scheme + "://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host %q", host)
+	}
+	return c, nil
+}
+
+// dockerTLSConfig loads ca.pem, cert.pem and key.pem from DOCKER_CERT_PATH
+// (default ~/.docker). Missing files are skipped so that servers with public
+// certificates work without any setup.
+func dockerTLSConfig() (*tls.Config, error) {
+	dir := os.Getenv("DOCKER_CERT_PATH")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".docker")
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca, err := os.ReadFile(filepath.Join(dir, "ca.pem")); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", filepath.Join(dir, "ca.pem"))
+		}
+		cfg.RootCAs = pool
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if _, err := os.Stat(certFile); err == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load docker client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// request sends one API call. Responses with an error status are turned into
+// an *apiError carrying the daemon's message.
+func (c *dockerClient) request(ctx context.Context, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	target := c.base + "/" + apiVersion + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("cannot connect to docker daemon at %s: %w", c.host, err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var msg struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+			msg.Message = strings.TrimSpace(string(data))
+		}
+		if msg.Message == "" {
+			msg.Message = resp.Status
+		}
+		return nil, &apiError{status: resp.StatusCode, message: msg.Message}
+	}
+	return resp, nil
+}
+
+// doJSON sends in as a JSON body (when non-nil) and decodes the response
+// into out (when non-nil).
+func (c *dockerClient) doJSON(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	header := http.Header{}
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.request(ctx, method, path, query, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode docker response: %w", err)
+	}
+	return nil
+}
+
+// jsonMessage is one line of the progress stream returned by build, pull and
+// push.
+type jsonMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	ID          string `json:"id"`
+	Progress    string `json:"progress"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+	Aux json.RawMessage `json:"aux"`
+}
+
+// readJSONMessages calls fn for every message in the stream and stops with
+// the daemon's error message if the operation failed part way through.
+func readJSONMessages(r io.Reader, fn func(jsonMessage)) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg jsonMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read docker progress stream: %w", err)
+		}
+		if msg.ErrorDetail != nil && msg.ErrorDetail.Message != "" {
+			return fmt.Errorf("%s", msg.ErrorDetail.Message)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("%s", msg.Error)
+		}
+		if fn != nil {
+			fn(msg)
+		}
+	}
+}
+
+// demuxStream splits the multiplexed stdout/stderr stream used for
+// containers without a TTY. Each frame starts with an 8 byte header: the
+// stream type followed by a big-endian payload length.
+func demuxStream(r io.Reader, stdout, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// splitReference splits an image reference into repository and tag (or
+// digest), defaulting the tag to latest.
+func splitReference(ref string) (string, string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+// intParam reads a numeric parameter, accepting the float64 values produced
+// by HCL as well as plain ints and numeric strings.
+func intParam(params map[string]interface{}, name string, def int) int {
+	switch v := params[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// stringList reads a parameter that may be given as a single string or as an
+// array of values.
+func stringList(params map[string]interface{}, name string) []string {
+	switch v := params[name].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	}
+	return nil
+}
+
+// stringMap reads an object parameter as string keys and values.
+func stringMap(params map[string]interface{}, name string) map[string]string {
+	raw, ok := params[name].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	out := make(map[string]string, len(raw))
+	for k, v := range raw {
+		out[k] = fmt.Sprint(v)
+	}
+	return out
+}
@@ -0,0 +1,891 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// Labels used by docker compose to track project resources. Using the same
+// labels lets compose_down clean up stacks started with the docker CLI and
+// the other way round.
+const (
+	labelProject    = "com.docker.compose.project"
+	labelService    = "com.docker.compose.service"
+	labelNumber     = "com.docker.compose.container-number"
+	labelOneoff     = "com.docker.compose.oneoff"
+	labelConfigHash = "com.docker.compose.config-hash"
+	labelNetwork    = "com.docker.compose.network"
+	labelVolume     = "com.docker.compose.volume"
+)
+
+// composeFileNames are tried in order when no file is given.
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// composeActions declares compose_up and compose_down.
+func composeActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "compose_up",
+			Description: "Create and start the services of a compose file in dependency order",
+			Inputs: withComposeInputs(map[string]plugin.InputSpec{
+				"services": {
+					Type:        "array",
+					Description: "Only start these services and their dependencies (default: all)",
+					Required:    false,
+				},
+				"build": {
+					Type:        "boolean",
+					Description: "Rebuild images of services with a build section even if they exist",
+					Required:    false,
+					Default:     false,
+				},
+				"force_recreate": {
+					Type:        "boolean",
+					Description: "Recreate containers even if their configuration is unchanged",
+					Required:    false,
+					Default:     false,
+				},
+				"wait": {
+					Type:        "boolean",
+					Description: "Wait for services with a healthcheck to become healthy",
+					Required:    false,
+					Default:     true,
+				},
+				"timeout": {
+					Type:        "number",
+					Description: "Seconds to wait for each service to become healthy",
+					Required:    false,
+					Default:     120,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"project": {
+					Type:        "string",
+					Description: "Compose project name",
+				},
+				"services": {
+					Type:        "object",
+					Description: "Per-service container_id, container_name, state, health and action",
+				},
+				"order": {
+					Type:        "array",
+					Description: "Services in the order they were started",
+				},
+				"networks": {
+					Type:        "array",
+					Description: "Networks used by the project",
+				},
+				"volumes": {
+					Type:        "array",
+					Description: "Named volumes used by the project",
+				},
+			},
+		},
+		{
+			Name:        "compose_down",
+			Description: "Stop and remove the containers and networks of a compose project",
+			Inputs: withComposeInputs(map[string]plugin.InputSpec{
+				"volumes": {
+					Type:        "boolean",
+					Description: "Also remove named volumes declared by the project and anonymous volumes",
+					Required:    false,
+					Default:     false,
+				},
+				"timeout": {
+					Type:        "number",
+					Description: "Seconds to wait for each container to stop before killing it",
+					Required:    false,
+					Default:     10,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"project": {
+					Type:        "string",
+					Description: "Compose project name",
+				},
+				"containers": {
+					Type:        "array",
+					Description: "Names of removed containers",
+				},
+				"networks": {
+					Type:        "array",
+					Description: "Names of removed networks",
+				},
+				"volumes": {
+					Type:        "array",
+					Description: "Names of removed volumes",
+				},
+			},
+		},
+	}
+}
+
+// withComposeInputs adds the inputs that locate a compose project.
+func withComposeInputs(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["file"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Path to the compose file (default: compose.yaml or docker-compose.yml in project_directory)",
+		Required:    false,
+	}
+	inputs["project_directory"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Directory relative paths are resolved against (default: the compose file's directory)",
+		Required:    false,
+	}
+	inputs["project_name"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Project name (default: name in the file or the directory name)",
+		Required:    false,
+	}
+	inputs["env"] = plugin.InputSpec{
+		Type:        "object",
+		Description: "Variables for ${VAR} interpolation, overriding the environment and .env",
+		Required:    false,
+	}
+	return withHostInput(inputs)
+}
+
+// composeProject is a parsed compose file and the names derived from it.
+type composeProject struct {
+	name string
+	dir  string
+	file *composeFile
+}
+
+// loadProject locates and parses the compose file. compose_down can run
+// with only a project_name, in which case file is nil.
+func loadProject(params map[string]interface{}, fileRequired bool) (*composeProject, error) {
+	dir, _ := params["project_directory"].(string)
+	path, _ := params["file"].(string)
+	if path == "" {
+		base := dir
+		if base == "" {
+			base = "."
+		}
+		for _, name := range composeFileNames {
+			candidate := filepath.Join(base, name)
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+
+	p := &composeProject{}
+	if path != "" {
+		file, err := loadComposeFile(path, stringMap(params, "env"))
+		if err != nil {
+			return nil, err
+		}
+		p.file = file
+		if dir == "" {
+			dir = filepath.Dir(path)
+		}
+	} else if fileRequired {
+		return nil, fmt.Errorf("no compose file found; set the file parameter")
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	p.dir = abs
+
+	switch {
+	case params["project_name"] != nil && params["project_name"] != "":
+		p.name = projectName(fmt.Sprint(params["project_name"]))
+	case p.file != nil && p.file.Name != "":
+		p.name = projectName(p.file.Name)
+	default:
+		p.name = projectName(filepath.Base(abs))
+	}
+	if p.name == "" {
+		return nil, fmt.Errorf("could not derive a project name; set project_name")
+	}
+	return p, nil
+}
+
+func (p *composeProject) networkName(key string) string {
+	if n := p.file.Networks[key]; n != nil {
+		if n.Name != "" {
+			return n.Name
+		}
+		if n.External {
+			return key
+		}
+	}
+	return p.name + "_" + key
+}
+
+func (p *composeProject) volumeName(key string) string {
+	if v := p.file.Volumes[key]; v != nil {
+		if v.Name != "" {
+			return v.Name
+		}
+		if v.External {
+			return key
+		}
+	}
+	return p.name + "_" + key
+}
+
+func (p *composeProject) containerName(service string) string {
+	if name := p.file.Services[service].ContainerName; name != "" {
+		return name
+	}
+	return p.name + "-" + service + "-1"
+}
+
+func (p *composeProject) imageName(service string) string {
+	if image := p.file.Services[service].Image; image != "" {
+		return image
+	}
+	return p.name + "-" + service
+}
+
+// serviceNetworks returns the networks a service joins; services without a
+// networks section join the project's default network.
+func (p *composeProject) serviceNetworks(service string) serviceNetworks {
+	if nets := p.file.Services[service].Networks; len(nets) > 0 {
+		return nets
+	}
+	return serviceNetworks{"default": nil}
+}
+
+// resolvePath makes a bind mount source absolute relative to the project.
+func (p *composeProject) resolvePath(source string) string {
+	if strings.HasPrefix(source, "~") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, source[1:])
+	}
+	if filepath.IsAbs(source) {
+		return source
+	}
+	return filepath.Join(p.dir, source)
+}
+
+// containerConfig builds the create request for a service. The returned
+// config carries a hash of itself and of imageID in a label so that an
+// unchanged service is left running on the next compose_up, while one whose
+// image was rebuilt or pulled to a new ID is recreated.
+func (p *composeProject) containerConfig(service, imageID string) (map[string]interface{}, error) {
+	svc := p.file.Services[service]
+
+	ports := make([]string, len(svc.Ports))
+	for i, port := range svc.Ports {
+		ports[i] = string(port)
+	}
+	exposed, bindings, err := parsePorts(ports)
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", service, err)
+	}
+
+	var binds []string
+	anonymous := make(map[string]struct{})
+	for _, m := range svc.Volumes {
+		switch {
+		case m.Source == "":
+			anonymous[m.Target] = struct{}{}
+			continue
+		case m.Type == "bind":
+			binds = append(binds, p.resolvePath(m.Source)+":"+m.Target)
+		default:
+			if _, ok := p.file.Volumes[m.Source]; !ok {
+				return nil, fmt.Errorf("service %s refers to undefined volume %s", service, m.Source)
+			}
+			binds = append(binds, p.volumeName(m.Source)+":"+m.Target)
+		}
+		if m.ReadOnly {
+			binds[len(binds)-1] += ":ro"
+		}
+	}
+
+	labels := map[string]string{}
+	for k, v := range svc.Labels {
+		labels[k] = v
+	}
+	labels[labelProject] = p.name
+	labels[labelService] = service
+	labels[labelNumber] = "1"
+	labels[labelOneoff] = "False"
+
+	restart, err := restartPolicy(svc.Restart)
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", service, err)
+	}
+
+	// The container is created on the first network; the others are
+	// connected before it starts.
+	networks := p.serviceNetworks(service)
+	keys := make([]string, 0, len(networks))
+	for key := range networks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	primary := keys[0]
+
+	hostConfig := map[string]interface{}{
+		"PortBindings":  bindings,
+		"Binds":         binds,
+		"RestartPolicy": restart,
+		"NetworkMode":   p.networkName(primary),
+		"ExtraHosts":    svc.ExtraHosts,
+	}
+	config := map[string]interface{}{
+		"Image":        p.imageName(service),
+		"Env":          envList(svc.Environment),
+		"Labels":       labels,
+		"ExposedPorts": exposed,
+		"Volumes":      anonymous,
+		"WorkingDir":   svc.WorkingDir,
+		"User":         svc.User,
+		"Hostname":     svc.Hostname,
+		"HostConfig":   hostConfig,
+		"NetworkingConfig": map[string]interface{}{
+			"EndpointsConfig": map[string]interface{}{
+				p.networkName(primary): map[string]interface{}{
+					"Aliases": append([]string{service}, networks[primary]...),
+				},
+			},
+		},
+	}
+	if cmd := svc.Command.words(); len(cmd) > 0 {
+		config["Cmd"] = cmd
+	}
+	if entrypoint := svc.Entrypoint.words(); len(entrypoint) > 0 {
+		config["Entrypoint"] = entrypoint
+	}
+	if svc.Healthcheck != nil {
+		health, err := healthcheckConfig(svc.Healthcheck)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", service, err)
+		}
+		config["Healthcheck"] = health
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(append(data, imageID...))
+	labels[labelConfigHash] = hex.EncodeToString(sum[:])
+	return config, nil
+}
+
+// usedNetworks returns the network keys referenced by the given services.
+func (p *composeProject) usedNetworks(services []string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, service := range services {
+		for key := range p.serviceNetworks(service) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// withDependencies expands services to include everything they depend on.
+func (p *composeProject) withDependencies(services []string) (map[string]bool, error) {
+	wanted := map[string]bool{}
+	var visit func(string) error
+	visit = func(name string) error {
+		svc, ok := p.file.Services[name]
+		if !ok {
+			return fmt.Errorf("no such service: %s", name)
+		}
+		if wanted[name] {
+			return nil
+		}
+		wanted[name] = true
+		for dep := range svc.DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range services {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return wanted, nil
+}
+
+func (c *dockerClient) ensureNetwork(ctx context.Context, p *composeProject, key string) error {
+	name := p.networkName(key)
+	err := c.doJSON(ctx, http.MethodGet, "/networks/"+url.PathEscape(name), nil, nil, nil)
+	if err == nil || !isNotFound(err) {
+		return err
+	}
+
+	spec := p.file.Networks[key]
+	if spec == nil {
+		spec = &composeNetwork{}
+	}
+	if spec.External {
+		return fmt.Errorf("external network %s not found", name)
+	}
+	labels := map[string]string{labelProject: p.name, labelNetwork: key}
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	body := map[string]interface{}{
+		"Name":           name,
+		"CheckDuplicate": true,
+		"Internal":       spec.Internal,
+		"Labels":         labels,
+	}
+	if spec.Driver != "" {
+		body["Driver"] = spec.Driver
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/networks/create", nil, body, nil); err != nil {
+		return fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+	return nil
+}
+
+func (c *dockerClient) ensureVolume(ctx context.Context, p *composeProject, key string) error {
+	name := p.volumeName(key)
+	err := c.doJSON(ctx, http.MethodGet, "/volumes/"+url.PathEscape(name), nil, nil, nil)
+	if err == nil || !isNotFound(err) {
+		return err
+	}
+
+	spec := p.file.Volumes[key]
+	if spec == nil {
+		spec = &composeVolume{}
+	}
+	if spec.External {
+		return fmt.Errorf("external volume %s not found", name)
+	}
+	labels := map[string]string{labelProject: p.name, labelVolume: key}
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	body := map[string]interface{}{"Name": name, "Labels": labels}
+	if spec.Driver != "" {
+		body["Driver"] = spec.Driver
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/volumes/create", nil, body, nil); err != nil {
+		return fmt.Errorf("failed to create volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// projectContainers lists every container of the project, keyed by service.
+func (c *dockerClient) projectContainers(ctx context.Context, project string) (map[string]containerSummary, error) {
+	filters, _ := json.Marshal(map[string][]string{"label": {labelProject + "=" + project}})
+	query := url.Values{"all": {"1"}, "filters": {string(filters)}}
+	var summaries []containerSummary
+	if err := c.doJSON(ctx, http.MethodGet, "/containers/json", query, nil, &summaries); err != nil {
+		return nil, fmt.Errorf("failed to list project containers: %w", err)
+	}
+	out := make(map[string]containerSummary, len(summaries))
+	for _, s := range summaries {
+		out[s.Labels[labelService]] = s
+	}
+	return out, nil
+}
+
+// waitHealthy polls a container until its healthcheck reports healthy.
+func (c *dockerClient) waitHealthy(ctx context.Context, id, service string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		info, _, err := c.inspectContainer(ctx, id)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("service %s did not become healthy within %s", service, timeout)
+			}
+			return err
+		}
+		switch {
+		case info.State.Health == nil:
+			return fmt.Errorf("service %s has no healthcheck", service)
+		case info.State.Health.Status == "healthy":
+			return nil
+		case info.State.Health.Status == "unhealthy":
+			return fmt.Errorf("service %s is unhealthy", service)
+		case !info.State.Running:
+			return fmt.Errorf("service %s exited with code %d before becoming healthy", service, info.State.ExitCode)
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("service %s did not become healthy within %s (status %s)", service, timeout, info.State.Health.Status)
+			}
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// waitCondition blocks until dependency dep reaches a depends_on condition.
+func (c *dockerClient) waitCondition(ctx context.Context, id, dep, condition string, timeout time.Duration) error {
+	switch condition {
+	case "service_started":
+		return nil
+	case "service_healthy":
+		return c.waitHealthy(ctx, id, dep, timeout)
+	case "service_completed_successfully":
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		code, err := c.waitContainer(waitCtx, id, "not-running")
+		if err != nil {
+			return fmt.Errorf("waiting for service %s: %w", dep, err)
+		}
+		if code != 0 {
+			return fmt.Errorf("service %s exited with code %d", dep, code)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported depends_on condition %q for %s", condition, dep)
+	}
+}
+
+// imageID returns the ID of image, or "" if the daemon does not have it.
+func (c *dockerClient) imageID(ctx context.Context, image string) (string, error) {
+	var info struct {
+		ID string `json:"Id"`
+	}
+	err := c.doJSON(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil, &info)
+	if isNotFound(err) {
+		return "", nil
+	}
+	return info.ID, err
+}
+
+func (p *DockerPlugin) executeComposeUp(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	project, err := loadProject(params, true)
+	if err != nil {
+		return nil, err
+	}
+	order, err := project.file.startOrder()
+	if err != nil {
+		return nil, err
+	}
+	if selected := stringList(params, "services"); len(selected) > 0 {
+		wanted, err := project.withDependencies(selected)
+		if err != nil {
+			return nil, err
+		}
+		filtered := order[:0]
+		for _, name := range order {
+			if wanted[name] {
+				filtered = append(filtered, name)
+			}
+		}
+		order = filtered
+	}
+
+	rebuild, _ := params["build"].(bool)
+	forceRecreate, _ := params["force_recreate"].(bool)
+	wait := true
+	if w, ok := params["wait"].(bool); ok {
+		wait = w
+	}
+	timeout := time.Duration(intParam(params, "timeout", 120)) * time.Second
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := project.usedNetworks(order)
+	networkNames := make([]string, 0, len(networks))
+	for _, key := range networks {
+		if err := client.ensureNetwork(ctx, project, key); err != nil {
+			return nil, err
+		}
+		networkNames = append(networkNames, project.networkName(key))
+	}
+	volumeKeys := make([]string, 0, len(project.file.Volumes))
+	for key := range project.file.Volumes {
+		volumeKeys = append(volumeKeys, key)
+	}
+	sort.Strings(volumeKeys)
+	volumeNames := make([]string, 0, len(volumeKeys))
+	for _, key := range volumeKeys {
+		if err := client.ensureVolume(ctx, project, key); err != nil {
+			return nil, err
+		}
+		volumeNames = append(volumeNames, project.volumeName(key))
+	}
+
+	existing, err := client.projectContainers(ctx, project.name)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]string, len(order))
+	actions := make(map[string]string, len(order))
+	for _, service := range order {
+		svc := project.file.Services[service]
+
+		deps := make([]string, 0, len(svc.DependsOn))
+		for dep := range svc.DependsOn {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := client.waitCondition(ctx, ids[dep], dep, svc.DependsOn[dep], timeout); err != nil {
+				return nil, fmt.Errorf("cannot start %s: %w", service, err)
+			}
+		}
+
+		// The image ID goes into the config hash, so a service whose image
+		// was rebuilt or pulled to a new ID is recreated.
+		image := project.imageName(service)
+		imageID, err := client.imageID(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case svc.Build != nil && (rebuild || imageID == ""):
+			opts := buildOptions{
+				contextDir: project.resolvePath(svc.Build.Context),
+				dockerfile: svc.Build.Dockerfile,
+				tags:       []string{image},
+				buildArgs:  svc.Build.Args,
+				target:     svc.Build.Target,
+				labels:     svc.Build.Labels,
+				cacheFrom:  svc.Build.CacheFrom,
+			}
+			if len(svc.Build.Platforms) > 0 {
+				opts.platform = svc.Build.Platforms[0]
+			}
+			result, err := client.buildImage(ctx, opts)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %w", service, err)
+			}
+			imageID = result.imageID
+		case imageID == "":
+			auth, err := resolveAuth(ctx, map[string]interface{}{}, registryForImage(image))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := client.pullImage(ctx, image, auth, ""); err != nil {
+				return nil, fmt.Errorf("service %s: %w", service, err)
+			}
+			if imageID, err = client.imageID(ctx, image); err != nil {
+				return nil, err
+			}
+		}
+
+		config, err := project.containerConfig(service, imageID)
+		if err != nil {
+			return nil, err
+		}
+		hash := config["Labels"].(map[string]string)[labelConfigHash]
+
+		if current, ok := existing[service]; ok {
+			if current.Labels[labelConfigHash] == hash && !forceRecreate {
+				ids[service] = current.ID
+				actions[service] = "unchanged"
+				if current.State != "running" {
+					if err := client.doJSON(ctx, http.MethodPost, "/containers/"+current.ID+"/start", nil, nil, nil); err != nil {
+						return nil, fmt.Errorf("failed to start %s: %w", service, err)
+					}
+					actions[service] = "started"
+				}
+				continue
+			}
+			if err := client.doJSON(ctx, http.MethodDelete, "/containers/"+current.ID, url.Values{"force": {"1"}}, nil, nil); err != nil {
+				return nil, fmt.Errorf("failed to remove outdated container for %s: %w", service, err)
+			}
+			actions[service] = "recreated"
+		} else {
+			actions[service] = "created"
+		}
+
+		id, _, err := client.createContainer(ctx, project.containerName(service), config)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", service, err)
+		}
+		ids[service] = id
+
+		nets := project.serviceNetworks(service)
+		primary := config["HostConfig"].(map[string]interface{})["NetworkMode"]
+		for _, key := range project.usedNetworks([]string{service}) {
+			name := project.networkName(key)
+			if name == primary {
+				continue
+			}
+			body := map[string]interface{}{
+				"Container":      id,
+				"EndpointConfig": map[string]interface{}{"Aliases": append([]string{service}, nets[key]...)},
+			}
+			if err := client.doJSON(ctx, http.MethodPost, "/networks/"+url.PathEscape(name)+"/connect", nil, body, nil); err != nil {
+				return nil, fmt.Errorf("failed to connect %s to network %s: %w", service, name, err)
+			}
+		}
+
+		if err := client.doJSON(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil, nil); err != nil {
+			return nil, fmt.Errorf("failed to start %s: %w", service, err)
+		}
+	}
+
+	if wait {
+		for _, service := range order {
+			if project.file.Services[service].Healthcheck == nil {
+				continue
+			}
+			if err := client.waitHealthy(ctx, ids[service], service, timeout); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	services := make(map[string]interface{}, len(order))
+	for _, service := range order {
+		info, _, err := client.inspectContainer(ctx, ids[service])
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", service, err)
+		}
+		services[service] = map[string]interface{}{
+			"container_id":   info.ID,
+			"container_name": strings.TrimPrefix(info.Name, "/"),
+			"image":          info.Config.Image,
+			"state":          info.State.Status,
+			"health":         info.health(),
+			"action":         actions[service],
+		}
+	}
+
+	return map[string]interface{}{
+		"project":  project.name,
+		"services": services,
+		"order":    order,
+		"networks": networkNames,
+		"volumes":  volumeNames,
+		"success":  true,
+		"message":  fmt.Sprintf("Project %s is up with %d services", project.name, len(order)),
+	}, nil
+}
+
+func (p *DockerPlugin) executeComposeDown(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	project, err := loadProject(params, false)
+	if err != nil {
+		return nil, err
+	}
+	removeVolumes, _ := params["volumes"].(bool)
+	stopTimeout := strconv.Itoa(intParam(params, "timeout", 10))
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := client.projectContainers(ctx, project.name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stop dependents before their dependencies: reverse start order when
+	// the file is available, then anything else the project left behind.
+	var services []string
+	if project.file != nil {
+		if order, err := project.file.startOrder(); err == nil {
+			for i := len(order) - 1; i >= 0; i-- {
+				if _, ok := existing[order[i]]; ok {
+					services = append(services, order[i])
+				}
+			}
+		}
+	}
+	listed := make(map[string]bool, len(services))
+	for _, s := range services {
+		listed[s] = true
+	}
+	var rest []string
+	for service := range existing {
+		if !listed[service] {
+			rest = append(rest, service)
+		}
+	}
+	sort.Strings(rest)
+	services = append(services, rest...)
+
+	removedContainers := []string{}
+	for _, service := range services {
+		c := existing[service]
+		if c.State == "running" {
+			if err := client.doJSON(ctx, http.MethodPost, "/containers/"+c.ID+"/stop", url.Values{"t": {stopTimeout}}, nil, nil); err != nil && !isNotFound(err) {
+				return nil, fmt.Errorf("failed to stop %s: %w", service, err)
+			}
+		}
+		query := url.Values{"force": {"1"}}
+		if removeVolumes {
+			query.Set("v", "1")
+		}
+		if err := client.doJSON(ctx, http.MethodDelete, "/containers/"+c.ID, query, nil, nil); err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", service, err)
+		}
+		removedContainers = append(removedContainers, c.toMap()["name"].(string))
+	}
+
+	labelFilter, _ := json.Marshal(map[string][]string{"label": {labelProject + "=" + project.name}})
+	query := url.Values{"filters": {string(labelFilter)}}
+
+	var networks []struct {
+		ID   string `json:"Id"`
+		Name string `json:"Name"`
+	}
+	if err := client.doJSON(ctx, http.MethodGet, "/networks", query, nil, &networks); err != nil {
+		return nil, fmt.Errorf("failed to list project networks: %w", err)
+	}
+	removedNetworks := []string{}
+	for _, n := range networks {
+		if err := client.doJSON(ctx, http.MethodDelete, "/networks/"+n.ID, nil, nil, nil); err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("failed to remove network %s: %w", n.Name, err)
+		}
+		removedNetworks = append(removedNetworks, n.Name)
+	}
+
+	removedVolumes := []string{}
+	if removeVolumes {
+		var volumes struct {
+			Volumes []struct {
+				Name string `json:"Name"`
+			} `json:"Volumes"`
+		}
+		if err := client.doJSON(ctx, http.MethodGet, "/volumes", query, nil, &volumes); err != nil {
+			return nil, fmt.Errorf("failed to list project volumes: %w", err)
+		}
+		for _, v := range volumes.Volumes {
+			if err := client.doJSON(ctx, http.MethodDelete, "/volumes/"+url.PathEscape(v.Name), nil, nil, nil); err != nil && !isNotFound(err) {
+				return nil, fmt.Errorf("failed to remove volume %s: %w", v.Name, err)
+			}
+			removedVolumes = append(removedVolumes, v.Name)
+		}
+	}
+
+	return map[string]interface{}{
+		"project":    project.name,
+		"containers": removedContainers,
+		"networks":   removedNetworks,
+		"volumes":    removedVolumes,
+		"success":    true,
+		"message":    fmt.Sprintf("Project %s is down: removed %d containers, %d networks, %d volumes", project.name, len(removedContainers), len(removedNetworks), len(removedVolumes)),
+	}, nil
+}
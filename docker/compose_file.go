@@ -0,0 +1,489 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeFile is the subset of the Compose specification the plugin
+// understands.
+type composeFile struct {
+	Name     string                     `yaml:"name"`
+	Services map[string]*composeService `yaml:"services"`
+	Networks map[string]*composeNetwork `yaml:"networks"`
+	Volumes  map[string]*composeVolume  `yaml:"volumes"`
+}
+
+type composeService struct {
+	Image         string              `yaml:"image"`
+	Build         *composeBuild       `yaml:"build"`
+	ContainerName string              `yaml:"container_name"`
+	Command       stringOrList        `yaml:"command"`
+	Entrypoint    stringOrList        `yaml:"entrypoint"`
+	Environment   mappingOrList       `yaml:"environment"`
+	Labels        mappingOrList       `yaml:"labels"`
+	Ports         []composePort       `yaml:"ports"`
+	Volumes       []composeMount      `yaml:"volumes"`
+	Networks      serviceNetworks     `yaml:"networks"`
+	DependsOn     dependsOn           `yaml:"depends_on"`
+	Healthcheck   *composeHealthcheck `yaml:"healthcheck"`
+	Restart       string              `yaml:"restart"`
+	WorkingDir    string              `yaml:"working_dir"`
+	User          string              `yaml:"user"`
+	Hostname      string              `yaml:"hostname"`
+	ExtraHosts    []string            `yaml:"extra_hosts"`
+}
+
+type composeBuild struct {
+	Context    string        `yaml:"context"`
+	Dockerfile string        `yaml:"dockerfile"`
+	Args       mappingOrList `yaml:"args"`
+	Target     string        `yaml:"target"`
+	Labels     mappingOrList `yaml:"labels"`
+	CacheFrom  []string      `yaml:"cache_from"`
+	Platforms  []string      `yaml:"platforms"`
+}
+
+// UnmarshalYAML accepts both "build: ./dir" and the long form.
+func (b *composeBuild) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		b.Context = node.Value
+		return nil
+	}
+	type plain composeBuild
+	return node.Decode((*plain)(b))
+}
+
+type composeNetwork struct {
+	Name     string        `yaml:"name"`
+	Driver   string        `yaml:"driver"`
+	External bool          `yaml:"external"`
+	Internal bool          `yaml:"internal"`
+	Labels   mappingOrList `yaml:"labels"`
+}
+
+type composeVolume struct {
+	Name     string        `yaml:"name"`
+	Driver   string        `yaml:"driver"`
+	External bool          `yaml:"external"`
+	Labels   mappingOrList `yaml:"labels"`
+}
+
+type composeHealthcheck struct {
+	Test        stringOrList `yaml:"test"`
+	Interval    string       `yaml:"interval"`
+	Timeout     string       `yaml:"timeout"`
+	StartPeriod string       `yaml:"start_period"`
+	Retries     int          `yaml:"retries"`
+	Disable     bool         `yaml:"disable"`
+}
+
+// stringOrList is a YAML value given as a single string or a sequence.
+type stringOrList struct {
+	values []string
+	shell  bool
+}
+
+func (s *stringOrList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		s.values, s.shell = []string{node.Value}, true
+		return nil
+	}
+	return node.Decode(&s.values)
+}
+
+// words returns the value as an argv; a plain string is split on whitespace
+// like the Compose CLI does for command and entrypoint.
+func (s stringOrList) words() []string {
+	if s.shell && len(s.values) == 1 {
+		return strings.Fields(s.values[0])
+	}
+	return s.values
+}
+
+// mappingOrList is a YAML value given as a mapping or as a list of
+// KEY=value strings.
+type mappingOrList map[string]string
+
+func (m *mappingOrList) UnmarshalYAML(node *yaml.Node) error {
+	out := mappingOrList{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			out[node.Content[i].Value] = node.Content[i+1].Value
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			key, value, _ := strings.Cut(item.Value, "=")
+			out[key] = value
+		}
+	default:
+		return fmt.Errorf("line %d: expected a mapping or a list", node.Line)
+	}
+	*m = out
+	return nil
+}
+
+// composePort is a port entry in short ("8080:80/tcp") or long syntax,
+// normalised to the short form.
+type composePort string
+
+func (p *composePort) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*p = composePort(node.Value)
+		return nil
+	}
+	var long struct {
+		Target    int    `yaml:"target"`
+		Published string `yaml:"published"`
+		HostIP    string `yaml:"host_ip"`
+		Protocol  string `yaml:"protocol"`
+	}
+	if err := node.Decode(&long); err != nil {
+		return err
+	}
+	spec := strconv.Itoa(long.Target)
+	if long.Published != "" {
+		spec = long.Published + ":" + spec
+		if long.HostIP != "" {
+			spec = long.HostIP + ":" + spec
+		}
+	}
+	if long.Protocol != "" {
+		spec += "/" + long.Protocol
+	}
+	*p = composePort(spec)
+	return nil
+}
+
+// composeMount is a volume entry in short ("src:dst:ro") or long syntax.
+type composeMount struct {
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only"`
+}
+
+func (m *composeMount) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		type plain composeMount
+		return node.Decode((*plain)(m))
+	}
+	parts := strings.Split(node.Value, ":")
+	switch len(parts) {
+	case 1:
+		m.Target = parts[0]
+		m.Type = "volume"
+	default:
+		m.Source, m.Target = parts[0], parts[1]
+		if len(parts) > 2 {
+			for _, opt := range strings.Split(parts[2], ",") {
+				m.ReadOnly = m.ReadOnly || opt == "ro"
+			}
+		}
+		m.Type = "volume"
+		if strings.HasPrefix(m.Source, ".") || strings.HasPrefix(m.Source, "/") || strings.HasPrefix(m.Source, "~") {
+			m.Type = "bind"
+		}
+	}
+	return nil
+}
+
+// serviceNetworks is the networks a service joins, with their aliases.
+type serviceNetworks map[string][]string
+
+func (n *serviceNetworks) UnmarshalYAML(node *yaml.Node) error {
+	out := serviceNetworks{}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			out[item.Value] = nil
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var opts struct {
+				Aliases []string `yaml:"aliases"`
+			}
+			if err := node.Content[i+1].Decode(&opts); err != nil {
+				return err
+			}
+			out[node.Content[i].Value] = opts.Aliases
+		}
+	default:
+		return fmt.Errorf("line %d: expected a list or mapping of networks", node.Line)
+	}
+	*n = out
+	return nil
+}
+
+// dependsOn maps a dependency to the condition it must reach before the
+// dependent service is started.
+type dependsOn map[string]string
+
+func (d *dependsOn) UnmarshalYAML(node *yaml.Node) error {
+	out := dependsOn{}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			out[item.Value] = "service_started"
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var opts struct {
+				Condition string `yaml:"condition"`
+			}
+			if err := node.Content[i+1].Decode(&opts); err != nil {
+				return err
+			}
+			if opts.Condition == "" {
+				opts.Condition = "service_started"
+			}
+			out[node.Content[i].Value] = opts.Condition
+		}
+	default:
+		return fmt.Errorf("line %d: expected a list or mapping of services", node.Line)
+	}
+	*d = out
+	return nil
+}
+
+// interpolation matches $VAR, ${VAR}, ${VAR:-default}, ${VAR-default},
+// ${VAR:?err} and ${VAR?err}, plus the $$ escape.
+var interpolation = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?])([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// interpolate substitutes variables the way Compose does, looking them up
+// in vars.
+func interpolate(text string, vars map[string]string) (string, error) {
+	var firstErr error
+	out := interpolation.ReplaceAllStringFunc(text, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		m := interpolation.FindStringSubmatch(match)
+		name, op, arg := m[1], m[2], m[3]
+		if name == "" {
+			name = m[4]
+		}
+		value, set := vars[name]
+		switch op {
+		case ":-":
+			if value == "" {
+				return arg
+			}
+		case "-":
+			if !set {
+				return arg
+			}
+		case ":?", "?":
+			if !set || (op == ":?" && value == "") {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("required variable %s is missing: %s", name, arg)
+				}
+			}
+		}
+		return value
+	})
+	return out, firstErr
+}
+
+// readEnvFile parses a .env file of KEY=value lines. A missing file is not
+// an error.
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	return vars, nil
+}
+
+// loadComposeFile reads, interpolates and parses path. Variables come from
+// the process environment, then the .env file next to the compose file,
+// then overrides.
+func loadComposeFile(path string, overrides map[string]string) (*composeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			vars[k] = v
+		}
+	}
+	dotenv, err := readEnvFile(filepath.Join(filepath.Dir(path), ".env"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .env: %w", err)
+	}
+	for k, v := range dotenv {
+		vars[k] = v
+	}
+	for k, v := range overrides {
+		vars[k] = v
+	}
+
+	text, err := interpolate(string(data), vars)
+	if err != nil {
+		return nil, err
+	}
+
+	var file composeFile
+	if err := yaml.Unmarshal([]byte(text), &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(file.Services) == 0 {
+		return nil, fmt.Errorf("%s defines no services", path)
+	}
+	for name, svc := range file.Services {
+		if svc == nil {
+			return nil, fmt.Errorf("service %s is empty", name)
+		}
+		if svc.Image == "" && svc.Build == nil {
+			return nil, fmt.Errorf("service %s has neither image nor build", name)
+		}
+		for dep := range svc.DependsOn {
+			if _, ok := file.Services[dep]; !ok {
+				return nil, fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+		}
+		for network := range svc.Networks {
+			if _, ok := file.Networks[network]; !ok && network != "default" {
+				return nil, fmt.Errorf("service %s uses undefined network %s", name, network)
+			}
+		}
+	}
+	return &file, nil
+}
+
+// startOrder returns the services sorted so that every service comes after
+// its dependencies. Ties are broken by name to keep runs reproducible.
+func (f *composeFile) startOrder() ([]string, error) {
+	remaining := make(map[string]int, len(f.Services))
+	dependents := make(map[string][]string)
+	for name, svc := range f.Services {
+		remaining[name] = len(svc.DependsOn)
+		for dep := range svc.DependsOn {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready, order []string
+	for name, n := range remaining {
+		if n == 0 {
+			ready = append(ready, name)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dependent := range dependents[name] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(order) != len(f.Services) {
+		var cycle []string
+		for name, n := range remaining {
+			if n > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("dependency cycle between services: %s", strings.Join(cycle, ", "))
+	}
+	return order, nil
+}
+
+// projectName derives a Compose project name: lowercase letters, digits,
+// dashes and underscores only.
+func projectName(name string) string {
+	name = strings.ToLower(name)
+	var sb strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimLeft(sb.String(), "-_")
+}
+
+// healthcheckConfig converts a Compose healthcheck into the API's
+// HealthConfig, with durations in nanoseconds.
+func healthcheckConfig(h *composeHealthcheck) (map[string]interface{}, error) {
+	if h.Disable {
+		return map[string]interface{}{"Test": []string{"NONE"}}, nil
+	}
+	test := h.Test.values
+	if h.Test.shell && len(test) == 1 {
+		test = []string{"CMD-SHELL", test[0]}
+	}
+	config := map[string]interface{}{"Test": test}
+	for key, value := range map[string]string{"Interval": h.Interval, "Timeout": h.Timeout, "StartPeriod": h.StartPeriod} {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid healthcheck %s %q: %w", strings.ToLower(key), value, err)
+		}
+		config[key] = d.Nanoseconds()
+	}
+	if h.Retries > 0 {
+		config["Retries"] = h.Retries
+	}
+	return config, nil
+}
+
+// restartPolicy converts "no", "always", "unless-stopped" or
+// "on-failure[:N]" into the API's RestartPolicy.
+func restartPolicy(value string) (map[string]interface{}, error) {
+	name, count, _ := strings.Cut(value, ":")
+	policy := map[string]interface{}{"Name": name}
+	switch name {
+	case "", "no":
+		policy["Name"] = "no"
+	case "always", "unless-stopped":
+	case "on-failure":
+		if count != "" {
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				return nil, fmt.Errorf("invalid restart policy %q", value)
+			}
+			policy["MaximumRetryCount"] = n
+		}
+	default:
+		return nil, fmt.Errorf("invalid restart policy %q", value)
+	}
+	return policy, nil
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeContainer is a container held by fakeEngine.
+type fakeContainer struct {
+	name   string
+	image  string
+	labels map[string]string
+	state  string
+}
+
+// fakeEngine is a stateful httptest stand-in for the parts of the Engine API
+// compose_up and compose_down use: networks, volumes, images, builds and the
+// container lifecycle.
+type fakeEngine struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	requests   []string
+	networks   map[string]bool
+	volumes    map[string]bool
+	images     map[string]string
+	containers map[string]*fakeContainer
+	nextID     int
+
+	// buildID is the image ID the next build produces.
+	buildID string
+	// health is the healthcheck status reported for containers of a service.
+	health map[string]string
+}
+
+func newFakeEngine(t *testing.T) *fakeEngine {
+	t.Helper()
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+
+	e := &fakeEngine{
+		networks:   map[string]bool{},
+		volumes:    map[string]bool{},
+		images:     map[string]string{},
+		containers: map[string]*fakeContainer{},
+		buildID:    "sha256:built-1",
+		health:     map[string]string{},
+	}
+	e.Server = httptest.NewServer(http.HandlerFunc(e.serve))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *fakeEngine) seen() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.requests...)
+}
+
+// count returns how many requests matched method and path.
+func (e *fakeEngine) count(method, path string) int {
+	n := 0
+	for _, r := range e.seen() {
+		if r == method+" "+path {
+			n++
+		}
+	}
+	return n
+}
+
+func (e *fakeEngine) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests = append(e.requests, r.Method+" "+path)
+
+	writeJSON := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(status int, msg string) {
+		w.WriteHeader(status)
+		writeJSON(map[string]string{"message": msg})
+	}
+	decode := func(v interface{}) bool {
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return false
+		}
+		return true
+	}
+
+	switch {
+	case r.Method == http.MethodPost && path == "/networks/create":
+		var body struct{ Name string }
+		if decode(&body) {
+			e.networks[body.Name] = true
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(map[string]string{"Id": body.Name})
+		}
+
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/networks/") && strings.HasSuffix(path, "/connect"):
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/networks/"):
+		name := strings.TrimPrefix(path, "/networks/")
+		if !e.networks[name] {
+			fail(http.StatusNotFound, "network "+name+" not found")
+			return
+		}
+		writeJSON(map[string]string{"Name": name})
+
+	case r.Method == http.MethodPost && path == "/volumes/create":
+		var body struct{ Name string }
+		if decode(&body) {
+			e.volumes[body.Name] = true
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(map[string]string{"Name": body.Name})
+		}
+
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/volumes/"):
+		name := strings.TrimPrefix(path, "/volumes/")
+		if !e.volumes[name] {
+			fail(http.StatusNotFound, "get "+name+": no such volume")
+			return
+		}
+		writeJSON(map[string]string{"Name": name})
+
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")
+		id, ok := e.images[name]
+		if !ok {
+			fail(http.StatusNotFound, "No such image: "+name)
+			return
+		}
+		writeJSON(map[string]string{"Id": id})
+
+	case r.Method == http.MethodPost && path == "/images/create":
+		q := r.URL.Query()
+		e.images[q.Get("fromImage")+":"+q.Get("tag")] = "sha256:pulled"
+		writeJSON(map[string]string{"status": "Status: Downloaded newer image"})
+
+	case r.Method == http.MethodPost && path == "/build":
+		io.Copy(io.Discard, r.Body)
+		for _, tag := range r.URL.Query()["t"] {
+			e.images[tag] = e.buildID
+		}
+		writeJSON(map[string]interface{}{"aux": map[string]string{"ID": e.buildID}})
+
+	case r.Method == http.MethodGet && path == "/containers/json":
+		var list []map[string]interface{}
+		for id, c := range e.containers {
+			list = append(list, map[string]interface{}{
+				"Id":     id,
+				"Names":  []string{"/" + c.name},
+				"Image":  c.image,
+				"State":  c.state,
+				"Labels": c.labels,
+			})
+		}
+		writeJSON(list)
+
+	case r.Method == http.MethodPost && path == "/containers/create":
+		var body struct {
+			Image  string
+			Labels map[string]string
+		}
+		if !decode(&body) {
+			return
+		}
+		e.nextID++
+		id := fmt.Sprintf("container-%d", e.nextID)
+		e.containers[id] = &fakeContainer{
+			name:   r.URL.Query().Get("name"),
+			image:  body.Image,
+			labels: body.Labels,
+			state:  "created",
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(map[string]interface{}{"Id": id, "Warnings": []string{}})
+
+	case strings.HasPrefix(path, "/containers/"):
+		id, op, _ := strings.Cut(strings.TrimPrefix(path, "/containers/"), "/")
+		c, ok := e.containers[id]
+		if !ok {
+			fail(http.StatusNotFound, "No such container: "+id)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && op == "start":
+			c.state = "running"
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete && op == "":
+			delete(e.containers, id)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && op == "json":
+			state := map[string]interface{}{"Status": c.state, "Running": c.state == "running"}
+			if status := e.health[c.labels[labelService]]; status != "" {
+				state["Health"] = map[string]interface{}{"Status": status}
+			}
+			writeJSON(map[string]interface{}{
+				"Id":     id,
+				"Name":   "/" + c.name,
+				"Config": map[string]interface{}{"Image": c.image, "Labels": c.labels},
+				"State":  state,
+			})
+		default:
+			fail(http.StatusNotFound, "page not found: "+r.Method+" "+path)
+		}
+
+	default:
+		fail(http.StatusNotFound, "page not found: "+r.Method+" "+path)
+	}
+}
+
+// writeProject writes a compose file and any extra files into a new
+// directory and returns the compose file path.
+func writeProject(t *testing.T, compose string, extra map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{"compose.yaml": compose}
+	for name, content := range extra {
+		files[name] = content
+	}
+	writeFiles(t, dir, files)
+	return filepath.Join(dir, "compose.yaml")
+}
+
+// composeUp runs compose_up and returns the action taken for each service.
+func composeUp(t *testing.T, e *fakeEngine, file string, extra map[string]interface{}) map[string]string {
+	t.Helper()
+	params := map[string]interface{}{"host": e.URL, "file": file, "project_name": "shop"}
+	for k, v := range extra {
+		params[k] = v
+	}
+	out, err := (&DockerPlugin{}).Execute(context.Background(), "compose_up", params)
+	if err != nil {
+		t.Fatalf("compose_up: %v", err)
+	}
+	actions := map[string]string{}
+	for name, svc := range out["services"].(map[string]interface{}) {
+		actions[name] = svc.(map[string]interface{})["action"].(string)
+	}
+	return actions
+}
+
+func TestComposeUpRecreatesRebuiltServices(t *testing.T) {
+	e := newFakeEngine(t)
+	file := writeProject(t, "services:\n  api:\n    build: .\n  cache:\n    image: redis:7\n", map[string]string{
+		"Dockerfile": "FROM alpine:3.19\n",
+	})
+
+	if got := composeUp(t, e, file, nil); got["api"] != "created" || got["cache"] != "created" {
+		t.Fatalf("first up = %v, want both created", got)
+	}
+	if e.count(http.MethodPost, "/build") != 1 || e.count(http.MethodPost, "/images/create") != 1 {
+		t.Errorf("requests = %v, want one build and one pull", e.seen())
+	}
+
+	// A rebuild that produces the same image leaves the container alone.
+	if got := composeUp(t, e, file, map[string]interface{}{"build": true}); got["api"] != "unchanged" || got["cache"] != "unchanged" {
+		t.Errorf("rebuild to the same ID = %v, want both unchanged", got)
+	}
+
+	e.mu.Lock()
+	e.buildID = "sha256:built-2"
+	e.mu.Unlock()
+	if got := composeUp(t, e, file, map[string]interface{}{"build": true}); got["api"] != "recreated" || got["cache"] != "unchanged" {
+		t.Errorf("rebuild to a new ID = %v, want api recreated and cache unchanged", got)
+	}
+	if n := e.count(http.MethodDelete, "/containers/container-1"); n != 1 {
+		t.Errorf("old api container deleted %d times, want 1", n)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.containers) != 2 {
+		t.Errorf("containers = %d, want one per service", len(e.containers))
+	}
+	for _, c := range e.containers {
+		if c.name == "shop-api-1" && c.image != "shop-api" {
+			t.Errorf("api container runs %q", c.image)
+		}
+	}
+}
+
+func TestComposeUpRecreatesWhenPulledImageChanges(t *testing.T) {
+	e := newFakeEngine(t)
+	file := writeProject(t, "services:\n  cache:\n    image: redis:7\n", nil)
+	composeUp(t, e, file, nil)
+
+	// The tag now points at another image, as after a pull elsewhere.
+	e.mu.Lock()
+	e.images["redis:7"] = "sha256:pulled-again"
+	e.mu.Unlock()
+	if got := composeUp(t, e, file, nil); got["cache"] != "recreated" {
+		t.Errorf("action = %v, want recreated", got["cache"])
+	}
+}
+
+func TestComposeUpBuildsMissingImagesOnly(t *testing.T) {
+	e := newFakeEngine(t)
+	file := writeProject(t, "services:\n  api:\n    build: .\n", map[string]string{"Dockerfile": "FROM alpine:3.19\n"})
+	composeUp(t, e, file, nil)
+	composeUp(t, e, file, nil)
+	if n := e.count(http.MethodPost, "/build"); n != 1 {
+		t.Errorf("built %d times, want only when the image is missing", n)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"TAG": "1.25", "EMPTY": "", "PORT": "8080"}
+	tests := []struct {
+		text    string
+		want    string
+		wantErr string
+	}{
+		{text: "nginx:$TAG", want: "nginx:1.25"},
+		{text: "nginx:${TAG}-alpine", want: "nginx:1.25-alpine"},
+		{text: "${PORT}:80", want: "8080:80"},
+		{text: "x${UNSET}y", want: "xy"},
+		{text: "${UNSET:-latest}", want: "latest"},
+		{text: "${EMPTY:-latest}", want: "latest"},
+		{text: "${TAG:-latest}", want: "1.25"},
+		{text: "${UNSET-latest}", want: "latest"},
+		{text: "${EMPTY-latest}", want: ""},
+		{text: "${UNSET:-}", want: ""},
+		{text: "${TAG:?tag is required}", want: "1.25"},
+		{text: "${EMPTY?must be set}", want: ""},
+		{text: "echo $$HOME $${TAG}", want: "echo $HOME ${TAG}"},
+		{text: "$$$TAG", want: "$1.25"},
+		{text: "cost: 5$", want: "cost: 5$"},
+		{text: "${UNSET:?set UNSET}", wantErr: "required variable UNSET is missing: set UNSET"},
+		{text: "${EMPTY:?must not be empty}", wantErr: "required variable EMPTY is missing: must not be empty"},
+		{text: "${UNSET?must be set}", wantErr: "required variable UNSET is missing: must be set"},
+		{text: "${FIRST:?one} ${SECOND:?two}", wantErr: "required variable FIRST is missing: one"},
+	}
+	for _, tt := range tests {
+		got, err := interpolate(tt.text, vars)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("interpolate(%q) error = %v, want %q", tt.text, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("interpolate(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadComposeFileVariables(t *testing.T) {
+	t.Setenv("CORYNTH_TEST_TAG", "from-env")
+	t.Setenv("CORYNTH_TEST_PORT", "8080")
+	t.Setenv("CORYNTH_TEST_MODE", "from-env")
+	file := writeProject(t, `services:
+  web:
+    image: nginx:${CORYNTH_TEST_TAG}
+    ports: ["${CORYNTH_TEST_PORT}:80"]
+    environment:
+      MODE: ${CORYNTH_TEST_MODE}
+      DB: ${CORYNTH_TEST_DB:-sqlite}
+`, map[string]string{
+		".env": "# local settings\nCORYNTH_TEST_TAG=from-dotenv\nexport CORYNTH_TEST_MODE='from-dotenv'\n",
+	})
+
+	f, err := loadComposeFile(file, map[string]string{"CORYNTH_TEST_MODE": "from-override"})
+	if err != nil {
+		t.Fatalf("loadComposeFile: %v", err)
+	}
+	web := f.Services["web"]
+	if web.Image != "nginx:from-dotenv" {
+		t.Errorf("image = %q, want .env to override the environment", web.Image)
+	}
+	if len(web.Ports) != 1 || web.Ports[0] != "8080:80" {
+		t.Errorf("ports = %v, want the environment value", web.Ports)
+	}
+	want := mappingOrList{"MODE": "from-override", "DB": "sqlite"}
+	if !reflect.DeepEqual(web.Environment, want) {
+		t.Errorf("environment = %v, want %v", web.Environment, want)
+	}
+
+	file = writeProject(t, "services:\n  web:\n    image: nginx:${CORYNTH_TEST_MISSING:?set a tag}\n", nil)
+	if _, err := loadComposeFile(file, nil); err == nil || !strings.Contains(err.Error(), "required variable CORYNTH_TEST_MISSING is missing: set a tag") {
+		t.Errorf("error = %v, want the missing required variable", err)
+	}
+}
+
+func TestStartOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    map[string][]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "independent services by name",
+			deps: map[string][]string{"web": nil, "cache": nil, "api": nil},
+			want: []string{"api", "cache", "web"},
+		},
+		{
+			name: "dependencies first",
+			deps: map[string][]string{"web": {"api"}, "api": {"db", "cache"}, "db": nil, "cache": nil},
+			want: []string{"cache", "db", "api", "web"},
+		},
+		{
+			name: "ties broken by name once ready",
+			deps: map[string][]string{"worker": {"db"}, "api": {"db"}, "db": nil, "admin": nil},
+			want: []string{"admin", "db", "api", "worker"},
+		},
+		{
+			name:    "two service cycle",
+			deps:    map[string][]string{"a": {"b"}, "b": {"a"}, "c": nil},
+			wantErr: "dependency cycle between services: a, b",
+		},
+		{
+			name:    "three service cycle",
+			deps:    map[string][]string{"db": nil, "x": {"db", "z"}, "y": {"x"}, "z": {"y"}},
+			wantErr: "dependency cycle between services: x, y, z",
+		},
+		{
+			name:    "self dependency",
+			deps:    map[string][]string{"a": {"a"}},
+			wantErr: "dependency cycle between services: a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &composeFile{Services: map[string]*composeService{}}
+			for name, deps := range tt.deps {
+				svc := &composeService{DependsOn: dependsOn{}}
+				for _, dep := range deps {
+					svc.DependsOn[dep] = "service_started"
+				}
+				f.Services[name] = svc
+			}
+			got, err := f.startOrder()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposeUpCycleCreatesNothing(t *testing.T) {
+	e := newFakeEngine(t)
+	file := writeProject(t, `services:
+  a:
+    image: alpine:3.19
+    depends_on: [b]
+  b:
+    image: alpine:3.19
+    depends_on: [a]
+`, nil)
+	_, err := (&DockerPlugin{}).Execute(context.Background(), "compose_up", map[string]interface{}{"host": e.URL, "file": file})
+	if err == nil || err.Error() != "dependency cycle between services: a, b" {
+		t.Errorf("error = %v, want the dependency cycle", err)
+	}
+	if got := e.seen(); len(got) != 0 {
+		t.Errorf("requests = %v, want none", got)
+	}
+}
+
+func TestComposeUpHealthGating(t *testing.T) {
+	const project = `services:
+  db:
+    image: postgres:16
+    healthcheck:
+      test: ["CMD", "pg_isready"]
+  app:
+    image: example/app:1.0
+    depends_on:
+      db:
+        condition: service_healthy
+    healthcheck:
+      test: ["CMD", "true"]
+`
+	tests := []struct {
+		name        string
+		health      map[string]string
+		wait        bool
+		wantErr     string
+		wantCreated []string
+	}{
+		{
+			name:        "healthy dependency",
+			health:      map[string]string{"db": "healthy", "app": "healthy"},
+			wait:        true,
+			wantCreated: []string{"shop-db-1", "shop-app-1"},
+		},
+		{
+			name:        "unhealthy dependency",
+			health:      map[string]string{"db": "unhealthy"},
+			wait:        true,
+			wantErr:     "cannot start app: service db is unhealthy",
+			wantCreated: []string{"shop-db-1"},
+		},
+		{
+			name:        "dependency without a healthcheck status",
+			health:      map[string]string{},
+			wait:        true,
+			wantErr:     "cannot start app: service db has no healthcheck",
+			wantCreated: []string{"shop-db-1"},
+		},
+		{
+			name:        "unhealthy service after start",
+			health:      map[string]string{"db": "healthy", "app": "unhealthy"},
+			wait:        true,
+			wantErr:     "service app is unhealthy",
+			wantCreated: []string{"shop-db-1", "shop-app-1"},
+		},
+		{
+			name:        "wait disabled still gates dependents",
+			health:      map[string]string{"db": "healthy", "app": "unhealthy"},
+			wait:        false,
+			wantCreated: []string{"shop-db-1", "shop-app-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newFakeEngine(t)
+			e.health = tt.health
+			file := writeProject(t, project, nil)
+			_, err := (&DockerPlugin{}).Execute(context.Background(), "compose_up", map[string]interface{}{
+				"host":         e.URL,
+				"file":         file,
+				"project_name": "shop",
+				"wait":         tt.wait,
+			})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("compose_up: %v", err)
+			}
+
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			var created []string
+			for i := 1; i <= e.nextID; i++ {
+				if c, ok := e.containers[fmt.Sprintf("container-%d", i)]; ok {
+					created = append(created, c.name)
+				}
+			}
+			if !reflect.DeepEqual(created, tt.wantCreated) {
+				t.Errorf("containers = %v, want %v", created, tt.wantCreated)
+			}
+		})
+	}
+}
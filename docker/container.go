@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// portBinding mirrors the Engine API PortBinding object.
+type portBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// parsePorts converts docker CLI style port specs ("80", "8080:80",
+// "127.0.0.1:8080:80", "53:53/udp") into exposed ports and bindings.
+func parsePorts(specs []string) (map[string]struct{}, map[string][]portBinding, error) {
+	exposed := make(map[string]struct{})
+	bindings := make(map[string][]portBinding)
+
+	for _, spec := range specs {
+		proto := "tcp"
+		if i := strings.LastIndex(spec, "/"); i >= 0 {
+			proto = strings.ToLower(spec[i+1:])
+			spec = spec[:i]
+		}
+		if proto != "tcp" && proto != "udp" && proto != "sctp" {
+			return nil, nil, fmt.Errorf("invalid protocol in port mapping %q", spec)
+		}
+
+		parts := strings.Split(spec, ":")
+		containerPort := parts[len(parts)-1]
+		binding := portBinding{}
+		switch len(parts) {
+		case 1:
+		case 2:
+			binding.HostPort = parts[0]
+		default:
+			binding.HostIP = strings.Trim(strings.Join(parts[:len(parts)-2], ":"), "[]")
+			binding.HostPort = parts[len(parts)-2]
+		}
+		if _, err := strconv.Atoi(containerPort); err != nil {
+			return nil, nil, fmt.Errorf("invalid container port in mapping %q", spec)
+		}
+		if binding.HostPort != "" {
+			if _, err := strconv.Atoi(binding.HostPort); err != nil {
+				return nil, nil, fmt.Errorf("invalid host port in mapping %q", spec)
+			}
+		}
+
+		key := containerPort + "/" + proto
+		exposed[key] = struct{}{}
+		if len(parts) > 1 {
+			bindings[key] = append(bindings[key], binding)
+		}
+	}
+	return exposed, bindings, nil
+}
+
+// parseVolumes splits volume specs into bind mounts ("src:dst[:mode]") and
+// anonymous volumes ("/dst").
+func parseVolumes(specs []string) ([]string, map[string]struct{}) {
+	var binds []string
+	anonymous := make(map[string]struct{})
+	for _, spec := range specs {
+		if strings.Contains(spec, ":") {
+			binds = append(binds, spec)
+		} else {
+			anonymous[spec] = struct{}{}
+		}
+	}
+	return binds, anonymous
+}
+
+// envList turns an environment object into sorted KEY=value entries.
+func envList(env map[string]string) []string {
+	out := make([]string, 0, len(env))
+	for k, v := range env {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// commandList accepts a command as an array or as a single string, which is
+// split on whitespace.
+func commandList(params map[string]interface{}, name string) []string {
+	if s, ok := params[name].(string); ok {
+		return strings.Fields(s)
+	}
+	return stringList(params, name)
+}
+
+// createContainer creates a container from config, pulling the image first
+// if the daemon does not have it.
+func (c *dockerClient) createContainer(ctx context.Context, name string, config map[string]interface{}) (string, []string, error) {
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+
+	var created struct {
+		ID       string   `json:"Id"`
+		Warnings []string `json:"Warnings"`
+	}
+	err := c.doJSON(ctx, http.MethodPost, "/containers/create", query, config, &created)
+	if isNotFound(err) {
+		image, _ := config["Image"].(string)
+		auth, authErr := resolveAuth(ctx, map[string]interface{}{}, registryForImage(image))
+		if authErr != nil {
+			return "", nil, authErr
+		}
+		if _, pullErr := c.pullImage(ctx, image, auth, ""); pullErr != nil {
+			return "", nil, pullErr
+		}
+		err = c.doJSON(ctx, http.MethodPost, "/containers/create", query, config, &created)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create container: %w", err)
+	}
+	return created.ID, created.Warnings, nil
+}
+
+// waitContainer blocks until the container stops and returns its exit code.
+func (c *dockerClient) waitContainer(ctx context.Context, id, condition string) (int64, error) {
+	query := url.Values{}
+	if condition != "" {
+		query.Set("condition", condition)
+	}
+	var waited struct {
+		StatusCode int64 `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/containers/"+id+"/wait", query, nil, &waited); err != nil {
+		return 0, err
+	}
+	if waited.Error != nil && waited.Error.Message != "" {
+		return waited.StatusCode, fmt.Errorf("%s", waited.Error.Message)
+	}
+	return waited.StatusCode, nil
+}
+
+// containerLogs returns the stdout and stderr of a container. Containers
+// created with a TTY have a single, unframed stream.
+func (c *dockerClient) containerLogs(ctx context.Context, id string, query url.Values, tty bool) (string, string, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+
+	resp, err := c.request(ctx, http.MethodGet, "/containers/"+id+"/logs", query, nil, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	var stdout, stderr bytes.Buffer
+	if tty {
+		_, err = stdout.ReadFrom(resp.Body)
+	} else {
+		err = demuxStream(resp.Body, &stdout, &stderr)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read logs: %w", err)
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+// containerSummary is an entry of GET /containers/json.
+type containerSummary struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	ImageID string            `json:"ImageID"`
+	Command string            `json:"Command"`
+	Created int64             `json:"Created"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Labels  map[string]string `json:"Labels"`
+	Ports   []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+}
+
+func (s containerSummary) toMap() map[string]interface{} {
+	name := ""
+	if len(s.Names) > 0 {
+		name = strings.TrimPrefix(s.Names[0], "/")
+	}
+	ports := make([]string, 0, len(s.Ports))
+	for _, p := range s.Ports {
+		if p.PublicPort != 0 {
+			ports = append(ports, fmt.Sprintf("%s:%d->%d/%s", p.IP, p.PublicPort, p.PrivatePort, p.Type))
+		} else {
+			ports = append(ports, fmt.Sprintf("%d/%s", p.PrivatePort, p.Type))
+		}
+	}
+	return map[string]interface{}{
+		"id":       s.ID,
+		"short_id": shortID(s.ID),
+		"name":     name,
+		"image":    s.Image,
+		"image_id": s.ImageID,
+		"command":  s.Command,
+		"created":  s.Created,
+		"state":    s.State,
+		"status":   s.Status,
+		"ports":    ports,
+		"labels":   s.Labels,
+	}
+}
+
+// shortID returns the 12 character form of a container or image ID.
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -4,4 +4,7 @@ go 1.21
 
 replace github.com/corynth/corynth-dist => ../../../corynth-dist
 
-require github.com/corynth/corynth-dist v0.0.0-00010101000000-000000000000
\ No newline at end of file
+require (
+	github.com/corynth/corynth-dist v0.0.0-00010101000000-000000000000
+	gopkg.in/yaml.v3 v3.0.1
+)
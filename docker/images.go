@@ -0,0 +1,618 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// imageActions declares the registry and image management actions.
+func imageActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "pull",
+			Description: "Pull an image from a registry",
+			Inputs: withRegistryInputs(map[string]plugin.InputSpec{
+				"image": {
+					Type:        "string",
+					Description: "Image reference (e.g., nginx:1.25 or registry.example.com/team/app@sha256:...)",
+					Required:    true,
+				},
+				"platform": {
+					Type:        "string",
+					Description: "Platform to pull (e.g., linux/arm64)",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"digest": {
+					Type:        "string",
+					Description: "Digest of the pulled image",
+				},
+				"status": {
+					Type:        "string",
+					Description: "Final status reported by the daemon",
+				},
+				"layers": {
+					Type:        "array",
+					Description: "Per-layer final status",
+				},
+				"layer_summary": {
+					Type:        "object",
+					Description: "Counts of total, downloaded and already existing layers",
+				},
+			},
+		},
+		{
+			Name:        "push",
+			Description: "Push an image to a registry",
+			Inputs: withRegistryInputs(map[string]plugin.InputSpec{
+				"image": {
+					Type:        "string",
+					Description: "Image reference to push (e.g., registry.example.com/team/app:1.2.0)",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"digest": {
+					Type:        "string",
+					Description: "Manifest digest reported by the registry",
+				},
+				"size": {
+					Type:        "number",
+					Description: "Manifest size in bytes",
+				},
+				"layers": {
+					Type:        "array",
+					Description: "Per-layer final status",
+				},
+				"layer_summary": {
+					Type:        "object",
+					Description: "Counts of total, pushed, mounted and already existing layers",
+				},
+			},
+		},
+		{
+			Name:        "tag",
+			Description: "Create a tag that refers to an existing image",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"source": {
+					Type:        "string",
+					Description: "Existing image name or ID",
+					Required:    true,
+				},
+				"target": {
+					Type:        "string",
+					Description: "New reference (repository[:tag])",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"target": {
+					Type:        "string",
+					Description: "The new image reference",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the operation succeeded",
+				},
+			},
+		},
+		{
+			Name:        "login",
+			Description: "Verify registry credentials and optionally store them in config.json",
+			Inputs: withRegistryInputs(map[string]plugin.InputSpec{
+				"registry": {
+					Type:        "string",
+					Description: "Registry address (default: Docker Hub)",
+					Required:    false,
+				},
+				"save": {
+					Type:        "boolean",
+					Description: "Store the credentials in config.json for later steps",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"registry": {
+					Type:        "string",
+					Description: "Registry logged in to",
+				},
+				"status": {
+					Type:        "string",
+					Description: "Status reported by the daemon",
+				},
+				"saved": {
+					Type:        "boolean",
+					Description: "Whether the credentials were written to config.json",
+				},
+			},
+		},
+		{
+			Name:        "images",
+			Description: "List local images",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"all": {
+					Type:        "boolean",
+					Description: "Include intermediate images",
+					Required:    false,
+					Default:     false,
+				},
+				"reference": {
+					Type:        "string",
+					Description: "Only images matching this reference pattern (e.g., myapp:*)",
+					Required:    false,
+				},
+				"filters": {
+					Type:        "object",
+					Description: "Additional filters such as {dangling = \"true\", label = [\"team=api\"]}",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"images": {
+					Type:        "array",
+					Description: "List of images",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of images listed",
+				},
+			},
+		},
+		{
+			Name:        "image_prune",
+			Description: "Remove unused images",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"all": {
+					Type:        "boolean",
+					Description: "Remove all unused images, not just dangling ones",
+					Required:    false,
+					Default:     false,
+				},
+				"until": {
+					Type:        "string",
+					Description: "Only remove images created before this time (e.g., 24h or an RFC3339 timestamp)",
+					Required:    false,
+				},
+				"filters": {
+					Type:        "object",
+					Description: "Additional filters such as {label = [\"stage=build\"]}",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"deleted": {
+					Type:        "array",
+					Description: "IDs of deleted images",
+				},
+				"untagged": {
+					Type:        "array",
+					Description: "References that were untagged",
+				},
+				"space_reclaimed": {
+					Type:        "number",
+					Description: "Bytes freed",
+				},
+			},
+		},
+	}
+}
+
+// withRegistryInputs adds the credential inputs shared by the registry
+// actions.
+func withRegistryInputs(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["username"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Registry username (default: credentials from config.json)",
+		Required:    false,
+	}
+	inputs["password"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Registry password or access token",
+		Required:    false,
+	}
+	inputs["config_file"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Docker config.json to read credentials from (default: $DOCKER_CONFIG/config.json or ~/.docker/config.json)",
+		Required:    false,
+	}
+	return withHostInput(inputs)
+}
+
+// layerProgress records the last status of every layer seen in a pull or
+// push progress stream.
+type layerProgress struct {
+	order  []string
+	status map[string]string
+}
+
+func newLayerProgress() *layerProgress {
+	return &layerProgress{status: make(map[string]string)}
+}
+
+func (l *layerProgress) observe(msg jsonMessage) {
+	// Messages about the repository itself ("latest: Pulling from ...")
+	// also carry an ID; only layer messages are tracked.
+	if msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from") {
+		return
+	}
+	if _, seen := l.status[msg.ID]; !seen {
+		l.order = append(l.order, msg.ID)
+	}
+	l.status[msg.ID] = msg.Status
+}
+
+func (l *layerProgress) list() []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(l.order))
+	for _, id := range l.order {
+		out = append(out, map[string]interface{}{"id": id, "status": l.status[id]})
+	}
+	return out
+}
+
+// count returns how many layers finished with a status starting with one of
+// prefixes.
+func (l *layerProgress) count(prefixes ...string) int {
+	n := 0
+	for _, id := range l.order {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(l.status[id], prefix) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// pullResult summarises a finished pull.
+type pullResult struct {
+	digest string
+	status string
+	layers *layerProgress
+}
+
+// pullImage pulls ref and follows the progress stream. A reference without
+// a tag or digest pulls :latest rather than every tag.
+func (c *dockerClient) pullImage(ctx context.Context, ref string, auth authConfig, platform string) (pullResult, error) {
+	result := pullResult{layers: newLayerProgress()}
+
+	name, tag := splitReference(ref)
+	query := url.Values{"fromImage": {name}, "tag": {tag}}
+	if platform != "" {
+		query.Set("platform", platform)
+	}
+	header := http.Header{}
+	if !auth.empty() {
+		header.Set("X-Registry-Auth", auth.header())
+	}
+
+	resp, err := c.request(ctx, http.MethodPost, "/images/create", query, nil, header)
+	if err != nil {
+		return result, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+
+	err = readJSONMessages(resp.Body, func(msg jsonMessage) {
+		switch {
+		case strings.HasPrefix(msg.Status, "Digest: "):
+			result.digest = strings.TrimPrefix(msg.Status, "Digest: ")
+		case strings.HasPrefix(msg.Status, "Status: "):
+			result.status = strings.TrimPrefix(msg.Status, "Status: ")
+		default:
+			result.layers.observe(msg)
+		}
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	return result, nil
+}
+
+func (p *DockerPlugin) executePull(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	image, ok := params["image"].(string)
+	if !ok || image == "" {
+		return nil, fmt.Errorf("image parameter is required")
+	}
+	platform, _ := params["platform"].(string)
+
+	auth, err := resolveAuth(ctx, params, registryForImage(image))
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.pullImage(ctx, image, auth, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := result.layers
+	return map[string]interface{}{
+		"image":  image,
+		"digest": result.digest,
+		"status": result.status,
+		"layers": layers.list(),
+		"layer_summary": map[string]interface{}{
+			"total":      len(layers.order),
+			"downloaded": layers.count("Pull complete"),
+			"existing":   layers.count("Already exists"),
+		},
+		"success": true,
+		"message": fmt.Sprintf("Pulled %s (%s)", image, result.status),
+	}, nil
+}
+
+func (p *DockerPlugin) executePush(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	image, ok := params["image"].(string)
+	if !ok || image == "" {
+		return nil, fmt.Errorf("image parameter is required")
+	}
+
+	auth, err := resolveAuth(ctx, params, registryForImage(image))
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	name, tag := splitReference(image)
+	query := url.Values{"tag": {tag}}
+	// The header is mandatory for push, even for anonymous registries.
+	header := http.Header{"X-Registry-Auth": {auth.header()}}
+	resp, err := client.request(ctx, http.MethodPost, "/images/"+name+"/push", query, nil, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to push %s: %w", image, err)
+	}
+	defer resp.Body.Close()
+
+	layers := newLayerProgress()
+	var pushed struct {
+		Tag    string `json:"Tag"`
+		Digest string `json:"Digest"`
+		Size   int64  `json:"Size"`
+	}
+	err = readJSONMessages(resp.Body, func(msg jsonMessage) {
+		if len(msg.Aux) > 0 {
+			json.Unmarshal(msg.Aux, &pushed)
+			return
+		}
+		layers.observe(msg)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to push %s: %w", image, err)
+	}
+
+	return map[string]interface{}{
+		"image":  image,
+		"digest": pushed.Digest,
+		"size":   pushed.Size,
+		"layers": layers.list(),
+		"layer_summary": map[string]interface{}{
+			"total":    len(layers.order),
+			"pushed":   layers.count("Pushed"),
+			"mounted":  layers.count("Mounted from"),
+			"existing": layers.count("Layer already exists"),
+		},
+		"success": true,
+		"message": fmt.Sprintf("Pushed %s@%s", name, pushed.Digest),
+	}, nil
+}
+
+func (p *DockerPlugin) executeTag(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	source, ok := params["source"].(string)
+	if !ok || source == "" {
+		return nil, fmt.Errorf("source parameter is required")
+	}
+	target, ok := params["target"].(string)
+	if !ok || target == "" {
+		return nil, fmt.Errorf("target parameter is required")
+	}
+	if strings.Contains(target, "@") {
+		return nil, fmt.Errorf("target cannot contain a digest")
+	}
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, tag := splitReference(target)
+	query := url.Values{"repo": {repo}, "tag": {tag}}
+	if err := client.doJSON(ctx, http.MethodPost, "/images/"+source+"/tag", query, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to tag %s as %s: %w", source, target, err)
+	}
+
+	return map[string]interface{}{
+		"source":  source,
+		"target":  repo + ":" + tag,
+		"success": true,
+		"message": fmt.Sprintf("Tagged %s as %s:%s", source, repo, tag),
+	}, nil
+}
+
+func (p *DockerPlugin) executeLogin(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	registry := defaultRegistry
+	if r, ok := params["registry"].(string); ok && r != "" {
+		registry = r
+	}
+
+	auth, err := resolveAuth(ctx, params, registry)
+	if err != nil {
+		return nil, err
+	}
+	if auth.empty() {
+		return nil, fmt.Errorf("no credentials for %s: set username and password or add them to %s", registry, dockerConfigPath(params))
+	}
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var status struct {
+		Status        string `json:"Status"`
+		IdentityToken string `json:"IdentityToken"`
+	}
+	if err := client.doJSON(ctx, http.MethodPost, "/auth", nil, auth, &status); err != nil {
+		return nil, fmt.Errorf("login to %s failed: %w", registry, err)
+	}
+
+	saved := false
+	if save, _ := params["save"].(bool); save {
+		if status.IdentityToken != "" {
+			auth.IdentityToken, auth.Password = status.IdentityToken, ""
+		}
+		if err := saveAuth(dockerConfigPath(params), auth); err != nil {
+			return nil, fmt.Errorf("failed to save credentials: %w", err)
+		}
+		saved = true
+	}
+
+	return map[string]interface{}{
+		"registry": registry,
+		"username": auth.Username,
+		"status":   status.Status,
+		"saved":    saved,
+		"success":  true,
+		"message":  fmt.Sprintf("%s to %s", status.Status, registry),
+	}, nil
+}
+
+// filtersParam encodes the filters object parameter, merged with extra,
+// into the JSON form the API expects.
+func filtersParam(params map[string]interface{}, extra map[string][]string) (string, error) {
+	filters := make(map[string][]string)
+	if raw, ok := params["filters"].(map[string]interface{}); ok {
+		for key := range raw {
+			filters[key] = stringList(raw, key)
+		}
+	}
+	for key, values := range extra {
+		filters[key] = append(filters[key], values...)
+	}
+	if len(filters) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(filters)
+	return string(encoded), err
+}
+
+func (p *DockerPlugin) executeImages(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	query := url.Values{}
+	if all, _ := params["all"].(bool); all {
+		query.Set("all", "1")
+	}
+	extra := map[string][]string{}
+	if ref, ok := params["reference"].(string); ok && ref != "" {
+		extra["reference"] = []string{ref}
+	}
+	filters, err := filtersParam(params, extra)
+	if err != nil {
+		return nil, err
+	}
+	if filters != "" {
+		query.Set("filters", filters)
+	}
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []struct {
+		ID          string            `json:"Id"`
+		RepoTags    []string          `json:"RepoTags"`
+		RepoDigests []string          `json:"RepoDigests"`
+		Created     int64             `json:"Created"`
+		Size        int64             `json:"Size"`
+		Labels      map[string]string `json:"Labels"`
+	}
+	if err := client.doJSON(ctx, http.MethodGet, "/images/json", query, nil, &summaries); err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	images := make([]map[string]interface{}, 0, len(summaries))
+	for _, s := range summaries {
+		images = append(images, map[string]interface{}{
+			"id":       s.ID,
+			"short_id": shortID(s.ID),
+			"tags":     s.RepoTags,
+			"digests":  s.RepoDigests,
+			"created":  s.Created,
+			"size":     s.Size,
+			"labels":   s.Labels,
+		})
+	}
+
+	return map[string]interface{}{
+		"images":  images,
+		"count":   len(images),
+		"message": fmt.Sprintf("Listed %d images", len(images)),
+	}, nil
+}
+
+func (p *DockerPlugin) executeImagePrune(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	extra := map[string][]string{}
+	// The API only removes dangling images unless told otherwise.
+	if all, _ := params["all"].(bool); all {
+		extra["dangling"] = []string{"false"}
+	}
+	if until, ok := params["until"].(string); ok && until != "" {
+		extra["until"] = []string{until}
+	}
+	filters, err := filtersParam(params, extra)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if filters != "" {
+		query.Set("filters", filters)
+	}
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned struct {
+		ImagesDeleted []struct {
+			Untagged string `json:"Untagged"`
+			Deleted  string `json:"Deleted"`
+		} `json:"ImagesDeleted"`
+		SpaceReclaimed int64 `json:"SpaceReclaimed"`
+	}
+	if err := client.doJSON(ctx, http.MethodPost, "/images/prune", query, nil, &pruned); err != nil {
+		return nil, fmt.Errorf("failed to prune images: %w", err)
+	}
+
+	deleted, untagged := []string{}, []string{}
+	for _, item := range pruned.ImagesDeleted {
+		if item.Deleted != "" {
+			deleted = append(deleted, item.Deleted)
+		}
+		if item.Untagged != "" {
+			untagged = append(untagged, item.Untagged)
+		}
+	}
+
+	return map[string]interface{}{
+		"deleted":         deleted,
+		"untagged":        untagged,
+		"count":           len(deleted),
+		"space_reclaimed": pruned.SpaceReclaimed,
+		"message":         fmt.Sprintf("Deleted %d images, reclaimed %d bytes", len(deleted), pruned.SpaceReclaimed),
+	}, nil
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeCredentialHelpers puts docker-credential-fake and
+// docker-credential-broken first in PATH. fake knows ghcr.io and holds an
+// identity token for Docker Hub; broken always fails.
+func fakeCredentialHelpers(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	scripts := map[string]string{
+		"docker-credential-fake": `#!/bin/sh
+read server
+case "$server" in
+ghcr.io) echo '{"ServerURL":"ghcr.io","Username":"octo","Secret":"ghp_token"}' ;;
+https://index.docker.io/v1/) echo '{"ServerURL":"https://index.docker.io/v1/","Username":"<token>","Secret":"hub-identity"}' ;;
+*) echo "credentials not found in native keychain"; exit 1 ;;
+esac
+`,
+		"docker-credential-broken": "#!/bin/sh\necho 'keychain is locked' >&2\nexit 1\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func basicAuth(user, pass string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+}
+
+func TestSplitReference(t *testing.T) {
+	tests := []struct {
+		ref, name, tag string
+	}{
+		{"nginx", "nginx", "latest"},
+		{"nginx:1.25", "nginx", "1.25"},
+		{"library/nginx:1.25-alpine", "library/nginx", "1.25-alpine"},
+		{"localhost:5000/app", "localhost:5000/app", "latest"},
+		{"localhost:5000/app:2.0", "localhost:5000/app", "2.0"},
+		{"ghcr.io/org/app@sha256:0123", "ghcr.io/org/app", "sha256:0123"},
+	}
+	for _, tt := range tests {
+		name, tag := splitReference(tt.ref)
+		if name != tt.name || tag != tt.tag {
+			t.Errorf("splitReference(%q) = %q, %q; want %q, %q", tt.ref, name, tag, tt.name, tt.tag)
+		}
+	}
+}
+
+func TestRegistryForImage(t *testing.T) {
+	tests := []struct {
+		ref, want string
+	}{
+		{"nginx", defaultRegistry},
+		{"library/nginx:1.25", defaultRegistry},
+		{"ghcr.io/org/app", "ghcr.io"},
+		{"registry.example.com:5000/team/app:1.0", "registry.example.com:5000"},
+		{"localhost/app", "localhost"},
+		{"localhost:5000/app", "localhost:5000"},
+	}
+	for _, tt := range tests {
+		if got := registryForImage(tt.ref); got != tt.want {
+			t.Errorf("registryForImage(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRegistry(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{defaultRegistry, "index.docker.io"},
+		{"docker.io", "index.docker.io"},
+		{"registry-1.docker.io", "index.docker.io"},
+		{"https://registry.example.com/v2/", "registry.example.com"},
+		{"http://localhost:5000", "localhost:5000"},
+		{"ghcr.io", "ghcr.io"},
+	}
+	for _, tt := range tests {
+		if got := normalizeRegistry(tt.addr); got != tt.want {
+			t.Errorf("normalizeRegistry(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestResolveAuth(t *testing.T) {
+	fakeCredentialHelpers(t)
+
+	tests := []struct {
+		name     string
+		config   string
+		params   map[string]interface{}
+		registry string
+		want     authConfig
+		wantErr  string
+	}{
+		{
+			name:     "explicit credentials win over config.json",
+			config:   `{"auths": {"ghcr.io": {"auth": "` + basicAuth("stored", "old") + `"}}}`,
+			params:   map[string]interface{}{"username": "ci", "password": "s3cret"},
+			registry: "ghcr.io",
+			want:     authConfig{Username: "ci", Password: "s3cret", ServerAddress: "ghcr.io"},
+		},
+		{
+			name:     "no config file is anonymous",
+			registry: "ghcr.io",
+			want:     authConfig{ServerAddress: "ghcr.io"},
+		},
+		{
+			name:     "auth entry",
+			config:   `{"auths": {"https://registry.example.com/v2/": {"auth": "` + basicAuth("deploy", "pa:ss") + `"}}}`,
+			registry: "registry.example.com",
+			want:     authConfig{Username: "deploy", Password: "pa:ss", ServerAddress: "registry.example.com"},
+		},
+		{
+			name:     "Docker Hub aliases match",
+			config:   `{"auths": {"docker.io": {"username": "hub", "password": "pw"}}}`,
+			registry: defaultRegistry,
+			want:     authConfig{Username: "hub", Password: "pw", ServerAddress: defaultRegistry},
+		},
+		{
+			name:     "identity token entry",
+			config:   `{"auths": {"ghcr.io": {"identitytoken": "refresh"}}}`,
+			registry: "ghcr.io",
+			want:     authConfig{IdentityToken: "refresh", ServerAddress: "ghcr.io"},
+		},
+		{
+			name:     "other registries are anonymous",
+			config:   `{"auths": {"ghcr.io": {"auth": "` + basicAuth("octo", "pw") + `"}}}`,
+			registry: "quay.io",
+			want:     authConfig{ServerAddress: "quay.io"},
+		},
+		{
+			name:     "credHelpers entry",
+			config:   `{"auths": {"ghcr.io": {"auth": "` + basicAuth("stale", "pw") + `"}}, "credHelpers": {"ghcr.io": "fake"}}`,
+			registry: "ghcr.io",
+			want:     authConfig{Username: "octo", Password: "ghp_token", ServerAddress: "ghcr.io"},
+		},
+		{
+			name:     "credsStore identity token",
+			config:   `{"credsStore": "fake"}`,
+			registry: defaultRegistry,
+			want:     authConfig{IdentityToken: "hub-identity", ServerAddress: defaultRegistry},
+		},
+		{
+			name:     "credsStore without credentials is anonymous",
+			config:   `{"credsStore": "fake"}`,
+			registry: "quay.io",
+			want:     authConfig{ServerAddress: "quay.io"},
+		},
+		{
+			name:     "auths entry before credsStore",
+			config:   `{"auths": {"quay.io": {"auth": "` + basicAuth("robot", "pw") + `"}}, "credsStore": "broken"}`,
+			registry: "quay.io",
+			want:     authConfig{Username: "robot", Password: "pw", ServerAddress: "quay.io"},
+		},
+		{
+			name:     "failing helper",
+			config:   `{"credsStore": "broken"}`,
+			registry: "quay.io",
+			wantErr:  "credential helper broken failed: keychain is locked",
+		},
+		{
+			name:     "invalid auth entry",
+			config:   `{"auths": {"quay.io": {"auth": "not base64!"}}}`,
+			registry: "quay.io",
+			wantErr:  "invalid auth entry for quay.io",
+		},
+		{
+			name:     "invalid config file",
+			config:   `{"auths": [`,
+			registry: "quay.io",
+			wantErr:  "failed to parse",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if tt.config != "" {
+				if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			params := map[string]interface{}{"config_file": path}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+
+			got, err := resolveAuth(context.Background(), params, tt.registry)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveAuth: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("auth = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// decodeRegistryAuth decodes an X-Registry-Auth header.
+func decodeRegistryAuth(t *testing.T, header string) authConfig {
+	t.Helper()
+	data, err := base64.URLEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("X-Registry-Auth %q: %v", header, err)
+	}
+	var auth authConfig
+	if err := json.Unmarshal(data, &auth); err != nil {
+		t.Fatalf("X-Registry-Auth %q: %v", data, err)
+	}
+	return auth
+}
+
+func TestPull(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    map[string]interface{}
+		wantQuery string
+		wantAuth  *authConfig
+	}{
+		{
+			name:      "anonymous pull of latest",
+			params:    map[string]interface{}{"image": "nginx"},
+			wantQuery: "fromImage=nginx&tag=latest",
+		},
+		{
+			name:      "tag and platform",
+			params:    map[string]interface{}{"image": "nginx:1.25", "platform": "linux/arm64"},
+			wantQuery: "fromImage=nginx&platform=linux%2Farm64&tag=1.25",
+		},
+		{
+			name:      "private registry with credentials",
+			params:    map[string]interface{}{"image": "registry.example.com/team/app:1.0", "username": "ci", "password": "s3cret"},
+			wantQuery: "fromImage=registry.example.com%2Fteam%2Fapp&tag=1.0",
+			wantAuth:  &authConfig{Username: "ci", Password: "s3cret", ServerAddress: "registry.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDaemon(t)
+			out, err := (&DockerPlugin{}).Execute(context.Background(), "pull", d.params(tt.params))
+			if err != nil {
+				t.Fatalf("pull: %v", err)
+			}
+			if got := d.query.Encode(); got != tt.wantQuery {
+				t.Errorf("query = %s, want %s", got, tt.wantQuery)
+			}
+			if tt.wantAuth == nil && d.registryAuth != "" {
+				t.Errorf("anonymous pull sent X-Registry-Auth %q", d.registryAuth)
+			}
+			if tt.wantAuth != nil && decodeRegistryAuth(t, d.registryAuth) != *tt.wantAuth {
+				t.Errorf("X-Registry-Auth = %+v, want %+v", decodeRegistryAuth(t, d.registryAuth), *tt.wantAuth)
+			}
+
+			if out["digest"] != "sha256:0123" || out["status"] != "Downloaded newer image for nginx:latest" {
+				t.Errorf("digest = %v, status = %v", out["digest"], out["status"])
+			}
+			wantLayers := []map[string]interface{}{
+				{"id": "a1b2", "status": "Pull complete"},
+				{"id": "c3d4", "status": "Already exists"},
+			}
+			if !reflect.DeepEqual(out["layers"], wantLayers) {
+				t.Errorf("layers = %v, want %v", out["layers"], wantLayers)
+			}
+			wantSummary := map[string]interface{}{"total": 2, "downloaded": 1, "existing": 1}
+			if !reflect.DeepEqual(out["layer_summary"], wantSummary) {
+				t.Errorf("layer_summary = %v, want %v", out["layer_summary"], wantSummary)
+			}
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		wantPath string
+		wantTag  string
+		wantAuth authConfig
+	}{
+		{
+			name:     "anonymous push still sends the header",
+			params:   map[string]interface{}{"image": "localhost:5000/app"},
+			wantPath: "POST /images/localhost:5000/app/push",
+			wantTag:  "latest",
+			wantAuth: authConfig{ServerAddress: "localhost:5000"},
+		},
+		{
+			name:     "credentials",
+			params:   map[string]interface{}{"image": "registry.example.com/team/app:1.0", "username": "ci", "password": "s3cret"},
+			wantPath: "POST /images/registry.example.com/team/app/push",
+			wantTag:  "1.0",
+			wantAuth: authConfig{Username: "ci", Password: "s3cret", ServerAddress: "registry.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDaemon(t)
+			out, err := (&DockerPlugin{}).Execute(context.Background(), "push", d.params(tt.params))
+			if err != nil {
+				t.Fatalf("push: %v", err)
+			}
+			if got := d.seen(); !reflect.DeepEqual(got, []string{tt.wantPath}) {
+				t.Errorf("requests = %v, want %s", got, tt.wantPath)
+			}
+			if got := d.query.Get("tag"); got != tt.wantTag {
+				t.Errorf("tag = %q, want %q", got, tt.wantTag)
+			}
+			if d.registryAuth == "" {
+				t.Fatal("push sent no X-Registry-Auth header")
+			}
+			if got := decodeRegistryAuth(t, d.registryAuth); got != tt.wantAuth {
+				t.Errorf("X-Registry-Auth = %+v, want %+v", got, tt.wantAuth)
+			}
+
+			if out["digest"] != "sha256:4567" || out["size"] != int64(1234) {
+				t.Errorf("digest = %v, size = %#v", out["digest"], out["size"])
+			}
+			wantSummary := map[string]interface{}{"total": 3, "pushed": 1, "mounted": 1, "existing": 1}
+			if !reflect.DeepEqual(out["layer_summary"], wantSummary) {
+				t.Errorf("layer_summary = %v, want %v", out["layer_summary"], wantSummary)
+			}
+		})
+	}
+}
+
+func TestPullAndPushStreamErrors(t *testing.T) {
+	for _, action := range []string{"pull", "push"} {
+		d := newFakeDaemon(t)
+		d.streamError = "denied: requested access to the resource is denied"
+		_, err := (&DockerPlugin{}).Execute(context.Background(), action, d.params(map[string]interface{}{"image": "ghcr.io/org/app:1.0"}))
+		if err == nil || !strings.Contains(err.Error(), "failed to "+action+" ghcr.io/org/app:1.0: denied") {
+			t.Errorf("%s: error = %v, want the daemon's stream error", action, err)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		target     string
+		wantQuery  string
+		wantTarget string
+		wantErr    string
+	}{
+		{
+			name:       "repository and tag",
+			source:     "nginx:1.25",
+			target:     "registry.example.com/team/nginx:stable",
+			wantQuery:  "repo=registry.example.com%2Fteam%2Fnginx&tag=stable",
+			wantTarget: "registry.example.com/team/nginx:stable",
+		},
+		{
+			name:       "registry port without a tag",
+			source:     "nginx:1.25",
+			target:     "localhost:5000/nginx",
+			wantQuery:  "repo=localhost%3A5000%2Fnginx&tag=latest",
+			wantTarget: "localhost:5000/nginx:latest",
+		},
+		{
+			name:    "digest target",
+			source:  "nginx:1.25",
+			target:  "nginx@sha256:0123",
+			wantErr: "target cannot contain a digest",
+		},
+		{
+			name:    "missing source image",
+			source:  "nginx:1.24",
+			target:  "nginx:old",
+			wantErr: "No such image: nginx:1.24",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDaemon(t)
+			out, err := (&DockerPlugin{}).Execute(context.Background(), "tag", d.params(map[string]interface{}{
+				"source": tt.source,
+				"target": tt.target,
+			}))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tag: %v", err)
+			}
+			if got := d.query.Encode(); got != tt.wantQuery {
+				t.Errorf("query = %s, want %s", got, tt.wantQuery)
+			}
+			if out["target"] != tt.wantTarget {
+				t.Errorf("target = %v, want %s", out["target"], tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        map[string]interface{}
+		identityToken string
+		wantLogin     authConfig
+		wantSaved     map[string]string
+		wantErr       string
+	}{
+		{
+			name:      "Docker Hub by default",
+			params:    map[string]interface{}{"username": "hub", "password": "s3cret"},
+			wantLogin: authConfig{Username: "hub", Password: "s3cret", ServerAddress: defaultRegistry},
+		},
+		{
+			name:      "save the password",
+			params:    map[string]interface{}{"registry": "ghcr.io", "username": "octo", "password": "s3cret", "save": true},
+			wantLogin: authConfig{Username: "octo", Password: "s3cret", ServerAddress: "ghcr.io"},
+			wantSaved: map[string]string{"auth": basicAuth("octo", "s3cret")},
+		},
+		{
+			name:          "save the identity token instead of the password",
+			params:        map[string]interface{}{"registry": "ghcr.io", "username": "octo", "password": "s3cret", "save": true},
+			identityToken: "refresh-token",
+			wantLogin:     authConfig{Username: "octo", Password: "s3cret", ServerAddress: "ghcr.io"},
+			wantSaved:     map[string]string{"identitytoken": "refresh-token"},
+		},
+		{
+			name:    "wrong password",
+			params:  map[string]interface{}{"registry": "ghcr.io", "username": "octo", "password": "nope", "save": true},
+			wantErr: "unauthorized: incorrect username or password",
+		},
+		{
+			name:    "no credentials",
+			params:  map[string]interface{}{"registry": "ghcr.io"},
+			wantErr: "no credentials for ghcr.io",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDaemon(t)
+			d.identityToken = tt.identityToken
+			config := filepath.Join(t.TempDir(), "config.json")
+			// Other settings in config.json survive a save.
+			if err := os.WriteFile(config, []byte(`{"credsStore": "", "detachKeys": "ctrl-x"}`), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			params := d.params(map[string]interface{}{"config_file": config})
+			for k, v := range tt.params {
+				params[k] = v
+			}
+
+			out, err := (&DockerPlugin{}).Execute(context.Background(), "login", params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				if data, _ := os.ReadFile(config); strings.Contains(string(data), "auths") {
+					t.Errorf("failed login saved credentials: %s", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if d.login != tt.wantLogin {
+				t.Errorf("POST /auth body = %+v, want %+v", d.login, tt.wantLogin)
+			}
+			if out["status"] != "Login Succeeded" || out["saved"] != (tt.wantSaved != nil) {
+				t.Errorf("status = %v, saved = %v", out["status"], out["saved"])
+			}
+
+			data, err := os.ReadFile(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saved struct {
+				Auths      map[string]map[string]string `json:"auths"`
+				DetachKeys string                       `json:"detachKeys"`
+			}
+			if err := json.Unmarshal(data, &saved); err != nil {
+				t.Fatalf("config.json: %v", err)
+			}
+			if saved.DetachKeys != "ctrl-x" {
+				t.Errorf("config.json lost other settings: %s", data)
+			}
+			registry := tt.wantLogin.ServerAddress
+			if !reflect.DeepEqual(saved.Auths[registry], tt.wantSaved) {
+				t.Errorf("saved auth for %s = %v, want %v", registry, saved.Auths[registry], tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestImagePrune(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      map[string]interface{}
+		wantFilters string
+	}{
+		{
+			name:        "dangling images only",
+			params:      map[string]interface{}{},
+			wantFilters: "",
+		},
+		{
+			name:        "all unused images",
+			params:      map[string]interface{}{"all": true},
+			wantFilters: `{"dangling":["false"]}`,
+		},
+		{
+			name: "until and label filters",
+			params: map[string]interface{}{
+				"until":   "24h",
+				"filters": map[string]interface{}{"label": []interface{}{"stage=build"}, "until": "48h"},
+			},
+			wantFilters: `{"label":["stage=build"],"until":["48h","24h"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDaemon(t)
+			out, err := (&DockerPlugin{}).Execute(context.Background(), "image_prune", d.params(tt.params))
+			if err != nil {
+				t.Fatalf("image_prune: %v", err)
+			}
+			if got := d.query.Get("filters"); got != tt.wantFilters {
+				t.Errorf("filters = %s, want %s", got, tt.wantFilters)
+			}
+			if !reflect.DeepEqual(out["deleted"], []string{"sha256:aaaa", "sha256:bbbb"}) ||
+				!reflect.DeepEqual(out["untagged"], []string{"nginx:1.24"}) {
+				t.Errorf("deleted = %v, untagged = %v", out["deleted"], out["untagged"])
+			}
+			if out["count"] != 2 || out["space_reclaimed"] != int64(2048) {
+				t.Errorf("count = %v, space_reclaimed = %#v", out["count"], out["space_reclaimed"])
+			}
+		})
+	}
+}
@@ -0,0 +1,769 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// lifecycleActions declares the container lifecycle actions.
+func lifecycleActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "stop",
+			Description: "Stop a running container",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"container": {
+					Type:        "string",
+					Description: "Container name or ID",
+					Required:    true,
+				},
+				"timeout": {
+					Type:        "number",
+					Description: "Seconds to wait for the container to stop before killing it",
+					Required:    false,
+					Default:     10,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"container_id": {
+					Type:        "string",
+					Description: "Container ID",
+				},
+				"was_running": {
+					Type:        "boolean",
+					Description: "Whether the container was running before the call",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the operation succeeded",
+				},
+			},
+		},
+		{
+			Name:        "start",
+			Description: "Start a stopped container",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"container": {
+					Type:        "string",
+					Description: "Container name or ID",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"container_id": {
+					Type:        "string",
+					Description: "Container ID",
+				},
+				"was_running": {
+					Type:        "boolean",
+					Description: "Whether the container was already running",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the operation succeeded",
+				},
+			},
+		},
+		{
+			Name:        "restart",
+			Description: "Restart a container",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"container": {
+					Type:        "string",
+					Description: "Container name or ID",
+					Required:    true,
+				},
+				"timeout": {
+					Type:        "number",
+					Description: "Seconds to wait for the container to stop before killing it",
+					Required:    false,
+					Default:     10,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"container_id": {
+					Type:        "string",
+					Description: "Container ID",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the operation succeeded",
+				},
+			},
+		},
+		{
+			Name:        "rm",
+			Description: "Remove a container",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"container": {
+					Type:        "string",
+					Description: "Container name or ID",
+					Required:    true,
+				},
+				"force": {
+					Type:        "boolean",
+					Description: "Kill and remove a running container",
+					Required:    false,
+					Default:     false,
+				},
+				"volumes": {
+					Type:        "boolean",
+					Description: "Also remove anonymous volumes attached to the container",
+					Required:    false,
+					Default:     false,
+				},
+				"ignore_missing": {
+					Type:        "boolean",
+					Description: "Succeed when the container does not exist",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"container_id": {
+					Type:        "string",
+					Description: "Container ID (empty when the container did not exist)",
+				},
+				"removed": {
+					Type:        "boolean",
+					Description: "Whether a container was removed",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the operation succeeded",
+				},
+			},
+		},
+		{
+			Name:        "logs",
+			Description: "Fetch container logs",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"container": {
+					Type:        "string",
+					Description: "Container name or ID",
+					Required:    true,
+				},
+				"tail": {
+					Type:        "number",
+					Description: "Number of lines from the end of the logs (default: all)",
+					Required:    false,
+				},
+				"since": {
+					Type:        "string",
+					Description: "Only logs after this time: RFC3339 timestamp, unix seconds or a duration such as 10m",
+					Required:    false,
+				},
+				"until": {
+					Type:        "string",
+					Description: "Only logs before this time, in the same formats as since",
+					Required:    false,
+				},
+				"timestamps": {
+					Type:        "boolean",
+					Description: "Prefix every line with its timestamp",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"stdout": {
+					Type:        "string",
+					Description: "Standard output",
+				},
+				"stderr": {
+					Type:        "string",
+					Description: "Standard error",
+				},
+				"lines": {
+					Type:        "number",
+					Description: "Number of log lines returned",
+				},
+			},
+		},
+		{
+			Name:        "exec",
+			Description: "Run a command inside a running container",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"container": {
+					Type:        "string",
+					Description: "Container name or ID",
+					Required:    true,
+				},
+				"command": {
+					Type:        "array",
+					Description: "Command and arguments to run",
+					Required:    true,
+				},
+				"environment": {
+					Type:        "object",
+					Description: "Extra environment variables",
+					Required:    false,
+				},
+				"workdir": {
+					Type:        "string",
+					Description: "Working directory inside the container",
+					Required:    false,
+				},
+				"user": {
+					Type:        "string",
+					Description: "User to run the command as",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"stdout": {
+					Type:        "string",
+					Description: "Standard output",
+				},
+				"stderr": {
+					Type:        "string",
+					Description: "Standard error",
+				},
+				"exit_code": {
+					Type:        "number",
+					Description: "Exit code of the command",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the command exited with code 0",
+				},
+			},
+		},
+		{
+			Name:        "inspect",
+			Description: "Return low-level information about a container",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"container": {
+					Type:        "string",
+					Description: "Container name or ID",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"container_id": {
+					Type:        "string",
+					Description: "Container ID",
+				},
+				"name": {
+					Type:        "string",
+					Description: "Container name",
+				},
+				"image": {
+					Type:        "string",
+					Description: "Image the container was created from",
+				},
+				"status": {
+					Type:        "string",
+					Description: "Container state (created, running, exited, ...)",
+				},
+				"running": {
+					Type:        "boolean",
+					Description: "Whether the container is running",
+				},
+				"exit_code": {
+					Type:        "number",
+					Description: "Exit code of the last run",
+				},
+				"health": {
+					Type:        "string",
+					Description: "Health check status, if the container has one",
+				},
+				"ip_address": {
+					Type:        "string",
+					Description: "IP address on the first attached network",
+				},
+				"details": {
+					Type:        "object",
+					Description: "Full inspect document returned by the daemon",
+				},
+			},
+		},
+		{
+			Name:        "wait",
+			Description: "Wait for a container to stop and return its exit code",
+			Inputs: withHostInput(map[string]plugin.InputSpec{
+				"container": {
+					Type:        "string",
+					Description: "Container name or ID",
+					Required:    true,
+				},
+				"condition": {
+					Type:        "string",
+					Description: "not-running, next-exit or removed",
+					Required:    false,
+					Default:     "not-running",
+				},
+				"timeout": {
+					Type:        "number",
+					Description: "Maximum seconds to wait (0 waits indefinitely)",
+					Required:    false,
+					Default:     0,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"exit_code": {
+					Type:        "number",
+					Description: "Container exit code",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the container exited with code 0",
+				},
+			},
+		},
+	}
+}
+
+// containerParam returns the required container name or ID, escaped for use
+// in a URL path.
+func containerParam(params map[string]interface{}) (string, error) {
+	container, ok := params["container"].(string)
+	if !ok || container == "" {
+		return "", fmt.Errorf("container parameter is required")
+	}
+	return url.PathEscape(container), nil
+}
+
+// containerInfo is the part of GET /containers/{id}/json used by the plugin.
+type containerInfo struct {
+	ID     string `json:"Id"`
+	Name   string `json:"Name"`
+	Image  string `json:"Image"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Tty    bool              `json:"Tty"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	State struct {
+		Status     string `json:"Status"`
+		Running    bool   `json:"Running"`
+		ExitCode   int64  `json:"ExitCode"`
+		Error      string `json:"Error"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+		Health     *struct {
+			Status        string `json:"Status"`
+			FailingStreak int    `json:"FailingStreak"`
+		} `json:"Health"`
+	} `json:"State"`
+	NetworkSettings struct {
+		IPAddress string `json:"IPAddress"`
+		Networks  map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func (c *dockerClient) inspectContainer(ctx context.Context, id string) (containerInfo, map[string]interface{}, error) {
+	var info containerInfo
+	resp, err := c.request(ctx, http.MethodGet, "/containers/"+id+"/json", nil, nil, nil)
+	if err != nil {
+		return info, nil, err
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return info, nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		return info, nil, fmt.Errorf("failed to decode docker response: %w", err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+		return info, nil, fmt.Errorf("failed to decode docker response: %w", err)
+	}
+	return info, raw, nil
+}
+
+// containerID resolves a container name or short ID to the full ID, so the
+// container_id output is the same however the container was referenced.
+func (c *dockerClient) containerID(ctx context.Context, ref string) (string, error) {
+	var info struct {
+		ID string `json:"Id"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/containers/"+ref+"/json", nil, nil, &info); err != nil {
+		return "", err
+	}
+	return info.ID, nil
+}
+
+// ipAddress returns the container address on the default bridge or, for
+// user-defined networks, on the first network in name order.
+func (i containerInfo) ipAddress() string {
+	if i.NetworkSettings.IPAddress != "" {
+		return i.NetworkSettings.IPAddress
+	}
+	first := ""
+	for name, n := range i.NetworkSettings.Networks {
+		if n.IPAddress != "" && (first == "" || name < first) {
+			first = name
+		}
+	}
+	if first == "" {
+		return ""
+	}
+	return i.NetworkSettings.Networks[first].IPAddress
+}
+
+func (i containerInfo) health() string {
+	if i.State.Health == nil {
+		return ""
+	}
+	return i.State.Health.Status
+}
+
+// logTime converts since/until values into the unix timestamp format the
+// API expects. Durations are taken relative to now.
+func logTime(value string, now time.Time) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return strconv.FormatInt(now.Add(-d).Unix(), 10), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return strconv.FormatInt(t.Unix(), 10), nil
+	}
+	return "", fmt.Errorf("invalid time %q: use RFC3339, unix seconds or a duration", value)
+}
+
+func (p *DockerPlugin) executeStop(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	id, err := containerParam(params)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	containerID, err := client.containerID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop container %s: %w", params["container"], err)
+	}
+	query := url.Values{"t": {strconv.Itoa(intParam(params, "timeout", 10))}}
+	resp, err := client.request(ctx, http.MethodPost, "/containers/"+containerID+"/stop", query, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop container %s: %w", params["container"], err)
+	}
+	resp.Body.Close()
+
+	// 304 Not Modified means the container was already stopped.
+	wasRunning := resp.StatusCode != http.StatusNotModified
+	return map[string]interface{}{
+		"container_id": containerID,
+		"was_running":  wasRunning,
+		"success":      true,
+		"message":      fmt.Sprintf("Container %s stopped", params["container"]),
+	}, nil
+}
+
+func (p *DockerPlugin) executeStart(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	id, err := containerParam(params)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	containerID, err := client.containerID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start container %s: %w", params["container"], err)
+	}
+	resp, err := client.request(ctx, http.MethodPost, "/containers/"+containerID+"/start", nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start container %s: %w", params["container"], err)
+	}
+	resp.Body.Close()
+
+	return map[string]interface{}{
+		"container_id": containerID,
+		"was_running":  resp.StatusCode == http.StatusNotModified,
+		"success":      true,
+		"message":      fmt.Sprintf("Container %s started", params["container"]),
+	}, nil
+}
+
+func (p *DockerPlugin) executeRestart(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	id, err := containerParam(params)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	containerID, err := client.containerID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restart container %s: %w", params["container"], err)
+	}
+	query := url.Values{"t": {strconv.Itoa(intParam(params, "timeout", 10))}}
+	if err := client.doJSON(ctx, http.MethodPost, "/containers/"+containerID+"/restart", query, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to restart container %s: %w", params["container"], err)
+	}
+
+	return map[string]interface{}{
+		"container_id": containerID,
+		"success":      true,
+		"message":      fmt.Sprintf("Container %s restarted", params["container"]),
+	}, nil
+}
+
+func (p *DockerPlugin) executeRemove(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	id, err := containerParam(params)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	if force, _ := params["force"].(bool); force {
+		query.Set("force", "1")
+	}
+	if volumes, _ := params["volumes"].(bool); volumes {
+		query.Set("v", "1")
+	}
+
+	removed := true
+	containerID, err := client.containerID(ctx, id)
+	if err == nil {
+		err = client.doJSON(ctx, http.MethodDelete, "/containers/"+containerID, query, nil, nil)
+	}
+	if ignore, _ := params["ignore_missing"].(bool); ignore && isNotFound(err) {
+		removed, err = false, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove container %s: %w", params["container"], err)
+	}
+
+	message := fmt.Sprintf("Container %s removed", params["container"])
+	if !removed {
+		message = fmt.Sprintf("Container %s does not exist", params["container"])
+	}
+	return map[string]interface{}{
+		"container_id": containerID,
+		"removed":      removed,
+		"success":      true,
+		"message":      message,
+	}, nil
+}
+
+func (p *DockerPlugin) executeLogs(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	id, err := containerParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"tail": {"all"}}
+	if tail := intParam(params, "tail", -1); tail >= 0 {
+		query.Set("tail", strconv.Itoa(tail))
+	}
+	now := time.Now()
+	for _, name := range []string{"since", "until"} {
+		value, _ := params[name].(string)
+		ts, err := logTime(value, now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if ts != "" {
+			query.Set(name, ts)
+		}
+	}
+	if timestamps, _ := params["timestamps"].(bool); timestamps {
+		query.Set("timestamps", "1")
+	}
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := client.inspectContainer(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %w", params["container"], err)
+	}
+	stdout, stderr, err := client.containerLogs(ctx, id, query, info.Config.Tty)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"container_id": info.ID,
+		"stdout":       stdout,
+		"stderr":       stderr,
+		"lines":        countLines(stdout) + countLines(stderr),
+		"message":      fmt.Sprintf("Fetched logs for container %s", params["container"]),
+	}, nil
+}
+
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	n := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
+
+func (p *DockerPlugin) executeExec(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	id, err := containerParam(params)
+	if err != nil {
+		return nil, err
+	}
+	cmd := commandList(params, "command")
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("command parameter is required")
+	}
+
+	config := map[string]interface{}{
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Cmd":          cmd,
+		"Env":          envList(stringMap(params, "environment")),
+	}
+	if workdir, ok := params["workdir"].(string); ok && workdir != "" {
+		config["WorkingDir"] = workdir
+	}
+	if user, ok := params["user"].(string); ok && user != "" {
+		config["User"] = user
+	}
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := client.doJSON(ctx, http.MethodPost, "/containers/"+id+"/exec", nil, config, &created); err != nil {
+		return nil, fmt.Errorf("failed to create exec in container %s: %w", params["container"], err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"Detach": false, "Tty": false})
+	resp, err := client.request(ctx, http.MethodPost, "/exec/"+created.ID+"/start", nil,
+		bytes.NewReader(body), http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start exec in container %s: %w", params["container"], err)
+	}
+	var stdout, stderr bytes.Buffer
+	err = demuxStream(resp.Body, &stdout, &stderr)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	var inspect struct {
+		ExitCode int64 `json:"ExitCode"`
+		Running  bool  `json:"Running"`
+	}
+	if err := client.doJSON(ctx, http.MethodGet, "/exec/"+created.ID+"/json", nil, nil, &inspect); err != nil {
+		return nil, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+
+	return map[string]interface{}{
+		"exec_id":   created.ID,
+		"stdout":    stdout.String(),
+		"stderr":    stderr.String(),
+		"exit_code": inspect.ExitCode,
+		"success":   inspect.ExitCode == 0,
+		"message":   fmt.Sprintf("Command exited with code %d in container %s", inspect.ExitCode, params["container"]),
+	}, nil
+}
+
+func (p *DockerPlugin) executeInspect(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	id, err := containerParam(params)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	info, raw, err := client.inspectContainer(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %w", params["container"], err)
+	}
+
+	return map[string]interface{}{
+		"container_id": info.ID,
+		"name":         strings.TrimPrefix(info.Name, "/"),
+		"image":        info.Config.Image,
+		"image_id":     info.Image,
+		"status":       info.State.Status,
+		"running":      info.State.Running,
+		"exit_code":    info.State.ExitCode,
+		"error":        info.State.Error,
+		"started_at":   info.State.StartedAt,
+		"finished_at":  info.State.FinishedAt,
+		"health":       info.health(),
+		"ip_address":   info.ipAddress(),
+		"details":      raw,
+		"message":      fmt.Sprintf("Container %s is %s", params["container"], info.State.Status),
+	}, nil
+}
+
+func (p *DockerPlugin) executeWait(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	id, err := containerParam(params)
+	if err != nil {
+		return nil, err
+	}
+	condition := "not-running"
+	if c, ok := params["condition"].(string); ok && c != "" {
+		condition = c
+	}
+	if condition != "not-running" && condition != "next-exit" && condition != "removed" {
+		return nil, fmt.Errorf("condition must be not-running, next-exit or removed")
+	}
+
+	if timeout := intParam(params, "timeout", 0); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	containerID, err := client.containerID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for container %s: %w", params["container"], err)
+	}
+	exitCode, err := client.waitContainer(ctx, containerID, condition)
+	if err == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out waiting for container %s", params["container"])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for container %s: %w", params["container"], err)
+	}
+
+	return map[string]interface{}{
+		"container_id": containerID,
+		"exit_code":    exitCode,
+		"success":      exitCode == 0,
+		"message":      fmt.Sprintf("Container %s exited with code %d", params["container"], exitCode),
+	}, nil
+}
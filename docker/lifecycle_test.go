@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLifecycleReturnsFullContainerID(t *testing.T) {
+	for _, action := range []string{"stop", "start", "restart", "rm", "wait"} {
+		for _, ref := range []string{"web", fakeContainerID[:12]} {
+			d := newFakeDaemon(t)
+			out, err := (&DockerPlugin{}).Execute(context.Background(), action, d.params(map[string]interface{}{"container": ref}))
+			if err != nil {
+				t.Fatalf("%s %s: %v", action, ref, err)
+			}
+			if out["container_id"] != fakeContainerID {
+				t.Errorf("%s %s: container_id = %v, want %s", action, ref, out["container_id"], fakeContainerID)
+			}
+
+			// Later calls address the container by the resolved ID.
+			requests := d.seen()
+			if requests[0] != "GET /containers/"+ref+"/json" {
+				t.Errorf("%s %s: first request = %s, want an inspect", action, ref, requests[0])
+			}
+			for _, r := range requests[1:] {
+				if !strings.Contains(r, fakeContainerID) {
+					t.Errorf("%s %s: request %s does not use the full ID", action, ref, r)
+				}
+			}
+		}
+	}
+}
+
+func TestRemoveIgnoreMissing(t *testing.T) {
+	d := newFakeDaemon(t)
+	out, err := (&DockerPlugin{}).Execute(context.Background(), "rm", d.params(map[string]interface{}{
+		"container":      "gone",
+		"ignore_missing": true,
+	}))
+	if err != nil {
+		t.Fatalf("rm: %v", err)
+	}
+	if out["removed"] != false || out["container_id"] != "" {
+		t.Errorf("rm = %v, want removed false and an empty container_id", out)
+	}
+	if got := d.seen(); !reflect.DeepEqual(got, []string{"GET /containers/gone/json"}) {
+		t.Errorf("requests = %v", got)
+	}
+
+	_, err = (&DockerPlugin{}).Execute(context.Background(), "stop", d.params(map[string]interface{}{"container": "gone"}))
+	if err == nil || !strings.Contains(err.Error(), "No such container: gone") {
+		t.Errorf("stop missing: error = %v", err)
+	}
+}
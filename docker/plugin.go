@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
-	
+	"net/http"
+	"net/url"
+
 	"github.com/corynth/corynth-dist/pkg/plugin"
 )
 
@@ -21,11 +23,11 @@ func (p *DockerPlugin) Metadata() plugin.Metadata {
 }
 
 func (p *DockerPlugin) Actions() []plugin.Action {
-	return []plugin.Action{
+	actions := []plugin.Action{
 		{
 			Name:        "run",
 			Description: "Run a Docker container",
-			Inputs: map[string]plugin.InputSpec{
+			Inputs: withHostInput(map[string]plugin.InputSpec{
 				"image": {
 					Type:        "string",
 					Description: "Docker image name",
@@ -46,18 +48,45 @@ func (p *DockerPlugin) Actions() []plugin.Action {
 					Description: "Environment variables",
 					Required:    false,
 				},
+				"volumes": {
+					Type:        "array",
+					Description: "Volume mounts (e.g., ['/data:/var/lib/data:ro', 'cache:/cache'])",
+					Required:    false,
+				},
+				"command": {
+					Type:        "array",
+					Description: "Command to run instead of the image default",
+					Required:    false,
+				},
+				"network": {
+					Type:        "string",
+					Description: "Network to connect the container to",
+					Required:    false,
+				},
 				"detached": {
 					Type:        "boolean",
-					Description: "Run in detached mode",
+					Description: "Run in detached mode; otherwise wait for the container to exit and capture its output",
 					Required:    false,
 					Default:     true,
 				},
-			},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"container_id": {
 					Type:        "string",
 					Description: "Container ID",
 				},
+				"exit_code": {
+					Type:        "number",
+					Description: "Exit code (attached runs only)",
+				},
+				"stdout": {
+					Type:        "string",
+					Description: "Container stdout (attached runs only)",
+				},
+				"stderr": {
+					Type:        "string",
+					Description: "Container stderr (attached runs only)",
+				},
 				"success": {
 					Type:        "boolean",
 					Description: "Whether the operation succeeded",
@@ -67,7 +96,7 @@ func (p *DockerPlugin) Actions() []plugin.Action {
 		{
 			Name:        "build",
 			Description: "Build a Docker image",
-			Inputs: map[string]plugin.InputSpec{
+			Inputs: withHostInput(map[string]plugin.InputSpec{
 				"context": {
 					Type:        "string",
 					Description: "Build context directory",
@@ -76,7 +105,7 @@ func (p *DockerPlugin) Actions() []plugin.Action {
 				},
 				"dockerfile": {
 					Type:        "string",
-					Description: "Path to Dockerfile",
+					Description: "Path to the Dockerfile, relative to context rather than to the working directory as with docker build -f. An absolute path may point outside the context",
 					Required:    false,
 					Default:     "Dockerfile",
 				},
@@ -85,12 +114,66 @@ func (p *DockerPlugin) Actions() []plugin.Action {
 					Description: "Image tag",
 					Required:    true,
 				},
-			},
+				"tags": {
+					Type:        "array",
+					Description: "Additional image tags",
+					Required:    false,
+				},
+				"build_args": {
+					Type:        "object",
+					Description: "Build-time variables (ARG values)",
+					Required:    false,
+				},
+				"target": {
+					Type:        "string",
+					Description: "Build stage to stop at in a multi-stage Dockerfile",
+					Required:    false,
+				},
+				"labels": {
+					Type:        "object",
+					Description: "Labels to set on the image",
+					Required:    false,
+				},
+				"platform": {
+					Type:        "string",
+					Description: "Target platform, e.g. linux/amd64",
+					Required:    false,
+				},
+				"cache_from": {
+					Type:        "array",
+					Description: "Images to use as cache sources",
+					Required:    false,
+				},
+				"no_cache": {
+					Type:        "boolean",
+					Description: "Do not use the build cache",
+					Required:    false,
+					Default:     false,
+				},
+				"pull": {
+					Type:        "boolean",
+					Description: "Always pull newer versions of base images",
+					Required:    false,
+					Default:     false,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"image_id": {
 					Type:        "string",
 					Description: "Built image ID",
 				},
+				"digest": {
+					Type:        "string",
+					Description: "Content digest of the built image configuration (sha256:...), the same value as image_id. The registry manifest digest is only known after push",
+				},
+				"tag": {
+					Type:        "string",
+					Description: "Image tag",
+				},
+				"log": {
+					Type:        "string",
+					Description: "Build output",
+				},
 				"success": {
 					Type:        "boolean",
 					Description: "Whether the build succeeded",
@@ -100,22 +183,44 @@ func (p *DockerPlugin) Actions() []plugin.Action {
 		{
 			Name:        "ps",
 			Description: "List Docker containers",
-			Inputs: map[string]plugin.InputSpec{
+			Inputs: withHostInput(map[string]plugin.InputSpec{
 				"all": {
 					Type:        "boolean",
 					Description: "Show all containers (including stopped)",
 					Required:    false,
 					Default:     false,
 				},
-			},
+				"filters": {
+					Type:        "object",
+					Description: "Filters such as {name = \"web\", status = \"running\", label = [\"env=prod\"]}",
+					Required:    false,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"containers": {
 					Type:        "array",
 					Description: "List of containers",
 				},
+				"count": {
+					Type:        "number",
+					Description: "Number of containers listed",
+				},
 			},
 		},
 	}
+	actions = append(actions, lifecycleActions()...)
+	actions = append(actions, imageActions()...)
+	return append(actions, composeActions()...)
+}
+
+// withHostInput adds the daemon address accepted by every action.
+func withHostInput(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["host"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Docker daemon address (default: DOCKER_HOST or unix:///var/run/docker.sock)",
+		Required:    false,
+	}
+	return inputs
 }
 
 func (p *DockerPlugin) Validate(params map[string]interface{}) error {
@@ -130,6 +235,38 @@ func (p *DockerPlugin) Execute(ctx context.Context, action string, params map[st
 		return p.executeBuild(ctx, params)
 	case "ps":
 		return p.executePS(ctx, params)
+	case "stop":
+		return p.executeStop(ctx, params)
+	case "start":
+		return p.executeStart(ctx, params)
+	case "restart":
+		return p.executeRestart(ctx, params)
+	case "rm":
+		return p.executeRemove(ctx, params)
+	case "logs":
+		return p.executeLogs(ctx, params)
+	case "exec":
+		return p.executeExec(ctx, params)
+	case "inspect":
+		return p.executeInspect(ctx, params)
+	case "wait":
+		return p.executeWait(ctx, params)
+	case "pull":
+		return p.executePull(ctx, params)
+	case "push":
+		return p.executePush(ctx, params)
+	case "tag":
+		return p.executeTag(ctx, params)
+	case "login":
+		return p.executeLogin(ctx, params)
+	case "images":
+		return p.executeImages(ctx, params)
+	case "image_prune":
+		return p.executeImagePrune(ctx, params)
+	case "compose_up":
+		return p.executeComposeUp(ctx, params)
+	case "compose_down":
+		return p.executeComposeDown(ctx, params)
 	default:
 		return nil, fmt.Errorf("unknown action: %s", action)
 	}
@@ -140,16 +277,77 @@ func (p *DockerPlugin) executeRun(ctx context.Context, params map[string]interfa
 	if !ok || image == "" {
 		return nil, fmt.Errorf("image parameter is required")
 	}
+	name, _ := params["name"].(string)
 
-	// In production, this would execute: docker run [options] image
-	containerID := fmt.Sprintf("container_%s_123", image)
-	
-	return map[string]interface{}{
-		"container_id": containerID,
-		"success":      true,
+	detached := true
+	if d, ok := params["detached"].(bool); ok {
+		detached = d
+	}
+
+	exposed, bindings, err := parsePorts(stringList(params, "ports"))
+	if err != nil {
+		return nil, err
+	}
+	binds, anonymous := parseVolumes(stringList(params, "volumes"))
+
+	hostConfig := map[string]interface{}{
+		"PortBindings": bindings,
+		"Binds":        binds,
+	}
+	if network, ok := params["network"].(string); ok && network != "" {
+		hostConfig["NetworkMode"] = network
+	}
+	config := map[string]interface{}{
+		"Image":        image,
+		"Env":          envList(stringMap(params, "environment")),
+		"ExposedPorts": exposed,
+		"Volumes":      anonymous,
+		"HostConfig":   hostConfig,
+	}
+	if cmd := commandList(params, "command"); len(cmd) > 0 {
+		config["Cmd"] = cmd
+	}
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, warnings, err := client.createContainer(ctx, name, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.doJSON(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to start container %s: %w", shortID(id), err)
+	}
+
+	result := map[string]interface{}{
+		"container_id": id,
+		"short_id":     shortID(id),
+		"name":         name,
 		"image":        image,
-		"message":      fmt.Sprintf("Container started from image %s", image),
-	}, nil
+		"warnings":     warnings,
+		"success":      true,
+		"message":      fmt.Sprintf("Container %s started from image %s", shortID(id), image),
+	}
+	if detached {
+		return result, nil
+	}
+
+	exitCode, err := client.waitContainer(ctx, id, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for container %s: %w", shortID(id), err)
+	}
+	stdout, stderr, err := client.containerLogs(ctx, id, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	result["exit_code"] = exitCode
+	result["stdout"] = stdout
+	result["stderr"] = stderr
+	result["success"] = exitCode == 0
+	result["message"] = fmt.Sprintf("Container %s from image %s exited with code %d", shortID(id), image, exitCode)
+	return result, nil
 }
 
 func (p *DockerPlugin) executeBuild(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
@@ -158,20 +356,47 @@ func (p *DockerPlugin) executeBuild(ctx context.Context, params map[string]inter
 		return nil, fmt.Errorf("tag parameter is required")
 	}
 
-	context := "."
-	if c, ok := params["context"].(string); ok {
-		context = c
+	contextDir := "."
+	if c, ok := params["context"].(string); ok && c != "" {
+		contextDir = c
+	}
+	dockerfile, _ := params["dockerfile"].(string)
+
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	noCache, _ := params["no_cache"].(bool)
+	pull, _ := params["pull"].(bool)
+	target, _ := params["target"].(string)
+	platform, _ := params["platform"].(string)
+
+	result, err := client.buildImage(ctx, buildOptions{
+		contextDir: contextDir,
+		dockerfile: dockerfile,
+		tags:       append([]string{tag}, stringList(params, "tags")...),
+		buildArgs:  stringMap(params, "build_args"),
+		target:     target,
+		labels:     stringMap(params, "labels"),
+		platform:   platform,
+		cacheFrom:  stringList(params, "cache_from"),
+		noCache:    noCache,
+		pull:       pull,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// In production, this would execute: docker build -t tag context
-	imageID := fmt.Sprintf("image_%s_456", tag)
-	
 	return map[string]interface{}{
-		"image_id": imageID,
+		"image_id": result.imageID,
+		"short_id": shortID(result.imageID),
+		"digest":   result.imageID,
+		"log":      result.log,
 		"success":  true,
 		"tag":      tag,
-		"context":  context,
-		"message":  fmt.Sprintf("Image built with tag %s from context %s", tag, context),
+		"context":  contextDir,
+		"message":  fmt.Sprintf("Image %s built with tag %s from context %s", shortID(result.imageID), tag, contextDir),
 	}, nil
 }
 
@@ -181,37 +406,39 @@ func (p *DockerPlugin) executePS(ctx context.Context, params map[string]interfac
 		all = a
 	}
 
-	// In production, this would execute: docker ps [--all]
-	containers := []map[string]interface{}{
-		{
-			"id":     "abc123",
-			"image":  "nginx:latest",
-			"status": "running",
-			"name":   "web-server",
-		},
-		{
-			"id":     "def456", 
-			"image":  "redis:alpine",
-			"status": "running",
-			"name":   "cache",
-		},
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	filters, err := filtersParam(params, nil)
+	if err != nil {
+		return nil, err
+	}
+	if filters != "" {
+		query.Set("filters", filters)
 	}
 
-	if all {
-		containers = append(containers, map[string]interface{}{
-			"id":     "ghi789",
-			"image":  "mysql:5.7",
-			"status": "exited",
-			"name":   "database",
-		})
+	client, err := newClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []containerSummary
+	if err := client.doJSON(ctx, http.MethodGet, "/containers/json", query, nil, &summaries); err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	containers := make([]map[string]interface{}, 0, len(summaries))
+	for _, s := range summaries {
+		containers = append(containers, s.toMap())
 	}
 
 	return map[string]interface{}{
 		"containers": containers,
 		"count":      len(containers),
 		"all":        all,
-		"message":    "Docker containers listed",
+		"message":    fmt.Sprintf("Listed %d Docker containers", len(containers)),
 	}, nil
 }
 
-var ExportedPlugin plugin.Plugin = &DockerPlugin{}
\ No newline at end of file
+var ExportedPlugin plugin.Plugin = &DockerPlugin{}
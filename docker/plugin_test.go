@@ -0,0 +1,527 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	fakeContainerID = "4f66ad9a0b2e3c1d5e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d"
+	fakeImageID     = "sha256:9b8f2d7c6e5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b9c"
+)
+
+// fakeDaemon is an httptest stand-in for the Docker Engine API. It records
+// every request and serves just the endpoints the tests exercise.
+type fakeDaemon struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	create   map[string]interface{}
+	name     string
+	build    *http.Request
+	context  map[string]string
+
+	imageMissing bool
+	noAux        bool
+	exitCode     int
+
+	// registryAuth and query are the X-Registry-Auth header and query of
+	// the last registry request: pull, push, tag, login or prune.
+	registryAuth string
+	query        url.Values
+	login        authConfig
+	// streamError fails a pull or push part way through its progress stream.
+	streamError   string
+	identityToken string
+}
+
+func newFakeDaemon(t *testing.T) *fakeDaemon {
+	t.Helper()
+	// Keep the host's docker configuration out of the tests.
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+
+	d := &fakeDaemon{}
+	d.Server = httptest.NewServer(http.HandlerFunc(d.serve))
+	t.Cleanup(d.Close)
+	return d
+}
+
+func (d *fakeDaemon) params(extra map[string]interface{}) map[string]interface{} {
+	params := map[string]interface{}{"host": d.URL}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return params
+}
+
+func (d *fakeDaemon) seen() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.requests...)
+}
+
+func (d *fakeDaemon) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	d.mu.Lock()
+	d.requests = append(d.requests, r.Method+" "+path)
+	if path == "/images/create" || path == "/auth" || strings.HasPrefix(path, "/images/") && (strings.HasSuffix(path, "/push") || strings.HasSuffix(path, "/tag") || path == "/images/prune") {
+		d.registryAuth = r.Header.Get("X-Registry-Auth")
+		d.query = r.URL.Query()
+	}
+	streamError := d.streamError
+	d.mu.Unlock()
+
+	writeJSON := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(status int, msg string) {
+		w.WriteHeader(status)
+		writeJSON(map[string]string{"message": msg})
+	}
+
+	switch {
+	case r.Method == http.MethodPost && path == "/containers/create":
+		d.mu.Lock()
+		missing := d.imageMissing
+		d.mu.Unlock()
+		if missing {
+			fail(http.StatusNotFound, "No such image: "+r.URL.Query().Get("fromImage"))
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		d.mu.Lock()
+		d.create = body
+		d.name = r.URL.Query().Get("name")
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(map[string]interface{}{"Id": fakeContainerID, "Warnings": []string{}})
+
+	case r.Method == http.MethodPost && path == "/images/create":
+		d.mu.Lock()
+		d.imageMissing = false
+		d.mu.Unlock()
+		writeJSON(map[string]string{"status": "Pulling from library/nginx", "id": "latest"})
+		writeJSON(map[string]string{"status": "Pulling fs layer", "id": "a1b2"})
+		writeJSON(map[string]string{"status": "Already exists", "id": "c3d4"})
+		if streamError != "" {
+			writeJSON(map[string]interface{}{"error": streamError, "errorDetail": map[string]string{"message": streamError}})
+			return
+		}
+		writeJSON(map[string]string{"status": "Pull complete", "id": "a1b2"})
+		writeJSON(map[string]string{"status": "Digest: sha256:0123"})
+		writeJSON(map[string]string{"status": "Status: Downloaded newer image for nginx:latest"})
+
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/push"):
+		writeJSON(map[string]string{"status": "The push refers to repository [registry.example.com/team/app]"})
+		writeJSON(map[string]string{"status": "Preparing", "id": "a1b2"})
+		writeJSON(map[string]string{"status": "Preparing", "id": "c3d4"})
+		writeJSON(map[string]string{"status": "Preparing", "id": "e5f6"})
+		if streamError != "" {
+			writeJSON(map[string]interface{}{"error": streamError, "errorDetail": map[string]string{"message": streamError}})
+			return
+		}
+		writeJSON(map[string]string{"status": "Pushed", "id": "a1b2"})
+		writeJSON(map[string]string{"status": "Layer already exists", "id": "c3d4"})
+		writeJSON(map[string]string{"status": "Mounted from library/alpine", "id": "e5f6"})
+		writeJSON(map[string]string{"status": "1.0: digest: sha256:4567 size: 1234"})
+		writeJSON(map[string]interface{}{"aux": map[string]interface{}{"Tag": "1.0", "Digest": "sha256:4567", "Size": 1234}})
+
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/tag"):
+		if path != "/images/nginx:1.25/tag" {
+			fail(http.StatusNotFound, "No such image: "+strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/tag"))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodPost && path == "/auth":
+		var auth authConfig
+		if err := json.NewDecoder(r.Body).Decode(&auth); err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		d.mu.Lock()
+		d.login = auth
+		token := d.identityToken
+		d.mu.Unlock()
+		if auth.Password != "s3cret" && auth.IdentityToken == "" {
+			fail(http.StatusUnauthorized, "Get \"https://"+auth.ServerAddress+"/v2/\": unauthorized: incorrect username or password")
+			return
+		}
+		writeJSON(map[string]string{"Status": "Login Succeeded", "IdentityToken": token})
+
+	case r.Method == http.MethodPost && path == "/images/prune":
+		writeJSON(map[string]interface{}{
+			"ImagesDeleted": []map[string]string{
+				{"Untagged": "nginx:1.24"},
+				{"Deleted": "sha256:aaaa"},
+				{"Deleted": "sha256:bbbb"},
+			},
+			"SpaceReclaimed": 2048,
+		})
+
+	case r.Method == http.MethodPost && path == "/containers/"+fakeContainerID+"/start":
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && path != "/containers/json" && strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/json"):
+		ref := strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/json")
+		if ref != "web" && !strings.HasPrefix(fakeContainerID, ref) {
+			fail(http.StatusNotFound, "No such container: "+ref)
+			return
+		}
+		writeJSON(map[string]interface{}{"Id": fakeContainerID, "Name": "/web", "State": map[string]interface{}{"Status": "running", "Running": true}})
+
+	case r.Method == http.MethodPost && (path == "/containers/"+fakeContainerID+"/stop" || path == "/containers/"+fakeContainerID+"/restart"),
+		r.Method == http.MethodDelete && path == "/containers/"+fakeContainerID:
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPost && path == "/containers/"+fakeContainerID+"/wait":
+		writeJSON(map[string]interface{}{"StatusCode": d.exitCode})
+
+	case r.Method == http.MethodGet && path == "/containers/"+fakeContainerID+"/logs":
+		writeFrame(w, 1, "hello from stdout\n")
+		writeFrame(w, 2, "warning on stderr\n")
+
+	case r.Method == http.MethodGet && path == "/containers/json":
+		writeJSON([]map[string]interface{}{{
+			"Id":      fakeContainerID,
+			"Names":   []string{"/web"},
+			"Image":   "nginx:1.25",
+			"ImageID": fakeImageID,
+			"State":   "running",
+			"Status":  "Up 2 minutes",
+			"Ports":   []map[string]interface{}{{"IP": "0.0.0.0", "PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"}},
+		}})
+
+	case r.Method == http.MethodPost && path == "/build":
+		files := map[string]string{}
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				fail(http.StatusBadRequest, err.Error())
+				return
+			}
+			data, _ := io.ReadAll(tr)
+			files[hdr.Name] = string(data)
+		}
+		d.mu.Lock()
+		d.build = r
+		d.context = files
+		noAux := d.noAux
+		d.mu.Unlock()
+		writeJSON(map[string]string{"stream": "Step 1/2 : FROM alpine:3.19\n"})
+		writeJSON(map[string]string{"stream": "Step 2/2 : COPY app /app\n"})
+		if !noAux {
+			writeJSON(map[string]interface{}{"aux": map[string]string{"ID": fakeImageID}})
+		}
+		writeJSON(map[string]string{"stream": "Successfully built 9b8f2d7c6e5a\n"})
+
+	case r.Method == http.MethodGet && path == "/images/example/app:1.0/json":
+		writeJSON(map[string]string{"Id": fakeImageID})
+
+	default:
+		fail(http.StatusNotFound, "page not found: "+r.Method+" "+path)
+	}
+}
+
+// writeFrame writes one frame of the multiplexed stdout/stderr stream.
+func writeFrame(w io.Writer, stream byte, payload string) {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	w.Write(header)
+	io.WriteString(w, payload)
+}
+
+func TestRunDetachedCreateBody(t *testing.T) {
+	d := newFakeDaemon(t)
+	out, err := (&DockerPlugin{}).Execute(context.Background(), "run", d.params(map[string]interface{}{
+		"image":       "nginx:1.25",
+		"name":        "web",
+		"ports":       []interface{}{"8080:80", "127.0.0.1:5353:53/udp", "9000"},
+		"environment": map[string]interface{}{"MODE": "production", "WORKERS": float64(4)},
+		"volumes":     []interface{}{"/srv/www:/usr/share/nginx/html:ro", "/var/cache/nginx"},
+		"command":     "nginx -g daemon-off",
+		"network":     "frontend",
+	}))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if out["container_id"] != fakeContainerID || out["short_id"] != fakeContainerID[:12] {
+		t.Errorf("ids = %v / %v, want the daemon's ID", out["container_id"], out["short_id"])
+	}
+	if _, waited := out["exit_code"]; waited {
+		t.Error("detached run waited for the container")
+	}
+	if d.name != "web" {
+		t.Errorf("name query = %q, want web", d.name)
+	}
+
+	body := d.create
+	if body["Image"] != "nginx:1.25" {
+		t.Errorf("Image = %v", body["Image"])
+	}
+	if got := body["Env"]; !reflect.DeepEqual(got, []interface{}{"MODE=production", "WORKERS=4"}) {
+		t.Errorf("Env = %v", got)
+	}
+	if got := body["Cmd"]; !reflect.DeepEqual(got, []interface{}{"nginx", "-g", "daemon-off"}) {
+		t.Errorf("Cmd = %v", got)
+	}
+	if got := sortedKeys(body["ExposedPorts"]); !reflect.DeepEqual(got, []string{"53/udp", "80/tcp", "9000/tcp"}) {
+		t.Errorf("ExposedPorts = %v", got)
+	}
+	if got := sortedKeys(body["Volumes"]); !reflect.DeepEqual(got, []string{"/var/cache/nginx"}) {
+		t.Errorf("Volumes = %v", got)
+	}
+
+	host, _ := body["HostConfig"].(map[string]interface{})
+	wantBindings := map[string]interface{}{
+		"80/tcp": []interface{}{map[string]interface{}{"HostIp": "", "HostPort": "8080"}},
+		"53/udp": []interface{}{map[string]interface{}{"HostIp": "127.0.0.1", "HostPort": "5353"}},
+	}
+	if !reflect.DeepEqual(host["PortBindings"], wantBindings) {
+		t.Errorf("PortBindings = %v, want %v", host["PortBindings"], wantBindings)
+	}
+	if got := host["Binds"]; !reflect.DeepEqual(got, []interface{}{"/srv/www:/usr/share/nginx/html:ro"}) {
+		t.Errorf("Binds = %v", got)
+	}
+	if host["NetworkMode"] != "frontend" {
+		t.Errorf("NetworkMode = %v", host["NetworkMode"])
+	}
+
+	want := []string{
+		"POST /containers/create",
+		"POST /containers/" + fakeContainerID + "/start",
+	}
+	if got := d.seen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestRunAttachedWaitsAndCollectsLogs(t *testing.T) {
+	d := newFakeDaemon(t)
+	d.exitCode = 3
+	out, err := (&DockerPlugin{}).Execute(context.Background(), "run", d.params(map[string]interface{}{
+		"image":    "alpine:3.19",
+		"command":  []interface{}{"sh", "-c", "exit 3"},
+		"detached": false,
+	}))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if out["exit_code"] != int64(3) || out["success"] != false {
+		t.Errorf("exit_code = %v, success = %v; want 3, false", out["exit_code"], out["success"])
+	}
+	if out["stdout"] != "hello from stdout\n" || out["stderr"] != "warning on stderr\n" {
+		t.Errorf("stdout = %q, stderr = %q", out["stdout"], out["stderr"])
+	}
+	if out["container_id"] != fakeContainerID {
+		t.Errorf("container_id = %v", out["container_id"])
+	}
+	if d.name != "" {
+		t.Errorf("unnamed run sent name %q", d.name)
+	}
+}
+
+func TestRunPullsMissingImage(t *testing.T) {
+	d := newFakeDaemon(t)
+	d.imageMissing = true
+	out, err := (&DockerPlugin{}).Execute(context.Background(), "run", d.params(map[string]interface{}{
+		"image": "nginx",
+	}))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if out["container_id"] != fakeContainerID {
+		t.Errorf("container_id = %v", out["container_id"])
+	}
+	want := []string{
+		"POST /containers/create",
+		"POST /images/create",
+		"POST /containers/create",
+		"POST /containers/" + fakeContainerID + "/start",
+	}
+	if got := d.seen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestRunRejectsInvalidPorts(t *testing.T) {
+	d := newFakeDaemon(t)
+	_, err := (&DockerPlugin{}).Execute(context.Background(), "run", d.params(map[string]interface{}{
+		"image": "nginx",
+		"ports": []interface{}{"http:80"},
+	}))
+	if err == nil || !strings.Contains(err.Error(), "invalid host port") {
+		t.Errorf("error = %v, want invalid host port", err)
+	}
+	if got := d.seen(); len(got) != 0 {
+		t.Errorf("invalid input reached the daemon: %v", got)
+	}
+}
+
+func TestPS(t *testing.T) {
+	d := newFakeDaemon(t)
+	out, err := (&DockerPlugin{}).Execute(context.Background(), "ps", d.params(nil))
+	if err != nil {
+		t.Fatalf("ps: %v", err)
+	}
+	containers := out["containers"].([]map[string]interface{})
+	if len(containers) != 1 || out["count"] != 1 {
+		t.Fatalf("containers = %v", containers)
+	}
+	c := containers[0]
+	if c["id"] != fakeContainerID || c["name"] != "web" || c["state"] != "running" {
+		t.Errorf("container = %v", c)
+	}
+	if got := c["ports"]; !reflect.DeepEqual(got, []string{"0.0.0.0:8080->80/tcp"}) {
+		t.Errorf("ports = %v", got)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	d := newFakeDaemon(t)
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":    "FROM alpine:3.19\nCOPY app /app\n",
+		"app":           "#!/bin/sh\necho hi\n",
+		".dockerignore": "secrets.env\n",
+		"secrets.env":   "TOKEN=x\n",
+	})
+
+	out, err := (&DockerPlugin{}).Execute(context.Background(), "build", d.params(map[string]interface{}{
+		"tag":        "example/app:1.0",
+		"tags":       []interface{}{"example/app:latest"},
+		"context":    dir,
+		"build_args": map[string]interface{}{"VERSION": "1.0"},
+		"target":     "runtime",
+	}))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if out["image_id"] != fakeImageID || out["short_id"] != fakeImageID[7:19] {
+		t.Errorf("image_id = %v, short_id = %v", out["image_id"], out["short_id"])
+	}
+	if out["digest"] != fakeImageID {
+		t.Errorf("digest = %v, want the image ID from the aux message", out["digest"])
+	}
+	if !strings.Contains(out["log"].(string), "Step 2/2 : COPY app /app") {
+		t.Errorf("log = %q", out["log"])
+	}
+
+	q := d.build.URL.Query()
+	if got := q["t"]; !reflect.DeepEqual(got, []string{"example/app:1.0", "example/app:latest"}) {
+		t.Errorf("t = %v", got)
+	}
+	if q.Get("buildargs") != `{"VERSION":"1.0"}` || q.Get("target") != "runtime" || q.Get("dockerfile") != "Dockerfile" {
+		t.Errorf("query = %v", q)
+	}
+	if d.context["Dockerfile"] == "" || d.context["app"] == "" {
+		t.Errorf("context is missing files: %v", keys(d.context))
+	}
+	if _, ok := d.context["secrets.env"]; ok {
+		t.Error(".dockerignore'd file was sent to the daemon")
+	}
+}
+
+func TestBuildWithoutAuxInspectsTag(t *testing.T) {
+	d := newFakeDaemon(t)
+	d.noAux = true
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM alpine:3.19\n"})
+
+	out, err := (&DockerPlugin{}).Execute(context.Background(), "build", d.params(map[string]interface{}{
+		"tag":     "example/app:1.0",
+		"context": dir,
+	}))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if out["image_id"] != fakeImageID || out["digest"] != fakeImageID {
+		t.Errorf("image_id = %v, digest = %v, want the inspected ID", out["image_id"], out["digest"])
+	}
+}
+
+func TestDaemonErrorMessage(t *testing.T) {
+	d := newFakeDaemon(t)
+	c, err := newClient(d.params(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.doJSON(context.Background(), http.MethodGet, "/nope", nil, nil, nil)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.status != http.StatusNotFound || !strings.Contains(apiErr.message, "page not found") {
+		t.Errorf("error = %#v", err)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func sortedKeys(v interface{}) []string {
+	m, _ := v.(map[string]interface{})
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func keys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestResolveDockerfile(t *testing.T) {
+	tests := []struct {
+		dockerfile, name, external string
+	}{
+		{"", "Dockerfile", ""},
+		{"docker/Dockerfile", "docker/Dockerfile", ""},
+		{"/src/app/build.Dockerfile", "build.Dockerfile", ""},
+		{"../shared/Dockerfile", ".corynth.Dockerfile", "/src/shared/Dockerfile"},
+		{"/opt/Dockerfile", ".corynth.Dockerfile", "/opt/Dockerfile"},
+	}
+	for _, tt := range tests {
+		name, external := resolveDockerfile("/src/app", tt.dockerfile)
+		if name != tt.name || external != tt.external {
+			t.Errorf("resolveDockerfile(%q) = %q, %q; want %q, %q", tt.dockerfile, name, external, tt.name, tt.external)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+workflow "build-and-push" {
+  description = "Build an image, tag it for a registry and push it"
+  version     = "1.0.0"
+
+  variable "registry" {
+    type        = string
+    default     = "registry.example.com"
+    description = "Registry to push to"
+  }
+
+  variable "app_name" {
+    type        = string
+    default     = "webapp"
+    description = "Application name"
+  }
+
+  variable "version" {
+    type        = string
+    default     = "1.0.0"
+    description = "Release version"
+  }
+
+  variable "registry_user" {
+    type        = string
+    default     = ""
+    description = "Registry username (empty uses ~/.docker/config.json)"
+  }
+
+  variable "registry_password" {
+    type        = string
+    default     = ""
+    description = "Registry password or token"
+  }
+
+  step "pull_base_image" {
+    plugin = "docker"
+    action = "pull"
+    
+    params = {
+      image = "nginx:alpine"
+    }
+  }
+
+  step "build_image" {
+    plugin = "docker"
+    action = "build"
+    
+    depends_on = ["pull_base_image"]
+    
+    params = {
+      context = "/tmp/docker-build"
+      dockerfile = "docker/Dockerfile"
+      tag = "${var.app_name}:${var.version}"
+      target = "runtime"
+      platform = "linux/amd64"
+      build_args = {
+        "APP_VERSION" = "${var.version}"
+      }
+      labels = {
+        "org.opencontainers.image.version" = "${var.version}"
+      }
+      cache_from = ["${var.registry}/${var.app_name}:latest"]
+    }
+  }
+
+  step "tag_release" {
+    plugin = "docker"
+    action = "tag"
+    
+    depends_on = ["build_image"]
+    
+    params = {
+      source = "${var.app_name}:${var.version}"
+      target = "${var.registry}/${var.app_name}:${var.version}"
+    }
+  }
+
+  step "registry_login" {
+    plugin = "docker"
+    action = "login"
+    
+    depends_on = ["tag_release"]
+    
+    params = {
+      registry = var.registry
+      username = var.registry_user
+      password = var.registry_password
+    }
+  }
+
+  step "push_release" {
+    plugin = "docker"
+    action = "push"
+    
+    depends_on = ["registry_login"]
+    
+    params = {
+      image = "${var.registry}/${var.app_name}:${var.version}"
+      username = var.registry_user
+      password = var.registry_password
+    }
+  }
+
+  step "prune_dangling" {
+    plugin = "docker"
+    action = "image_prune"
+    
+    depends_on = ["push_release"]
+    
+    params = {
+      until = "24h"
+    }
+  }
+
+  step "report" {
+    plugin = "shell"
+    action = "exec"
+    
+    depends_on = ["prune_dangling"]
+    
+    params = {
+      command = "echo 'Built ${build_image.image_id}; pushed ${var.registry}/${var.app_name}@${push_release.digest} (${push_release.layer_summary.pushed} layers pushed, ${push_release.layer_summary.existing} already present)'"
+    }
+  }
+}
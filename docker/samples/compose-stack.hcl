@@ -0,0 +1,90 @@
+workflow "compose-stack" {
+  description = "Deploy the microservices stack from a compose file"
+  version     = "1.0.0"
+
+  variable "stack_name" {
+    type        = string
+    default     = "myapp"
+    description = "Compose project name"
+  }
+
+  variable "db_password" {
+    type        = string
+    default     = "password123"
+    description = "Database password"
+  }
+
+  step "stack_up" {
+    plugin = "docker"
+    action = "compose_up"
+    
+    params = {
+      file = "samples/microservices/docker-compose.yml"
+      project_name = "${var.stack_name}"
+      env = {
+        "STACK_NAME" = "${var.stack_name}"
+        "DB_PASSWORD" = "${var.db_password}"
+      }
+      wait = true
+      timeout = 180
+    }
+  }
+
+  step "check_cache" {
+    plugin = "docker"
+    action = "exec"
+    
+    depends_on = ["stack_up"]
+    
+    params = {
+      container = "${stack_up.services.cache.container_id}"
+      command = ["redis-cli", "ping"]
+    }
+  }
+
+  step "api_logs" {
+    plugin = "docker"
+    action = "logs"
+    
+    depends_on = ["stack_up"]
+    
+    params = {
+      container = "${stack_up.services.api.container_id}"
+      tail = 50
+    }
+  }
+
+  step "report" {
+    plugin = "shell"
+    action = "script"
+    
+    depends_on = ["check_cache", "api_logs"]
+    
+    params = {
+      script = <<-EOF
+        #!/bin/bash
+        echo "=== Stack ${stack_up.project} ==="
+        echo "Database: ${stack_up.services.db.health}"
+        echo "Cache: ${check_cache.stdout}"
+        echo "API container: ${stack_up.services.api.container_name}"
+      EOF
+    }
+  }
+
+  step "stack_down" {
+    plugin = "docker"
+    action = "compose_down"
+    
+    depends_on = ["report"]
+    
+    params = {
+      file = "samples/microservices/docker-compose.yml"
+      project_name = "${var.stack_name}"
+      env = {
+        "STACK_NAME" = "${var.stack_name}"
+        "DB_PASSWORD" = "${var.db_password}"
+      }
+      volumes = true
+    }
+  }
+}
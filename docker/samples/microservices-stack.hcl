@@ -109,11 +109,60 @@ workflow "microservices-stack" {
     }
   }
 
+  step "inspect_api" {
+    plugin = "docker"
+    action = "inspect"
+    
+    depends_on = ["verify_stack"]
+    
+    params = {
+      container = "${var.stack_name}-api"
+    }
+  }
+
+  step "check_database" {
+    plugin = "docker"
+    action = "exec"
+    
+    depends_on = ["verify_stack"]
+    
+    params = {
+      container = "${var.stack_name}-db"
+      command = ["pg_isready", "-U", "app", "-d", "${var.stack_name}"]
+    }
+  }
+
+  step "check_cache" {
+    plugin = "docker"
+    action = "exec"
+    
+    depends_on = ["verify_stack"]
+    
+    params = {
+      container = "${var.stack_name}-cache"
+      command = ["redis-cli", "ping"]
+    }
+  }
+
+  step "api_logs" {
+    plugin = "docker"
+    action = "logs"
+    
+    depends_on = ["inspect_api"]
+    
+    params = {
+      container = "${var.stack_name}-api"
+      tail = 50
+      since = "10m"
+      timestamps = true
+    }
+  }
+
   step "health_check_stack" {
     plugin = "shell"
     action = "script"
     
-    depends_on = ["verify_stack"]
+    depends_on = ["check_database", "check_cache", "api_logs"]
     
     params = {
       script = <<-EOF
@@ -121,27 +170,75 @@ workflow "microservices-stack" {
         echo "=== Microservices Stack Health Check ==="
         echo "Stack: ${var.stack_name}"
         echo ""
-        
-        echo "Testing database connection..."
-        sleep 2
-        echo "✓ Database ready"
-        
-        echo "Testing cache connection..."
-        sleep 1
-        echo "✓ Redis ready"
-        
-        echo "Testing API service..."
-        sleep 2
-        echo "✓ API service ready"
-        
-        echo "Testing frontend..."
-        sleep 1
-        echo "✓ Frontend ready"
-        
-        echo ""
-        echo "🚀 Microservices stack deployment completed!"
+        echo "Database: exit code ${check_database.exit_code}"
+        echo "Cache: ${check_cache.stdout}"
+        echo "API: ${inspect_api.status} at ${inspect_api.ip_address}"
         echo "Containers running: ${verify_stack.count}"
       EOF
     }
   }
+
+  step "teardown_frontend" {
+    plugin = "docker"
+    action = "rm"
+    
+    depends_on = ["health_check_stack"]
+    
+    params = {
+      container = "${var.stack_name}-web"
+      force = true
+      ignore_missing = true
+    }
+  }
+
+  step "stop_api" {
+    plugin = "docker"
+    action = "stop"
+    
+    depends_on = ["teardown_frontend"]
+    
+    params = {
+      container = "${var.stack_name}-api"
+      timeout = 15
+    }
+  }
+
+  step "teardown_api" {
+    plugin = "docker"
+    action = "rm"
+    
+    depends_on = ["stop_api"]
+    
+    params = {
+      container = "${var.stack_name}-api"
+      ignore_missing = true
+    }
+  }
+
+  step "teardown_cache" {
+    plugin = "docker"
+    action = "rm"
+    
+    depends_on = ["teardown_api"]
+    
+    params = {
+      container = "${var.stack_name}-cache"
+      force = true
+      ignore_missing = true
+    }
+  }
+
+  step "teardown_database" {
+    plugin = "docker"
+    action = "rm"
+    
+    depends_on = ["teardown_cache"]
+    
+    params = {
+      container = "${var.stack_name}-db"
+      force = true
+      volumes = true
+      ignore_missing = true
+    }
+  }
 }
\ No newline at end of file
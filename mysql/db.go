@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// connConfig holds the connection settings shared by every mysql action.
+type connConfig struct {
+	host     string
+	port     int
+	database string
+	user     string
+	password string
+	tls      string
+	timeout  time.Duration
+}
+
+func (c connConfig) addr() string {
+	return net.JoinHostPort(c.host, strconv.Itoa(c.port))
+}
+
+func (c connConfig) String() string {
+	return fmt.Sprintf("%s@%s/%s", c.user, c.addr(), c.database)
+}
+
+func configFromParams(params map[string]interface{}) (connConfig, error) {
+	cfg := connConfig{
+		host:    "localhost",
+		port:    intParam(params, "port", 3306),
+		timeout: time.Duration(intParam(params, "timeout", 10)) * time.Second,
+	}
+	if h, ok := params["host"].(string); ok && h != "" {
+		cfg.host = h
+	}
+
+	database, ok := params["database"].(string)
+	if !ok || database == "" {
+		return cfg, fmt.Errorf("database parameter is required")
+	}
+	cfg.database = database
+
+	user, ok := params["user"].(string)
+	if !ok || user == "" {
+		return cfg, fmt.Errorf("user parameter is required")
+	}
+	cfg.user = user
+
+	cfg.password, _ = params["password"].(string)
+	cfg.tls, _ = params["tls"].(string)
+	return cfg, nil
+}
+
+// openDB opens a connection pool for cfg and verifies the server is reachable.
+func openDB(ctx context.Context, cfg connConfig) (*sql.DB, error) {
+	db, err := newDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", cfg, err)
+	}
+	return db, nil
+}
+
+// newDB builds the connection pool for cfg without connecting. It is a
+// variable so tests can substitute a stand-in driver.
+var newDB = func(cfg connConfig) (*sql.DB, error) {
+	dsn := mysql.NewConfig()
+	dsn.User = cfg.user
+	dsn.Passwd = cfg.password
+	dsn.Net = "tcp"
+	dsn.Addr = cfg.addr()
+	dsn.DBName = cfg.database
+	dsn.Timeout = cfg.timeout
+	if cfg.tls != "" {
+		dsn.TLSConfig = cfg.tls
+	}
+
+	connector, err := mysql.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection settings: %w", err)
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// scanRows reads every row into a column-name to value map, converting each
+// value to its natural Go type based on the column's declared type.
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, []map[string]interface{}, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read column types: %w", err)
+	}
+
+	columns := make([]map[string]interface{}, len(columnTypes))
+	for i, ct := range columnTypes {
+		nullable, _ := ct.Nullable()
+		columns[i] = map[string]interface{}{
+			"name":     ct.Name(),
+			"type":     ct.DatabaseTypeName(),
+			"nullable": nullable,
+		}
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columnTypes))
+		pointers := make([]interface{}, len(columnTypes))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		row := make(map[string]interface{}, len(columnTypes))
+		for i, ct := range columnTypes {
+			row[ct.Name()] = convertValue(values[i], ct.DatabaseTypeName())
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	return result, columns, nil
+}
+
+// convertValue maps a driver value to a JSON-friendly typed value. The text
+// protocol returns every value as []byte, the binary protocol (used for
+// parameterised queries) already returns integers, floats and times.
+func convertValue(value interface{}, dbType string) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float32:
+		return float64(v)
+	case []byte:
+		return convertBytes(v, dbType)
+	default:
+		return v
+	}
+}
+
+func convertBytes(b []byte, dbType string) interface{} {
+	s := string(b)
+	dbType = strings.TrimPrefix(dbType, "UNSIGNED ")
+
+	switch dbType {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n
+		}
+	case "FLOAT", "DOUBLE":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case "DECIMAL":
+		// Kept as a string so no precision is lost; callers can parse it.
+		return s
+	case "BIT":
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n
+	case "JSON":
+		var decoded interface{}
+		if err := json.Unmarshal(b, &decoded); err == nil {
+			return decoded
+		}
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "GEOMETRY":
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return s
+}
+
+// intParam reads a numeric parameter, accepting the float64 values produced
+// by HCL as well as plain ints and numeric strings.
+func intParam(params map[string]interface{}, name string, def int) int {
+	switch v := params[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// bindArgs converts a params array into placeholder arguments. HCL numbers
+// arrive as float64; whole numbers are bound as integers so they compare
+// correctly against integer columns. Objects and arrays are bound as JSON.
+func bindArgs(raw interface{}) ([]interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("params must be an array")
+	}
+
+	args := make([]interface{}, len(list))
+	for i, value := range list {
+		switch v := value.(type) {
+		case float64:
+			if v == float64(int64(v)) {
+				args[i] = int64(v)
+			} else {
+				args[i] = v
+			}
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("params[%d]: %w", i, err)
+			}
+			args[i] = string(encoded)
+		default:
+			args[i] = v
+		}
+	}
+	return args, nil
+}
+
+func isolationLevel(name string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, " ", "_")) {
+	case "read_uncommitted":
+		return sql.LevelReadUncommitted, nil
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+	return sql.LevelDefault, fmt.Errorf("unknown isolation level: %s", name)
+}
+
+// stringList reads a parameter that may be given as a single string or as an
+// array of values.
+func stringList(params map[string]interface{}, name string) []string {
+	switch v := params[name].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	}
+	return nil
+}
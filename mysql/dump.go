@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// dumpOptions controls what a logical dump contains.
+type dumpOptions struct {
+	tables            []string
+	noData            bool
+	singleTransaction bool
+	batchSize         int
+}
+
+// dumpStats summarises a finished dump.
+type dumpStats struct {
+	tables int
+	views  int
+	rows   int64
+}
+
+// countingWriter counts the bytes that pass through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeDump writes a mysqldump-compatible logical dump of the connection's
+// current database to w. When opts.singleTransaction is set the whole dump is
+// read from one REPEATABLE READ snapshot, so InnoDB tables are consistent
+// without locking them.
+func writeDump(ctx context.Context, conn *sql.Conn, cfg connConfig, w io.Writer, opts dumpOptions) (dumpStats, error) {
+	var stats dumpStats
+
+	if opts.singleTransaction {
+		if _, err := conn.ExecContext(ctx, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+			return stats, fmt.Errorf("failed to set isolation level: %w", err)
+		}
+		if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return stats, fmt.Errorf("failed to start snapshot: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), "ROLLBACK")
+	}
+
+	tables, views, err := listTables(ctx, conn)
+	if err != nil {
+		return stats, err
+	}
+	if len(opts.tables) > 0 {
+		tables, views, err = filterTables(opts.tables, tables, views)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "-- Corynth MySQL dump\n--\n-- Host: %s    Database: %s\n-- Dumped at: %s\n\n",
+		cfg.addr(), cfg.database, time.Now().UTC().Format(time.RFC3339))
+	bw.WriteString("/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n")
+	bw.WriteString("/*!40101 SET NAMES utf8mb4 */;\n")
+	bw.WriteString("/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;\n")
+	bw.WriteString("/*!40103 SET TIME_ZONE='+00:00' */;\n")
+	bw.WriteString("/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;\n")
+	bw.WriteString("/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;\n")
+	bw.WriteString("/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;\n\n")
+
+	// Dump in UTC so TIMESTAMP values restore to the same instant anywhere.
+	if _, err := conn.ExecContext(ctx, "SET SESSION time_zone = '+00:00'"); err != nil {
+		return stats, fmt.Errorf("failed to set time zone: %w", err)
+	}
+
+	for _, table := range tables {
+		var name, create string
+		if err := conn.QueryRowContext(ctx, "SHOW CREATE TABLE "+quoteIdent(table)).Scan(&name, &create); err != nil {
+			return stats, fmt.Errorf("failed to read definition of %s: %w", table, err)
+		}
+		fmt.Fprintf(bw, "--\n-- Table structure for table %s\n--\n\n", quoteIdent(table))
+		fmt.Fprintf(bw, "DROP TABLE IF EXISTS %s;\n%s;\n\n", quoteIdent(table), create)
+		stats.tables++
+
+		if opts.noData {
+			continue
+		}
+		n, err := dumpTableData(ctx, conn, bw, table, opts.batchSize)
+		if err != nil {
+			return stats, err
+		}
+		stats.rows += n
+	}
+
+	for _, view := range views {
+		var name, create, charset, collation string
+		if err := conn.QueryRowContext(ctx, "SHOW CREATE VIEW "+quoteIdent(view)).Scan(&name, &create, &charset, &collation); err != nil {
+			return stats, fmt.Errorf("failed to read definition of view %s: %w", view, err)
+		}
+		fmt.Fprintf(bw, "--\n-- View structure for view %s\n--\n\n", quoteIdent(view))
+		fmt.Fprintf(bw, "DROP VIEW IF EXISTS %s;\n%s;\n\n", quoteIdent(view), create)
+		stats.views++
+	}
+
+	bw.WriteString("/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;\n")
+	bw.WriteString("/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;\n")
+	bw.WriteString("/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;\n")
+	bw.WriteString("/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;\n")
+	bw.WriteString("/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;\n\n")
+	fmt.Fprintf(bw, "-- Dump completed: %d tables, %d views, %d rows\n", stats.tables, stats.views, stats.rows)
+
+	if err := bw.Flush(); err != nil {
+		return stats, fmt.Errorf("failed to write dump: %w", err)
+	}
+	return stats, nil
+}
+
+func listTables(ctx context.Context, conn *sql.Conn) ([]string, []string, error) {
+	rows, err := conn.QueryContext(ctx, "SHOW FULL TABLES")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables, views []string
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			return nil, nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		if kind == "VIEW" {
+			views = append(views, name)
+		} else {
+			tables = append(tables, name)
+		}
+	}
+	return tables, views, rows.Err()
+}
+
+func filterTables(wanted, tables, views []string) ([]string, []string, error) {
+	kinds := make(map[string]string)
+	for _, t := range tables {
+		kinds[t] = "table"
+	}
+	for _, v := range views {
+		kinds[v] = "view"
+	}
+
+	var outTables, outViews []string
+	for _, name := range wanted {
+		switch kinds[name] {
+		case "table":
+			outTables = append(outTables, name)
+		case "view":
+			outViews = append(outViews, name)
+		default:
+			return nil, nil, fmt.Errorf("table %s does not exist", name)
+		}
+	}
+	return outTables, outViews, nil
+}
+
+func dumpTableData(ctx context.Context, conn *sql.Conn, w *bufio.Writer, table string, batchSize int) (int64, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT * FROM "+quoteIdent(table))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read column types of %s: %w", table, err)
+	}
+	kinds := make([]string, len(columnTypes))
+	for i, ct := range columnTypes {
+		kinds[i] = strings.TrimPrefix(ct.DatabaseTypeName(), "UNSIGNED ")
+	}
+
+	fmt.Fprintf(w, "--\n-- Dumping data for table %s\n--\n\n", quoteIdent(table))
+
+	values := make([]sql.RawBytes, len(columnTypes))
+	pointers := make([]interface{}, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	var count int64
+	inBatch := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return count, fmt.Errorf("failed to read row from %s: %w", table, err)
+		}
+
+		if inBatch == 0 {
+			fmt.Fprintf(w, "INSERT INTO %s VALUES (", quoteIdent(table))
+		} else {
+			w.WriteString(",(")
+		}
+		for i, v := range values {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(sqlLiteral(v, kinds[i]))
+		}
+		w.WriteByte(')')
+
+		count++
+		inBatch++
+		if inBatch >= batchSize {
+			w.WriteString(";\n")
+			inBatch = 0
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	if inBatch > 0 {
+		w.WriteString(";\n")
+	}
+	w.WriteString("\n")
+	return count, nil
+}
+
+// sqlLiteral renders a raw column value the way mysqldump does: numbers bare,
+// binary data as hex, everything else as an escaped string.
+func sqlLiteral(v sql.RawBytes, kind string) string {
+	if v == nil {
+		return "NULL"
+	}
+	switch kind {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR", "DECIMAL", "FLOAT", "DOUBLE":
+		return string(v)
+	case "BIT", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "GEOMETRY":
+		if len(v) == 0 {
+			return "''"
+		}
+		return "0x" + strings.ToUpper(hex.EncodeToString(v))
+	}
+
+	var b strings.Builder
+	b.Grow(len(v) + 2)
+	b.WriteByte('\'')
+	for _, c := range v {
+		switch c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case 0x1a:
+			b.WriteString(`\Z`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// splitStatements reads SQL from r and calls fn for every complete statement.
+// It understands quoted strings, identifiers and comments well enough to
+// replay mysqldump output; /*! ... */ version comments are kept because the
+// server executes them.
+func splitStatements(r io.Reader, fn func(string) error) error {
+	br := bufio.NewReader(r)
+	var stmt strings.Builder
+	var quote byte
+
+	flush := func() error {
+		s := strings.TrimSpace(stmt.String())
+		stmt.Reset()
+		if s == "" {
+			return nil
+		}
+		return fn(s)
+	}
+
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if quote != 0 {
+			stmt.WriteByte(c)
+			switch {
+			case c == '\\' && quote != '`':
+				next, err := br.ReadByte()
+				if err != nil {
+					return fmt.Errorf("unterminated string in dump")
+				}
+				stmt.WriteByte(next)
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+			stmt.WriteByte(c)
+		case ';':
+			if err := flush(); err != nil {
+				return err
+			}
+		case '#':
+			if _, err := br.ReadString('\n'); err != nil && err != io.EOF {
+				return err
+			}
+			stmt.WriteByte('\n')
+		case '-':
+			peek, _ := br.Peek(2)
+			if len(peek) == 2 && peek[0] == '-' && (peek[1] == ' ' || peek[1] == '\t' || peek[1] == '\n') {
+				if _, err := br.ReadString('\n'); err != nil && err != io.EOF {
+					return err
+				}
+				stmt.WriteByte('\n')
+				continue
+			}
+			stmt.WriteByte(c)
+		case '/':
+			peek, _ := br.Peek(1)
+			if len(peek) == 1 && peek[0] == '*' {
+				comment, err := readBlockComment(br)
+				if err != nil {
+					return err
+				}
+				// Executable comments (/*! ... */) are part of the statement.
+				if strings.HasPrefix(comment, "/*!") {
+					stmt.WriteString(comment)
+				} else {
+					stmt.WriteByte(' ')
+				}
+				continue
+			}
+			stmt.WriteByte(c)
+		default:
+			stmt.WriteByte(c)
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Errorf("unterminated quoted string in dump")
+	}
+	return flush()
+}
+
+// readBlockComment consumes a /* ... */ comment whose leading '/' has
+// already been read, and returns it including the delimiters.
+func readBlockComment(br *bufio.Reader) (string, error) {
+	var b strings.Builder
+	b.WriteByte('/')
+	prev := byte(0)
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("unterminated comment in dump")
+		}
+		b.WriteByte(c)
+		if prev == '*' && c == '/' && b.Len() > 3 {
+			return b.String(), nil
+		}
+		prev = c
+	}
+}
+
+// fileChecksum returns the size and SHA-256 of the file at path.
+func fileChecksum(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// openDumpReader opens a dump file for reading, transparently decompressing
+// it when it starts with the gzip magic bytes.
+func openDumpReader(path string) (io.Reader, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("invalid gzip data: %w", err)
+		}
+		return gz, func() error { gz.Close(); return f.Close() }, nil
+	}
+	return br, f.Close, nil
+}
@@ -4,4 +4,10 @@ go 1.21
 
 replace github.com/corynth/corynth-dist => ../../../corynth-dist
 
-require github.com/corynth/corynth-dist v0.0.0-00010101000000-000000000000
\ No newline at end of file
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/corynth/corynth-dist v0.0.0-00010101000000-000000000000
+	github.com/go-sql-driver/mysql v1.8.1
+)
+
+require filippo.io/edwards25519 v1.1.0 // indirect
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFile matches names like 0001_create_users.up.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// migration is one numbered version found in the migrations directory.
+type migration struct {
+	version  int64
+	name     string
+	upPath   string
+	downPath string
+}
+
+// appliedMigration is a row of the tracking table.
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt string
+}
+
+// loadMigrations reads dir and returns its migrations ordered by version.
+func loadMigrations(dir string) ([]*migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int64]*migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{version: version, name: m[2]}
+			byVersion[version] = mig
+		} else if mig.name != m[2] {
+			return nil, fmt.Errorf("migration version %d has conflicting names %q and %q", version, mig.name, m[2])
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if m[3] == "up" {
+			mig.upPath = path
+		} else {
+			mig.downPath = path
+		}
+	}
+
+	migrations := make([]*migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.upPath == "" {
+			return nil, fmt.Errorf("migration %d_%s has no .up.sql file", mig.version, mig.name)
+		}
+		migrations = append(migrations, mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn, table string) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+quoteIdent(table)+` (
+  version BIGINT NOT NULL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", table, err)
+	}
+	return nil
+}
+
+// readApplied returns the applied versions. A missing tracking table is
+// treated as "nothing applied" so dry runs never have to create it.
+func readApplied(ctx context.Context, conn *sql.Conn, database, table string) (map[int64]appliedMigration, error) {
+	applied := make(map[int64]appliedMigration)
+
+	var exists int
+	err := conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ?",
+		database, table).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for %s: %w", table, err)
+	}
+	if exists == 0 {
+		return applied, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM "+quoteIdent(table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// runMigrationFile replays the statements of one migration file. MySQL
+// commits DDL implicitly, so a failing file can leave earlier statements in
+// place; the version is only recorded once every statement succeeded.
+func runMigrationFile(ctx context.Context, conn *sql.Conn, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n := 0
+	return splitStatements(f, func(stmt string) error {
+		n++
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s: statement %d failed: %w", filepath.Base(path), n, err)
+		}
+		return nil
+	})
+}
+
+// migrationLockName derives the GET_LOCK name guarding a migrations table.
+// MySQL limits lock names to 64 characters, so the database and table are
+// hashed rather than embedded verbatim.
+func migrationLockName(database, table string) string {
+	sum := sha1.Sum([]byte(database + "." + table))
+	return "corynth_migrate:" + hex.EncodeToString(sum[:])
+}
+
+// acquireMigrationLock takes a server-wide named lock so that concurrent
+// migrate runs against the same database apply each version only once.
+func acquireMigrationLock(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) (func(), error) {
+	var got sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, int(timeout.Seconds())).Scan(&got)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return nil, fmt.Errorf("timed out waiting for migration lock %q; another migration is running", name)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+	}, nil
+}
+
+func (p *MySQLPlugin) executeMigrate(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, ok := params["directory"].(string)
+	if !ok || dir == "" {
+		return nil, fmt.Errorf("directory parameter is required")
+	}
+	command := "up"
+	if c, ok := params["command"].(string); ok && c != "" {
+		command = strings.ToLower(c)
+	}
+	if command != "up" && command != "down" && command != "status" {
+		return nil, fmt.Errorf("command must be up, down or status")
+	}
+	table := "schema_migrations"
+	if t, ok := params["table"].(string); ok && t != "" {
+		table = t
+	}
+	dryRun, _ := params["dry_run"].(bool)
+	lockTimeout := time.Duration(intParam(params, "lock_timeout", 30)) * time.Second
+
+	steps := intParam(params, "steps", 0)
+	if command == "down" && steps <= 0 {
+		steps = 1
+	}
+
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openDB(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// GET_LOCK is held by the session, so everything runs on one connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if command != "status" && !dryRun {
+		release, err := acquireMigrationLock(ctx, conn, migrationLockName(cfg.database, table), lockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		if err := ensureMigrationsTable(ctx, conn, table); err != nil {
+			return nil, err
+		}
+	}
+
+	applied, err := readApplied(ctx, conn, cfg.database, table)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out which migrations this run touches.
+	var plan []*migration
+	switch command {
+	case "up":
+		for _, mig := range migrations {
+			if _, done := applied[mig.version]; !done {
+				plan = append(plan, mig)
+			}
+		}
+		if steps > 0 && len(plan) > steps {
+			plan = plan[:steps]
+		}
+	case "down":
+		for i := len(migrations) - 1; i >= 0 && len(plan) < steps; i-- {
+			if _, done := applied[migrations[i].version]; done {
+				plan = append(plan, migrations[i])
+			}
+		}
+		for _, mig := range plan {
+			if mig.downPath == "" {
+				return nil, fmt.Errorf("migration %d_%s has no .down.sql file", mig.version, mig.name)
+			}
+		}
+	}
+
+	changes := []map[string]interface{}{}
+	for _, mig := range plan {
+		change := map[string]interface{}{
+			"version":   mig.version,
+			"name":      mig.name,
+			"direction": command,
+		}
+		if dryRun {
+			changes = append(changes, change)
+			continue
+		}
+
+		if command == "up" {
+			checksum, err := fileSHA256(mig.upPath)
+			if err != nil {
+				return nil, err
+			}
+			if err := runMigrationFile(ctx, conn, mig.upPath); err != nil {
+				return nil, fmt.Errorf("migration %d failed after applying %d migrations: %w", mig.version, len(changes), err)
+			}
+			_, err = conn.ExecContext(ctx, "INSERT INTO "+quoteIdent(table)+" (version, name, checksum) VALUES (?, ?, ?)",
+				mig.version, mig.name, checksum)
+			if err != nil {
+				return nil, fmt.Errorf("failed to record migration %d: %w", mig.version, err)
+			}
+			applied[mig.version] = appliedMigration{name: mig.name, checksum: checksum}
+		} else {
+			if err := runMigrationFile(ctx, conn, mig.downPath); err != nil {
+				return nil, fmt.Errorf("rollback of migration %d failed after reverting %d migrations: %w", mig.version, len(changes), err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM "+quoteIdent(table)+" WHERE version = ?", mig.version); err != nil {
+				return nil, fmt.Errorf("failed to unrecord migration %d: %w", mig.version, err)
+			}
+			delete(applied, mig.version)
+		}
+		changes = append(changes, change)
+	}
+
+	// Report the state after this run (or the unchanged state for
+	// status and dry runs).
+	status := make([]map[string]interface{}, 0, len(migrations))
+	pending := 0
+	var current int64
+	for _, mig := range migrations {
+		entry := map[string]interface{}{
+			"version": mig.version,
+			"name":    mig.name,
+			"applied": false,
+		}
+		if a, ok := applied[mig.version]; ok {
+			entry["applied"] = true
+			entry["applied_at"] = a.appliedAt
+			if checksum, err := fileSHA256(mig.upPath); err == nil && a.checksum != "" {
+				entry["modified"] = checksum != a.checksum
+			}
+			current = mig.version
+		} else {
+			pending++
+		}
+		status = append(status, entry)
+	}
+
+	return map[string]interface{}{
+		"applied":         changes,
+		"count":           len(changes),
+		"current_version": current,
+		"pending":         pending,
+		"migrations":      status,
+		"dry_run":         dryRun,
+		"message":         fmt.Sprintf("migrate %s: %d migrations on %s (current version %d, %d pending)", command, len(changes), cfg, current, pending),
+	}, nil
+}
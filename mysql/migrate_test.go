@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrationLockName(t *testing.T) {
+	long := strings.Repeat("d", 64)
+	name := migrationLockName(long, strings.Repeat("t", 64))
+	if len(name) > 64 {
+		t.Errorf("lock name %q is %d characters, MySQL allows 64", name, len(name))
+	}
+	if !strings.HasPrefix(name, "corynth_migrate:") {
+		t.Errorf("lock name %q lacks the corynth_migrate: prefix", name)
+	}
+	if migrationLockName("shop", "schema_migrations") != migrationLockName("shop", "schema_migrations") {
+		t.Error("lock name is not stable across runs")
+	}
+	if migrationLockName("shop", "schema_migrations") == migrationLockName("shop", "other_migrations") {
+		t.Error("different tables share a lock name")
+	}
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
+	"database/sql"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	
 	"github.com/corynth/corynth-dist/pkg/plugin"
 )
@@ -25,150 +30,289 @@ func (p *MySQLPlugin) Actions() []plugin.Action {
 		{
 			Name:        "query",
 			Description: "Execute a SELECT query",
-			Inputs: map[string]plugin.InputSpec{
-				"host": {
-					Type:        "string",
-					Description: "MySQL host",
-					Required:    false,
-					Default:     "localhost",
-				},
-				"port": {
-					Type:        "number",
-					Description: "MySQL port",
-					Required:    false,
-					Default:     3306,
-				},
-				"database": {
-					Type:        "string",
-					Description: "Database name",
-					Required:    true,
-				},
-				"user": {
-					Type:        "string",
-					Description: "Database user",
-					Required:    true,
-				},
-				"password": {
-					Type:        "string",
-					Description: "Database password",
-					Required:    false,
-				},
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
 				"query": {
 					Type:        "string",
 					Description: "SQL query to execute",
 					Required:    true,
 				},
-			},
+				"params": {
+					Type:        "array",
+					Description: "Values bound to ? placeholders in order",
+					Required:    false,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"rows": {
 					Type:        "array",
-					Description: "Query result rows",
+					Description: "Query result rows as column name to value maps",
 				},
 				"count": {
 					Type:        "number",
 					Description: "Number of rows returned",
 				},
+				"columns": {
+					Type:        "array",
+					Description: "Column metadata (name, type, nullable)",
+				},
 			},
 		},
 		{
 			Name:        "execute",
 			Description: "Execute an INSERT, UPDATE, or DELETE statement",
-			Inputs: map[string]plugin.InputSpec{
-				"host": {
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"statement": {
 					Type:        "string",
-					Description: "MySQL host",
+					Description: "SQL statement to execute",
+					Required:    true,
+				},
+				"params": {
+					Type:        "array",
+					Description: "Values bound to ? placeholders in order",
 					Required:    false,
-					Default:     "localhost",
 				},
-				"port": {
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"affected_rows": {
 					Type:        "number",
-					Description: "MySQL port",
-					Required:    false,
-					Default:     3306,
+					Description: "Number of rows affected",
 				},
-				"database": {
+				"last_insert_id": {
+					Type:        "number",
+					Description: "ID generated for an AUTO_INCREMENT column by the statement",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the operation succeeded",
+				},
+			},
+		},
+		{
+			Name:        "backup",
+			Description: "Create a logical database backup (schema and data)",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"output_file": {
 					Type:        "string",
-					Description: "Database name",
+					Description: "Path to backup file",
 					Required:    true,
 				},
-				"user": {
+				"tables": {
+					Type:        "array",
+					Description: "Tables or views to include (default: all)",
+					Required:    false,
+				},
+				"compress": {
+					Type:        "boolean",
+					Description: "Gzip the dump (default: true when output_file ends in .gz)",
+					Required:    false,
+				},
+				"single_transaction": {
+					Type:        "boolean",
+					Description: "Read all tables from one consistent snapshot",
+					Required:    false,
+					Default:     true,
+				},
+				"no_data": {
+					Type:        "boolean",
+					Description: "Dump schema only",
+					Required:    false,
+					Default:     false,
+				},
+				"batch_size": {
+					Type:        "number",
+					Description: "Rows per INSERT statement",
+					Required:    false,
+					Default:     500,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"file": {
+					Type:        "string",
+					Description: "Path to the backup file",
+				},
+				"size": {
+					Type:        "number",
+					Description: "Size of backup in bytes",
+				},
+				"checksum": {
 					Type:        "string",
-					Description: "Database user",
+					Description: "SHA-256 of the backup file",
+				},
+				"tables": {
+					Type:        "number",
+					Description: "Number of tables dumped",
+				},
+				"rows": {
+					Type:        "number",
+					Description: "Number of rows dumped",
+				},
+			},
+		},
+		{
+			Name:        "restore",
+			Description: "Replay a backup file into the database",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"input_file": {
+					Type:        "string",
+					Description: "Path to the backup file (plain or gzip)",
 					Required:    true,
 				},
-				"password": {
+				"checksum": {
 					Type:        "string",
-					Description: "Database password",
+					Description: "Expected SHA-256 of the file; restore is refused on mismatch",
 					Required:    false,
 				},
-				"statement": {
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"statements": {
+					Type:        "number",
+					Description: "Number of statements executed",
+				},
+				"size": {
+					Type:        "number",
+					Description: "Size of the backup file in bytes",
+				},
+				"checksum": {
 					Type:        "string",
-					Description: "SQL statement to execute",
-					Required:    true,
+					Description: "SHA-256 of the backup file",
 				},
 			},
+		},
+		{
+			Name:        "transaction",
+			Description: "Execute several statements atomically, rolling back on the first failure",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"statements": {
+					Type:        "array",
+					Description: "Statements to execute; each is a SQL string or an object with statement and params",
+					Required:    true,
+				},
+				"isolation": {
+					Type:        "string",
+					Description: "Isolation level: read_uncommitted, read_committed, repeatable_read or serializable",
+					Required:    false,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
+				"results": {
+					Type:        "array",
+					Description: "Per-statement results with affected_rows and last_insert_id",
+				},
 				"affected_rows": {
 					Type:        "number",
-					Description: "Number of rows affected",
+					Description: "Total number of rows affected",
 				},
-				"success": {
+				"committed": {
 					Type:        "boolean",
-					Description: "Whether the operation succeeded",
+					Description: "Whether the transaction was committed",
 				},
 			},
 		},
 		{
-			Name:        "backup",
-			Description: "Create a database backup",
-			Inputs: map[string]plugin.InputSpec{
-				"host": {
+			Name:        "migrate",
+			Description: "Apply or roll back versioned schema migrations from a directory",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"directory": {
+					Type:        "string",
+					Description: "Directory of <version>_<name>.up.sql and .down.sql files",
+					Required:    true,
+				},
+				"command": {
 					Type:        "string",
-					Description: "MySQL host",
+					Description: "up, down or status",
 					Required:    false,
-					Default:     "localhost",
+					Default:     "up",
 				},
-				"port": {
+				"steps": {
 					Type:        "number",
-					Description: "MySQL port",
+					Description: "Number of migrations to apply (up, 0 = all) or roll back (down)",
 					Required:    false,
-					Default:     3306,
+					Default:     0,
 				},
-				"database": {
-					Type:        "string",
-					Description: "Database name",
-					Required:    true,
-				},
-				"user": {
-					Type:        "string",
-					Description: "Database user",
-					Required:    true,
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Report what would run without changing the database",
+					Required:    false,
+					Default:     false,
 				},
-				"password": {
+				"table": {
 					Type:        "string",
-					Description: "Database password",
+					Description: "Table used to track applied versions",
 					Required:    false,
+					Default:     "schema_migrations",
 				},
-				"output_file": {
-					Type:        "string",
-					Description: "Path to backup file",
-					Required:    true,
+				"lock_timeout": {
+					Type:        "number",
+					Description: "Seconds to wait for another migration run to finish",
+					Required:    false,
+					Default:     30,
 				},
-			},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
-				"file": {
-					Type:        "string",
-					Description: "Path to the backup file",
+				"applied": {
+					Type:        "array",
+					Description: "Migrations applied or rolled back by this run",
 				},
-				"size": {
+				"current_version": {
 					Type:        "number",
-					Description: "Size of backup in bytes",
+					Description: "Highest applied version after the run",
+				},
+				"pending": {
+					Type:        "number",
+					Description: "Number of migrations not yet applied",
+				},
+				"migrations": {
+					Type:        "array",
+					Description: "Status of every migration in the directory",
 				},
 			},
 		},
 	}
 }
 
+// withConnectionInputs adds the connection settings accepted by every action.
+func withConnectionInputs(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["host"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "MySQL host",
+		Required:    false,
+		Default:     "localhost",
+	}
+	inputs["port"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "MySQL port",
+		Required:    false,
+		Default:     3306,
+	}
+	inputs["database"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Database name",
+		Required:    true,
+	}
+	inputs["user"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Database user",
+		Required:    true,
+	}
+	inputs["password"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Database password",
+		Required:    false,
+	}
+	inputs["tls"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "TLS mode: true, false, skip-verify or preferred",
+		Required:    false,
+	}
+	inputs["timeout"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "Connection timeout in seconds",
+		Required:    false,
+		Default:     10,
+	}
+	return inputs
+}
+
 func (p *MySQLPlugin) Validate(params map[string]interface{}) error {
 	if database, ok := params["database"].(string); ok && database == "" {
 		return fmt.Errorf("database name cannot be empty")
@@ -195,20 +339,21 @@ func (p *MySQLPlugin) Execute(ctx context.Context, action string, params map[str
 		return p.executeStatement(ctx, params)
 	case "backup":
 		return p.executeBackup(ctx, params)
+	case "restore":
+		return p.executeRestore(ctx, params)
+	case "transaction":
+		return p.executeTransaction(ctx, params)
+	case "migrate":
+		return p.executeMigrate(ctx, params)
 	default:
 		return nil, fmt.Errorf("unknown action: %s", action)
 	}
 }
 
 func (p *MySQLPlugin) executeQuery(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	database, ok := params["database"].(string)
-	if !ok || database == "" {
-		return nil, fmt.Errorf("database parameter is required")
-	}
-
-	user, ok := params["user"].(string)
-	if !ok || user == "" {
-		return nil, fmt.Errorf("user parameter is required")
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
 	}
 
 	query, ok := params["query"].(string)
@@ -216,39 +361,40 @@ func (p *MySQLPlugin) executeQuery(ctx context.Context, params map[string]interf
 		return nil, fmt.Errorf("query parameter is required")
 	}
 
-	host := "localhost"
-	if h, ok := params["host"].(string); ok {
-		host = h
+	db, err := openDB(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	args, err := bindArgs(params["params"])
+	if err != nil {
+		return nil, err
 	}
 
-	port := 3306
-	if p, ok := params["port"].(float64); ok {
-		port = int(p)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
 	}
+	defer rows.Close()
 
-	// In production, this would connect to MySQL and execute the query
-	// For demonstration, we'll return mock data
-	mockRows := []map[string]interface{}{
-		{"id": 1, "name": "Record 1"},
-		{"id": 2, "name": "Record 2"},
+	result, columns, err := scanRows(rows)
+	if err != nil {
+		return nil, err
 	}
 
 	return map[string]interface{}{
-		"rows":    mockRows,
-		"count":   len(mockRows),
-		"message": fmt.Sprintf("Executed query on %s@%s:%d/%s", user, host, port, database),
+		"rows":    result,
+		"count":   len(result),
+		"columns": columns,
+		"message": fmt.Sprintf("Executed query on %s", cfg),
 	}, nil
 }
 
 func (p *MySQLPlugin) executeStatement(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	database, ok := params["database"].(string)
-	if !ok || database == "" {
-		return nil, fmt.Errorf("database parameter is required")
-	}
-
-	user, ok := params["user"].(string)
-	if !ok || user == "" {
-		return nil, fmt.Errorf("user parameter is required")
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
 	}
 
 	statement, ok := params["statement"].(string)
@@ -256,34 +402,120 @@ func (p *MySQLPlugin) executeStatement(ctx context.Context, params map[string]in
 		return nil, fmt.Errorf("statement parameter is required")
 	}
 
-	host := "localhost"
-	if h, ok := params["host"].(string); ok {
-		host = h
+	db, err := openDB(ctx, cfg)
+	if err != nil {
+		return nil, err
 	}
+	defer db.Close()
 
-	port := 3306
-	if p, ok := params["port"].(float64); ok {
-		port = int(p)
+	args, err := bindArgs(params["params"])
+	if err != nil {
+		return nil, err
 	}
 
-	// In production, this would connect to MySQL and execute the statement
-	// For demonstration, we'll simulate success
+	res, err := db.ExecContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("statement failed: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	lastInsertID, _ := res.LastInsertId()
+
 	return map[string]interface{}{
-		"affected_rows": 1,
-		"success":       true,
-		"message":       fmt.Sprintf("Executed statement on %s@%s:%d/%s", user, host, port, database),
+		"affected_rows":  affected,
+		"last_insert_id": lastInsertID,
+		"success":        true,
+		"message":        fmt.Sprintf("Executed statement on %s", cfg),
 	}, nil
 }
 
-func (p *MySQLPlugin) executeBackup(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	database, ok := params["database"].(string)
-	if !ok || database == "" {
-		return nil, fmt.Errorf("database parameter is required")
+func (p *MySQLPlugin) executeTransaction(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := params["statements"].([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("statements parameter is required and must be a non-empty array")
+	}
+
+	type step struct {
+		statement string
+		args      []interface{}
+	}
+	steps := make([]step, 0, len(list))
+	for i, item := range list {
+		switch v := item.(type) {
+		case string:
+			steps = append(steps, step{statement: v})
+		case map[string]interface{}:
+			statement, _ := v["statement"].(string)
+			if statement == "" {
+				return nil, fmt.Errorf("statements[%d]: statement is required", i)
+			}
+			args, err := bindArgs(v["params"])
+			if err != nil {
+				return nil, fmt.Errorf("statements[%d]: %w", i, err)
+			}
+			steps = append(steps, step{statement: statement, args: args})
+		default:
+			return nil, fmt.Errorf("statements[%d] must be a string or an object", i)
+		}
+	}
+
+	opts := &sql.TxOptions{}
+	if level, ok := params["isolation"].(string); ok && level != "" {
+		if opts.Isolation, err = isolationLevel(level); err != nil {
+			return nil, err
+		}
 	}
 
-	user, ok := params["user"].(string)
-	if !ok || user == "" {
-		return nil, fmt.Errorf("user parameter is required")
+	db, err := openDB(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rollback is a no-op once Commit has succeeded.
+	defer tx.Rollback()
+
+	results := make([]map[string]interface{}, 0, len(steps))
+	var total int64
+	for i, s := range steps {
+		res, err := tx.ExecContext(ctx, s.statement, s.args...)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d failed, transaction rolled back: %w", i, err)
+		}
+		affected, _ := res.RowsAffected()
+		lastInsertID, _ := res.LastInsertId()
+		total += affected
+		results = append(results, map[string]interface{}{
+			"index":          i,
+			"affected_rows":  affected,
+			"last_insert_id": lastInsertID,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return map[string]interface{}{
+		"results":       results,
+		"affected_rows": total,
+		"committed":     true,
+		"message":       fmt.Sprintf("Committed %d statements on %s", len(steps), cfg),
+	}, nil
+}
+
+func (p *MySQLPlugin) executeBackup(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
 	}
 
 	outputFile, ok := params["output_file"].(string)
@@ -291,22 +523,139 @@ func (p *MySQLPlugin) executeBackup(ctx context.Context, params map[string]inter
 		return nil, fmt.Errorf("output_file parameter is required")
 	}
 
-	host := "localhost"
-	if h, ok := params["host"].(string); ok {
-		host = h
+	compress := strings.HasSuffix(outputFile, ".gz")
+	if c, ok := params["compress"].(bool); ok {
+		compress = c
+	}
+	opts := dumpOptions{
+		tables:            stringList(params, "tables"),
+		singleTransaction: true,
+		batchSize:         intParam(params, "batch_size", 500),
+	}
+	if s, ok := params["single_transaction"].(bool); ok {
+		opts.singleTransaction = s
+	}
+	opts.noData, _ = params["no_data"].(bool)
+	if opts.batchSize < 1 {
+		opts.batchSize = 1
+	}
+
+	db, err := openDB(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Write next to the destination and rename on success, so a failed
+	// backup never leaves a truncated dump at output_file.
+	tmp, err := os.CreateTemp(filepath.Dir(outputFile), ".mysql-backup-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	counter := &countingWriter{w: tmp}
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(tmp)
+		counter.w = gz
 	}
 
-	port := 3306
-	if p, ok := params["port"].(float64); ok {
-		port = int(p)
+	stats, err := writeDump(ctx, conn, cfg, counter, opts)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("backup failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), outputFile); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+
+	size, checksum, err := fileChecksum(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum backup: %w", err)
+	}
+
+	return map[string]interface{}{
+		"file":              outputFile,
+		"size":              size,
+		"uncompressed_size": counter.n,
+		"checksum":          checksum,
+		"compressed":        compress,
+		"tables":            stats.tables,
+		"views":             stats.views,
+		"rows":              stats.rows,
+		"message":           fmt.Sprintf("Backed up database %s to %s (%d bytes)", cfg, outputFile, size),
+	}, nil
+}
+
+func (p *MySQLPlugin) executeRestore(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	inputFile, ok := params["input_file"].(string)
+	if !ok || inputFile == "" {
+		return nil, fmt.Errorf("input_file parameter is required")
+	}
+
+	size, checksum, err := fileChecksum(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", inputFile, err)
+	}
+	if expected, ok := params["checksum"].(string); ok && expected != "" && !strings.EqualFold(expected, checksum) {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", inputFile, expected, checksum)
+	}
+
+	reader, closeReader, err := openDumpReader(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", inputFile, err)
+	}
+	defer closeReader()
+
+	db, err := openDB(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Session settings in the dump (FOREIGN_KEY_CHECKS, SQL_MODE, ...) must
+	// apply to every following statement, so replay on a single connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	statements := 0
+	err = splitStatements(reader, func(stmt string) error {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("statement %d failed: %w", statements+1, err)
+		}
+		statements++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restore failed: %w", err)
 	}
 
-	// In production, this would use mysqldump to create a backup
-	// For demonstration, we'll simulate success
 	return map[string]interface{}{
-		"file":    outputFile,
-		"size":    1024000, // Mock 1MB backup
-		"message": fmt.Sprintf("Backed up database %s@%s:%d/%s to %s", user, host, port, database, outputFile),
+		"file":       inputFile,
+		"statements": statements,
+		"size":       size,
+		"checksum":   checksum,
+		"message":    fmt.Sprintf("Restored %s into %s (%d statements)", inputFile, cfg, statements),
 	}, nil
 }
 
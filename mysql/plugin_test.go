@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// useMock routes openDB to a sqlmock stand-in for the duration of the test
+// and returns the mock together with the config the plugin connected with.
+func useMock(t *testing.T) (sqlmock.Sqlmock, *connConfig) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	var got connConfig
+	orig := newDB
+	newDB = func(cfg connConfig) (*sql.DB, error) {
+		got = cfg
+		return db, nil
+	}
+	t.Cleanup(func() {
+		newDB = orig
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+	return mock, &got
+}
+
+func connParams(extra map[string]interface{}) map[string]interface{} {
+	params := map[string]interface{}{
+		"host":     "db.internal",
+		"port":     float64(3307),
+		"database": "shop",
+		"user":     "app",
+		"password": "secret",
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return params
+}
+
+func TestQueryTypedRows(t *testing.T) {
+	mock, cfg := useMock(t)
+
+	created := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	rows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("id").OfType("BIGINT", int64(0)).Nullable(false),
+		sqlmock.NewColumn("stock").OfType("UNSIGNED INT", uint64(0)).Nullable(false),
+		sqlmock.NewColumn("price").OfType("DECIMAL", "").Nullable(false),
+		sqlmock.NewColumn("ratio").OfType("DOUBLE", float64(0)).Nullable(true),
+		sqlmock.NewColumn("note").OfType("VARCHAR", "").Nullable(true),
+		sqlmock.NewColumn("created_at").OfType("DATETIME", time.Time{}).Nullable(false),
+		sqlmock.NewColumn("updated_at").OfType("DATETIME", "").Nullable(true),
+		sqlmock.NewColumn("thumbnail").OfType("BLOB", []byte{}).Nullable(true),
+		sqlmock.NewColumn("attrs").OfType("JSON", "").Nullable(true),
+		sqlmock.NewColumn("flags").OfType("BIT", []byte{}).Nullable(false),
+	).AddRow(
+		// Text protocol values arrive as []byte; parameterised queries use
+		// the binary protocol, which already yields time.Time for DATETIME.
+		[]byte("9007199254740993"),
+		[]byte("42"),
+		[]byte("19.990"),
+		[]byte("0.25"),
+		nil,
+		created,
+		[]byte("2024-03-02 08:00:00"),
+		[]byte{0x89, 'P', 'N', 'G'},
+		[]byte(`{"colour":"red","sizes":[1,2]}`),
+		[]byte{0x01, 0x02},
+	)
+
+	mock.ExpectPing()
+	mock.ExpectQuery("SELECT * FROM products WHERE id = ? AND name = ?").
+		WithArgs(int64(7), "widget").
+		WillReturnRows(rows)
+	mock.ExpectClose()
+
+	out, err := (&MySQLPlugin{}).Execute(context.Background(), "query", connParams(map[string]interface{}{
+		"query":  "SELECT * FROM products WHERE id = ? AND name = ?",
+		"params": []interface{}{float64(7), "widget"},
+	}))
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+
+	if cfg.addr() != "db.internal:3307" || cfg.database != "shop" || cfg.user != "app" || cfg.password != "secret" {
+		t.Errorf("connected with %+v", *cfg)
+	}
+	if out["count"] != 1 {
+		t.Fatalf("count = %v, want 1", out["count"])
+	}
+
+	row := out["rows"].([]map[string]interface{})[0]
+	want := map[string]interface{}{
+		"id":         int64(9007199254740993),
+		"stock":      int64(42),
+		"price":      "19.990",
+		"ratio":      0.25,
+		"note":       nil,
+		"created_at": "2024-03-01T12:30:00Z",
+		"updated_at": "2024-03-02 08:00:00",
+		"thumbnail":  "iVBORw==",
+		"attrs":      map[string]interface{}{"colour": "red", "sizes": []interface{}{float64(1), float64(2)}},
+		"flags":      uint64(0x0102),
+	}
+	for name, w := range want {
+		if got := row[name]; !reflect.DeepEqual(got, w) {
+			t.Errorf("%s = %#v (%T), want %#v (%T)", name, got, got, w, w)
+		}
+	}
+
+	columns := out["columns"].([]map[string]interface{})
+	if len(columns) != 10 {
+		t.Fatalf("got %d columns, want 10", len(columns))
+	}
+	wantColumns := []map[string]interface{}{
+		{"name": "id", "type": "BIGINT", "nullable": false},
+		{"name": "stock", "type": "UNSIGNED INT", "nullable": false},
+		{"name": "price", "type": "DECIMAL", "nullable": false},
+		{"name": "ratio", "type": "DOUBLE", "nullable": true},
+		{"name": "note", "type": "VARCHAR", "nullable": true},
+	}
+	for i, w := range wantColumns {
+		if !reflect.DeepEqual(columns[i], w) {
+			t.Errorf("columns[%d] = %v, want %v", i, columns[i], w)
+		}
+	}
+}
+
+func TestQueryNoRows(t *testing.T) {
+	mock, _ := useMock(t)
+	mock.ExpectPing()
+	mock.ExpectQuery("SELECT id FROM products WHERE 1 = 0").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectClose()
+
+	out, err := (&MySQLPlugin{}).Execute(context.Background(), "query", connParams(map[string]interface{}{
+		"query": "SELECT id FROM products WHERE 1 = 0",
+	}))
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	rows := out["rows"].([]map[string]interface{})
+	if rows == nil || len(rows) != 0 || out["count"] != 0 {
+		t.Errorf("rows = %#v, count = %v; want an empty, non-nil slice", rows, out["count"])
+	}
+}
+
+func TestStatementResult(t *testing.T) {
+	mock, _ := useMock(t)
+	mock.ExpectPing()
+	mock.ExpectExec("INSERT INTO orders (customer, total, meta) VALUES (?, ?, ?)").
+		WithArgs(int64(12), 49.95, `{"gift":true}`).
+		WillReturnResult(sqlmock.NewResult(1041, 1))
+	mock.ExpectClose()
+
+	out, err := (&MySQLPlugin{}).Execute(context.Background(), "execute", connParams(map[string]interface{}{
+		"statement": "INSERT INTO orders (customer, total, meta) VALUES (?, ?, ?)",
+		"params":    []interface{}{float64(12), 49.95, map[string]interface{}{"gift": true}},
+	}))
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if out["affected_rows"] != int64(1) || out["last_insert_id"] != int64(1041) || out["success"] != true {
+		t.Errorf("result = %v, want affected_rows 1 and last_insert_id 1041", out)
+	}
+}
+
+func TestStatementError(t *testing.T) {
+	mock, _ := useMock(t)
+	mock.ExpectPing()
+	mock.ExpectExec("DELETE FROM orders").WillReturnError(driver.ErrBadConn)
+	mock.ExpectClose()
+
+	_, err := (&MySQLPlugin{}).Execute(context.Background(), "execute", connParams(map[string]interface{}{
+		"statement": "DELETE FROM orders",
+	}))
+	if err == nil || !regexp.MustCompile(`^statement failed: `).MatchString(err.Error()) {
+		t.Errorf("error = %v, want statement failed", err)
+	}
+}
+
+func TestBindArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  interface{}
+		want []interface{}
+	}{
+		{"nil", nil, nil},
+		{"whole numbers become integers", []interface{}{float64(3), float64(-8)}, []interface{}{int64(3), int64(-8)}},
+		{"fractions stay floats", []interface{}{1.5}, []interface{}{1.5}},
+		{"strings, bools and nulls pass through", []interface{}{"a", true, nil}, []interface{}{"a", true, nil}},
+		{"objects and arrays are JSON", []interface{}{map[string]interface{}{"k": "v"}, []interface{}{float64(1), "x"}}, []interface{}{`{"k":"v"}`, `[1,"x"]`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bindArgs(tt.raw)
+			if err != nil {
+				t.Fatalf("bindArgs: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bindArgs = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := bindArgs("not an array"); err == nil {
+		t.Error("bindArgs accepted a non-array")
+	}
+}
+
+func TestQueryRequiresConnectionParams(t *testing.T) {
+	_, err := (&MySQLPlugin{}).Execute(context.Background(), "query", map[string]interface{}{
+		"user":  "app",
+		"query": "SELECT 1",
+	})
+	if err == nil || err.Error() != "database parameter is required" {
+		t.Errorf("error = %v, want database parameter is required", err)
+	}
+}
@@ -0,0 +1,11 @@
+CREATE TABLE categories (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(50) NOT NULL UNIQUE,
+  description TEXT,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO categories (name, description) VALUES
+  ('Electronics', 'Electronic devices and accessories'),
+  ('Books', 'Physical and digital books'),
+  ('Clothing', 'Apparel and fashion items');
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// writeFileAtomically streams into a temporary file next to path, optionally
+// gzip-compressing it, and renames it into place only if write succeeds.
+// It returns the final size on disk and its SHA-256.
+func writeFileAtomically(path string, compress bool, write func(io.Writer) error) (int64, string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".postgres-export-*")
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(tmp, h)}
+	var w io.Writer = counter
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(counter)
+		w = gz
+	}
+
+	err = write(w)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return counter.n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// countingWriter counts the bytes that pass through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// listTables returns the ordinary and partitioned tables visible through the
+// connection's search_path, as schema-qualified identifiers.
+func listTables(ctx context.Context, conn *pgx.Conn) ([]pgx.Identifier, error) {
+	rows, err := conn.Query(ctx, `SELECT n.nspname, c.relname
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p') AND n.nspname = ANY(current_schemas(false))
+ORDER BY n.nspname, c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []pgx.Identifier
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		tables = append(tables, pgx.Identifier{schema, name})
+	}
+	return tables, rows.Err()
+}
+
+// filterTables keeps the tables named in wanted, which may be given as
+// "table" or "schema.table".
+func filterTables(wanted []string, tables []pgx.Identifier) ([]pgx.Identifier, error) {
+	var out []pgx.Identifier
+	for _, name := range wanted {
+		found := false
+		for _, t := range tables {
+			if name == t[1] || name == t[0]+"."+t[1] {
+				out = append(out, t)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("table %s does not exist in the search_path", name)
+		}
+	}
+	return out, nil
+}
+
+// copyQueryTo streams the result of query to w using COPY ... TO STDOUT.
+func copyQueryTo(ctx context.Context, conn *pgx.Conn, w io.Writer, query, format string, header bool) (int64, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	options := "FORMAT " + format
+	if header && format == "csv" {
+		options += ", HEADER true"
+	}
+	tag, err := conn.PgConn().CopyTo(ctx, w, fmt.Sprintf("COPY (%s) TO STDOUT WITH (%s)", query, options))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// connConfig holds the connection settings shared by every postgres action.
+type connConfig struct {
+	host       string
+	port       int
+	database   string
+	user       string
+	password   string
+	sslmode    string
+	searchPath string
+	timeout    time.Duration
+}
+
+func (c connConfig) addr() string {
+	return net.JoinHostPort(c.host, strconv.Itoa(c.port))
+}
+
+func (c connConfig) String() string {
+	return fmt.Sprintf("%s@%s/%s", c.user, c.addr(), c.database)
+}
+
+func configFromParams(params map[string]interface{}) (connConfig, error) {
+	cfg := connConfig{
+		host:    "localhost",
+		port:    intParam(params, "port", 5432),
+		sslmode: "prefer",
+		timeout: time.Duration(intParam(params, "timeout", 10)) * time.Second,
+	}
+	if h, ok := params["host"].(string); ok && h != "" {
+		cfg.host = h
+	}
+
+	database, ok := params["database"].(string)
+	if !ok || database == "" {
+		return cfg, fmt.Errorf("database parameter is required")
+	}
+	cfg.database = database
+
+	user, ok := params["user"].(string)
+	if !ok || user == "" {
+		return cfg, fmt.Errorf("user parameter is required")
+	}
+	cfg.user = user
+
+	cfg.password, _ = params["password"].(string)
+	if mode, ok := params["sslmode"].(string); ok && mode != "" {
+		switch mode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+			cfg.sslmode = mode
+		default:
+			return cfg, fmt.Errorf("unsupported sslmode: %s", mode)
+		}
+	}
+	cfg.searchPath, _ = params["search_path"].(string)
+	return cfg, nil
+}
+
+// connect opens a single connection described by cfg.
+func connect(ctx context.Context, cfg connConfig) (*pgx.Conn, error) {
+	dsn := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=%s connect_timeout=%d",
+		dsnValue(cfg.host), cfg.port, dsnValue(cfg.database), dsnValue(cfg.user),
+		dsnValue(cfg.password), cfg.sslmode, int(cfg.timeout.Seconds()))
+
+	pgCfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection settings: %w", err)
+	}
+	if cfg.searchPath != "" {
+		pgCfg.RuntimeParams["search_path"] = cfg.searchPath
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, pgCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", cfg, err)
+	}
+	return conn, nil
+}
+
+// dsnValue quotes a keyword/value connection string value.
+func dsnValue(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// collectRows reads every row into a column-name to value map and returns
+// the column metadata alongside.
+func collectRows(conn *pgx.Conn, rows pgx.Rows) ([]map[string]interface{}, []map[string]interface{}, error) {
+	fields := rows.FieldDescriptions()
+	columns := make([]map[string]interface{}, len(fields))
+	for i, f := range fields {
+		typeName := strconv.FormatUint(uint64(f.DataTypeOID), 10)
+		if t, ok := conn.TypeMap().TypeForOID(f.DataTypeOID); ok {
+			typeName = t.Name
+		}
+		columns[i] = map[string]interface{}{
+			"name": f.Name,
+			"type": typeName,
+		}
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read row: %w", err)
+		}
+		row := make(map[string]interface{}, len(fields))
+		for i, f := range fields {
+			row[f.Name] = convertValue(values[i])
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	return result, columns, nil
+}
+
+// convertValue maps a decoded pgx value to a JSON-friendly typed value.
+func convertValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case [16]byte:
+		// uuid
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	case pgtype.Numeric:
+		// Kept as a string so no precision is lost; callers can parse it.
+		if !v.Valid {
+			return nil
+		}
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = convertValue(item)
+		}
+		return out
+	case int64, float64, bool, string, map[string]interface{}:
+		return v
+	case driver.Valuer:
+		if dv, err := v.Value(); err == nil {
+			return convertValue(dv)
+		}
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	// Fall back to the JSON representation for anything else.
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return string(b)
+	}
+	return decoded
+}
+
+// bindArgs converts a params array into placeholder arguments. HCL numbers
+// arrive as float64; whole numbers are bound as integers so they compare
+// correctly against integer columns. Objects and arrays are bound as JSON.
+func bindArgs(raw interface{}) ([]interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("params must be an array")
+	}
+
+	args := make([]interface{}, len(list))
+	for i, value := range list {
+		switch v := value.(type) {
+		case float64:
+			if v == float64(int64(v)) {
+				args[i] = int64(v)
+			} else {
+				args[i] = v
+			}
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("params[%d]: %w", i, err)
+			}
+			args[i] = string(encoded)
+		default:
+			args[i] = v
+		}
+	}
+	return args, nil
+}
+
+// intParam reads a numeric parameter, accepting the float64 values produced
+// by HCL as well as plain ints and numeric strings.
+func intParam(params map[string]interface{}, name string, def int) int {
+	switch v := params[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// stringList reads a parameter that may be given as a single string or as an
+// array of values.
+func stringList(params map[string]interface{}, name string) []string {
+	switch v := params[name].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	}
+	return nil
+}
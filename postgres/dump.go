@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// dumpOptions controls what a logical dump contains.
+type dumpOptions struct {
+	tables          []string
+	schema          bool
+	disableTriggers bool
+	pgDump          string
+}
+
+// dumpStats summarises a finished dump.
+type dumpStats struct {
+	tables int
+	views  int
+	rows   int64
+}
+
+// writeDump writes a psql-restorable dump of the tables visible through the
+// connection's search_path to w. Everything is read from one REPEATABLE READ
+// snapshot; when opts.schema is set the snapshot is exported to pg_dump so
+// the CREATE statements describe exactly the data that was copied.
+//
+// The layout follows pg_dump: pre-data DDL (schemas, types, tables, views,
+// sequences), COPY blocks, sequence positions, then post-data DDL (indexes,
+// constraints, triggers) so constraints are checked once, after loading.
+func writeDump(ctx context.Context, conn *pgx.Conn, cfg connConfig, w io.Writer, opts dumpOptions) (dumpStats, error) {
+	var stats dumpStats
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return stats, fmt.Errorf("failed to start snapshot: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	tables, err := listTables(ctx, conn)
+	if err != nil {
+		return stats, err
+	}
+	if len(opts.tables) > 0 {
+		if tables, err = filterTables(opts.tables, tables); err != nil {
+			return stats, err
+		}
+	}
+	sequences, err := listSequences(ctx, conn, tables, len(opts.tables) > 0)
+	if err != nil {
+		return stats, err
+	}
+
+	var dumper *pgDumper
+	if opts.schema {
+		var snapshot string
+		if err := conn.QueryRow(ctx, "SELECT pg_export_snapshot()").Scan(&snapshot); err != nil {
+			return stats, fmt.Errorf("failed to export snapshot: %w", err)
+		}
+		dumper = &pgDumper{binary: opts.pgDump, cfg: cfg, snapshot: snapshot}
+		if len(opts.tables) > 0 {
+			for _, t := range tables {
+				dumper.selectors = append(dumper.selectors, "--table="+t.Sanitize())
+			}
+		} else {
+			// Excluding the schemas outside the search_path, rather than
+			// naming the ones inside it, keeps pg_dump from emitting a
+			// CREATE SCHEMA public that would fail on restore.
+			excluded, err := schemasOutsideSearchPath(ctx, conn)
+			if err != nil {
+				return stats, err
+			}
+			for _, s := range excluded {
+				dumper.selectors = append(dumper.selectors, "--exclude-schema="+pgx.Identifier{s}.Sanitize())
+			}
+			if stats.views, err = countViews(ctx, conn); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "--\n-- Corynth PostgreSQL dump\n--\n-- Host: %s    Database: %s\n-- Dumped at: %s\n--\n\n",
+		cfg.addr(), cfg.database, time.Now().UTC().Format(time.RFC3339))
+
+	if dumper != nil {
+		bw.WriteString("--\n-- Schema (pg_dump pre-data)\n--\n\n")
+		if err := dumper.section(ctx, bw, "pre-data"); err != nil {
+			return stats, err
+		}
+		bw.WriteString("\n")
+	}
+
+	bw.WriteString("SET client_encoding = 'UTF8';\nSET standard_conforming_strings = on;\n")
+	if opts.disableTriggers {
+		// Like pg_dump --disable-triggers: skips foreign key checks while
+		// the data is loaded. Restoring requires superuser.
+		bw.WriteString("SET session_replication_role = replica;\n")
+	}
+	bw.WriteString("\n")
+
+	for _, table := range tables {
+		name := table.Sanitize()
+		fmt.Fprintf(bw, "--\n-- Data for %s\n--\n\nCOPY %s FROM stdin;\n", name, name)
+		tag, err := conn.PgConn().CopyTo(ctx, bw, "COPY "+name+" TO STDOUT")
+		if err != nil {
+			return stats, fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+		bw.WriteString("\\.\n\n")
+		stats.tables++
+		stats.rows += tag.RowsAffected()
+	}
+
+	if len(sequences) > 0 {
+		bw.WriteString("--\n-- Sequence positions\n--\n\n")
+	}
+	for _, seq := range sequences {
+		name := seq.Sanitize()
+		var last int64
+		var called bool
+		if err := conn.QueryRow(ctx, "SELECT last_value, is_called FROM "+name).Scan(&last, &called); err != nil {
+			return stats, fmt.Errorf("failed to read sequence %s: %w", name, err)
+		}
+		fmt.Fprintf(bw, "SELECT pg_catalog.setval(%s, %d, %t);\n", quoteLiteral(name), last, called)
+	}
+	if len(sequences) > 0 {
+		bw.WriteString("\n")
+	}
+
+	if opts.disableTriggers {
+		bw.WriteString("SET session_replication_role = DEFAULT;\n\n")
+	}
+
+	if dumper != nil {
+		bw.WriteString("--\n-- Indexes, constraints and triggers (pg_dump post-data)\n--\n\n")
+		if err := dumper.section(ctx, bw, "post-data"); err != nil {
+			return stats, err
+		}
+	}
+
+	fmt.Fprintf(bw, "\n-- Dump completed: %d tables, %d views, %d rows\n", stats.tables, stats.views, stats.rows)
+	if err := bw.Flush(); err != nil {
+		return stats, fmt.Errorf("failed to write dump: %w", err)
+	}
+	return stats, nil
+}
+
+// listSequences returns the sequences whose positions belong in the dump:
+// every sequence in the search_path, or when only some tables are dumped,
+// the serial and identity sequences owned by those tables.
+func listSequences(ctx context.Context, conn *pgx.Conn, tables []pgx.Identifier, ownedOnly bool) ([]pgx.Identifier, error) {
+	rows, err := conn.Query(ctx, `SELECT n.nspname, s.relname, tn.nspname, t.relname
+FROM pg_class s
+JOIN pg_namespace n ON n.oid = s.relnamespace
+LEFT JOIN pg_depend d ON d.classid = 'pg_class'::regclass AND d.objid = s.oid
+	AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+LEFT JOIN pg_class t ON t.oid = d.refobjid
+LEFT JOIN pg_namespace tn ON tn.oid = t.relnamespace
+WHERE s.relkind = 'S' AND n.nspname = ANY(current_schemas(false))
+ORDER BY n.nspname, s.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sequences: %w", err)
+	}
+	defer rows.Close()
+
+	dumped := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		dumped[t.Sanitize()] = true
+	}
+
+	var sequences []pgx.Identifier
+	for rows.Next() {
+		var schema, name string
+		var ownerSchema, ownerName *string
+		if err := rows.Scan(&schema, &name, &ownerSchema, &ownerName); err != nil {
+			return nil, fmt.Errorf("failed to list sequences: %w", err)
+		}
+		if ownedOnly && (ownerName == nil || !dumped[pgx.Identifier{*ownerSchema, *ownerName}.Sanitize()]) {
+			continue
+		}
+		sequences = append(sequences, pgx.Identifier{schema, name})
+	}
+	return sequences, rows.Err()
+}
+
+// schemasOutsideSearchPath lists the user schemas that are not part of the
+// connection's search_path.
+func schemasOutsideSearchPath(ctx context.Context, conn *pgx.Conn) ([]string, error) {
+	rows, err := conn.Query(ctx, `SELECT nspname FROM pg_namespace
+WHERE nspname <> ALL(current_schemas(false))
+	AND nspname <> 'information_schema' AND nspname NOT LIKE 'pg\_%'
+ORDER BY nspname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to list schemas: %w", err)
+		}
+		schemas = append(schemas, name)
+	}
+	return schemas, rows.Err()
+}
+
+// countViews counts the views and materialized views in the search_path.
+func countViews(ctx context.Context, conn *pgx.Conn) (int, error) {
+	var n int
+	err := conn.QueryRow(ctx, `SELECT count(*)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('v', 'm') AND n.nspname = ANY(current_schemas(false))`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count views: %w", err)
+	}
+	return n, nil
+}
+
+// pgDumper runs pg_dump for the schema sections of a dump, reading from an
+// exported snapshot so its output matches the copied data.
+type pgDumper struct {
+	binary    string
+	cfg       connConfig
+	snapshot  string
+	selectors []string
+}
+
+// section writes one pg_dump section ("pre-data" or "post-data") to w.
+// Ownership and grants are left out so the dump restores as any user.
+func (d *pgDumper) section(ctx context.Context, w io.Writer, section string) error {
+	binary, err := exec.LookPath(d.binary)
+	if err != nil {
+		return fmt.Errorf("%s is required to dump the schema (set schema = false for a data-only dump): %w", d.binary, err)
+	}
+
+	args := []string{
+		"--section=" + section,
+		"--snapshot=" + d.snapshot,
+		"--no-owner",
+		"--no-privileges",
+	}
+	cmd := exec.CommandContext(ctx, binary, append(args, d.selectors...)...)
+	// Connection settings go through the environment so the password never
+	// appears on a command line.
+	cmd.Env = append(os.Environ(),
+		"PGHOST="+d.cfg.host,
+		"PGPORT="+strconv.Itoa(d.cfg.port),
+		"PGDATABASE="+d.cfg.database,
+		"PGUSER="+d.cfg.user,
+		"PGPASSWORD="+d.cfg.password,
+		"PGSSLMODE="+d.cfg.sslmode,
+		"PGCONNECT_TIMEOUT="+strconv.Itoa(int(d.cfg.timeout.Seconds())),
+	)
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("pg_dump --section=%s failed: %s", section, msg)
+	}
+	return nil
+}
+
+// quoteLiteral quotes s as a SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePgDump writes a pg_dump stand-in that prints its arguments and the
+// connection environment, or fails with a message on stderr.
+func fakePgDump(t *testing.T, fail bool) string {
+	t.Helper()
+	script := `#!/bin/sh
+for arg in "$@"; do echo "arg $arg"; done
+echo "env $PGHOST $PGPORT $PGDATABASE $PGUSER $PGPASSWORD $PGSSLMODE $PGCONNECT_TIMEOUT"
+`
+	if fail {
+		script = "#!/bin/sh\necho 'pg_dump: error: aborting because of server version mismatch' >&2\nexit 1\n"
+	}
+	path := filepath.Join(t.TempDir(), "pg_dump")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testDumper(binary string) *pgDumper {
+	return &pgDumper{
+		binary: binary,
+		cfg: connConfig{
+			host:     "db.internal",
+			port:     5433,
+			database: "shop",
+			user:     "app",
+			password: "s3cret",
+			sslmode:  "require",
+			timeout:  10 * time.Second,
+		},
+		snapshot:  "00000003-0000001B-1",
+		selectors: []string{`--table="public"."orders"`, `--table="public"."order lines"`},
+	}
+}
+
+func TestPgDumperSection(t *testing.T) {
+	var out bytes.Buffer
+	if err := testDumper(fakePgDump(t, false)).section(context.Background(), &out, "pre-data"); err != nil {
+		t.Fatalf("section: %v", err)
+	}
+
+	want := []string{
+		"arg --section=pre-data",
+		"arg --snapshot=00000003-0000001B-1",
+		"arg --no-owner",
+		"arg --no-privileges",
+		`arg --table="public"."orders"`,
+		`arg --table="public"."order lines"`,
+		"env db.internal 5433 shop app s3cret require 10",
+	}
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("pg_dump saw:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for _, line := range got {
+		if strings.HasPrefix(line, "arg ") && strings.Contains(line, "s3cret") {
+			t.Errorf("password passed on the command line: %s", line)
+		}
+	}
+}
+
+func TestPgDumperSectionFailure(t *testing.T) {
+	err := testDumper(fakePgDump(t, true)).section(context.Background(), &bytes.Buffer{}, "post-data")
+	if err == nil || !strings.Contains(err.Error(), "server version mismatch") {
+		t.Errorf("error = %v, want pg_dump's stderr", err)
+	}
+}
+
+func TestPgDumperMissingBinary(t *testing.T) {
+	err := testDumper(filepath.Join(t.TempDir(), "pg_dump")).section(context.Background(), &bytes.Buffer{}, "pre-data")
+	if err == nil || !strings.Contains(err.Error(), "schema = false") {
+		t.Errorf("error = %v, want a hint about data-only dumps", err)
+	}
+}
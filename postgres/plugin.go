@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+type PostgresPlugin struct{}
+
+func (p *PostgresPlugin) Metadata() plugin.Metadata {
+	return plugin.Metadata{
+		Name:        "postgres",
+		Version:     "1.0.0",
+		Description: "PostgreSQL database operations and management",
+		Author:      "Corynth Team",
+		Tags:        []string{"database", "postgres", "postgresql", "sql", "rdbms"},
+		License:     "Apache-2.0",
+	}
+}
+
+func (p *PostgresPlugin) Actions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "query",
+			Description: "Execute a SELECT query",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"query": {
+					Type:        "string",
+					Description: "SQL query to execute",
+					Required:    true,
+				},
+				"params": {
+					Type:        "array",
+					Description: "Values bound to $1, $2, ... placeholders",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"rows": {
+					Type:        "array",
+					Description: "Query result rows as column name to value maps",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of rows returned",
+				},
+				"columns": {
+					Type:        "array",
+					Description: "Column metadata (name, type)",
+				},
+			},
+		},
+		{
+			Name:        "execute",
+			Description: "Execute an INSERT, UPDATE, or DELETE statement",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"statement": {
+					Type:        "string",
+					Description: "SQL statement to execute",
+					Required:    true,
+				},
+				"params": {
+					Type:        "array",
+					Description: "Values bound to $1, $2, ... placeholders",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"affected_rows": {
+					Type:        "number",
+					Description: "Number of rows affected",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the operation succeeded",
+				},
+			},
+		},
+		{
+			Name:        "backup",
+			Description: "Dump schema and data from one consistent snapshot as a script restorable with psql -f",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"output_file": {
+					Type:        "string",
+					Description: "Path to backup file",
+					Required:    true,
+				},
+				"tables": {
+					Type:        "array",
+					Description: "Tables to include, as table or schema.table (default: all in search_path)",
+					Required:    false,
+				},
+				"compress": {
+					Type:        "boolean",
+					Description: "Gzip the dump (default: true when output_file ends in .gz)",
+					Required:    false,
+				},
+				"disable_triggers": {
+					Type:        "boolean",
+					Description: "Disable triggers and foreign key checks while the dump is restored (restore needs superuser)",
+					Required:    false,
+					Default:     false,
+				},
+				"schema": {
+					Type:        "boolean",
+					Description: "Include CREATE statements for tables, views, sequences, indexes and constraints. Needs pg_dump (see pg_dump_path) and fails before connecting if it is missing; false writes a data-only dump without it",
+					Required:    false,
+					Default:     true,
+				},
+				"pg_dump_path": {
+					Type:        "string",
+					Description: "pg_dump binary used for the schema, no older than the server",
+					Required:    false,
+					Default:     "pg_dump",
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"file": {
+					Type:        "string",
+					Description: "Path to the backup file",
+				},
+				"size": {
+					Type:        "number",
+					Description: "Size of backup in bytes",
+				},
+				"checksum": {
+					Type:        "string",
+					Description: "SHA-256 of the backup file",
+				},
+				"tables": {
+					Type:        "number",
+					Description: "Number of tables dumped",
+				},
+				"rows": {
+					Type:        "number",
+					Description: "Number of rows dumped",
+				},
+			},
+		},
+		{
+			Name:        "export",
+			Description: "Bulk export the result of a query to a file using COPY",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"query": {
+					Type:        "string",
+					Description: "SELECT query or table name to export",
+					Required:    true,
+				},
+				"output_file": {
+					Type:        "string",
+					Description: "Path to the export file",
+					Required:    true,
+				},
+				"format": {
+					Type:        "string",
+					Description: "COPY format: csv, text or binary",
+					Required:    false,
+					Default:     "csv",
+				},
+				"header": {
+					Type:        "boolean",
+					Description: "Write a header row (csv only)",
+					Required:    false,
+					Default:     true,
+				},
+				"compress": {
+					Type:        "boolean",
+					Description: "Gzip the export (default: true when output_file ends in .gz)",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"file": {
+					Type:        "string",
+					Description: "Path to the export file",
+				},
+				"rows": {
+					Type:        "number",
+					Description: "Number of rows exported",
+				},
+				"size": {
+					Type:        "number",
+					Description: "Size of the export file in bytes",
+				},
+				"checksum": {
+					Type:        "string",
+					Description: "SHA-256 of the export file",
+				},
+			},
+		},
+	}
+}
+
+// withConnectionInputs adds the connection settings accepted by every action.
+func withConnectionInputs(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["host"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "PostgreSQL host",
+		Required:    false,
+		Default:     "localhost",
+	}
+	inputs["port"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "PostgreSQL port",
+		Required:    false,
+		Default:     5432,
+	}
+	inputs["database"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Database name",
+		Required:    true,
+	}
+	inputs["user"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Database user",
+		Required:    true,
+	}
+	inputs["password"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Database password",
+		Required:    false,
+	}
+	inputs["sslmode"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "SSL mode: disable, allow, prefer, require, verify-ca or verify-full",
+		Required:    false,
+		Default:     "prefer",
+	}
+	inputs["search_path"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Schema search path for the session (e.g. app,public)",
+		Required:    false,
+	}
+	inputs["timeout"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "Connection timeout in seconds",
+		Required:    false,
+		Default:     10,
+	}
+	return inputs
+}
+
+func (p *PostgresPlugin) Validate(params map[string]interface{}) error {
+	if database, ok := params["database"].(string); ok && database == "" {
+		return fmt.Errorf("database name cannot be empty")
+	}
+
+	if user, ok := params["user"].(string); ok && user == "" {
+		return fmt.Errorf("user cannot be empty")
+	}
+
+	if port, ok := params["port"].(float64); ok {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("port must be between 1 and 65535")
+		}
+	}
+
+	return nil
+}
+
+func (p *PostgresPlugin) Execute(ctx context.Context, action string, params map[string]interface{}) (map[string]interface{}, error) {
+	switch action {
+	case "query":
+		return p.executeQuery(ctx, params)
+	case "execute":
+		return p.executeStatement(ctx, params)
+	case "backup":
+		return p.executeBackup(ctx, params)
+	case "export":
+		return p.executeExport(ctx, params)
+	default:
+		return nil, fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+func (p *PostgresPlugin) executeQuery(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	query, ok := params["query"].(string)
+	if !ok || query == "" {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+
+	args, err := bindArgs(params["params"])
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	result, columns, err := collectRows(conn, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"rows":    result,
+		"count":   len(result),
+		"columns": columns,
+		"message": fmt.Sprintf("Executed query on %s", cfg),
+	}, nil
+}
+
+func (p *PostgresPlugin) executeStatement(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	statement, ok := params["statement"].(string)
+	if !ok || statement == "" {
+		return nil, fmt.Errorf("statement parameter is required")
+	}
+
+	args, err := bindArgs(params["params"])
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	tag, err := conn.Exec(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("statement failed: %w", err)
+	}
+
+	return map[string]interface{}{
+		"affected_rows": tag.RowsAffected(),
+		"success":       true,
+		"command":       tag.String(),
+		"message":       fmt.Sprintf("Executed statement on %s", cfg),
+	}, nil
+}
+
+func (p *PostgresPlugin) executeBackup(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	outputFile, ok := params["output_file"].(string)
+	if !ok || outputFile == "" {
+		return nil, fmt.Errorf("output_file parameter is required")
+	}
+
+	compress := strings.HasSuffix(outputFile, ".gz")
+	if c, ok := params["compress"].(bool); ok {
+		compress = c
+	}
+	opts := dumpOptions{
+		tables: stringList(params, "tables"),
+		schema: true,
+		pgDump: "pg_dump",
+	}
+	opts.disableTriggers, _ = params["disable_triggers"].(bool)
+	if s, ok := params["schema"].(bool); ok {
+		opts.schema = s
+	}
+	if path, ok := params["pg_dump_path"].(string); ok && path != "" {
+		opts.pgDump = path
+	}
+	if opts.schema {
+		binary, err := exec.LookPath(opts.pgDump)
+		if err != nil {
+			return nil, fmt.Errorf("%s is required to back up the schema; install the PostgreSQL client tools, set pg_dump_path, or set schema = false for a data-only dump: %w", opts.pgDump, err)
+		}
+		opts.pgDump = binary
+	}
+
+	conn, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	var stats dumpStats
+	var uncompressed int64
+	size, checksum, err := writeFileAtomically(outputFile, compress, func(w io.Writer) error {
+		counter := &countingWriter{w: w}
+		var err error
+		stats, err = writeDump(ctx, conn, cfg, counter, opts)
+		uncompressed = counter.n
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backup failed: %w", err)
+	}
+
+	return map[string]interface{}{
+		"file":              outputFile,
+		"size":              size,
+		"uncompressed_size": uncompressed,
+		"checksum":          checksum,
+		"compressed":        compress,
+		"tables":            stats.tables,
+		"views":             stats.views,
+		"rows":              stats.rows,
+		"message":           fmt.Sprintf("Backed up database %s to %s (%d bytes)", cfg, outputFile, size),
+	}, nil
+}
+
+func (p *PostgresPlugin) executeExport(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	query, ok := params["query"].(string)
+	if !ok || query == "" {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+	outputFile, ok := params["output_file"].(string)
+	if !ok || outputFile == "" {
+		return nil, fmt.Errorf("output_file parameter is required")
+	}
+
+	format := "csv"
+	if f, ok := params["format"].(string); ok && f != "" {
+		format = strings.ToLower(f)
+	}
+	if format != "csv" && format != "text" && format != "binary" {
+		return nil, fmt.Errorf("format must be csv, text or binary")
+	}
+	header := true
+	if h, ok := params["header"].(bool); ok {
+		header = h
+	}
+	compress := strings.HasSuffix(outputFile, ".gz")
+	if c, ok := params["compress"].(bool); ok {
+		compress = c
+	}
+
+	// A bare table name is exported in full.
+	if !strings.ContainsAny(strings.TrimSpace(query), " \t\n") {
+		query = "SELECT * FROM " + query
+	}
+
+	conn, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	var rows int64
+	size, checksum, err := writeFileAtomically(outputFile, compress, func(w io.Writer) error {
+		var err error
+		rows, err = copyQueryTo(ctx, conn, w, query, format, header)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("export failed: %w", err)
+	}
+
+	return map[string]interface{}{
+		"file":     outputFile,
+		"rows":     rows,
+		"size":     size,
+		"checksum": checksum,
+		"format":   format,
+		"message":  fmt.Sprintf("Exported %d rows from %s to %s", rows, cfg, outputFile),
+	}, nil
+}
+
+var ExportedPlugin plugin.Plugin = &PostgresPlugin{}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// pgResult is the canned reply of fakePostgres to one statement.
+type pgResult struct {
+	params  []uint32
+	columns []pgproto3.FieldDescription
+	rows    [][]*string
+	tag     string
+	copy    string
+	err     string
+}
+
+// pgCall is a statement fakePostgres ran, with its bound arguments in text
+// form.
+type pgCall struct {
+	query string
+	args  []string
+}
+
+// fakePostgres is an in-process server speaking enough of the PostgreSQL
+// wire protocol for pgx: startup without authentication, the simple and
+// extended query protocols and COPY TO STDOUT.
+type fakePostgres struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	results map[string]pgResult
+	calls   []pgCall
+	conns   int
+}
+
+func newFakePostgres(t *testing.T) *fakePostgres {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakePostgres{ln: ln, results: map[string]pgResult{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakePostgres) handle(query string, res pgResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[query] = res
+}
+
+func (f *fakePostgres) params(extra map[string]interface{}) map[string]interface{} {
+	params := map[string]interface{}{
+		"host":     "127.0.0.1",
+		"port":     float64(f.ln.Addr().(*net.TCPAddr).Port),
+		"database": "shop",
+		"user":     "app",
+		"sslmode":  "disable",
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return params
+}
+
+func (f *fakePostgres) seen() []pgCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]pgCall(nil), f.calls...)
+}
+
+func (f *fakePostgres) result(query string) pgResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	res, ok := f.results[query]
+	if !ok {
+		return pgResult{err: "unexpected statement: " + query}
+	}
+	return res
+}
+
+func (f *fakePostgres) record(query string, args []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, pgCall{query: query, args: args})
+}
+
+func (f *fakePostgres) serve(conn net.Conn) {
+	defer conn.Close()
+	be := pgproto3.NewBackend(conn, conn)
+
+	if _, err := be.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	f.mu.Lock()
+	f.conns++
+	f.mu.Unlock()
+	be.Send(&pgproto3.AuthenticationOk{})
+	for name, value := range map[string]string{"server_version": "16.4", "client_encoding": "UTF8", "standard_conforming_strings": "on"} {
+		be.Send(&pgproto3.ParameterStatus{Name: name, Value: value})
+	}
+	be.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if be.Flush() != nil {
+		return
+	}
+
+	type portal struct {
+		query   string
+		formats []int16
+	}
+	statements := map[string]string{}
+	portals := map[string]portal{}
+	failed := false
+	fail := func(msg string) {
+		be.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42P01", Message: msg})
+		failed = true
+	}
+
+	for {
+		msg, err := be.Receive()
+		if err != nil {
+			return
+		}
+		if _, sync := msg.(*pgproto3.Sync); failed && !sync {
+			continue
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Parse:
+			if res := f.result(msg.Query); res.err != "" {
+				fail(res.err)
+				continue
+			}
+			statements[msg.Name] = msg.Query
+			be.Send(&pgproto3.ParseComplete{})
+		case *pgproto3.Describe:
+			query := statements[msg.Name]
+			var formats []int16
+			if msg.ObjectType == 'P' {
+				query, formats = portals[msg.Name].query, portals[msg.Name].formats
+			} else {
+				be.Send(&pgproto3.ParameterDescription{ParameterOIDs: f.result(query).params})
+			}
+			res := f.result(query)
+			if len(res.columns) == 0 {
+				be.Send(&pgproto3.NoData{})
+				continue
+			}
+			// A portal is described with the result formats it was bound with.
+			fields := append([]pgproto3.FieldDescription(nil), res.columns...)
+			for i := range fields {
+				fields[i].Format = formatCode(formats, i)
+			}
+			be.Send(&pgproto3.RowDescription{Fields: fields})
+		case *pgproto3.Bind:
+			query := statements[msg.PreparedStatement]
+			oids := f.result(query).params
+			args := make([]string, len(msg.Parameters))
+			for i, value := range msg.Parameters {
+				args[i] = decodeParam(oids[i], formatCode(msg.ParameterFormatCodes, i), value)
+			}
+			f.record(query, args)
+			portals[msg.DestinationPortal] = portal{query: query, formats: msg.ResultFormatCodes}
+			be.Send(&pgproto3.BindComplete{})
+		case *pgproto3.Execute:
+			p := portals[msg.Portal]
+			res := f.result(p.query)
+			for _, row := range res.rows {
+				be.Send(&pgproto3.DataRow{Values: encodeRow(res.columns, p.formats, row)})
+			}
+			be.Send(&pgproto3.CommandComplete{CommandTag: []byte(res.tag)})
+		case *pgproto3.Query:
+			f.record(msg.String, nil)
+			res := f.result(msg.String)
+			switch {
+			case res.err != "":
+				fail(res.err)
+			case res.copy != "":
+				be.Send(&pgproto3.CopyOutResponse{})
+				be.Send(&pgproto3.CopyData{Data: []byte(res.copy)})
+				be.Send(&pgproto3.CopyDone{})
+				be.Send(&pgproto3.CommandComplete{CommandTag: []byte(res.tag)})
+			default:
+				if len(res.columns) > 0 {
+					be.Send(&pgproto3.RowDescription{Fields: res.columns})
+					for _, row := range res.rows {
+						be.Send(&pgproto3.DataRow{Values: encodeRow(res.columns, nil, row)})
+					}
+				}
+				be.Send(&pgproto3.CommandComplete{CommandTag: []byte(res.tag)})
+			}
+			failed = false
+			be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if be.Flush() != nil {
+				return
+			}
+		case *pgproto3.Sync:
+			failed = false
+			be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if be.Flush() != nil {
+				return
+			}
+		case *pgproto3.Close:
+			be.Send(&pgproto3.CloseComplete{})
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+// formatCode applies the protocol's rules for per-value format codes: none
+// means text, one applies to every value.
+func formatCode(codes []int16, i int) int16 {
+	switch len(codes) {
+	case 0:
+		return pgtype.TextFormatCode
+	case 1:
+		return codes[0]
+	}
+	return codes[i]
+}
+
+func decodeParam(oid uint32, format int16, value []byte) string {
+	if value == nil {
+		return "NULL"
+	}
+	if format == pgtype.TextFormatCode {
+		return string(value)
+	}
+	switch oid {
+	case pgtype.Int8OID:
+		return strconv.FormatInt(int64(binary.BigEndian.Uint64(value)), 10)
+	case pgtype.BoolOID:
+		return strconv.FormatBool(value[0] == 1)
+	}
+	return string(value)
+}
+
+func encodeRow(columns []pgproto3.FieldDescription, formats []int16, row []*string) [][]byte {
+	values := make([][]byte, len(row))
+	for i, v := range row {
+		if v == nil {
+			continue
+		}
+		if formatCode(formats, i) == pgtype.TextFormatCode {
+			values[i] = []byte(*v)
+			continue
+		}
+		switch columns[i].DataTypeOID {
+		case pgtype.Int8OID:
+			n, _ := strconv.ParseInt(*v, 10, 64)
+			values[i] = binary.BigEndian.AppendUint64(nil, uint64(n))
+		case pgtype.Int4OID:
+			n, _ := strconv.ParseInt(*v, 10, 32)
+			values[i] = binary.BigEndian.AppendUint32(nil, uint32(n))
+		case pgtype.Float8OID:
+			x, _ := strconv.ParseFloat(*v, 64)
+			values[i] = binary.BigEndian.AppendUint64(nil, math.Float64bits(x))
+		case pgtype.BoolOID:
+			values[i] = []byte{0}
+			if *v == "t" {
+				values[i][0] = 1
+			}
+		default:
+			values[i] = []byte(*v)
+		}
+	}
+	return values
+}
+
+func column(name string, oid uint32) pgproto3.FieldDescription {
+	return pgproto3.FieldDescription{Name: []byte(name), DataTypeOID: oid, DataTypeSize: -1, TypeModifier: -1}
+}
+
+func text(values ...string) []*string {
+	row := make([]*string, len(values))
+	for i := range values {
+		if values[i] != "\x00" {
+			row[i] = &values[i]
+		}
+	}
+	return row
+}
+
+// null marks a NULL value in a text row.
+const null = "\x00"
+
+func TestQuery(t *testing.T) {
+	f := newFakePostgres(t)
+	query := "SELECT id, customer, paid, total, note FROM orders WHERE id > $1 AND status = $2"
+	f.handle(query, pgResult{
+		params: []uint32{pgtype.Int8OID, pgtype.TextOID},
+		columns: []pgproto3.FieldDescription{
+			column("id", pgtype.Int8OID),
+			column("customer", pgtype.TextOID),
+			column("paid", pgtype.BoolOID),
+			column("total", pgtype.Float8OID),
+			column("note", pgtype.TextOID),
+		},
+		rows: [][]*string{
+			text("7", "alice", "t", "19.5", null),
+			text("8", "bob", "f", "4", "gift"),
+		},
+		tag: "SELECT 2",
+	})
+
+	out, err := (&PostgresPlugin{}).Execute(context.Background(), "query", f.params(map[string]interface{}{
+		"query":  query,
+		"params": []interface{}{float64(6), "open"},
+	}))
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+
+	if calls := f.seen(); len(calls) != 1 || !reflect.DeepEqual(calls[0].args, []string{"6", "open"}) {
+		t.Errorf("calls = %v, want the params bound once", calls)
+	}
+	want := []map[string]interface{}{
+		{"id": int64(7), "customer": "alice", "paid": true, "total": 19.5, "note": nil},
+		{"id": int64(8), "customer": "bob", "paid": false, "total": float64(4), "note": "gift"},
+	}
+	if !reflect.DeepEqual(out["rows"], want) || out["count"] != 2 {
+		t.Errorf("rows = %v, count = %v", out["rows"], out["count"])
+	}
+	columns := out["columns"].([]map[string]interface{})
+	var types []string
+	for _, c := range columns {
+		types = append(types, c["name"].(string)+" "+c["type"].(string))
+	}
+	if want := []string{"id int8", "customer text", "paid bool", "total float8", "note text"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("columns = %v, want %v", types, want)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	f := newFakePostgres(t)
+	f.handle("SELECT * FROM missing", pgResult{err: `relation "missing" does not exist`})
+
+	_, err := (&PostgresPlugin{}).Execute(context.Background(), "query", f.params(map[string]interface{}{
+		"query": "SELECT * FROM missing",
+	}))
+	if err == nil || !strings.HasPrefix(err.Error(), "query failed: ") || !strings.Contains(err.Error(), `relation "missing" does not exist`) {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	f := newFakePostgres(t)
+	f.handle("UPDATE orders SET status = 'shipped' WHERE paid", pgResult{tag: "UPDATE 3"})
+	f.handle("DELETE FROM orders WHERE id = $1", pgResult{params: []uint32{pgtype.Int8OID}, tag: "DELETE 1"})
+	p := &PostgresPlugin{}
+
+	out, err := p.Execute(context.Background(), "execute", f.params(map[string]interface{}{
+		"statement": "UPDATE orders SET status = 'shipped' WHERE paid",
+	}))
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if out["affected_rows"] != int64(3) || out["command"] != "UPDATE 3" || out["success"] != true {
+		t.Errorf("output = %v", out)
+	}
+
+	out, err = p.Execute(context.Background(), "execute", f.params(map[string]interface{}{
+		"statement": "DELETE FROM orders WHERE id = $1",
+		"params":    []interface{}{float64(42)},
+	}))
+	if err != nil {
+		t.Fatalf("execute with params: %v", err)
+	}
+	if out["affected_rows"] != int64(1) {
+		t.Errorf("affected_rows = %v", out["affected_rows"])
+	}
+	calls := f.seen()
+	if last := calls[len(calls)-1]; !reflect.DeepEqual(last.args, []string{"42"}) {
+		t.Errorf("bound args = %v, want [42]", last.args)
+	}
+
+	_, err = p.Execute(context.Background(), "execute", f.params(map[string]interface{}{"statement": "DROP TABLE nope"}))
+	if err == nil || !strings.HasPrefix(err.Error(), "statement failed: ") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	f := newFakePostgres(t)
+	csv := "id,customer\n7,alice\n8,bob\n"
+	f.handle("COPY (SELECT * FROM orders) TO STDOUT WITH (FORMAT csv, HEADER true)", pgResult{copy: csv, tag: "COPY 2"})
+	f.handle("COPY (SELECT id FROM orders WHERE paid) TO STDOUT WITH (FORMAT text)", pgResult{copy: "7\n", tag: "COPY 1"})
+	dir := t.TempDir()
+	p := &PostgresPlugin{}
+
+	file := filepath.Join(dir, "orders.csv.gz")
+	out, err := p.Execute(context.Background(), "export", f.params(map[string]interface{}{
+		"query":       "orders",
+		"output_file": file,
+	}))
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if out["rows"] != int64(2) || out["format"] != "csv" {
+		t.Errorf("output = %v", out)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("export of a .gz file is not gzipped: %v", err)
+	}
+	var plain bytes.Buffer
+	plain.ReadFrom(gz)
+	if plain.String() != csv || out["size"] != int64(len(data)) {
+		t.Errorf("file = %q (%d bytes), size output %v", plain.String(), len(data), out["size"])
+	}
+
+	file = filepath.Join(dir, "paid.txt")
+	if _, err := p.Execute(context.Background(), "export", f.params(map[string]interface{}{
+		"query":       "SELECT id FROM orders WHERE paid;",
+		"output_file": file,
+		"format":      "TEXT",
+	})); err != nil {
+		t.Fatalf("text export: %v", err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "7\n" {
+		t.Errorf("text export = %q", data)
+	}
+
+	_, err = p.Execute(context.Background(), "export", f.params(map[string]interface{}{
+		"query":       "orders",
+		"output_file": filepath.Join(dir, "orders.xml"),
+		"format":      "xml",
+	}))
+	if err == nil || err.Error() != "format must be csv, text or binary" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestBackupRequiresPgDumpForSchema(t *testing.T) {
+	f := newFakePostgres(t)
+	t.Setenv("PATH", t.TempDir())
+	output := filepath.Join(t.TempDir(), "backup.sql")
+
+	_, err := (&PostgresPlugin{}).Execute(context.Background(), "backup", f.params(map[string]interface{}{
+		"output_file": output,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "pg_dump is required") || !strings.Contains(err.Error(), "schema = false") {
+		t.Errorf("error = %v, want the missing pg_dump and the data-only hint", err)
+	}
+	f.mu.Lock()
+	conns := f.conns
+	f.mu.Unlock()
+	if conns != 0 {
+		t.Errorf("connected %d times before checking for pg_dump", conns)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("backup file left behind: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+workflow "postgres-operations" {
+  description = "PostgreSQL queries, bulk export and backup"
+  version     = "1.0.0"
+
+  variable "pg_host" {
+    type        = string
+    default     = "localhost"
+    description = "PostgreSQL server host"
+  }
+
+  variable "database_name" {
+    type        = string
+    default     = "testdb"
+    description = "Database name to manage"
+  }
+
+  variable "pg_user" {
+    type        = string
+    default     = "postgres"
+    description = "PostgreSQL username"
+  }
+
+  variable "pg_password" {
+    type        = string
+    default     = "password123"
+    description = "PostgreSQL password"
+  }
+
+  step "create_users_table" {
+    plugin = "postgres"
+    action = "execute"
+
+    params = {
+      host        = var.pg_host
+      database    = var.database_name
+      user        = var.pg_user
+      password    = var.pg_password
+      sslmode     = "disable"
+      search_path = "public"
+      statement   = <<-EOF
+        CREATE TABLE IF NOT EXISTS users (
+          id SERIAL PRIMARY KEY,
+          username VARCHAR(50) NOT NULL UNIQUE,
+          email VARCHAR(100) NOT NULL,
+          created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        )
+      EOF
+    }
+  }
+
+  step "insert_user" {
+    plugin = "postgres"
+    action = "execute"
+
+    depends_on = ["create_users_table"]
+
+    params = {
+      host      = var.pg_host
+      database  = var.database_name
+      user      = var.pg_user
+      password  = var.pg_password
+      sslmode   = "disable"
+      statement = "INSERT INTO users (username, email) VALUES ($1, $2) ON CONFLICT (username) DO UPDATE SET email = EXCLUDED.email"
+      params    = ["john_doe", "john@example.com"]
+    }
+  }
+
+  step "query_users" {
+    plugin = "postgres"
+    action = "query"
+
+    depends_on = ["insert_user"]
+
+    params = {
+      host     = var.pg_host
+      database = var.database_name
+      user     = var.pg_user
+      password = var.pg_password
+      sslmode  = "disable"
+      query    = "SELECT id, username, email, created_at FROM users WHERE username = $1"
+      params   = ["john_doe"]
+    }
+  }
+
+  step "export_users" {
+    plugin = "postgres"
+    action = "export"
+
+    depends_on = ["query_users"]
+
+    params = {
+      host        = var.pg_host
+      database    = var.database_name
+      user        = var.pg_user
+      password    = var.pg_password
+      sslmode     = "disable"
+      query       = "SELECT id, username, email FROM users ORDER BY id"
+      output_file = "/tmp/${var.database_name}_users.csv"
+    }
+  }
+
+  step "create_backup" {
+    plugin = "postgres"
+    action = "backup"
+
+    depends_on = ["export_users"]
+
+    params = {
+      host        = var.pg_host
+      database    = var.database_name
+      user        = var.pg_user
+      password    = var.pg_password
+      sslmode     = "disable"
+      output_file = "/tmp/${var.database_name}_backup.sql.gz"
+    }
+  }
+
+  step "verify_backup" {
+    plugin = "shell"
+    action = "exec"
+
+    depends_on = ["create_backup"]
+
+    params = {
+      command = "echo 'Backed up ${create_backup.rows} rows from ${create_backup.tables} tables and ${create_backup.views} views (sha256 ${create_backup.checksum}). Restore with: gunzip -c ${create_backup.file} | psql -v ON_ERROR_STOP=1 -d <new database>. Users found: ${query_users.count}'"
+    }
+  }
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisError is an error reply sent by the server (e.g. "WRONGTYPE ...").
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// redisPush is an out-of-band RESP3 push message, used for pub/sub delivery.
+type redisPush []interface{}
+
+// redisConfig holds the connection settings shared by every redis action.
+type redisConfig struct {
+	host          string
+	port          int
+	username      string
+	password      string
+	db            int
+	protocol      int
+	tls           bool
+	tlsSkipVerify bool
+	tlsCAFile     string
+	timeout       time.Duration
+}
+
+func (c redisConfig) addr() string {
+	return net.JoinHostPort(c.host, strconv.Itoa(c.port))
+}
+
+func configFromParams(params map[string]interface{}) redisConfig {
+	cfg := redisConfig{
+		host:     "localhost",
+		port:     intParam(params, "port", 6379),
+		db:       intParam(params, "db", 0),
+		protocol: intParam(params, "protocol", 2),
+		timeout:  time.Duration(intParam(params, "timeout", 10)) * time.Second,
+	}
+	if h, ok := params["host"].(string); ok && h != "" {
+		cfg.host = h
+	}
+	cfg.username, _ = params["username"].(string)
+	cfg.password, _ = params["password"].(string)
+	cfg.tls, _ = params["tls"].(bool)
+	cfg.tlsSkipVerify, _ = params["tls_skip_verify"].(bool)
+	cfg.tlsCAFile, _ = params["tls_ca_file"].(string)
+	return cfg
+}
+
+// redisClient is a minimal RESP2/RESP3 client holding a single connection.
+type redisClient struct {
+	conn     net.Conn
+	rd       *bufio.Reader
+	wr       *bufio.Writer
+	timeout  time.Duration
+	protocol int
+}
+
+// dialRedis connects to the server described by cfg, negotiates the protocol
+// version, authenticates and selects the configured database.
+func dialRedis(ctx context.Context, cfg redisConfig) (*redisClient, error) {
+	dialer := &net.Dialer{Timeout: cfg.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", cfg.addr())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", cfg.addr(), err)
+	}
+
+	if cfg.tls {
+		tlsConfig := &tls.Config{
+			ServerName:         cfg.host,
+			InsecureSkipVerify: cfg.tlsSkipVerify,
+		}
+		if cfg.tlsCAFile != "" {
+			pem, err := os.ReadFile(cfg.tlsCAFile)
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				conn.Close()
+				return nil, fmt.Errorf("no certificates found in %s", cfg.tlsCAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	c := &redisClient{
+		conn:     conn,
+		rd:       bufio.NewReader(conn),
+		wr:       bufio.NewWriter(conn),
+		timeout:  cfg.timeout,
+		protocol: 2,
+	}
+
+	if err := c.handshake(ctx, cfg); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *redisClient) handshake(ctx context.Context, cfg redisConfig) error {
+	authenticated := false
+	if cfg.protocol == 3 {
+		args := []interface{}{"HELLO", 3}
+		if cfg.password != "" {
+			username := cfg.username
+			if username == "" {
+				username = "default"
+			}
+			args = append(args, "AUTH", username, cfg.password)
+		}
+		_, err := c.Do(ctx, args...)
+		var rerr redisError
+		switch {
+		case err == nil:
+			c.protocol = 3
+			authenticated = true
+		case errors.As(err, &rerr) && strings.HasPrefix(string(rerr), "ERR"):
+			// Server predates RESP3; continue with RESP2 below.
+		default:
+			return fmt.Errorf("HELLO failed: %w", err)
+		}
+	}
+
+	if !authenticated && cfg.password != "" {
+		args := []interface{}{"AUTH", cfg.password}
+		if cfg.username != "" {
+			args = []interface{}{"AUTH", cfg.username, cfg.password}
+		}
+		if _, err := c.Do(ctx, args...); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if cfg.db != 0 {
+		if _, err := c.Do(ctx, "SELECT", cfg.db); err != nil {
+			return fmt.Errorf("failed to select db %d: %w", cfg.db, err)
+		}
+	}
+	return nil
+}
+
+func (c *redisClient) Close() error {
+	return c.conn.Close()
+}
+
+// Do sends a single command and returns its reply. Error replies from the
+// server are returned as redisError.
+func (c *redisClient) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	if err := c.Send(ctx, args...); err != nil {
+		return nil, err
+	}
+	for {
+		reply, err := c.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Push messages are not replies to the command we just sent.
+		if _, ok := reply.(redisPush); ok {
+			continue
+		}
+		if rerr, ok := reply.(redisError); ok {
+			return nil, rerr
+		}
+		return reply, nil
+	}
+}
+
+// Send writes a command without waiting for its reply.
+func (c *redisClient) Send(ctx context.Context, args ...interface{}) error {
+	stop := c.watch(ctx)
+	defer stop()
+
+	fmt.Fprintf(c.wr, "*%d\r\n", len(args))
+	for _, arg := range args {
+		s := argString(arg)
+		fmt.Fprintf(c.wr, "$%d\r\n%s\r\n", len(s), s)
+	}
+	if err := c.wr.Flush(); err != nil {
+		return c.ioError(ctx, err)
+	}
+	return nil
+}
+
+// Receive reads the next reply from the connection. Server errors are
+// returned as a redisError value rather than as err.
+func (c *redisClient) Receive(ctx context.Context) (interface{}, error) {
+	stop := c.watch(ctx)
+	defer stop()
+
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, c.ioError(ctx, err)
+	}
+	return reply, nil
+}
+
+// watch applies the per-command timeout and the context deadline to the
+// connection, and unblocks pending I/O if ctx is cancelled.
+func (c *redisClient) watch(ctx context.Context) func() {
+	deadline := time.Time{}
+	if c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	c.conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Now())
+	})
+	return func() { stop() }
+}
+
+func (c *redisClient) ioError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	// The connection deadline is the context's, and can expire a moment
+	// before the context itself reports it.
+	var netErr net.Error
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) && errors.As(err, &netErr) && netErr.Timeout() {
+		return context.DeadlineExceeded
+	}
+	return fmt.Errorf("redis connection error: %w", err)
+}
+
+func (c *redisClient) readLine() (string, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("malformed RESP line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+func (c *redisClient) readBulk(n int) (string, error) {
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(c.rd, buf); err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+func (c *redisClient) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	kind, body := line[0], line[1:]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return redisError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '_':
+		return nil, nil
+	case '#':
+		return body == "t", nil
+	case ',':
+		switch body {
+		case "inf":
+			return math.Inf(1), nil
+		case "-inf":
+			return math.Inf(-1), nil
+		}
+		return strconv.ParseFloat(body, 64)
+	case '(':
+		return body, nil
+	case '$', '!', '=':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		s, err := c.readBulk(n)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case '!':
+			return redisError(s), nil
+		case '=':
+			// Verbatim strings carry a three letter format prefix, e.g. "txt:".
+			if len(s) >= 4 {
+				s = s[4:]
+			}
+		}
+		return s, nil
+	case '*', '~', '>':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		if kind == '>' {
+			return redisPush(items), nil
+		}
+		return items, nil
+	case '%', '|':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid map length %q", body)
+		}
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			k, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			v, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = v
+		}
+		if kind == '|' {
+			// Attributes annotate the reply that follows; skip them.
+			return c.readReply()
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown RESP type %q", kind)
+	}
+}
+
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// intParam reads a numeric parameter, accepting the float64 values produced
+// by HCL as well as plain ints and numeric strings.
+func intParam(params map[string]interface{}, name string, def int) int {
+	switch v := params[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// stringList reads a parameter that may be given as a single string or as an
+// array of values.
+func stringList(params map[string]interface{}, name string) []string {
+	switch v := params[name].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []string:
+		return append([]string(nil), v...)
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, argString(item))
+		}
+		return out
+	}
+	return nil
+}
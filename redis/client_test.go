@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process RESP server implementing just enough of the
+// protocol for the client tests: HELLO, AUTH, SELECT, SET, GET, EXISTS,
+// INCRBY, EXPIRE and PERSIST. BLPOP never replies, to exercise cancellation
+// of in-flight reads. Other commands are answered from canned replies.
+type fakeRedis struct {
+	ln net.Listener
+	fakeRedisOptions
+
+	mu       sync.Mutex
+	data     map[int]map[string]string
+	commands [][]string
+}
+
+// fakeRedisOptions configure a fakeRedis. They are fixed before the server
+// accepts its first connection.
+type fakeRedisOptions struct {
+	username string
+	password string
+	resp3    bool
+	replies  map[string]string
+}
+
+func newFakeRedis(t *testing.T, opts ...func(*fakeRedisOptions)) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeRedis{ln: ln, data: map[int]map[string]string{}}
+	for _, opt := range opts {
+		opt(&s.fakeRedisOptions)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// withAuth makes the server require AUTH as user, or as the default user
+// when user is empty.
+func withAuth(user, password string) func(*fakeRedisOptions) {
+	return func(o *fakeRedisOptions) {
+		o.username, o.password = user, password
+	}
+}
+
+// withRESP3 makes the server accept HELLO.
+func withRESP3(o *fakeRedisOptions) {
+	o.resp3 = true
+}
+
+// withReply makes the server answer every command named command with raw,
+// which is written to the connection as is and may hold several frames.
+func withReply(command, raw string) func(*fakeRedisOptions) {
+	return func(o *fakeRedisOptions) {
+		if o.replies == nil {
+			o.replies = map[string]string{}
+		}
+		o.replies[command] = raw
+	}
+}
+
+func (s *fakeRedis) params(extra map[string]interface{}) map[string]interface{} {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	params := map[string]interface{}{
+		"host":    "127.0.0.1",
+		"port":    float64(addr.Port),
+		"timeout": float64(5),
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return params
+}
+
+func (s *fakeRedis) seen() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.commands...)
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	wr := bufio.NewWriter(conn)
+	authed := s.password == ""
+	db := 0
+	proto := 2
+
+	null := func() string {
+		if proto == 3 {
+			return "_\r\n"
+		}
+		return "$-1\r\n"
+	}
+	bulk := func(v string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v) }
+
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		s.mu.Unlock()
+
+		name := strings.ToUpper(args[0])
+		var reply string
+		switch {
+		case name == "HELLO":
+			if !s.resp3 {
+				reply = "-ERR unknown command 'HELLO'\r\n"
+				break
+			}
+			if len(args) >= 5 && strings.EqualFold(args[2], "AUTH") {
+				if args[3] != s.userOrDefault() || args[4] != s.password {
+					reply = "-WRONGPASS invalid username-password pair\r\n"
+					break
+				}
+				authed = true
+			}
+			if !authed {
+				reply = "-NOAUTH HELLO must be called with the client already authenticated\r\n"
+				break
+			}
+			proto, _ = strconv.Atoi(args[1])
+			reply = "%2\r\n" + bulk("server") + bulk("redis") + bulk("proto") + ":" + args[1] + "\r\n"
+		case name == "AUTH":
+			user, pass := "default", args[len(args)-1]
+			if len(args) == 3 {
+				user = args[1]
+			}
+			if user != s.userOrDefault() || pass != s.password {
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+				break
+			}
+			authed = true
+			reply = "+OK\r\n"
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case s.replies[name] != "":
+			reply = s.replies[name]
+		case name == "SELECT":
+			db, _ = strconv.Atoi(args[1])
+			reply = "+OK\r\n"
+		case name == "SET":
+			s.mu.Lock()
+			if s.data[db] == nil {
+				s.data[db] = map[string]string{}
+			}
+			s.data[db][args[1]] = args[2]
+			s.mu.Unlock()
+			reply = "+OK\r\n"
+		case name == "GET":
+			s.mu.Lock()
+			v, ok := s.data[db][args[1]]
+			s.mu.Unlock()
+			if ok {
+				reply = bulk(v)
+			} else {
+				reply = null()
+			}
+		case name == "EXISTS":
+			n := 0
+			s.mu.Lock()
+			for _, key := range args[1:] {
+				if _, ok := s.data[db][key]; ok {
+					n++
+				}
+			}
+			s.mu.Unlock()
+			reply = fmt.Sprintf(":%d\r\n", n)
+		case name == "INCRBY":
+			by, _ := strconv.Atoi(args[2])
+			s.mu.Lock()
+			if s.data[db] == nil {
+				s.data[db] = map[string]string{}
+			}
+			n, _ := strconv.Atoi(s.data[db][args[1]])
+			n += by
+			s.data[db][args[1]] = strconv.Itoa(n)
+			s.mu.Unlock()
+			reply = fmt.Sprintf(":%d\r\n", n)
+		case name == "EXPIRE" || name == "PERSIST":
+			s.mu.Lock()
+			_, ok := s.data[db][args[1]]
+			s.mu.Unlock()
+			reply = ":0\r\n"
+			if ok {
+				reply = ":1\r\n"
+			}
+		case name == "BLPOP":
+			continue
+		default:
+			reply = fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+		}
+		wr.WriteString(reply)
+		if err := wr.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) userOrDefault() string {
+	if s.username == "" {
+		return "default"
+	}
+	return s.username
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected array, got %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestSetGet(t *testing.T) {
+	srv := newFakeRedis(t)
+	p := &RedisPlugin{}
+	ctx := context.Background()
+
+	out, err := p.Execute(ctx, "set", srv.params(map[string]interface{}{"key": "greeting", "value": "hello"}))
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if out["success"] != true {
+		t.Errorf("set success = %v, want true", out["success"])
+	}
+
+	out, err = p.Execute(ctx, "get", srv.params(map[string]interface{}{"key": "greeting"}))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if out["exists"] != true || out["value"] != "hello" {
+		t.Errorf("get = %v, want exists with value hello", out)
+	}
+
+	out, err = p.Execute(ctx, "get", srv.params(map[string]interface{}{"key": "missing"}))
+	if err != nil {
+		t.Fatalf("get missing: %v", err)
+	}
+	if out["exists"] != false || out["value"] != "" {
+		t.Errorf("get missing = %v, want not exists", out)
+	}
+}
+
+func TestSetWithTTL(t *testing.T) {
+	srv := newFakeRedis(t)
+	_, err := (&RedisPlugin{}).Execute(context.Background(), "set",
+		srv.params(map[string]interface{}{"key": "k", "value": "v", "ttl": float64(60)}))
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	cmds := srv.seen()
+	got := strings.Join(cmds[len(cmds)-1], " ")
+	if got != "SET k v EX 60" {
+		t.Errorf("command = %q, want %q", got, "SET k v EX 60")
+	}
+}
+
+func TestExists(t *testing.T) {
+	srv := newFakeRedis(t)
+	ctx := context.Background()
+	c, err := dialRedis(ctx, configFromParams(srv.params(nil)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do(ctx, "SET", "a", "1"); err != nil {
+		t.Fatalf("SET: %v", err)
+	}
+	reply, err := c.Do(ctx, "EXISTS", "a", "b")
+	if err != nil {
+		t.Fatalf("EXISTS: %v", err)
+	}
+	if reply != int64(1) {
+		t.Errorf("EXISTS = %#v, want int64(1)", reply)
+	}
+}
+
+func TestAuthAndSelect(t *testing.T) {
+	srv := newFakeRedis(t, withAuth("app", "s3cret"))
+	ctx := context.Background()
+	p := &RedisPlugin{}
+
+	conn := map[string]interface{}{"username": "app", "password": "s3cret", "db": float64(3)}
+	set := srv.params(conn)
+	set["key"], set["value"] = "k", "in-db-3"
+	if _, err := p.Execute(ctx, "set", set); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	cmds := srv.seen()
+	if len(cmds) < 3 {
+		t.Fatalf("expected AUTH, SELECT and SET, got %v", cmds)
+	}
+	if got := strings.Join(cmds[0], " "); got != "AUTH app s3cret" {
+		t.Errorf("first command = %q, want AUTH app s3cret", got)
+	}
+	if got := strings.Join(cmds[1], " "); got != "SELECT 3" {
+		t.Errorf("second command = %q, want SELECT 3", got)
+	}
+
+	// The key lives in db 3 only.
+	get := srv.params(map[string]interface{}{"username": "app", "password": "s3cret", "key": "k"})
+	out, err := p.Execute(ctx, "get", get)
+	if err != nil {
+		t.Fatalf("get db 0: %v", err)
+	}
+	if out["exists"] != false {
+		t.Errorf("key visible in db 0: %v", out)
+	}
+
+	get["db"] = float64(3)
+	out, err = p.Execute(ctx, "get", get)
+	if err != nil {
+		t.Fatalf("get db 3: %v", err)
+	}
+	if out["value"] != "in-db-3" {
+		t.Errorf("get db 3 = %v", out)
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	srv := newFakeRedis(t, withAuth("", "s3cret"))
+
+	_, err := dialRedis(context.Background(), configFromParams(srv.params(map[string]interface{}{"password": "wrong"})))
+	if err == nil {
+		t.Fatal("expected authentication to fail")
+	}
+	var rerr redisError
+	if !errors.As(err, &rerr) || !strings.HasPrefix(string(rerr), "WRONGPASS") {
+		t.Errorf("error = %v, want WRONGPASS redisError", err)
+	}
+}
+
+func TestHelloRESP3(t *testing.T) {
+	srv := newFakeRedis(t, withRESP3, withAuth("", "s3cret"))
+	ctx := context.Background()
+
+	c, err := dialRedis(ctx, configFromParams(srv.params(map[string]interface{}{"password": "s3cret", "protocol": float64(3)})))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	if c.protocol != 3 {
+		t.Errorf("protocol = %d, want 3", c.protocol)
+	}
+	cmds := srv.seen()
+	if got := strings.Join(cmds[0], " "); got != "HELLO 3 AUTH default s3cret" {
+		t.Errorf("handshake = %q, want HELLO with AUTH", got)
+	}
+	for _, cmd := range cmds {
+		if cmd[0] == "AUTH" {
+			t.Errorf("separate AUTH sent after a successful HELLO: %v", cmds)
+		}
+	}
+
+	// RESP3 nulls decode to nil like RESP2 null bulk strings.
+	reply, err := c.Do(ctx, "GET", "missing")
+	if err != nil || reply != nil {
+		t.Errorf("GET missing = %#v, %v; want nil", reply, err)
+	}
+}
+
+func TestHelloFallbackToRESP2(t *testing.T) {
+	srv := newFakeRedis(t, withAuth("", "s3cret"))
+	ctx := context.Background()
+
+	c, err := dialRedis(ctx, configFromParams(srv.params(map[string]interface{}{"password": "s3cret", "protocol": float64(3)})))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	if c.protocol != 2 {
+		t.Errorf("protocol = %d, want 2 after HELLO is rejected", c.protocol)
+	}
+	cmds := srv.seen()
+	if len(cmds) < 2 || cmds[0][0] != "HELLO" || strings.Join(cmds[1], " ") != "AUTH s3cret" {
+		t.Errorf("handshake = %v, want HELLO then AUTH", cmds)
+	}
+	if _, err := c.Do(ctx, "SET", "k", "v"); err != nil {
+		t.Errorf("SET after fallback: %v", err)
+	}
+}
+
+func TestContextCancelDuringRead(t *testing.T) {
+	srv := newFakeRedis(t)
+	c, err := dialRedis(context.Background(), configFromParams(srv.params(nil)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = c.Do(ctx, "BLPOP", "queue", 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancel took %v to unblock the read", elapsed)
+	}
+}
+
+func TestContextDeadlineDuringRead(t *testing.T) {
+	srv := newFakeRedis(t)
+	c, err := dialRedis(context.Background(), configFromParams(srv.params(nil)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = c.Do(ctx, "BLPOP", "queue", 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("deadline took %v to unblock the read", elapsed)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	srv := newFakeRedis(t)
+	cfg := configFromParams(srv.params(nil))
+	cfg.timeout = 50 * time.Millisecond
+	c, err := dialRedis(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	_, err = c.Do(context.Background(), "BLPOP", "queue", 0)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("error = %v, want a timeout", err)
+	}
+}
+
+func TestIncrReturnsInt(t *testing.T) {
+	srv := newFakeRedis(t)
+	p := &RedisPlugin{}
+	ctx := context.Background()
+
+	out, err := p.Execute(ctx, "incr", srv.params(map[string]interface{}{"key": "counter", "by": float64(5)}))
+	if err != nil {
+		t.Fatalf("incr: %v", err)
+	}
+	if out["value"] != 5 {
+		t.Errorf("incr value = %#v, want int 5", out["value"])
+	}
+
+	out, err = p.Execute(ctx, "decr", srv.params(map[string]interface{}{"key": "counter"}))
+	if err != nil {
+		t.Fatalf("decr: %v", err)
+	}
+	if out["value"] != 4 {
+		t.Errorf("decr value = %#v, want int 4", out["value"])
+	}
+}
+
+func TestExpire(t *testing.T) {
+	srv := newFakeRedis(t)
+	p := &RedisPlugin{}
+	ctx := context.Background()
+	if _, err := p.Execute(ctx, "set", srv.params(map[string]interface{}{"key": "session", "value": "x"})); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	out, err := p.Execute(ctx, "expire", srv.params(map[string]interface{}{"key": "session", "ttl": float64(60)}))
+	if err != nil || out["success"] != true {
+		t.Fatalf("expire = %v, %v", out, err)
+	}
+	out, err = p.Execute(ctx, "expire", srv.params(map[string]interface{}{"key": "session", "persist": true}))
+	if err != nil || out["success"] != true {
+		t.Fatalf("persist = %v, %v", out, err)
+	}
+	var sent []string
+	for _, cmd := range srv.seen() {
+		if cmd[0] == "EXPIRE" || cmd[0] == "PERSIST" {
+			sent = append(sent, strings.Join(cmd, " "))
+		}
+	}
+	if want := []string{"EXPIRE session 60", "PERSIST session"}; strings.Join(sent, ", ") != strings.Join(want, ", ") {
+		t.Errorf("commands = %v, want %v", sent, want)
+	}
+
+	invalid := []map[string]interface{}{
+		{"key": "session"},
+		{"key": "session", "ttl": float64(0)},
+		{"key": "session", "ttl": float64(-5)},
+		{"key": "session", "ttl": float64(60), "persist": true},
+	}
+	before := len(srv.seen())
+	for _, params := range invalid {
+		if _, err := p.Execute(ctx, "expire", srv.params(params)); err == nil {
+			t.Errorf("expire %v succeeded", params)
+		}
+	}
+	if n := len(srv.seen()) - before; n != 0 {
+		t.Errorf("invalid expire sent %d commands", n)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// Lua scripts that only touch the lock while it is still held by token, so a
+// holder whose lease expired cannot release or extend someone else's lock.
+const (
+	lockReleaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+	lockExtendScript  = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+)
+
+// lockActions declares the distributed lock actions.
+func lockActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "lock_acquire",
+			Description: "Acquire a distributed lock, waiting for it to become free",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Lock key",
+					Required:    true,
+				},
+				"lease": {
+					Type:        "number",
+					Description: "Lease duration in milliseconds before the lock expires on its own",
+					Required:    false,
+					Default:     30000,
+				},
+				"wait": {
+					Type:        "number",
+					Description: "Seconds to keep retrying while the lock is held elsewhere (0 = try once)",
+					Required:    false,
+					Default:     0,
+				},
+				"retry_interval": {
+					Type:        "number",
+					Description: "Milliseconds between acquisition attempts",
+					Required:    false,
+					Default:     250,
+				},
+				"token": {
+					Type:        "string",
+					Description: "Owner token to store in the lock (random if omitted)",
+					Required:    false,
+				},
+				"fail_if_locked": {
+					Type:        "boolean",
+					Description: "Fail the step if the lock could not be acquired",
+					Required:    false,
+					Default:     true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"acquired": {
+					Type:        "boolean",
+					Description: "Whether the lock was acquired",
+				},
+				"token": {
+					Type:        "string",
+					Description: "Owner token required to release or extend the lock",
+				},
+				"attempts": {
+					Type:        "number",
+					Description: "Number of acquisition attempts made",
+				},
+			},
+		},
+		{
+			Name:        "lock_release",
+			Description: "Release a distributed lock held with the given token",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Lock key",
+					Required:    true,
+				},
+				"token": {
+					Type:        "string",
+					Description: "Owner token returned by lock_acquire",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"released": {
+					Type:        "boolean",
+					Description: "Whether the lock was held by token and has been released",
+				},
+			},
+		},
+		{
+			Name:        "lock_extend",
+			Description: "Extend the lease of a distributed lock held with the given token",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Lock key",
+					Required:    true,
+				},
+				"token": {
+					Type:        "string",
+					Description: "Owner token returned by lock_acquire",
+					Required:    true,
+				},
+				"lease": {
+					Type:        "number",
+					Description: "New lease duration in milliseconds",
+					Required:    false,
+					Default:     30000,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"extended": {
+					Type:        "boolean",
+					Description: "Whether the lock was held by token and its lease was extended",
+				},
+			},
+		},
+	}
+}
+
+func (p *RedisPlugin) executeLockAcquire(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	lease := intParam(params, "lease", 30000)
+	if lease <= 0 {
+		return nil, fmt.Errorf("lease must be positive")
+	}
+	wait := time.Duration(intParam(params, "wait", 0)) * time.Second
+	interval := time.Duration(intParam(params, "retry_interval", 250)) * time.Millisecond
+	if interval <= 0 {
+		return nil, fmt.Errorf("retry_interval must be positive")
+	}
+	failIfLocked := true
+	if f, ok := params["fail_if_locked"].(bool); ok {
+		failIfLocked = f
+	}
+
+	token, _ := params["token"].(string)
+	if token == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate lock token: %w", err)
+		}
+		token = hex.EncodeToString(buf)
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	// Retries stop at whichever comes first: the wait budget or the step's own
+	// deadline/cancellation.
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	attempts := 0
+	for {
+		attempts++
+		reply, err := client.Do(ctx, "SET", key, token, "NX", "PX", lease)
+		if err != nil {
+			return nil, fmt.Errorf("SET NX failed: %w", err)
+		}
+		if reply == "OK" {
+			return map[string]interface{}{
+				"acquired": true,
+				"token":    token,
+				"key":      key,
+				"attempts": attempts,
+			}, nil
+		}
+
+		if waitCtx.Err() != nil {
+			break
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-waitCtx.Done():
+			timer.Stop()
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if failIfLocked {
+		return nil, fmt.Errorf("lock '%s' is held by another owner (gave up after %d attempts)", key, attempts)
+	}
+	return map[string]interface{}{
+		"acquired": false,
+		"token":    "",
+		"key":      key,
+		"attempts": attempts,
+	}, nil
+}
+
+func (p *RedisPlugin) executeLockRelease(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	token, ok := params["token"].(string)
+	if !ok || token == "" {
+		return nil, fmt.Errorf("token parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "EVAL", lockReleaseScript, 1, key, token)
+	if err != nil {
+		return nil, fmt.Errorf("lock release failed: %w", err)
+	}
+
+	return map[string]interface{}{
+		"released": reply == int64(1),
+		"key":      key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeLockExtend(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	token, ok := params["token"].(string)
+	if !ok || token == "" {
+		return nil, fmt.Errorf("token parameter is required")
+	}
+	lease := intParam(params, "lease", 30000)
+	if lease <= 0 {
+		return nil, fmt.Errorf("lease must be positive")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "EVAL", lockExtendScript, 1, key, token, lease)
+	if err != nil {
+		return nil, fmt.Errorf("lock extend failed: %w", err)
+	}
+
+	return map[string]interface{}{
+		"extended": reply == int64(1),
+		"key":      key,
+		"lease":    lease,
+	}, nil
+}
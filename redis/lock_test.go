@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLockAcquireRejectsNonPositiveRetryInterval(t *testing.T) {
+	srv := newFakeRedis(t)
+	for _, interval := range []float64{0, -100} {
+		params := srv.params(map[string]interface{}{"key": "deploy", "wait": float64(1), "retry_interval": interval})
+		_, err := (&RedisPlugin{}).Execute(context.Background(), "lock_acquire", params)
+		if err == nil || !strings.Contains(err.Error(), "retry_interval") {
+			t.Errorf("retry_interval=%v: error = %v, want retry_interval validation error", interval, err)
+		}
+	}
+	if cmds := srv.seen(); len(cmds) != 0 {
+		t.Errorf("invalid input reached the server: %v", cmds)
+	}
+}
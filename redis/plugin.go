@@ -21,23 +21,11 @@ func (p *RedisPlugin) Metadata() plugin.Metadata {
 }
 
 func (p *RedisPlugin) Actions() []plugin.Action {
-	return []plugin.Action{
+	actions := []plugin.Action{
 		{
 			Name:        "set",
 			Description: "Set a key-value pair in Redis",
-			Inputs: map[string]plugin.InputSpec{
-				"host": {
-					Type:        "string",
-					Description: "Redis host",
-					Required:    false,
-					Default:     "localhost",
-				},
-				"port": {
-					Type:        "number",
-					Description: "Redis port",
-					Required:    false,
-					Default:     6379,
-				},
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
 				"key": {
 					Type:        "string",
 					Description: "Key to set",
@@ -54,7 +42,7 @@ func (p *RedisPlugin) Actions() []plugin.Action {
 					Required:    false,
 					Default:     0,
 				},
-			},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"success": {
 					Type:        "boolean",
@@ -65,37 +53,293 @@ func (p *RedisPlugin) Actions() []plugin.Action {
 		{
 			Name:        "get",
 			Description: "Get a value from Redis by key",
-			Inputs: map[string]plugin.InputSpec{
-				"host": {
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Key to retrieve",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"exists": {
+					Type:        "boolean",
+					Description: "Whether the key exists",
+				},
+				"value": {
+					Type:        "string",
+					Description: "The value for the key",
+				},
+			},
+		},
+		{
+			Name:        "delete",
+			Description: "Delete one or more keys from Redis",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Key to delete",
+					Required:    false,
+				},
+				"keys": {
+					Type:        "array",
+					Description: "Keys to delete",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"deleted": {
+					Type:        "number",
+					Description: "Number of keys that were removed",
+				},
+			},
+		},
+		{
+			Name:        "list",
+			Description: "List keys matching a pattern using SCAN",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"pattern": {
+					Type:        "string",
+					Description: "Glob-style pattern passed to SCAN MATCH",
+					Required:    false,
+					Default:     "*",
+				},
+				"count": {
+					Type:        "number",
+					Description: "COUNT hint for each SCAN iteration",
+					Required:    false,
+					Default:     100,
+				},
+				"type": {
 					Type:        "string",
-					Description: "Redis host",
+					Description: "Only return keys of this type (string, list, set, zset, hash, stream)",
 					Required:    false,
-					Default:     "localhost",
 				},
-				"port": {
+				"limit": {
 					Type:        "number",
-					Description: "Redis port",
+					Description: "Maximum number of keys to return (0 = no limit)",
 					Required:    false,
-					Default:     6379,
+					Default:     1000,
 				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"keys": {
+					Type:        "array",
+					Description: "Matching keys",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of keys returned",
+				},
+				"truncated": {
+					Type:        "boolean",
+					Description: "Whether the scan stopped early because of limit",
+				},
+			},
+		},
+		{
+			Name:        "expire",
+			Description: "Set a timeout on a key",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
 				"key": {
 					Type:        "string",
-					Description: "Key to retrieve",
+					Description: "Key to expire",
 					Required:    true,
 				},
+				"ttl": {
+					Type:        "number",
+					Description: "Time to live in seconds; must be positive. Required unless persist is set",
+					Required:    false,
+				},
+				"persist": {
+					Type:        "boolean",
+					Description: "Remove the key's existing expiry instead of setting one",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the timeout was updated",
+				},
 			},
+		},
+		{
+			Name:        "ttl",
+			Description: "Get the remaining time to live of a key",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Key to inspect",
+					Required:    true,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
+				"ttl": {
+					Type:        "number",
+					Description: "Remaining time to live in seconds (-1 = no expiry, -2 = missing)",
+				},
 				"exists": {
 					Type:        "boolean",
 					Description: "Whether the key exists",
 				},
+			},
+		},
+		{
+			Name:        "incr",
+			Description: "Increment the integer value of a key",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Key to increment",
+					Required:    true,
+				},
+				"by": {
+					Type:        "number",
+					Description: "Amount to increment by",
+					Required:    false,
+					Default:     1,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
 				"value": {
+					Type:        "number",
+					Description: "Value after the increment",
+				},
+			},
+		},
+		{
+			Name:        "decr",
+			Description: "Decrement the integer value of a key",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
 					Type:        "string",
-					Description: "The value for the key",
+					Description: "Key to decrement",
+					Required:    true,
+				},
+				"by": {
+					Type:        "number",
+					Description: "Amount to decrement by",
+					Required:    false,
+					Default:     1,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"value": {
+					Type:        "number",
+					Description: "Value after the decrement",
+				},
+			},
+		},
+		{
+			Name:        "mget",
+			Description: "Get the values of several keys at once",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"keys": {
+					Type:        "array",
+					Description: "Keys to retrieve",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"values": {
+					Type:        "object",
+					Description: "Map of key to value for keys that exist",
+				},
+				"missing": {
+					Type:        "array",
+					Description: "Keys that do not exist",
+				},
+			},
+		},
+		{
+			Name:        "mset",
+			Description: "Set several key-value pairs atomically",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"values": {
+					Type:        "object",
+					Description: "Map of key to value",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the operation succeeded",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of keys set",
 				},
 			},
 		},
 	}
+	actions = append(actions, dataStructureActions()...)
+	actions = append(actions, lockActions()...)
+	actions = append(actions, pubSubActions()...)
+	return actions
+}
+
+// withConnectionInputs adds the connection settings accepted by every action.
+func withConnectionInputs(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["host"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Redis host",
+		Required:    false,
+		Default:     "localhost",
+	}
+	inputs["port"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "Redis port",
+		Required:    false,
+		Default:     6379,
+	}
+	inputs["username"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "ACL username (Redis 6+)",
+		Required:    false,
+	}
+	inputs["password"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Password for AUTH",
+		Required:    false,
+	}
+	inputs["db"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "Database number to SELECT",
+		Required:    false,
+		Default:     0,
+	}
+	inputs["protocol"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "RESP protocol version (2 or 3)",
+		Required:    false,
+		Default:     2,
+	}
+	inputs["tls"] = plugin.InputSpec{
+		Type:        "boolean",
+		Description: "Connect using TLS",
+		Required:    false,
+		Default:     false,
+	}
+	inputs["tls_skip_verify"] = plugin.InputSpec{
+		Type:        "boolean",
+		Description: "Skip TLS certificate verification",
+		Required:    false,
+		Default:     false,
+	}
+	inputs["tls_ca_file"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "PEM file with CA certificates to trust",
+		Required:    false,
+	}
+	inputs["timeout"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "Connect and command timeout in seconds",
+		Required:    false,
+		Default:     10,
+	}
+	return inputs
 }
 
 func (p *RedisPlugin) Validate(params map[string]interface{}) error {
@@ -108,6 +352,60 @@ func (p *RedisPlugin) Execute(ctx context.Context, action string, params map[str
 		return p.executeSet(ctx, params)
 	case "get":
 		return p.executeGet(ctx, params)
+	case "delete":
+		return p.executeDelete(ctx, params)
+	case "list":
+		return p.executeList(ctx, params)
+	case "expire":
+		return p.executeExpire(ctx, params)
+	case "ttl":
+		return p.executeTTL(ctx, params)
+	case "incr":
+		return p.executeIncr(ctx, params, 1)
+	case "decr":
+		return p.executeIncr(ctx, params, -1)
+	case "mget":
+		return p.executeMGet(ctx, params)
+	case "mset":
+		return p.executeMSet(ctx, params)
+	case "hset":
+		return p.executeHSet(ctx, params)
+	case "hget":
+		return p.executeHGet(ctx, params)
+	case "hgetall":
+		return p.executeHGetAll(ctx, params)
+	case "hdel":
+		return p.executeHDel(ctx, params)
+	case "lpush":
+		return p.executePush(ctx, params, "LPUSH")
+	case "rpush":
+		return p.executePush(ctx, params, "RPUSH")
+	case "lpop":
+		return p.executeLPop(ctx, params)
+	case "lrange":
+		return p.executeLRange(ctx, params)
+	case "sadd":
+		return p.executeSetMembers(ctx, params, "SADD")
+	case "srem":
+		return p.executeSetMembers(ctx, params, "SREM")
+	case "smembers":
+		return p.executeSMembers(ctx, params)
+	case "xadd":
+		return p.executeXAdd(ctx, params)
+	case "xrange":
+		return p.executeXRange(ctx, params)
+	case "xread":
+		return p.executeXRead(ctx, params)
+	case "lock_acquire":
+		return p.executeLockAcquire(ctx, params)
+	case "lock_release":
+		return p.executeLockRelease(ctx, params)
+	case "lock_extend":
+		return p.executeLockExtend(ctx, params)
+	case "publish":
+		return p.executePublish(ctx, params)
+	case "subscribe":
+		return p.executeSubscribe(ctx, params)
 	default:
 		return nil, fmt.Errorf("unknown action: %s", action)
 	}
@@ -124,22 +422,28 @@ func (p *RedisPlugin) executeSet(ctx context.Context, params map[string]interfac
 		return nil, fmt.Errorf("value parameter is required")
 	}
 
-	host := "localhost"
-	if h, ok := params["host"].(string); ok {
-		host = h
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"SET", key, value}
+	if ttl := intParam(params, "ttl", 0); ttl > 0 {
+		args = append(args, "EX", ttl)
 	}
 
-	port := 6379
-	if p, ok := params["port"].(float64); ok {
-		port = int(p)
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("SET failed: %w", err)
 	}
 
-	// In production, this would connect to Redis
 	return map[string]interface{}{
-		"success": true,
+		"success": reply == "OK",
 		"key":     key,
 		"value":   value,
-		"message": fmt.Sprintf("Successfully set key '%s' in Redis at %s:%d", key, host, port),
+		"message": fmt.Sprintf("Successfully set key '%s' in Redis at %s", key, cfg.addr()),
 	}, nil
 }
 
@@ -149,22 +453,297 @@ func (p *RedisPlugin) executeGet(ctx context.Context, params map[string]interfac
 		return nil, fmt.Errorf("key parameter is required")
 	}
 
-	host := "localhost"
-	if h, ok := params["host"].(string); ok {
-		host = h
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
 	}
+	defer client.Close()
 
-	port := 6379
-	if p, ok := params["port"].(float64); ok {
-		port = int(p)
+	reply, err := client.Do(ctx, "GET", key)
+	if err != nil {
+		return nil, fmt.Errorf("GET failed: %w", err)
+	}
+
+	if reply == nil {
+		return map[string]interface{}{
+			"exists":  false,
+			"value":   "",
+			"key":     key,
+			"message": fmt.Sprintf("Key '%s' not found in Redis at %s", key, cfg.addr()),
+		}, nil
 	}
 
-	// In production, this would connect to Redis
 	return map[string]interface{}{
 		"exists":  true,
-		"value":   fmt.Sprintf("mock_value_for_%s", key),
+		"value":   argString(reply),
 		"key":     key,
-		"message": fmt.Sprintf("Retrieved key '%s' from Redis at %s:%d", key, host, port),
+		"message": fmt.Sprintf("Retrieved key '%s' from Redis at %s", key, cfg.addr()),
+	}, nil
+}
+
+func (p *RedisPlugin) executeDelete(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	keys := stringList(params, "keys")
+	if key, ok := params["key"].(string); ok && key != "" {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key or keys parameter is required")
+	}
+
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"DEL"}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DEL failed: %w", err)
+	}
+	deleted, _ := reply.(int64)
+
+	return map[string]interface{}{
+		"deleted": int(deleted),
+		"keys":    keys,
+		"message": fmt.Sprintf("Deleted %d of %d keys from Redis at %s", deleted, len(keys), cfg.addr()),
+	}, nil
+}
+
+func (p *RedisPlugin) executeList(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	pattern := "*"
+	if pat, ok := params["pattern"].(string); ok && pat != "" {
+		pattern = pat
+	}
+	count := intParam(params, "count", 100)
+	limit := intParam(params, "limit", 1000)
+	keyType, _ := params["type"].(string)
+
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	keys := []string{}
+	seen := make(map[string]bool)
+	cursor := "0"
+	truncated := false
+	for {
+		args := []interface{}{"SCAN", cursor, "MATCH", pattern, "COUNT", count}
+		if keyType != "" {
+			args = append(args, "TYPE", keyType)
+		}
+		reply, err := client.Do(ctx, args...)
+		if err != nil {
+			return nil, fmt.Errorf("SCAN failed: %w", err)
+		}
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply: %v", reply)
+		}
+		cursor = argString(page[0])
+		batch, _ := page[1].([]interface{})
+		for _, k := range batch {
+			// SCAN may return a key more than once while the keyspace is rehashing.
+			key := argString(k)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keys = append(keys, key)
+		}
+		if limit > 0 && len(keys) >= limit {
+			truncated = len(keys) > limit || cursor != "0"
+			if len(keys) > limit {
+				keys = keys[:limit]
+			}
+			break
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+
+	return map[string]interface{}{
+		"keys":      keys,
+		"count":     len(keys),
+		"truncated": truncated,
+		"pattern":   pattern,
+		"message":   fmt.Sprintf("Found %d keys matching '%s' in Redis at %s", len(keys), pattern, cfg.addr()),
+	}, nil
+}
+
+func (p *RedisPlugin) executeExpire(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	persist, _ := params["persist"].(bool)
+	_, hasTTL := params["ttl"]
+	ttl := intParam(params, "ttl", 0)
+	switch {
+	case persist && hasTTL:
+		return nil, fmt.Errorf("ttl and persist cannot be combined")
+	case !persist && !hasTTL:
+		return nil, fmt.Errorf("ttl parameter is required")
+	case !persist && ttl <= 0:
+		return nil, fmt.Errorf("ttl must be a positive number of seconds; set persist = true to remove an expiry")
+	}
+
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	var reply interface{}
+	if persist {
+		reply, err = client.Do(ctx, "PERSIST", key)
+		if err != nil {
+			return nil, fmt.Errorf("PERSIST failed: %w", err)
+		}
+	} else {
+		reply, err = client.Do(ctx, "EXPIRE", key, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("EXPIRE failed: %w", err)
+		}
+	}
+
+	return map[string]interface{}{
+		"success": reply == int64(1),
+		"key":     key,
+		"ttl":     ttl,
+	}, nil
+}
+
+func (p *RedisPlugin) executeTTL(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "TTL", key)
+	if err != nil {
+		return nil, fmt.Errorf("TTL failed: %w", err)
+	}
+	ttl, _ := reply.(int64)
+
+	return map[string]interface{}{
+		"ttl":    int(ttl),
+		"exists": ttl != -2,
+		"key":    key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeIncr(ctx context.Context, params map[string]interface{}, sign int) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	by := intParam(params, "by", 1) * sign
+
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "INCRBY", key, by)
+	if err != nil {
+		return nil, fmt.Errorf("INCRBY failed: %w", err)
+	}
+	value, _ := reply.(int64)
+
+	return map[string]interface{}{
+		"value": int(value),
+		"key":   key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeMGet(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	keys := stringList(params, "keys")
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keys parameter is required")
+	}
+
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"MGET"}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("MGET failed: %w", err)
+	}
+	items, _ := reply.([]interface{})
+	if len(items) != len(keys) {
+		return nil, fmt.Errorf("unexpected MGET reply length %d for %d keys", len(items), len(keys))
+	}
+
+	values := make(map[string]interface{})
+	missing := []string{}
+	for i, key := range keys {
+		if items[i] == nil {
+			missing = append(missing, key)
+			continue
+		}
+		values[key] = argString(items[i])
+	}
+
+	return map[string]interface{}{
+		"values":  values,
+		"missing": missing,
+		"count":   len(values),
+	}, nil
+}
+
+func (p *RedisPlugin) executeMSet(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	values, ok := params["values"].(map[string]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("values parameter is required and must be an object")
+	}
+
+	cfg := configFromParams(params)
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"MSET"}
+	for key, value := range values {
+		args = append(args, key, value)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("MSET failed: %w", err)
+	}
+
+	return map[string]interface{}{
+		"success": reply == "OK",
+		"count":   len(values),
 	}, nil
 }
 
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// pubSubActions declares the publish and subscribe actions.
+func pubSubActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "publish",
+			Description: "Publish a message to a channel",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"channel": {
+					Type:        "string",
+					Description: "Channel to publish to",
+					Required:    true,
+				},
+				"message": {
+					Type:        "string",
+					Description: "Message payload",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"receivers": {
+					Type:        "number",
+					Description: "Number of subscribers that received the message",
+				},
+			},
+		},
+		{
+			Name:        "subscribe",
+			Description: "Listen on channels or patterns until enough messages arrive or the wait elapses",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"channels": {
+					Type:        "array",
+					Description: "Channels to subscribe to",
+					Required:    false,
+				},
+				"patterns": {
+					Type:        "array",
+					Description: "Glob-style channel patterns to subscribe to",
+					Required:    false,
+				},
+				"count": {
+					Type:        "number",
+					Description: "Stop after this many messages (0 = listen for the whole wait)",
+					Required:    false,
+					Default:     1,
+				},
+				"wait": {
+					Type:        "number",
+					Description: "Maximum seconds to listen",
+					Required:    false,
+					Default:     30,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"messages": {
+					Type:        "array",
+					Description: "Received messages, each with channel, pattern and message",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of messages received",
+				},
+				"timed_out": {
+					Type:        "boolean",
+					Description: "Whether the wait elapsed before count messages arrived",
+				},
+			},
+		},
+	}
+}
+
+func (p *RedisPlugin) executePublish(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	channel, ok := params["channel"].(string)
+	if !ok || channel == "" {
+		return nil, fmt.Errorf("channel parameter is required")
+	}
+	message, ok := params["message"].(string)
+	if !ok {
+		return nil, fmt.Errorf("message parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "PUBLISH", channel, message)
+	if err != nil {
+		return nil, fmt.Errorf("PUBLISH failed: %w", err)
+	}
+	receivers, _ := reply.(int64)
+
+	return map[string]interface{}{
+		"receivers": int(receivers),
+		"channel":   channel,
+	}, nil
+}
+
+func (p *RedisPlugin) executeSubscribe(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	channels := stringList(params, "channels")
+	patterns := stringList(params, "patterns")
+	if len(channels) == 0 && len(patterns) == 0 {
+		return nil, fmt.Errorf("channels or patterns parameter is required")
+	}
+	count := intParam(params, "count", 1)
+	wait := time.Duration(intParam(params, "wait", 30)) * time.Second
+	if wait <= 0 {
+		return nil, fmt.Errorf("wait must be positive")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	if len(channels) > 0 {
+		args := []interface{}{"SUBSCRIBE"}
+		for _, channel := range channels {
+			args = append(args, channel)
+		}
+		if err := client.Send(ctx, args...); err != nil {
+			return nil, err
+		}
+	}
+	if len(patterns) > 0 {
+		args := []interface{}{"PSUBSCRIBE"}
+		for _, pattern := range patterns {
+			args = append(args, pattern)
+		}
+		if err := client.Send(ctx, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	// While listening, the wait budget replaces the per-command timeout.
+	listenCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	client.timeout = 0
+
+	messages := []map[string]interface{}{}
+	timedOut := false
+	for count <= 0 || len(messages) < count {
+		reply, err := client.Receive(listenCtx)
+		if err != nil {
+			if ctx.Err() == nil && listenCtx.Err() != nil {
+				timedOut = true
+				break
+			}
+			return nil, err
+		}
+
+		var frame []interface{}
+		switch v := reply.(type) {
+		case redisPush:
+			frame = v
+		case []interface{}:
+			frame = v
+		case redisError:
+			return nil, fmt.Errorf("subscribe failed: %w", v)
+		default:
+			continue
+		}
+		if len(frame) == 0 {
+			continue
+		}
+
+		switch argString(frame[0]) {
+		case "message":
+			if len(frame) == 3 {
+				messages = append(messages, map[string]interface{}{
+					"channel": argString(frame[1]),
+					"pattern": "",
+					"message": argString(frame[2]),
+				})
+			}
+		case "pmessage":
+			if len(frame) == 4 {
+				messages = append(messages, map[string]interface{}{
+					"channel": argString(frame[2]),
+					"pattern": argString(frame[1]),
+					"message": argString(frame[3]),
+				})
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"messages":  messages,
+		"count":     len(messages),
+		"timed_out": timedOut,
+	}, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	srv := newFakeRedis(t, withReply("PUBLISH", ":2\r\n"))
+	out, err := (&RedisPlugin{}).Execute(context.Background(), "publish",
+		srv.params(map[string]interface{}{"channel": "deploys", "message": "v1.4.0"}))
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if out["receivers"] != 2 {
+		t.Errorf("receivers = %#v, want int 2", out["receivers"])
+	}
+	cmds := srv.seen()
+	if got := strings.Join(cmds[len(cmds)-1], " "); got != "PUBLISH deploys v1.4.0" {
+		t.Errorf("command = %q", got)
+	}
+}
+
+// pubSubFrame encodes a subscribe ack or message as a RESP array, or as a
+// RESP3 push when kind is '>'.
+func pubSubFrame(kind byte, items ...string) string {
+	encoded := make([]string, len(items))
+	for i, item := range items {
+		encoded[i] = respBulk(item)
+	}
+	return resp(kind, encoded...)
+}
+
+func message(channel, pattern, payload string) map[string]interface{} {
+	return map[string]interface{}{"channel": channel, "pattern": pattern, "message": payload}
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []func(*fakeRedisOptions)
+		params       map[string]interface{}
+		want         []map[string]interface{}
+		wantTimedOut bool
+		wantCommands []string
+	}{
+		{
+			name: "RESP2 message",
+			opts: []func(*fakeRedisOptions){withReply("SUBSCRIBE",
+				"*3\r\n"+respBulk("subscribe")+respBulk("deploys")+":1\r\n"+
+					pubSubFrame('*', "message", "deploys", "v1.4.0"))},
+			params:       map[string]interface{}{"channels": []interface{}{"deploys"}},
+			want:         []map[string]interface{}{message("deploys", "", "v1.4.0")},
+			wantCommands: []string{"SUBSCRIBE deploys"},
+		},
+		{
+			name: "RESP3 push",
+			opts: []func(*fakeRedisOptions){withReply("SUBSCRIBE",
+				">3\r\n"+respBulk("subscribe")+respBulk("deploys")+":1\r\n"+
+					pubSubFrame('>', "message", "deploys", "v1.4.0"))},
+			params:       map[string]interface{}{"channels": "deploys"},
+			want:         []map[string]interface{}{message("deploys", "", "v1.4.0")},
+			wantCommands: []string{"SUBSCRIBE deploys"},
+		},
+		{
+			name: "pattern message",
+			opts: []func(*fakeRedisOptions){withReply("PSUBSCRIBE",
+				"*3\r\n"+respBulk("psubscribe")+respBulk("deploys.*")+":1\r\n"+
+					pubSubFrame('*', "pmessage", "deploys.*", "deploys.eu", "v1.4.0"))},
+			params:       map[string]interface{}{"patterns": []interface{}{"deploys.*"}},
+			want:         []map[string]interface{}{message("deploys.eu", "deploys.*", "v1.4.0")},
+			wantCommands: []string{"PSUBSCRIBE deploys.*"},
+		},
+		{
+			name: "channels and patterns",
+			opts: []func(*fakeRedisOptions){
+				withReply("SUBSCRIBE", pubSubFrame('*', "message", "audit", "login")),
+				withReply("PSUBSCRIBE", pubSubFrame('*', "pmessage", "deploys.*", "deploys.us", "v1.4.1")),
+			},
+			params: map[string]interface{}{
+				"channels": []interface{}{"audit", "alerts"},
+				"patterns": []interface{}{"deploys.*"},
+				"count":    float64(2),
+			},
+			want: []map[string]interface{}{
+				message("audit", "", "login"),
+				message("deploys.us", "deploys.*", "v1.4.1"),
+			},
+			wantCommands: []string{"SUBSCRIBE audit alerts", "PSUBSCRIBE deploys.*"},
+		},
+		{
+			name: "stops at count",
+			opts: []func(*fakeRedisOptions){withReply("SUBSCRIBE",
+				pubSubFrame('*', "message", "jobs", "1")+
+					pubSubFrame('*', "message", "jobs", "2")+
+					pubSubFrame('*', "message", "jobs", "3"))},
+			params:       map[string]interface{}{"channels": []interface{}{"jobs"}, "count": float64(2)},
+			want:         []map[string]interface{}{message("jobs", "", "1"), message("jobs", "", "2")},
+			wantCommands: []string{"SUBSCRIBE jobs"},
+		},
+		{
+			name: "wait elapses",
+			opts: []func(*fakeRedisOptions){withReply("SUBSCRIBE",
+				"*3\r\n"+respBulk("subscribe")+respBulk("jobs")+":1\r\n")},
+			params:       map[string]interface{}{"channels": []interface{}{"jobs"}, "count": float64(0), "wait": float64(1)},
+			want:         []map[string]interface{}{},
+			wantTimedOut: true,
+			wantCommands: []string{"SUBSCRIBE jobs"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeRedis(t, tt.opts...)
+			out, err := (&RedisPlugin{}).Execute(context.Background(), "subscribe", srv.params(tt.params))
+			if err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+			if !reflect.DeepEqual(out["messages"], tt.want) {
+				t.Errorf("messages = %v, want %v", out["messages"], tt.want)
+			}
+			if out["count"] != len(tt.want) || out["timed_out"] != tt.wantTimedOut {
+				t.Errorf("count = %v, timed_out = %v; want %d, %v", out["count"], out["timed_out"], len(tt.want), tt.wantTimedOut)
+			}
+			var sent []string
+			for _, cmd := range srv.seen() {
+				sent = append(sent, strings.Join(cmd, " "))
+			}
+			if !reflect.DeepEqual(sent, tt.wantCommands) {
+				t.Errorf("commands = %v, want %v", sent, tt.wantCommands)
+			}
+		})
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr string
+		wantCmd bool
+	}{
+		{
+			name:    "no channels or patterns",
+			params:  map[string]interface{}{},
+			wantErr: "channels or patterns parameter is required",
+		},
+		{
+			name:    "non-positive wait",
+			params:  map[string]interface{}{"channels": "jobs", "wait": float64(0)},
+			wantErr: "wait must be positive",
+		},
+		{
+			name:    "server error",
+			params:  map[string]interface{}{"channels": "jobs"},
+			wantErr: "subscribe failed: NOPERM",
+			wantCmd: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeRedis(t, withReply("SUBSCRIBE", "-NOPERM this user has no permissions to access the 'jobs' channel\r\n"))
+			_, err := (&RedisPlugin{}).Execute(context.Background(), "subscribe", srv.params(tt.params))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+			if sent := len(srv.seen()) > 0; sent != tt.wantCmd {
+				t.Errorf("commands = %v", srv.seen())
+			}
+		})
+	}
+}
@@ -0,0 +1,883 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// dataStructureActions declares the hash, list, set and stream actions.
+func dataStructureActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "hset",
+			Description: "Set fields in a hash",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Hash key",
+					Required:    true,
+				},
+				"fields": {
+					Type:        "object",
+					Description: "Map of field to value",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"added": {
+					Type:        "number",
+					Description: "Number of fields that were newly created",
+				},
+			},
+		},
+		{
+			Name:        "hget",
+			Description: "Get a single field from a hash",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Hash key",
+					Required:    true,
+				},
+				"field": {
+					Type:        "string",
+					Description: "Field to retrieve",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"exists": {
+					Type:        "boolean",
+					Description: "Whether the field exists",
+				},
+				"value": {
+					Type:        "string",
+					Description: "The value of the field",
+				},
+			},
+		},
+		{
+			Name:        "hgetall",
+			Description: "Get all fields and values of a hash",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Hash key",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"fields": {
+					Type:        "object",
+					Description: "Map of field to value",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of fields",
+				},
+			},
+		},
+		{
+			Name:        "hdel",
+			Description: "Delete fields from a hash",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Hash key",
+					Required:    true,
+				},
+				"fields": {
+					Type:        "array",
+					Description: "Fields to delete",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"deleted": {
+					Type:        "number",
+					Description: "Number of fields that were removed",
+				},
+			},
+		},
+		{
+			Name:        "lpush",
+			Description: "Prepend values to a list",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "List key",
+					Required:    true,
+				},
+				"values": {
+					Type:        "array",
+					Description: "Values to push",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"length": {
+					Type:        "number",
+					Description: "Length of the list after the push",
+				},
+			},
+		},
+		{
+			Name:        "rpush",
+			Description: "Append values to a list",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "List key",
+					Required:    true,
+				},
+				"values": {
+					Type:        "array",
+					Description: "Values to push",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"length": {
+					Type:        "number",
+					Description: "Length of the list after the push",
+				},
+			},
+		},
+		{
+			Name:        "lpop",
+			Description: "Remove and return elements from the head of a list",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "List key",
+					Required:    true,
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of elements to pop",
+					Required:    false,
+					Default:     1,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"exists": {
+					Type:        "boolean",
+					Description: "Whether any element was popped",
+				},
+				"value": {
+					Type:        "string",
+					Description: "The first popped element",
+				},
+				"values": {
+					Type:        "array",
+					Description: "All popped elements",
+				},
+			},
+		},
+		{
+			Name:        "lrange",
+			Description: "Get a range of elements from a list",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "List key",
+					Required:    true,
+				},
+				"start": {
+					Type:        "number",
+					Description: "Start index",
+					Required:    false,
+					Default:     0,
+				},
+				"stop": {
+					Type:        "number",
+					Description: "Stop index, inclusive (-1 = end of list)",
+					Required:    false,
+					Default:     -1,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"values": {
+					Type:        "array",
+					Description: "Elements in the range",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of elements returned",
+				},
+			},
+		},
+		{
+			Name:        "sadd",
+			Description: "Add members to a set",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Set key",
+					Required:    true,
+				},
+				"members": {
+					Type:        "array",
+					Description: "Members to add",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"changed": {
+					Type:        "number",
+					Description: "Number of members that were added",
+				},
+			},
+		},
+		{
+			Name:        "smembers",
+			Description: "Get all members of a set",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Set key",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"members": {
+					Type:        "array",
+					Description: "Set members, sorted",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of members",
+				},
+			},
+		},
+		{
+			Name:        "srem",
+			Description: "Remove members from a set",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Set key",
+					Required:    true,
+				},
+				"members": {
+					Type:        "array",
+					Description: "Members to remove",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"changed": {
+					Type:        "number",
+					Description: "Number of members that were removed",
+				},
+			},
+		},
+		{
+			Name:        "xadd",
+			Description: "Append an entry to a stream",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Stream key",
+					Required:    true,
+				},
+				"fields": {
+					Type:        "object",
+					Description: "Map of field to value for the entry",
+					Required:    true,
+				},
+				"id": {
+					Type:        "string",
+					Description: "Entry ID",
+					Required:    false,
+					Default:     "*",
+				},
+				"maxlen": {
+					Type:        "number",
+					Description: "Approximately trim the stream to this many entries (0 = no trim)",
+					Required:    false,
+					Default:     0,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"id": {
+					Type:        "string",
+					Description: "ID of the added entry",
+				},
+			},
+		},
+		{
+			Name:        "xrange",
+			Description: "Read a range of entries from a stream",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Stream key",
+					Required:    true,
+				},
+				"start": {
+					Type:        "string",
+					Description: "Start ID",
+					Required:    false,
+					Default:     "-",
+				},
+				"end": {
+					Type:        "string",
+					Description: "End ID",
+					Required:    false,
+					Default:     "+",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Maximum number of entries (0 = no limit)",
+					Required:    false,
+					Default:     0,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"entries": {
+					Type:        "array",
+					Description: "Entries, each with id and fields",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of entries returned",
+				},
+			},
+		},
+		{
+			Name:        "xread",
+			Description: "Read new entries from one or more streams",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"key": {
+					Type:        "string",
+					Description: "Stream key to read",
+					Required:    false,
+				},
+				"id": {
+					Type:        "string",
+					Description: "Return entries after this ID",
+					Required:    false,
+					Default:     "0",
+				},
+				"streams": {
+					Type:        "object",
+					Description: "Map of stream key to last seen ID, for reading several streams",
+					Required:    false,
+				},
+				"count": {
+					Type:        "number",
+					Description: "Maximum entries per stream (0 = no limit)",
+					Required:    false,
+					Default:     0,
+				},
+				"block": {
+					Type:        "number",
+					Description: "Milliseconds to wait for new entries (0 = do not block)",
+					Required:    false,
+					Default:     0,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"streams": {
+					Type:        "object",
+					Description: "Map of stream key to entries read",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Total number of entries read",
+				},
+			},
+		},
+	}
+}
+
+func (p *RedisPlugin) executeHSet(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	fields, ok := params["fields"].(map[string]interface{})
+	if !ok || len(fields) == 0 {
+		return nil, fmt.Errorf("fields parameter is required and must be an object")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"HSET", key}
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("HSET failed: %w", err)
+	}
+	added, _ := reply.(int64)
+
+	return map[string]interface{}{
+		"added": int(added),
+		"key":   key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeHGet(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	field, ok := params["field"].(string)
+	if !ok || field == "" {
+		return nil, fmt.Errorf("field parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "HGET", key, field)
+	if err != nil {
+		return nil, fmt.Errorf("HGET failed: %w", err)
+	}
+
+	value := ""
+	if reply != nil {
+		value = argString(reply)
+	}
+	return map[string]interface{}{
+		"exists": reply != nil,
+		"value":  value,
+		"key":    key,
+		"field":  field,
+	}, nil
+}
+
+func (p *RedisPlugin) executeHGetAll(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "HGETALL", key)
+	if err != nil {
+		return nil, fmt.Errorf("HGETALL failed: %w", err)
+	}
+	fields := pairsToMap(reply)
+
+	return map[string]interface{}{
+		"fields": fields,
+		"count":  len(fields),
+		"key":    key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeHDel(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	fields := stringList(params, "fields")
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"HDEL", key}
+	for _, field := range fields {
+		args = append(args, field)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("HDEL failed: %w", err)
+	}
+	deleted, _ := reply.(int64)
+
+	return map[string]interface{}{
+		"deleted": int(deleted),
+		"key":     key,
+	}, nil
+}
+
+func (p *RedisPlugin) executePush(ctx context.Context, params map[string]interface{}, command string) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	values := stringList(params, "values")
+	if len(values) == 0 {
+		return nil, fmt.Errorf("values parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{command, key}
+	for _, value := range values {
+		args = append(args, value)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", command, err)
+	}
+	length, _ := reply.(int64)
+
+	return map[string]interface{}{
+		"length": int(length),
+		"key":    key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeLPop(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	count := intParam(params, "count", 1)
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"LPOP", key}
+	if count > 1 {
+		args = append(args, count)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("LPOP failed: %w", err)
+	}
+
+	values := []string{}
+	switch v := reply.(type) {
+	case []interface{}:
+		values = stringSlice(v)
+	case nil:
+	default:
+		values = append(values, argString(v))
+	}
+
+	value := ""
+	if len(values) > 0 {
+		value = values[0]
+	}
+	return map[string]interface{}{
+		"exists": len(values) > 0,
+		"value":  value,
+		"values": values,
+		"key":    key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeLRange(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "LRANGE", key, intParam(params, "start", 0), intParam(params, "stop", -1))
+	if err != nil {
+		return nil, fmt.Errorf("LRANGE failed: %w", err)
+	}
+	items, _ := reply.([]interface{})
+	values := stringSlice(items)
+
+	return map[string]interface{}{
+		"values": values,
+		"count":  len(values),
+		"key":    key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeSetMembers(ctx context.Context, params map[string]interface{}, command string) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	members := stringList(params, "members")
+	if len(members) == 0 {
+		return nil, fmt.Errorf("members parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{command, key}
+	for _, member := range members {
+		args = append(args, member)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", command, err)
+	}
+	changed, _ := reply.(int64)
+
+	return map[string]interface{}{
+		"changed": int(changed),
+		"key":     key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeSMembers(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := client.Do(ctx, "SMEMBERS", key)
+	if err != nil {
+		return nil, fmt.Errorf("SMEMBERS failed: %w", err)
+	}
+	items, _ := reply.([]interface{})
+	// Set order is arbitrary; sort so repeated runs produce stable output.
+	members := stringSlice(items)
+	sort.Strings(members)
+
+	return map[string]interface{}{
+		"members": members,
+		"count":   len(members),
+		"key":     key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeXAdd(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	fields, ok := params["fields"].(map[string]interface{})
+	if !ok || len(fields) == 0 {
+		return nil, fmt.Errorf("fields parameter is required and must be an object")
+	}
+	id := "*"
+	if i, ok := params["id"].(string); ok && i != "" {
+		id = i
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"XADD", key}
+	if maxlen := intParam(params, "maxlen", 0); maxlen > 0 {
+		args = append(args, "MAXLEN", "~", maxlen)
+	}
+	args = append(args, id)
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("XADD failed: %w", err)
+	}
+
+	return map[string]interface{}{
+		"id":  argString(reply),
+		"key": key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeXRange(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := params["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key parameter is required")
+	}
+	start := "-"
+	if s, ok := params["start"].(string); ok && s != "" {
+		start = s
+	}
+	end := "+"
+	if e, ok := params["end"].(string); ok && e != "" {
+		end = e
+	}
+
+	client, err := dialRedis(ctx, configFromParams(params))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	args := []interface{}{"XRANGE", key, start, end}
+	if count := intParam(params, "count", 0); count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("XRANGE failed: %w", err)
+	}
+	entries := streamEntries(reply)
+
+	return map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+		"key":     key,
+	}, nil
+}
+
+func (p *RedisPlugin) executeXRead(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	streams := make(map[string]string)
+	if s, ok := params["streams"].(map[string]interface{}); ok {
+		for key, id := range s {
+			streams[key] = argString(id)
+		}
+	}
+	if key, ok := params["key"].(string); ok && key != "" {
+		id := "0"
+		if i, ok := params["id"].(string); ok && i != "" {
+			id = i
+		}
+		streams[key] = id
+	}
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("key or streams parameter is required")
+	}
+
+	cfg := configFromParams(params)
+	block := intParam(params, "block", 0)
+	// The read must be allowed to wait at least as long as the server blocks.
+	cfg.timeout += time.Duration(block) * time.Millisecond
+
+	client, err := dialRedis(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	keys := make([]string, 0, len(streams))
+	for key := range streams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := []interface{}{"XREAD"}
+	if count := intParam(params, "count", 0); count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	if block > 0 {
+		args = append(args, "BLOCK", block)
+	}
+	args = append(args, "STREAMS")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	for _, key := range keys {
+		args = append(args, streams[key])
+	}
+
+	reply, err := client.Do(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("XREAD failed: %w", err)
+	}
+
+	result := make(map[string]interface{})
+	total := 0
+	collect := func(name string, raw interface{}) {
+		entries := streamEntries(raw)
+		result[name] = entries
+		total += len(entries)
+	}
+	switch v := reply.(type) {
+	case []interface{}:
+		// RESP2: [[key, entries], ...]
+		for _, item := range v {
+			pair, ok := item.([]interface{})
+			if ok && len(pair) == 2 {
+				collect(argString(pair[0]), pair[1])
+			}
+		}
+	case map[string]interface{}:
+		// RESP3: {key: entries, ...}
+		for name, raw := range v {
+			collect(name, raw)
+		}
+	}
+
+	return map[string]interface{}{
+		"streams": result,
+		"count":   total,
+	}, nil
+}
+
+// pairsToMap converts a field/value reply into a map. RESP2 servers send a
+// flat array of alternating fields and values, RESP3 servers send a map.
+func pairsToMap(reply interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	switch v := reply.(type) {
+	case map[string]interface{}:
+		for field, value := range v {
+			out[field] = argString(value)
+		}
+	case []interface{}:
+		for i := 0; i+1 < len(v); i += 2 {
+			out[argString(v[i])] = argString(v[i+1])
+		}
+	}
+	return out
+}
+
+// streamEntries converts an XRANGE style reply into entries of the form
+// {"id": ..., "fields": {...}}.
+func streamEntries(reply interface{}) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	items, _ := reply.([]interface{})
+	for _, item := range items {
+		entry, ok := item.([]interface{})
+		if !ok || len(entry) != 2 {
+			continue
+		}
+		entries = append(entries, map[string]interface{}{
+			"id":     argString(entry[0]),
+			"fields": pairsToMap(entry[1]),
+		})
+	}
+	return entries
+}
+
+func stringSlice(items []interface{}) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, argString(item))
+	}
+	return out
+}
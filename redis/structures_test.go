@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// resp encodes items as a RESP aggregate of the given kind: '*' for arrays,
+// '%' for maps (items alternate keys and values) and '>' for pushes.
+func resp(kind byte, items ...string) string {
+	n := len(items)
+	if kind == '%' {
+		n /= 2
+	}
+	return fmt.Sprintf("%c%d\r\n%s", kind, n, strings.Join(items, ""))
+}
+
+func respBulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// streamEntry encodes one XRANGE style entry with its fields as a flat array.
+func streamEntry(id string, fields ...string) string {
+	encoded := make([]string, len(fields))
+	for i, f := range fields {
+		encoded[i] = respBulk(f)
+	}
+	return resp('*', respBulk(id), resp('*', encoded...))
+}
+
+func entry(id string, fields map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"id": id, "fields": fields}
+}
+
+func TestXRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  []map[string]interface{}
+	}{
+		{
+			name: "entries",
+			reply: resp('*',
+				streamEntry("1-0", "event", "signup", "user", "ada"),
+				streamEntry("2-0", "event", "login"),
+			),
+			want: []map[string]interface{}{
+				entry("1-0", map[string]interface{}{"event": "signup", "user": "ada"}),
+				entry("2-0", map[string]interface{}{"event": "login"}),
+			},
+		},
+		{
+			name:  "empty stream",
+			reply: "*0\r\n",
+			want:  []map[string]interface{}{},
+		},
+		{
+			name:  "fields as a RESP3 map",
+			reply: resp('*', resp('*', respBulk("1-0"), resp('%', respBulk("event"), respBulk("signup")))),
+			want:  []map[string]interface{}{entry("1-0", map[string]interface{}{"event": "signup"})},
+		},
+		{
+			name:  "odd field count drops the dangling field",
+			reply: resp('*', streamEntry("1-0", "event", "signup", "user")),
+			want:  []map[string]interface{}{entry("1-0", map[string]interface{}{"event": "signup"})},
+		},
+		{
+			name:  "malformed entries are skipped",
+			reply: resp('*', respBulk("1-0"), resp('*', respBulk("2-0")), streamEntry("3-0", "k", "v")),
+			want:  []map[string]interface{}{entry("3-0", map[string]interface{}{"k": "v"})},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeRedis(t, withReply("XRANGE", tt.reply))
+			out, err := (&RedisPlugin{}).Execute(context.Background(), "xrange",
+				srv.params(map[string]interface{}{"key": "events", "count": float64(10)}))
+			if err != nil {
+				t.Fatalf("xrange: %v", err)
+			}
+			if !reflect.DeepEqual(out["entries"], tt.want) {
+				t.Errorf("entries = %v, want %v", out["entries"], tt.want)
+			}
+			if out["count"] != len(tt.want) {
+				t.Errorf("count = %v, want %d", out["count"], len(tt.want))
+			}
+			cmds := srv.seen()
+			if got := strings.Join(cmds[len(cmds)-1], " "); got != "XRANGE events - + COUNT 10" {
+				t.Errorf("command = %q", got)
+			}
+		})
+	}
+}
+
+func TestXRead(t *testing.T) {
+	orders := resp('*', streamEntry("1-0", "sku", "A1"), streamEntry("2-0", "sku", "B2"))
+	payments := resp('*', streamEntry("5-0", "amount", "12"))
+	wantOrders := []map[string]interface{}{
+		entry("1-0", map[string]interface{}{"sku": "A1"}),
+		entry("2-0", map[string]interface{}{"sku": "B2"}),
+	}
+	wantPayments := []map[string]interface{}{entry("5-0", map[string]interface{}{"amount": "12"})}
+
+	tests := []struct {
+		name      string
+		reply     string
+		want      map[string]interface{}
+		wantCount int
+	}{
+		{
+			name: "RESP2 array of key and entries",
+			reply: resp('*',
+				resp('*', respBulk("orders"), orders),
+				resp('*', respBulk("payments"), payments),
+			),
+			want:      map[string]interface{}{"orders": wantOrders, "payments": wantPayments},
+			wantCount: 3,
+		},
+		{
+			name:      "RESP3 map of key to entries",
+			reply:     resp('%', respBulk("orders"), orders, respBulk("payments"), payments),
+			want:      map[string]interface{}{"orders": wantOrders, "payments": wantPayments},
+			wantCount: 3,
+		},
+		{
+			name:      "RESP2 null after BLOCK times out",
+			reply:     "*-1\r\n",
+			want:      map[string]interface{}{},
+			wantCount: 0,
+		},
+		{
+			name:      "RESP3 null after BLOCK times out",
+			reply:     "_\r\n",
+			want:      map[string]interface{}{},
+			wantCount: 0,
+		},
+		{
+			name:      "malformed stream pairs are skipped",
+			reply:     resp('*', resp('*', respBulk("orders")), resp('*', respBulk("payments"), payments)),
+			want:      map[string]interface{}{"payments": wantPayments},
+			wantCount: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeRedis(t, withReply("XREAD", tt.reply))
+			out, err := (&RedisPlugin{}).Execute(context.Background(), "xread", srv.params(map[string]interface{}{
+				"streams": map[string]interface{}{"payments": "$", "orders": "0"},
+				"count":   float64(5),
+				"block":   float64(100),
+			}))
+			if err != nil {
+				t.Fatalf("xread: %v", err)
+			}
+			if !reflect.DeepEqual(out["streams"], tt.want) {
+				t.Errorf("streams = %v, want %v", out["streams"], tt.want)
+			}
+			if out["count"] != tt.wantCount {
+				t.Errorf("count = %v, want %d", out["count"], tt.wantCount)
+			}
+			cmds := srv.seen()
+			if got := strings.Join(cmds[len(cmds)-1], " "); got != "XREAD COUNT 5 BLOCK 100 STREAMS orders payments 0 $" {
+				t.Errorf("command = %q", got)
+			}
+		})
+	}
+}
+
+func TestXReadSingleKey(t *testing.T) {
+	srv := newFakeRedis(t, withReply("XREAD", "*-1\r\n"))
+	_, err := (&RedisPlugin{}).Execute(context.Background(), "xread", srv.params(map[string]interface{}{"key": "orders"}))
+	if err != nil {
+		t.Fatalf("xread: %v", err)
+	}
+	cmds := srv.seen()
+	if got := strings.Join(cmds[len(cmds)-1], " "); got != "XREAD STREAMS orders 0" {
+		t.Errorf("command = %q, want the key read from the start", got)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// checkActions declares validate and fmt.
+func checkActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "validate",
+			Description: "Validate the Terraform configuration",
+			Inputs: map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"fail_on_invalid": {
+					Type:        "boolean",
+					Description: "Fail the step when the configuration is invalid",
+					Required:    false,
+					Default:     true,
+				},
+			},
+			Outputs: map[string]plugin.OutputSpec{
+				"valid": {
+					Type:        "boolean",
+					Description: "Whether the configuration is valid",
+				},
+				"error_count": {
+					Type:        "number",
+					Description: "Number of errors",
+				},
+				"warning_count": {
+					Type:        "number",
+					Description: "Number of warnings",
+				},
+				"diagnostics": {
+					Type:        "array",
+					Description: "Errors and warnings with severity, summary, detail, file and line",
+				},
+			},
+		},
+		{
+			Name:        "fmt",
+			Description: "Check or rewrite Terraform files in the canonical format",
+			Inputs: map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"check": {
+					Type:        "boolean",
+					Description: "Only report files that need formatting instead of rewriting them",
+					Required:    false,
+					Default:     true,
+				},
+				"recursive": {
+					Type:        "boolean",
+					Description: "Also process subdirectories",
+					Required:    false,
+					Default:     true,
+				},
+				"fail_on_unformatted": {
+					Type:        "boolean",
+					Description: "Fail the step when check finds unformatted files",
+					Required:    false,
+					Default:     true,
+				},
+			},
+			Outputs: map[string]plugin.OutputSpec{
+				"formatted": {
+					Type:        "boolean",
+					Description: "Whether all files were already formatted",
+				},
+				"files": {
+					Type:        "array",
+					Description: "Files that need (or received) formatting",
+				},
+				"diff": {
+					Type:        "string",
+					Description: "Formatting changes as a diff",
+				},
+			},
+		},
+	}
+}
+
+func (p *TerraformPlugin) executeValidate(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate -json exits 1 for an invalid configuration but still prints
+	// its report, so the exit code is not treated as a failure here.
+	res, err := tf.run(ctx, "validate", "-json", "-no-color")
+	if err != nil {
+		return nil, err
+	}
+	var report struct {
+		Valid        bool `json:"valid"`
+		ErrorCount   int  `json:"error_count"`
+		WarningCount int  `json:"warning_count"`
+		Diagnostics  []struct {
+			Severity string `json:"severity"`
+			Summary  string `json:"summary"`
+			Detail   string `json:"detail"`
+			Range    *struct {
+				Filename string `json:"filename"`
+				Start    struct {
+					Line int `json:"line"`
+				} `json:"start"`
+			} `json:"range"`
+		} `json:"diagnostics"`
+	}
+	if err := json.Unmarshal([]byte(res.stdout), &report); err != nil {
+		return nil, commandError("validate", res, nil)
+	}
+
+	diagnostics := make([]map[string]interface{}, 0, len(report.Diagnostics))
+	var errors []string
+	for _, d := range report.Diagnostics {
+		entry := map[string]interface{}{
+			"severity": d.Severity,
+			"summary":  d.Summary,
+			"detail":   d.Detail,
+		}
+		location := ""
+		if d.Range != nil {
+			entry["file"] = d.Range.Filename
+			entry["line"] = d.Range.Start.Line
+			location = fmt.Sprintf("%s:%d: ", d.Range.Filename, d.Range.Start.Line)
+		}
+		diagnostics = append(diagnostics, entry)
+		if d.Severity == "error" {
+			errors = append(errors, location+d.Summary)
+		}
+	}
+
+	failOnInvalid := true
+	if f, ok := params["fail_on_invalid"].(bool); ok {
+		failOnInvalid = f
+	}
+	if !report.Valid && failOnInvalid {
+		return nil, fmt.Errorf("configuration is invalid: %s", strings.Join(errors, "; "))
+	}
+
+	message := "The configuration is valid"
+	if !report.Valid {
+		message = fmt.Sprintf("The configuration is invalid: %d errors", report.ErrorCount)
+	}
+	return map[string]interface{}{
+		"valid":         report.Valid,
+		"error_count":   report.ErrorCount,
+		"warning_count": report.WarningCount,
+		"diagnostics":   diagnostics,
+		"success":       true,
+		"message":       message,
+	}, nil
+}
+
+func (p *TerraformPlugin) executeFmt(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	check := true
+	if c, ok := params["check"].(bool); ok {
+		check = c
+	}
+	args := []string{"fmt", "-list=true", "-no-color"}
+	if check {
+		args = append(args, "-check", "-diff")
+	}
+	if recursive, ok := params["recursive"].(bool); !ok || recursive {
+		args = append(args, "-recursive")
+	}
+
+	// fmt -check exits 3 when files need formatting; anything else
+	// non-zero is a real error such as a syntax error.
+	res, err := tf.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	if res.exitCode != 0 && !(check && res.exitCode == 3) {
+		return nil, commandError("fmt", res, nil)
+	}
+
+	// With -diff each file name is followed by its diff; diff lines are
+	// recognised by their prefix.
+	files := []string{}
+	var diff strings.Builder
+	inDiff := false
+	for _, line := range strings.Split(res.stdout, "\n") {
+		if strings.HasPrefix(line, "--- old/") {
+			inDiff = true
+		}
+		if inDiff && line != "" && strings.ContainsAny(line[:1], " +-@\\") {
+			diff.WriteString(line + "\n")
+			continue
+		}
+		inDiff = false
+		if name := strings.TrimSpace(line); name != "" {
+			files = append(files, name)
+		}
+	}
+
+	formatted := len(files) == 0
+	failOnUnformatted := true
+	if f, ok := params["fail_on_unformatted"].(bool); ok {
+		failOnUnformatted = f
+	}
+	if check && !formatted && failOnUnformatted {
+		return nil, fmt.Errorf("%d files need formatting: %s", len(files), strings.Join(files, ", "))
+	}
+
+	message := "All files are formatted"
+	if !formatted && check {
+		message = fmt.Sprintf("%d files need formatting", len(files))
+	} else if !formatted {
+		message = fmt.Sprintf("Formatted %d files", len(files))
+	}
+	return map[string]interface{}{
+		"formatted": formatted,
+		"files":     files,
+		"diff":      diff.String(),
+		"success":   true,
+		"message":   message,
+	}, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// interruptGrace is how long terraform gets to release the state lock and
+// write state after it is interrupted, before it is killed.
+const interruptGrace = 2 * time.Minute
+
+// terraformCLI runs terraform against one working directory.
+type terraformCLI struct {
+	binary string
+	dir    string
+	env    []string
+
+	// workspace is passed as TF_WORKSPACE, which selects it for one
+	// command without changing the directory's selected workspace.
+	workspace string
+}
+
+// terraformRun is the captured result of one terraform invocation.
+type terraformRun struct {
+	stdout   string
+	stderr   string
+	exitCode int
+}
+
+func newTerraformCLI(params map[string]interface{}) (*terraformCLI, error) {
+	dir := "."
+	if wd, ok := params["working_dir"].(string); ok && wd != "" {
+		dir = wd
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid working_dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("working_dir %s is not a directory", dir)
+	}
+
+	binary, err := exec.LookPath("terraform")
+	if err != nil {
+		return nil, fmt.Errorf("terraform executable not found in PATH: %w", err)
+	}
+
+	// TF_IN_AUTOMATION trims "next steps" hints from the output and
+	// TF_INPUT=0 makes terraform fail rather than wait for a prompt.
+	env := append(os.Environ(), "TF_IN_AUTOMATION=1", "TF_INPUT=0")
+	workspace, _ := params["workspace"].(string)
+	return &terraformCLI{binary: binary, dir: abs, env: env, workspace: workspace}, nil
+}
+
+// path resolves a file argument the way terraform does under -chdir.
+func (t *terraformCLI) path(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(t.dir, file)
+}
+
+// run executes terraform -chdir=<dir> args. A non-zero exit status is
+// reported in the result rather than as an error, since some commands use
+// it to carry information (plan -detailed-exitcode). Cancelling ctx
+// interrupts terraform like Ctrl-C so it can stop cleanly.
+func (t *terraformCLI) run(ctx context.Context, args ...string) (terraformRun, error) {
+	cmd := exec.CommandContext(ctx, t.binary, append([]string{"-chdir=" + t.dir}, args...)...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = interruptGrace
+	cmd.Env = t.env
+	if t.workspace != "" {
+		cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], "TF_WORKSPACE="+t.workspace)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	res := terraformRun{stdout: stdout.String(), stderr: stderr.String()}
+	if ctx.Err() != nil {
+		return res, fmt.Errorf("terraform %s interrupted: %w", args[0], ctx.Err())
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		res.exitCode = exitErr.ExitCode()
+		return res, nil
+	}
+	if err != nil {
+		return res, fmt.Errorf("failed to run terraform %s: %w", args[0], err)
+	}
+	return res, nil
+}
+
+// exec runs a command that must succeed.
+func (t *terraformCLI) exec(ctx context.Context, args ...string) (terraformRun, error) {
+	res, err := t.run(ctx, args...)
+	if err != nil {
+		return res, err
+	}
+	if res.exitCode != 0 {
+		return res, commandError(args[0], res, nil)
+	}
+	return res, nil
+}
+
+// commandError describes a failed command, preferring diagnostics from the
+// machine-readable stream over raw stderr.
+func commandError(command string, res terraformRun, diagnostics []string) error {
+	msg := strings.Join(diagnostics, "; ")
+	if msg == "" {
+		msg = strings.TrimSpace(res.stderr)
+	}
+	if msg == "" {
+		msg = strings.TrimSpace(res.stdout)
+	}
+	return fmt.Errorf("terraform %s failed (exit code %d): %s", command, res.exitCode, msg)
+}
+
+// uiMessage is one line of terraform's -json machine-readable UI.
+type uiMessage struct {
+	Level      string `json:"@level"`
+	Message    string `json:"@message"`
+	Type       string `json:"type"`
+	Diagnostic *struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+		Address  string `json:"address"`
+	} `json:"diagnostic"`
+	Changes *changeSummary `json:"changes"`
+	Hook    *struct {
+		Resource struct {
+			Addr string `json:"addr"`
+		} `json:"resource"`
+		Action string `json:"action"`
+	} `json:"hook"`
+}
+
+// changeSummary is the change_summary message terraform emits at the end
+// of plan, apply and destroy.
+type changeSummary struct {
+	Add       int    `json:"add"`
+	Change    int    `json:"change"`
+	Import    int    `json:"import"`
+	Remove    int    `json:"remove"`
+	Operation string `json:"operation"`
+}
+
+// uiResult collects what a -json run reported.
+type uiResult struct {
+	log       string
+	summary   changeSummary
+	errors    []string
+	completed []map[string]interface{}
+}
+
+// parseUI reads a -json stream. Lines that are not JSON (for example
+// provider crash output) are kept in the log.
+func parseUI(stdout string) uiResult {
+	var result uiResult
+	var log strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var msg uiMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			log.WriteString(line + "\n")
+			continue
+		}
+		if msg.Type != "version" && msg.Message != "" {
+			log.WriteString(msg.Message + "\n")
+		}
+		switch msg.Type {
+		case "diagnostic":
+			if d := msg.Diagnostic; d != nil && d.Severity == "error" {
+				text := d.Summary
+				if d.Detail != "" {
+					text += ": " + d.Detail
+				}
+				if d.Address != "" {
+					text = d.Address + ": " + text
+				}
+				result.errors = append(result.errors, text)
+			}
+		case "change_summary":
+			if msg.Changes != nil {
+				result.summary = *msg.Changes
+			}
+		case "apply_complete":
+			if msg.Hook != nil {
+				result.completed = append(result.completed, map[string]interface{}{
+					"address": msg.Hook.Resource.Addr,
+					"action":  msg.Hook.Action,
+				})
+			}
+		}
+	}
+	result.log = log.String()
+	return result
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSlowTerraform runs until interrupted and records that it was, the way
+// terraform releases its state lock on SIGINT before exiting.
+const fakeSlowTerraform = `#!/bin/sh
+dir=${1#-chdir=}
+trap 'echo released > "$dir/interrupted"; exit 1' INT
+echo started > "$dir/started"
+while :; do sleep 0.1; done
+`
+
+func TestRunInterruptsTerraformOnCancel(t *testing.T) {
+	useFakeTerraform(t, fakeSlowTerraform)
+	dir := t.TempDir()
+	tf, err := newTerraformCLI(map[string]interface{}{"working_dir": dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for {
+			if _, err := os.Stat(filepath.Join(dir, "started")); err == nil {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	_, err = tf.run(ctx, "apply", "-auto-approve")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want the cancellation", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "interrupted")); statErr != nil {
+		t.Error("terraform was not interrupted before it stopped")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run took %v to return after the cancel", elapsed)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// driftAction declares drift.
+func driftAction() plugin.Action {
+	return plugin.Action{
+		Name:        "drift",
+		Description: "Detect changes made to infrastructure outside Terraform",
+		Inputs: withVariableInputs(map[string]plugin.InputSpec{
+			"working_dir": {
+				Type:        "string",
+				Description: "Directory containing Terraform configuration",
+				Required:    false,
+				Default:     ".",
+			},
+			"fail_on_drift": {
+				Type:        "boolean",
+				Description: "Fail the step when drift is detected",
+				Required:    false,
+				Default:     false,
+			},
+		}),
+		Outputs: map[string]plugin.OutputSpec{
+			"drifted": {
+				Type:        "boolean",
+				Description: "Whether live infrastructure differs from the state",
+			},
+			"count": {
+				Type:        "number",
+				Description: "Number of drifted resources",
+			},
+			"resources": {
+				Type:        "array",
+				Description: "Drifted resources with address, type, action (update or delete) and changed attributes",
+			},
+			"output": {
+				Type:        "string",
+				Description: "Plan output",
+			},
+		},
+	}
+}
+
+// executeDrift runs a refresh-only plan. With -detailed-exitcode terraform
+// exits 2 when the refreshed state differs from the stored state; the
+// saved plan's resource_drift lists which resources changed.
+func (p *TerraformPlugin) executeDrift(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := variableArgs(params)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "corynth-drift-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	planFile := filepath.Join(dir, "drift.tfplan")
+
+	args := []string{"plan", "-refresh-only", "-detailed-exitcode", "-input=false", "-no-color", "-out=" + planFile}
+	res, err := tf.run(ctx, append(args, vars...)...)
+	if err != nil {
+		return nil, err
+	}
+	if res.exitCode != 0 && res.exitCode != 2 {
+		return nil, commandError("plan", res, nil)
+	}
+	drifted := res.exitCode == 2
+
+	plan, err := tf.showPlan(ctx, planFile)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]map[string]interface{}, 0, len(plan.ResourceDrift))
+	addresses := make([]string, 0, len(plan.ResourceDrift))
+	for _, rc := range plan.ResourceDrift {
+		resources = append(resources, rc.toMap())
+		addresses = append(addresses, rc.Address)
+	}
+
+	message := "No drift detected"
+	if drifted {
+		message = fmt.Sprintf("Drift detected in %d resources", len(resources))
+	}
+	if failOnDrift, _ := params["fail_on_drift"].(bool); failOnDrift && drifted {
+		return nil, fmt.Errorf("%s: %v", message, addresses)
+	}
+
+	return map[string]interface{}{
+		"drifted":   drifted,
+		"count":     len(resources),
+		"resources": resources,
+		"workspace": tf.workspace,
+		"success":   true,
+		"output":    res.stdout,
+		"message":   message,
+	}, nil
+}
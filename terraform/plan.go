@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// tfPlan is the part of `terraform show -json <planfile>` the plugin uses.
+type tfPlan struct {
+	FormatVersion    string           `json:"format_version"`
+	TerraformVersion string           `json:"terraform_version"`
+	ResourceChanges  []resourceChange `json:"resource_changes"`
+	ResourceDrift    []resourceChange `json:"resource_drift"`
+	Errored          bool             `json:"errored"`
+}
+
+type resourceChange struct {
+	Address       string `json:"address"`
+	ModuleAddress string `json:"module_address"`
+	Mode          string `json:"mode"`
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	ProviderName  string `json:"provider_name"`
+	Change        struct {
+		Actions      []string    `json:"actions"`
+		Before       interface{} `json:"before"`
+		After        interface{} `json:"after"`
+		AfterUnknown interface{} `json:"after_unknown"`
+		Importing    *struct {
+			ID string `json:"id"`
+		} `json:"importing"`
+	} `json:"change"`
+	ActionReason string `json:"action_reason"`
+}
+
+// action folds terraform's action list into a single word. Replacements
+// are reported as ["delete","create"] or ["create","delete"] depending on
+// create_before_destroy.
+func (rc resourceChange) action() string {
+	actions := rc.Change.Actions
+	if len(actions) == 2 {
+		return "replace"
+	}
+	if len(actions) == 1 {
+		return actions[0]
+	}
+	return "no-op"
+}
+
+// changedAttributes lists the top-level attributes whose value changes or
+// will only be known after apply. Values are left out because they may be
+// sensitive.
+func (rc resourceChange) changedAttributes() []string {
+	before, _ := rc.Change.Before.(map[string]interface{})
+	after, _ := rc.Change.After.(map[string]interface{})
+	unknown, _ := rc.Change.AfterUnknown.(map[string]interface{})
+
+	seen := map[string]bool{}
+	for key := range before {
+		seen[key] = true
+	}
+	for key := range after {
+		seen[key] = true
+	}
+	var changed []string
+	for key := range seen {
+		if !reflect.DeepEqual(before[key], after[key]) {
+			changed = append(changed, key)
+		}
+	}
+	for key, v := range unknown {
+		if v != false && !seen[key] {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (rc resourceChange) toMap() map[string]interface{} {
+	out := map[string]interface{}{
+		"address":    rc.Address,
+		"module":     rc.ModuleAddress,
+		"mode":       rc.Mode,
+		"type":       rc.Type,
+		"name":       rc.Name,
+		"provider":   rc.ProviderName,
+		"action":     rc.action(),
+		"actions":    rc.Change.Actions,
+		"attributes": rc.changedAttributes(),
+	}
+	if rc.ActionReason != "" {
+		out["reason"] = rc.ActionReason
+	}
+	if rc.Change.Importing != nil {
+		out["import_id"] = rc.Change.Importing.ID
+	}
+	return out
+}
+
+// planSummary counts changes the way terraform's "Plan:" line does: a
+// replacement is one add and one destroy.
+type planSummary struct {
+	add, change, destroy, replace, imports int
+	changes                                []map[string]interface{}
+}
+
+func summarizePlan(plan *tfPlan) planSummary {
+	s := planSummary{changes: []map[string]interface{}{}}
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change.Importing != nil {
+			s.imports++
+		}
+		switch rc.action() {
+		case "create":
+			s.add++
+		case "update":
+			s.change++
+		case "delete":
+			s.destroy++
+		case "replace":
+			s.add++
+			s.destroy++
+			s.replace++
+		case "no-op", "read":
+			if rc.Change.Importing == nil {
+				continue
+			}
+		}
+		s.changes = append(s.changes, rc.toMap())
+	}
+	return s
+}
+
+func (s planSummary) total() int {
+	return len(s.changes)
+}
+
+func (s planSummary) line() string {
+	return fmt.Sprintf("Plan: %d to add, %d to change, %d to destroy.", s.add, s.change, s.destroy)
+}
+
+// showPlan decodes a saved plan file.
+func (t *terraformCLI) showPlan(ctx context.Context, planFile string) (*tfPlan, error) {
+	res, err := t.exec(ctx, "show", "-json", "-no-color", planFile)
+	if err != nil {
+		return nil, err
+	}
+	var plan tfPlan
+	if err := json.Unmarshal([]byte(strings.TrimSpace(res.stdout)), &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform show output: %w", err)
+	}
+	return &plan, nil
+}
+
+// fileChecksum returns the hex SHA-256 of a file, used to tie an apply to
+// the exact plan file that was reviewed.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
-	
+
 	"github.com/corynth/corynth-dist/pkg/plugin"
 )
 
@@ -21,11 +21,11 @@ func (p *TerraformPlugin) Metadata() plugin.Metadata {
 }
 
 func (p *TerraformPlugin) Actions() []plugin.Action {
-	return []plugin.Action{
+	actions := []plugin.Action{
 		{
 			Name:        "init",
 			Description: "Initialize Terraform working directory",
-			Inputs: map[string]plugin.InputSpec{
+			Inputs: withWorkspaceInput(map[string]plugin.InputSpec{
 				"working_dir": {
 					Type:        "string",
 					Description: "Directory containing Terraform configuration",
@@ -38,7 +38,24 @@ func (p *TerraformPlugin) Actions() []plugin.Action {
 					Required:    false,
 					Default:     true,
 				},
-			},
+				"backend_config": {
+					Type:        "object",
+					Description: "Backend settings as key/value pairs, or a list of backend config files",
+					Required:    false,
+				},
+				"reconfigure": {
+					Type:        "boolean",
+					Description: "Reconfigure the backend, ignoring any saved configuration",
+					Required:    false,
+					Default:     false,
+				},
+				"upgrade": {
+					Type:        "boolean",
+					Description: "Upgrade modules and providers within their version constraints",
+					Required:    false,
+					Default:     false,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"success": {
 					Type:        "boolean",
@@ -48,34 +65,68 @@ func (p *TerraformPlugin) Actions() []plugin.Action {
 					Type:        "string",
 					Description: "Command output",
 				},
+				"workspace_created": {
+					Type:        "boolean",
+					Description: "Whether the workspace input did not exist and was created",
+				},
 			},
 		},
 		{
 			Name:        "plan",
 			Description: "Create an execution plan",
-			Inputs: map[string]plugin.InputSpec{
+			Inputs: withVariableInputs(map[string]plugin.InputSpec{
 				"working_dir": {
 					Type:        "string",
 					Description: "Directory containing Terraform configuration",
 					Required:    false,
 					Default:     ".",
 				},
-				"var_file": {
+				"out": {
 					Type:        "string",
-					Description: "Path to variables file",
+					Description: "Path to save plan file, relative to working_dir",
 					Required:    false,
+					Default:     "corynth.tfplan",
 				},
-				"out": {
-					Type:        "string",
-					Description: "Path to save plan file",
+				"destroy": {
+					Type:        "boolean",
+					Description: "Plan the destruction of all managed resources",
 					Required:    false,
+					Default:     false,
 				},
-			},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"changes": {
 					Type:        "number",
 					Description: "Number of resources to change",
 				},
+				"add": {
+					Type:        "number",
+					Description: "Resources to create, including replacements",
+				},
+				"change": {
+					Type:        "number",
+					Description: "Resources to update in place",
+				},
+				"destroy": {
+					Type:        "number",
+					Description: "Resources to destroy, including replacements",
+				},
+				"has_changes": {
+					Type:        "boolean",
+					Description: "Whether the plan changes any resource",
+				},
+				"resource_changes": {
+					Type:        "array",
+					Description: "Per-resource changes with address, type, action and changed attributes",
+				},
+				"plan_file": {
+					Type:        "string",
+					Description: "Absolute path of the saved plan",
+				},
+				"plan_checksum": {
+					Type:        "string",
+					Description: "SHA-256 of the saved plan, for apply to verify",
+				},
 				"success": {
 					Type:        "boolean",
 					Description: "Whether planning succeeded",
@@ -89,7 +140,7 @@ func (p *TerraformPlugin) Actions() []plugin.Action {
 		{
 			Name:        "apply",
 			Description: "Apply Terraform configuration",
-			Inputs: map[string]plugin.InputSpec{
+			Inputs: withVariableInputs(map[string]plugin.InputSpec{
 				"working_dir": {
 					Type:        "string",
 					Description: "Directory containing Terraform configuration",
@@ -98,7 +149,12 @@ func (p *TerraformPlugin) Actions() []plugin.Action {
 				},
 				"plan_file": {
 					Type:        "string",
-					Description: "Path to plan file",
+					Description: "Plan saved by a plan step; applied exactly, without approval",
+					Required:    false,
+				},
+				"plan_checksum": {
+					Type:        "string",
+					Description: "Expected SHA-256 of plan_file, from the plan step's plan_checksum output",
 					Required:    false,
 				},
 				"auto_approve": {
@@ -107,12 +163,32 @@ func (p *TerraformPlugin) Actions() []plugin.Action {
 					Required:    false,
 					Default:     false,
 				},
-			},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"applied": {
 					Type:        "number",
 					Description: "Number of resources applied",
 				},
+				"added": {
+					Type:        "number",
+					Description: "Resources created",
+				},
+				"changed": {
+					Type:        "number",
+					Description: "Resources updated in place",
+				},
+				"destroyed": {
+					Type:        "number",
+					Description: "Resources destroyed",
+				},
+				"resources": {
+					Type:        "array",
+					Description: "Address and action of each resource terraform finished applying",
+				},
+				"planned": {
+					Type:        "object",
+					Description: "add, change and destroy counts of the applied plan_file",
+				},
 				"success": {
 					Type:        "boolean",
 					Description: "Whether apply succeeded",
@@ -126,7 +202,7 @@ func (p *TerraformPlugin) Actions() []plugin.Action {
 		{
 			Name:        "destroy",
 			Description: "Destroy Terraform-managed infrastructure",
-			Inputs: map[string]plugin.InputSpec{
+			Inputs: withVariableInputs(map[string]plugin.InputSpec{
 				"working_dir": {
 					Type:        "string",
 					Description: "Directory containing Terraform configuration",
@@ -139,19 +215,31 @@ func (p *TerraformPlugin) Actions() []plugin.Action {
 					Required:    false,
 					Default:     false,
 				},
-			},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"destroyed": {
 					Type:        "number",
 					Description: "Number of resources destroyed",
 				},
+				"resources": {
+					Type:        "array",
+					Description: "Addresses of destroyed resources",
+				},
 				"success": {
 					Type:        "boolean",
 					Description: "Whether destroy succeeded",
 				},
+				"output": {
+					Type:        "string",
+					Description: "Destroy output",
+				},
 			},
 		},
 	}
+	actions = append(actions, workspaceActions()...)
+	actions = append(actions, stateActions()...)
+	actions = append(actions, checkActions()...)
+	return append(actions, driftAction(), policyAction())
 }
 
 func (p *TerraformPlugin) Validate(params map[string]interface{}) error {
@@ -171,80 +259,254 @@ func (p *TerraformPlugin) Execute(ctx context.Context, action string, params map
 		return p.executeApply(ctx, params)
 	case "destroy":
 		return p.executeDestroy(ctx, params)
+	case "workspace_list":
+		return p.executeWorkspaceList(ctx, params)
+	case "workspace_select":
+		return p.executeWorkspaceSelect(ctx, params)
+	case "workspace_new":
+		return p.executeWorkspaceNew(ctx, params)
+	case "workspace_delete":
+		return p.executeWorkspaceDelete(ctx, params)
+	case "output":
+		return p.executeOutput(ctx, params)
+	case "state_list":
+		return p.executeStateList(ctx, params)
+	case "state_show":
+		return p.executeStateShow(ctx, params)
+	case "state_rm":
+		return p.executeStateRm(ctx, params)
+	case "import":
+		return p.executeImport(ctx, params)
+	case "validate":
+		return p.executeValidate(ctx, params)
+	case "fmt":
+		return p.executeFmt(ctx, params)
+	case "drift":
+		return p.executeDrift(ctx, params)
+	case "policy_check":
+		return p.executePolicyCheck(ctx, params)
 	default:
 		return nil, fmt.Errorf("unknown action: %s", action)
 	}
 }
 
 func (p *TerraformPlugin) executeInit(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	workingDir := "."
-	if wd, ok := params["working_dir"].(string); ok {
-		workingDir = wd
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"init", "-input=false", "-no-color"}
+	if backend, ok := params["backend"].(bool); ok && !backend {
+		args = append(args, "-backend=false")
+	}
+	args = append(args, backendConfigArgs(params)...)
+	if reconfigure, _ := params["reconfigure"].(bool); reconfigure {
+		args = append(args, "-reconfigure")
+	}
+	if upgrade, _ := params["upgrade"].(bool); upgrade {
+		args = append(args, "-upgrade")
+	}
+
+	// The backend is initialized before the workspace is known to exist,
+	// so init itself runs in the selected workspace.
+	workspace := tf.workspace
+	tf.workspace = ""
+	res, err := tf.exec(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	created := false
+	if workspace != "" {
+		if created, err = tf.ensureWorkspace(ctx, workspace); err != nil {
+			return nil, err
+		}
 	}
 
-	// In production, this would execute: terraform -chdir=workingDir init
 	return map[string]interface{}{
-		"success": true,
-		"output":  fmt.Sprintf("Terraform initialized in %s", workingDir),
-		"message": "Terraform working directory initialized successfully",
+		"success":           true,
+		"output":            res.stdout,
+		"workspace":         workspace,
+		"workspace_created": created,
+		"message":           fmt.Sprintf("Terraform initialized in %s", tf.dir),
 	}, nil
 }
 
 func (p *TerraformPlugin) executePlan(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	workingDir := "."
-	if wd, ok := params["working_dir"].(string); ok {
-		workingDir = wd
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	out := "corynth.tfplan"
+	if o, ok := params["out"].(string); ok && o != "" {
+		out = o
+	}
+	planFile := tf.path(out)
+
+	vars, err := variableArgs(params)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"plan", "-input=false", "-no-color", "-out=" + planFile}, vars...)
+	if destroy, ok := params["destroy"].(bool); ok && destroy {
+		args = append(args, "-destroy")
+	}
+	res, err := tf.exec(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := tf.showPlan(ctx, planFile)
+	if err != nil {
+		return nil, err
+	}
+	summary := summarizePlan(plan)
+	checksum, err := fileChecksum(planFile)
+	if err != nil {
+		return nil, err
 	}
 
-	// In production, this would execute: terraform -chdir=workingDir plan
 	return map[string]interface{}{
-		"changes": 3,
-		"success": true,
-		"output":  fmt.Sprintf("Plan: 3 to add, 0 to change, 0 to destroy in %s", workingDir),
-		"message": "Terraform plan generated successfully",
+		"changes":           summary.total(),
+		"add":               summary.add,
+		"change":            summary.change,
+		"destroy":           summary.destroy,
+		"replace":           summary.replace,
+		"import":            summary.imports,
+		"has_changes":       summary.total() > 0,
+		"resource_changes":  summary.changes,
+		"plan_file":         planFile,
+		"plan_checksum":     checksum,
+		"workspace":         tf.workspace,
+		"terraform_version": plan.TerraformVersion,
+		"success":           true,
+		"output":            res.stdout,
+		"message":           summary.line(),
 	}, nil
 }
 
 func (p *TerraformPlugin) executeApply(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	workingDir := "."
-	if wd, ok := params["working_dir"].(string); ok {
-		workingDir = wd
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
 	}
 
 	autoApprove := false
 	if aa, ok := params["auto_approve"].(bool); ok {
 		autoApprove = aa
 	}
+	planFile, _ := params["plan_file"].(string)
+
+	// A saved plan was approved when it was reviewed; anything else needs
+	// auto_approve because a workflow cannot answer terraform's prompt.
+	vars, err := variableArgs(params)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"apply", "-input=false", "-json"}
+	var planned map[string]interface{}
+	if planFile != "" {
+		if len(vars) > 0 {
+			return nil, fmt.Errorf("variables cannot be set when applying a saved plan; set them on the plan step")
+		}
+		planFile = tf.path(planFile)
+		checksum, err := fileChecksum(planFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read plan_file: %w", err)
+		}
+		if want, _ := params["plan_checksum"].(string); want != "" && want != checksum {
+			return nil, fmt.Errorf("plan_file %s does not match plan_checksum; it changed after the plan was reviewed", planFile)
+		}
+		plan, err := tf.showPlan(ctx, planFile)
+		if err != nil {
+			return nil, err
+		}
+		if plan.Errored {
+			return nil, fmt.Errorf("plan_file %s is from a plan that failed and cannot be applied", planFile)
+		}
+		summary := summarizePlan(plan)
+		planned = map[string]interface{}{
+			"add":     summary.add,
+			"change":  summary.change,
+			"destroy": summary.destroy,
+		}
+		args = append(args, planFile)
+	} else if !autoApprove {
+		return nil, fmt.Errorf("auto_approve must be true to apply without a plan_file")
+	} else {
+		args = append(append(args, "-auto-approve"), vars...)
+	}
+
+	res, err := tf.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	ui := parseUI(res.stdout)
+	if res.exitCode != 0 {
+		return nil, commandError("apply", res, ui.errors)
+	}
 
-	// In production, this would execute: terraform -chdir=workingDir apply
 	return map[string]interface{}{
-		"applied":      3,
+		"applied":      ui.summary.Add + ui.summary.Change + ui.summary.Remove,
+		"added":        ui.summary.Add,
+		"changed":      ui.summary.Change,
+		"destroyed":    ui.summary.Remove,
+		"resources":    resourceList(ui.completed),
+		"plan_file":    planFile,
+		"planned":      planned,
 		"success":      true,
-		"output":       fmt.Sprintf("Apply complete! Resources: 3 added, 0 changed, 0 destroyed in %s", workingDir),
+		"output":       ui.log,
 		"auto_approve": autoApprove,
-		"message":      "Terraform apply completed successfully",
+		"workspace":    tf.workspace,
+		"message":      fmt.Sprintf("Apply complete! Resources: %d added, %d changed, %d destroyed.", ui.summary.Add, ui.summary.Change, ui.summary.Remove),
 	}, nil
 }
 
 func (p *TerraformPlugin) executeDestroy(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	workingDir := "."
-	if wd, ok := params["working_dir"].(string); ok {
-		workingDir = wd
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
 	}
 
 	autoApprove := false
 	if aa, ok := params["auto_approve"].(bool); ok {
 		autoApprove = aa
 	}
+	if !autoApprove {
+		return nil, fmt.Errorf("auto_approve must be true to destroy")
+	}
+
+	vars, err := variableArgs(params)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tf.run(ctx, append([]string{"destroy", "-input=false", "-json", "-auto-approve"}, vars...)...)
+	if err != nil {
+		return nil, err
+	}
+	ui := parseUI(res.stdout)
+	if res.exitCode != 0 {
+		return nil, commandError("destroy", res, ui.errors)
+	}
 
-	// In production, this would execute: terraform -chdir=workingDir destroy
 	return map[string]interface{}{
-		"destroyed":    3,
+		"destroyed":    ui.summary.Remove,
+		"resources":    resourceList(ui.completed),
 		"success":      true,
-		"output":       fmt.Sprintf("Destroy complete! Resources: 0 added, 0 changed, 3 destroyed in %s", workingDir),
+		"output":       ui.log,
 		"auto_approve": autoApprove,
-		"message":      "Terraform destroy completed successfully",
+		"workspace":    tf.workspace,
+		"message":      fmt.Sprintf("Destroy complete! Resources: %d destroyed.", ui.summary.Remove),
 	}, nil
 }
 
-var ExportedPlugin plugin.Plugin = &TerraformPlugin{}
\ No newline at end of file
+// resourceList keeps outputs typed as arrays even when nothing changed.
+func resourceList(resources []map[string]interface{}) []map[string]interface{} {
+	if resources == nil {
+		return []map[string]interface{}{}
+	}
+	return resources
+}
+
+var ExportedPlugin plugin.Plugin = &TerraformPlugin{}
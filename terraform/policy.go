@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// policyAction declares policy_check.
+func policyAction() plugin.Action {
+	return plugin.Action{
+		Name:        "policy_check",
+		Description: "Evaluate declarative rules against a Terraform plan",
+		Inputs: map[string]plugin.InputSpec{
+			"working_dir": {
+				Type:        "string",
+				Description: "Directory containing Terraform configuration",
+				Required:    false,
+				Default:     ".",
+			},
+			"plan_file": {
+				Type:        "string",
+				Description: "Plan saved by a plan step",
+				Required:    false,
+			},
+			"plan_json": {
+				Type:        "string",
+				Description: "Path to `terraform show -json` output, instead of plan_file",
+				Required:    false,
+			},
+			"rules": {
+				Type:        "array",
+				Description: "Rules with name, effect (deny or warn), resource_types, addresses, actions, conditions and message",
+				Required:    false,
+			},
+			"rules_file": {
+				Type:        "string",
+				Description: "JSON file with a rules array, evaluated before rules",
+				Required:    false,
+			},
+			"fail_on_deny": {
+				Type:        "boolean",
+				Description: "Fail the step when a deny rule matches",
+				Required:    false,
+				Default:     true,
+			},
+		},
+		Outputs: map[string]plugin.OutputSpec{
+			"passed": {
+				Type:        "boolean",
+				Description: "Whether no deny rule matched",
+			},
+			"violations": {
+				Type:        "array",
+				Description: "Matches with rule, effect, address, type, action and message",
+			},
+			"deny_count": {
+				Type:        "number",
+				Description: "Number of deny violations",
+			},
+			"warn_count": {
+				Type:        "number",
+				Description: "Number of warnings",
+			},
+			"checked": {
+				Type:        "number",
+				Description: "Number of resource changes evaluated",
+			},
+		},
+	}
+}
+
+// policyRule matches resource changes in a plan. Every field that is set
+// must match; an empty rule matches every change.
+type policyRule struct {
+	Name          string            `json:"name"`
+	Effect        string            `json:"effect"`
+	ResourceTypes []string          `json:"resource_types"`
+	Addresses     []string          `json:"addresses"`
+	Actions       []string          `json:"actions"`
+	Conditions    []policyCondition `json:"conditions"`
+	Message       string            `json:"message"`
+}
+
+// policyCondition tests one attribute of the resource. Attribute paths are
+// dotted, with list indexes as numbers: "tags.Environment",
+// "ingress.0.cidr_blocks".
+type policyCondition struct {
+	Attribute string      `json:"attribute"`
+	Operator  string      `json:"operator"`
+	Value     interface{} `json:"value"`
+}
+
+// loadPolicyRules reads rules_file and rules. HCL objects arrive as
+// generic maps, so they are decoded through JSON like the file.
+func loadPolicyRules(params map[string]interface{}) ([]policyRule, error) {
+	var rules []policyRule
+	if path, ok := params["rules_file"].(string); ok && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules_file: %w", err)
+		}
+		var file struct {
+			Rules []policyRule `json:"rules"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			if err := json.Unmarshal(data, &file.Rules); err != nil {
+				return nil, fmt.Errorf("failed to parse rules_file %s: %w", path, err)
+			}
+		}
+		rules = append(rules, file.Rules...)
+	}
+	if raw, ok := params["rules"]; ok && raw != nil {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		var inline []policyRule
+		if err := json.Unmarshal(data, &inline); err != nil {
+			return nil, fmt.Errorf("invalid rules: %w", err)
+		}
+		rules = append(rules, inline...)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("rules or rules_file parameter is required")
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		switch r.Effect {
+		case "":
+			r.Effect = "deny"
+		case "deny", "warn":
+		default:
+			return nil, fmt.Errorf("rule %s: effect must be deny or warn, got %q", r.Name, r.Effect)
+		}
+		for _, c := range r.Conditions {
+			if c.Attribute == "" {
+				return nil, fmt.Errorf("rule %s: condition without attribute", r.Name)
+			}
+			if _, ok := conditionOperators[c.Operator]; !ok {
+				return nil, fmt.Errorf("rule %s: unknown operator %q", r.Name, c.Operator)
+			}
+			if c.Operator == "matches" {
+				if _, err := regexp.Compile(fmt.Sprint(c.Value)); err != nil {
+					return nil, fmt.Errorf("rule %s: invalid pattern: %w", r.Name, err)
+				}
+			}
+		}
+	}
+	return rules, nil
+}
+
+// globMatch matches s against a pattern where * matches any run of
+// characters and ? a single character. Resource addresses contain brackets,
+// so path.Match's character classes are not wanted here.
+func globMatch(pattern, s string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	matched, _ := regexp.MatchString(b.String(), s)
+	return matched
+}
+
+func matchesAny(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if globMatch(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// actionMatches reports whether a change's action satisfies a rule's
+// actions. A replacement destroys and creates the resource, so it also
+// matches "delete" and "create"; "destroy" is accepted for "delete". Rules
+// without actions match every change except no-op and read.
+func actionMatches(ruleActions []string, action string) bool {
+	if len(ruleActions) == 0 {
+		return action != "no-op" && action != "read"
+	}
+	for _, a := range ruleActions {
+		if a == "destroy" {
+			a = "delete"
+		}
+		if a == action || (action == "replace" && (a == "delete" || a == "create")) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupAttribute walks a dotted path through decoded JSON.
+func lookupAttribute(values interface{}, path string) (interface{}, bool) {
+	current := values
+	for _, part := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+var conditionOperators = map[string]func(actual interface{}, found bool, want interface{}) bool{
+	"exists": func(actual interface{}, found bool, want interface{}) bool {
+		return found
+	},
+	"not_exists": func(actual interface{}, found bool, want interface{}) bool {
+		return !found
+	},
+	"equals": func(actual interface{}, found bool, want interface{}) bool {
+		return found && reflect.DeepEqual(actual, want)
+	},
+	"not_equals": func(actual interface{}, found bool, want interface{}) bool {
+		return !found || !reflect.DeepEqual(actual, want)
+	},
+	"in": func(actual interface{}, found bool, want interface{}) bool {
+		return found && containsValue(want, actual)
+	},
+	"not_in": func(actual interface{}, found bool, want interface{}) bool {
+		return !found || !containsValue(want, actual)
+	},
+	"contains": func(actual interface{}, found bool, want interface{}) bool {
+		if s, ok := actual.(string); ok {
+			return strings.Contains(s, fmt.Sprint(want))
+		}
+		return found && containsValue(actual, want)
+	},
+	"matches": func(actual interface{}, found bool, want interface{}) bool {
+		matched, _ := regexp.MatchString(fmt.Sprint(want), fmt.Sprint(actual))
+		return found && matched
+	},
+	"greater_than": func(actual interface{}, found bool, want interface{}) bool {
+		a, aok := actual.(float64)
+		w, wok := want.(float64)
+		return found && aok && wok && a > w
+	},
+	"less_than": func(actual interface{}, found bool, want interface{}) bool {
+		a, aok := actual.(float64)
+		w, wok := want.(float64)
+		return found && aok && wok && a < w
+	},
+}
+
+func containsValue(list, value interface{}) bool {
+	items, ok := list.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range items {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate returns whether the rule matches a resource change. Conditions
+// are checked against the planned values, or the prior values for a
+// delete, since a deleted resource has no planned values.
+func (r policyRule) evaluate(rc resourceChange) bool {
+	if !matchesAny(r.ResourceTypes, rc.Type) || !matchesAny(r.Addresses, rc.Address) {
+		return false
+	}
+	action := rc.action()
+	if !actionMatches(r.Actions, action) {
+		return false
+	}
+	values := rc.Change.After
+	if action == "delete" {
+		values = rc.Change.Before
+	}
+	for _, c := range r.Conditions {
+		actual, found := lookupAttribute(values, c.Attribute)
+		if !conditionOperators[c.Operator](actual, found, c.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *TerraformPlugin) executePolicyCheck(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	rules, err := loadPolicyRules(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *tfPlan
+	if path, ok := params["plan_json"].(string); ok && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plan_json: %w", err)
+		}
+		plan = &tfPlan{}
+		if err := json.Unmarshal(data, plan); err != nil {
+			return nil, fmt.Errorf("failed to parse plan_json %s: %w", path, err)
+		}
+	} else if planFile, ok := params["plan_file"].(string); ok && planFile != "" {
+		tf, err := newTerraformCLI(params)
+		if err != nil {
+			return nil, err
+		}
+		if plan, err = tf.showPlan(ctx, tf.path(planFile)); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("plan_file or plan_json parameter is required")
+	}
+
+	violations := []map[string]interface{}{}
+	var denied []string
+	warnings := 0
+	for _, rc := range plan.ResourceChanges {
+		for _, rule := range rules {
+			if !rule.evaluate(rc) {
+				continue
+			}
+			message := rule.Message
+			if message == "" {
+				message = fmt.Sprintf("%s: %s of %s is not allowed", rule.Name, rc.action(), rc.Address)
+			}
+			violations = append(violations, map[string]interface{}{
+				"rule":    rule.Name,
+				"effect":  rule.Effect,
+				"address": rc.Address,
+				"type":    rc.Type,
+				"action":  rc.action(),
+				"message": message,
+			})
+			if rule.Effect == "deny" {
+				denied = append(denied, rc.Address+" ("+rule.Name+")")
+			} else {
+				warnings++
+			}
+		}
+	}
+
+	passed := len(denied) == 0
+	failOnDeny := true
+	if f, ok := params["fail_on_deny"].(bool); ok {
+		failOnDeny = f
+	}
+	if !passed && failOnDeny {
+		return nil, fmt.Errorf("policy check failed with %d violations: %s", len(denied), strings.Join(denied, ", "))
+	}
+
+	message := fmt.Sprintf("Policy check passed: %d rules, %d resource changes, %d warnings", len(rules), len(plan.ResourceChanges), warnings)
+	if !passed {
+		message = fmt.Sprintf("Policy check found %d violations and %d warnings", len(denied), warnings)
+	}
+	return map[string]interface{}{
+		"passed":     passed,
+		"violations": violations,
+		"deny_count": len(denied),
+		"warn_count": warnings,
+		"checked":    len(plan.ResourceChanges),
+		"success":    true,
+		"message":    message,
+	}, nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"aws_s3_bucket", "aws_s3_bucket", true},
+		{"aws_s3_bucket", "aws_s3_bucket_policy", false},
+		{"aws_s3_*", "aws_s3_bucket_policy", true},
+		{"aws_*", "google_storage_bucket", false},
+		{"*", "", true},
+		{"aws_instance.web?", "aws_instance.web1", true},
+		{"aws_instance.web?", "aws_instance.web", false},
+		{"aws_instance.web?", "aws_instance.web12", false},
+		{`module.net.aws_security_group.web["https"]`, `module.net.aws_security_group.web["https"]`, true},
+		{"aws_instance.web[0]", "aws_instance.web0", false},
+		{"aws_instance.web[*]", "aws_instance.web[3]", true},
+		{"module.*.aws_db_instance.*", "module.db.aws_db_instance.main", true},
+		{"aws_s3.bucket", "aws_s3xbucket", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestActionMatches(t *testing.T) {
+	tests := []struct {
+		rule   []string
+		action string
+		want   bool
+	}{
+		{nil, "create", true},
+		{nil, "update", true},
+		{nil, "delete", true},
+		{nil, "replace", true},
+		{nil, "no-op", false},
+		{nil, "read", false},
+		{[]string{"create"}, "create", true},
+		{[]string{"create"}, "update", false},
+		{[]string{"delete"}, "delete", true},
+		{[]string{"destroy"}, "delete", true},
+		{[]string{"replace"}, "replace", true},
+		{[]string{"delete"}, "replace", true},
+		{[]string{"destroy"}, "replace", true},
+		{[]string{"create"}, "replace", true},
+		{[]string{"update"}, "replace", false},
+		{[]string{"replace"}, "delete", false},
+		{[]string{"replace"}, "create", false},
+		{[]string{"update", "delete"}, "update", true},
+		{[]string{"no-op"}, "no-op", true},
+	}
+	for _, tt := range tests {
+		if got := actionMatches(tt.rule, tt.action); got != tt.want {
+			t.Errorf("actionMatches(%v, %q) = %v, want %v", tt.rule, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestLookupAttribute(t *testing.T) {
+	values := map[string]interface{}{
+		"acl":     "private",
+		"tags":    map[string]interface{}{"Environment": "prod", "Owner": nil},
+		"ingress": []interface{}{map[string]interface{}{"cidr_blocks": []interface{}{"10.0.0.0/8", "0.0.0.0/0"}}},
+		"count":   float64(0),
+		"enabled": false,
+	}
+	tests := []struct {
+		path      string
+		want      interface{}
+		wantFound bool
+	}{
+		{"acl", "private", true},
+		{"tags.Environment", "prod", true},
+		{"tags.Owner", nil, false},
+		{"tags.Team", nil, false},
+		{"ingress.0.cidr_blocks.1", "0.0.0.0/0", true},
+		{"ingress.0.cidr_blocks", []interface{}{"10.0.0.0/8", "0.0.0.0/0"}, true},
+		{"ingress.1", nil, false},
+		{"ingress.-1", nil, false},
+		{"ingress.first", nil, false},
+		{"acl.name", nil, false},
+		{"count", float64(0), true},
+		{"enabled", false, true},
+		{"missing.deeper", nil, false},
+	}
+	for _, tt := range tests {
+		got, found := lookupAttribute(values, tt.path)
+		if found != tt.wantFound || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lookupAttribute(%q) = %#v, %v; want %#v, %v", tt.path, got, found, tt.want, tt.wantFound)
+		}
+	}
+
+	if _, found := lookupAttribute(nil, "acl"); found {
+		t.Error("lookupAttribute on nil values found an attribute")
+	}
+}
+
+func TestConditionOperators(t *testing.T) {
+	cidrs := []interface{}{"10.0.0.0/8", "0.0.0.0/0"}
+	tests := []struct {
+		operator string
+		actual   interface{}
+		found    bool
+		want     interface{}
+		match    bool
+	}{
+		{"exists", "x", true, nil, true},
+		{"exists", nil, false, nil, false},
+		{"not_exists", nil, false, nil, true},
+		{"not_exists", "x", true, nil, false},
+		{"equals", "public-read", true, "public-read", true},
+		{"equals", "private", true, "public-read", false},
+		{"equals", float64(3), true, float64(3), true},
+		{"equals", nil, false, nil, false},
+		{"not_equals", "private", true, "public-read", true},
+		{"not_equals", "public-read", true, "public-read", false},
+		{"not_equals", nil, false, "public-read", true},
+		{"in", "public-read", true, []interface{}{"public-read", "public-read-write"}, true},
+		{"in", "private", true, []interface{}{"public-read", "public-read-write"}, false},
+		{"in", "public-read", true, "public-read", false},
+		{"in", nil, false, []interface{}{nil}, false},
+		{"not_in", "private", true, []interface{}{"public-read"}, true},
+		{"not_in", "public-read", true, []interface{}{"public-read"}, false},
+		{"not_in", nil, false, []interface{}{"public-read"}, true},
+		{"contains", cidrs, true, "0.0.0.0/0", true},
+		{"contains", cidrs, true, "192.168.0.0/16", false},
+		{"contains", "arn:aws:iam::*:role/admin", true, "*", true},
+		{"contains", "t3.micro", true, "large", false},
+		{"contains", nil, false, "x", false},
+		{"matches", "t3.2xlarge", true, `\.(\d+x)?large$`, true},
+		{"matches", "t3.micro", true, `\.(\d+x)?large$`, false},
+		{"matches", nil, false, ".*", false},
+		{"greater_than", float64(500), true, float64(100), true},
+		{"greater_than", float64(100), true, float64(100), false},
+		{"greater_than", "500", true, float64(100), false},
+		{"greater_than", nil, false, float64(100), false},
+		{"less_than", float64(7), true, float64(30), true},
+		{"less_than", float64(30), true, float64(30), false},
+		{"less_than", float64(7), true, "30", false},
+	}
+	for _, tt := range tests {
+		if got := conditionOperators[tt.operator](tt.actual, tt.found, tt.want); got != tt.match {
+			t.Errorf("%s(%#v, found=%v, %#v) = %v, want %v", tt.operator, tt.actual, tt.found, tt.want, got, tt.match)
+		}
+	}
+}
+
+func TestLoadPolicyRulesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []interface{}
+		wantErr string
+	}{
+		{
+			name:    "no rules",
+			rules:   nil,
+			wantErr: "rules or rules_file parameter is required",
+		},
+		{
+			name:    "unknown effect",
+			rules:   []interface{}{map[string]interface{}{"name": "r", "effect": "block"}},
+			wantErr: `rule r: effect must be deny or warn, got "block"`,
+		},
+		{
+			name: "unknown operator",
+			rules: []interface{}{map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{"attribute": "acl", "operator": "like"}},
+			}},
+			wantErr: `rule rule-1: unknown operator "like"`,
+		},
+		{
+			name: "condition without attribute",
+			rules: []interface{}{map[string]interface{}{
+				"name":       "r",
+				"conditions": []interface{}{map[string]interface{}{"operator": "exists"}},
+			}},
+			wantErr: "rule r: condition without attribute",
+		},
+		{
+			name: "invalid pattern",
+			rules: []interface{}{map[string]interface{}{
+				"name":       "r",
+				"conditions": []interface{}{map[string]interface{}{"attribute": "acl", "operator": "matches", "value": "("}},
+			}},
+			wantErr: "rule r: invalid pattern",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{}
+			if tt.rules != nil {
+				params["rules"] = tt.rules
+			}
+			_, err := loadPolicyRules(params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakePlanTerraform answers `show -json` with the plan fixture.
+func fakePlanTerraform(t *testing.T) {
+	t.Helper()
+	fixture, err := filepath.Abs(filepath.Join("testdata", "plan.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	useFakeTerraform(t, `#!/bin/sh
+shift
+case "$1" in
+show) cat '`+fixture+`' ;;
+*) echo "unexpected command: $*" >&2; exit 1 ;;
+esac
+`)
+}
+
+// policyRules covers every change in testdata/plan.json except the read
+// and the no-op.
+var policyRules = []interface{}{
+	map[string]interface{}{
+		"name":           "no-public-buckets",
+		"resource_types": []interface{}{"aws_s3_bucket"},
+		"conditions": []interface{}{map[string]interface{}{
+			"attribute": "acl",
+			"operator":  "in",
+			"value":     []interface{}{"public-read", "public-read-write"},
+		}},
+	},
+	map[string]interface{}{
+		"name":           "protect-databases",
+		"resource_types": []interface{}{"aws_db_*"},
+		"actions":        []interface{}{"destroy"},
+		"message":        "databases must not be destroyed",
+	},
+	map[string]interface{}{
+		"name":      "open-ingress",
+		"effect":    "warn",
+		"addresses": []interface{}{"module.net.*"},
+		"conditions": []interface{}{map[string]interface{}{
+			"attribute": "ingress.0.cidr_blocks",
+			"operator":  "contains",
+			"value":     "0.0.0.0/0",
+		}},
+	},
+	map[string]interface{}{
+		"name":    "legacy-instances",
+		"effect":  "warn",
+		"actions": []interface{}{"delete"},
+		"conditions": []interface{}{map[string]interface{}{
+			"attribute": "instance_type",
+			"operator":  "equals",
+			"value":     "t2.micro",
+		}},
+	},
+	map[string]interface{}{
+		"name":       "untouched",
+		"conditions": []interface{}{map[string]interface{}{"attribute": "max_session_duration", "operator": "exists"}},
+	},
+}
+
+func TestPolicyCheck(t *testing.T) {
+	fakePlanTerraform(t)
+
+	out, err := (&TerraformPlugin{}).Execute(context.Background(), "policy_check", map[string]interface{}{
+		"working_dir":  t.TempDir(),
+		"plan_file":    "tfplan",
+		"rules":        policyRules,
+		"fail_on_deny": false,
+	})
+	if err != nil {
+		t.Fatalf("policy_check: %v", err)
+	}
+
+	var got []string
+	for _, v := range out["violations"].([]map[string]interface{}) {
+		got = append(got, strings.Join([]string{v["rule"].(string), v["effect"].(string), v["action"].(string), v["address"].(string), v["message"].(string)}, " | "))
+	}
+	want := []string{
+		"no-public-buckets | deny | create | aws_s3_bucket.logs | no-public-buckets: create of aws_s3_bucket.logs is not allowed",
+		"protect-databases | deny | replace | aws_db_instance.main | databases must not be destroyed",
+		`open-ingress | warn | update | module.net.aws_security_group.web["https"] | open-ingress: update of module.net.aws_security_group.web["https"] is not allowed`,
+		"legacy-instances | warn | delete | aws_instance.old | legacy-instances: delete of aws_instance.old is not allowed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("violations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if out["passed"] != false || out["deny_count"] != 2 || out["warn_count"] != 2 || out["checked"] != 6 {
+		t.Errorf("passed = %v, deny_count = %v, warn_count = %v, checked = %v", out["passed"], out["deny_count"], out["warn_count"], out["checked"])
+	}
+}
+
+func TestPolicyCheckFailsOnDeny(t *testing.T) {
+	fakePlanTerraform(t)
+
+	_, err := (&TerraformPlugin{}).Execute(context.Background(), "policy_check", map[string]interface{}{
+		"working_dir": t.TempDir(),
+		"plan_file":   "tfplan",
+		"rules":       policyRules,
+	})
+	want := "policy check failed with 2 violations: aws_s3_bucket.logs (no-public-buckets), aws_db_instance.main (protect-databases)"
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
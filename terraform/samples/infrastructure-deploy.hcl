@@ -71,14 +71,78 @@ workflow "infrastructure-deploy" {
     }
   }
 
+  step "terraform_validate" {
+    plugin = "terraform"
+    action = "validate"
+    
+    depends_on = ["terraform_init"]
+    
+    params = {
+      working_dir = var.tf_workspace
+    }
+  }
+
+  step "check_drift" {
+    plugin = "terraform"
+    action = "drift"
+    
+    depends_on = ["terraform_validate"]
+    
+    params = {
+      working_dir = var.tf_workspace
+      fail_on_drift = true
+    }
+  }
+
   step "terraform_plan" {
     plugin = "terraform"
     action = "plan"
     
-    depends_on = ["terraform_init"]
+    depends_on = ["check_drift"]
     
     params = {
       working_dir = var.tf_workspace
+      out = "deploy.tfplan"
+    }
+  }
+
+  step "review_plan" {
+    plugin = "shell"
+    action = "exec"
+    
+    depends_on = ["terraform_plan"]
+    
+    params = {
+      command = "echo '${terraform_plan.message} Changing ${terraform_plan.changes} resources, first: ${terraform_plan.resource_changes[0].address} (${terraform_plan.resource_changes[0].action})'"
+    }
+  }
+
+  step "plan_guardrails" {
+    plugin = "terraform"
+    action = "policy_check"
+    
+    depends_on = ["review_plan"]
+    
+    params = {
+      working_dir = var.tf_workspace
+      plan_file = terraform_plan.plan_file
+      rules = [
+        {
+          name = "no-marker-destroy"
+          resource_types = ["local_file"]
+          actions = ["delete"]
+          message = "The deployment marker must not be destroyed"
+        },
+        {
+          name = "marker-in-tmp"
+          effect = "warn"
+          resource_types = ["local_*"]
+          conditions = [
+            { attribute = "filename", operator = "matches", value = "^/tmp/" }
+          ]
+          message = "The deployment marker is written to /tmp and will not survive a reboot"
+        }
+      ]
     }
   }
 
@@ -86,11 +150,23 @@ workflow "infrastructure-deploy" {
     plugin = "terraform"
     action = "apply"
     
-    depends_on = ["terraform_plan"]
+    depends_on = ["plan_guardrails"]
+    
+    params = {
+      working_dir = var.tf_workspace
+      plan_file = terraform_plan.plan_file
+      plan_checksum = terraform_plan.plan_checksum
+    }
+  }
+
+  step "read_outputs" {
+    plugin = "terraform"
+    action = "output"
+    
+    depends_on = ["terraform_apply"]
     
     params = {
       working_dir = var.tf_workspace
-      auto_approve = true
     }
   }
 
@@ -98,10 +174,10 @@ workflow "infrastructure-deploy" {
     plugin = "file"
     action = "exists"
     
-    depends_on = ["terraform_apply"]
+    depends_on = ["read_outputs"]
     
     params = {
-      path = "/tmp/deployment-${var.environment}.txt"
+      path = read_outputs.outputs.deployment_file
     }
   }
 }
\ No newline at end of file
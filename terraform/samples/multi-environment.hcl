@@ -65,6 +65,19 @@ workflow "multi-environment" {
     
     params = {
       working_dir = var.tf_workspace
+      workspace = "dev"
+    }
+  }
+
+  step "create_staging_workspace" {
+    plugin = "terraform"
+    action = "workspace_new"
+    
+    depends_on = ["init_terraform"]
+    
+    params = {
+      working_dir = var.tf_workspace
+      name = "staging"
     }
   }
 
@@ -73,10 +86,12 @@ workflow "multi-environment" {
     plugin = "terraform"
     action = "plan"
     
-    depends_on = ["init_terraform"]
+    depends_on = ["create_staging_workspace"]
     
     params = {
       working_dir = var.tf_workspace
+      workspace = "dev"
+      out = "dev.tfplan"
       variables = {
         "environment" = "dev"
       }
@@ -91,10 +106,8 @@ workflow "multi-environment" {
     
     params = {
       working_dir = var.tf_workspace
-      variables = {
-        "environment" = "dev"
-      }
-      auto_approve = true
+      workspace = "dev"
+      plan_file = plan_dev.plan_file
     }
   }
 
@@ -107,6 +120,8 @@ workflow "multi-environment" {
     
     params = {
       working_dir = var.tf_workspace
+      workspace = "staging"
+      out = "staging.tfplan"
       variables = {
         "environment" = "staging"
       }
@@ -121,10 +136,19 @@ workflow "multi-environment" {
     
     params = {
       working_dir = var.tf_workspace
-      variables = {
-        "environment" = "staging"
-      }
-      auto_approve = true
+      workspace = "staging"
+      plan_file = plan_staging.plan_file
+    }
+  }
+
+  step "list_workspaces" {
+    plugin = "terraform"
+    action = "workspace_list"
+    
+    depends_on = ["apply_staging"]
+    
+    params = {
+      working_dir = var.tf_workspace
     }
   }
 
@@ -132,7 +156,7 @@ workflow "multi-environment" {
     plugin = "shell"
     action = "exec"
     
-    depends_on = ["apply_staging"]
+    depends_on = ["list_workspaces"]
     
     params = {
       command = "ls -la /tmp/config-*.json && echo 'Multi-environment deployment completed'"
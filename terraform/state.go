@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// stateActions declares output, the state_* actions and import.
+func stateActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "output",
+			Description: "Read root module outputs",
+			Inputs: withWorkspaceInput(map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"name": {
+					Type:        "string",
+					Description: "Only read this output",
+					Required:    false,
+				},
+				"include_sensitive": {
+					Type:        "boolean",
+					Description: "Return the values of sensitive outputs instead of null",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"outputs": {
+					Type:        "object",
+					Description: "Output values keyed by name, with their terraform types preserved",
+				},
+				"types": {
+					Type:        "object",
+					Description: "Terraform type of each output, e.g. list(string)",
+				},
+				"sensitive": {
+					Type:        "array",
+					Description: "Names of sensitive outputs",
+				},
+				"value": {
+					Type:        "object",
+					Description: "Value of the output selected with name, in its own type",
+				},
+			},
+		},
+		{
+			Name:        "state_list",
+			Description: "List resources in the Terraform state",
+			Inputs: withWorkspaceInput(map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"addresses": {
+					Type:        "array",
+					Description: "Only list resources matching these addresses or module paths",
+					Required:    false,
+				},
+				"id": {
+					Type:        "string",
+					Description: "Only list resources with this remote ID",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"resources": {
+					Type:        "array",
+					Description: "Resource addresses",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of resources",
+				},
+			},
+		},
+		{
+			Name:        "state_show",
+			Description: "Show the attributes of a resource in the Terraform state",
+			Inputs: withWorkspaceInput(map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"address": {
+					Type:        "string",
+					Description: "Resource address, e.g. aws_instance.web or module.db.aws_db_instance.main",
+					Required:    true,
+				},
+				"include_sensitive": {
+					Type:        "boolean",
+					Description: "Return sensitive attribute values instead of null",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"attributes": {
+					Type:        "object",
+					Description: "Resource attributes",
+				},
+				"type": {
+					Type:        "string",
+					Description: "Resource type",
+				},
+				"provider": {
+					Type:        "string",
+					Description: "Provider that manages the resource",
+				},
+				"output": {
+					Type:        "string",
+					Description: "Human readable state show output",
+				},
+			},
+		},
+		{
+			Name:        "state_rm",
+			Description: "Remove resources from the Terraform state without destroying them",
+			Inputs: withWorkspaceInput(map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"addresses": {
+					Type:        "array",
+					Description: "Resource addresses to remove",
+					Required:    true,
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Only report what would be removed",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"removed": {
+					Type:        "array",
+					Description: "Removed (or, with dry_run, removable) resource addresses",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of resources removed",
+				},
+			},
+		},
+		{
+			Name:        "import",
+			Description: "Import existing infrastructure into the Terraform state",
+			Inputs: withVariableInputs(map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"address": {
+					Type:        "string",
+					Description: "Resource address to import into",
+					Required:    true,
+				},
+				"id": {
+					Type:        "string",
+					Description: "Provider-specific ID of the existing resource",
+					Required:    true,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"address": {
+					Type:        "string",
+					Description: "Imported resource address",
+				},
+				"success": {
+					Type:        "boolean",
+					Description: "Whether the import succeeded",
+				},
+				"output": {
+					Type:        "string",
+					Description: "Import output",
+				},
+			},
+		},
+	}
+}
+
+// typeString renders a type from JSON output (for example
+// ["map",["list","string"]]) in terraform syntax.
+func typeString(raw interface{}) string {
+	switch t := raw.(type) {
+	case string:
+		return t
+	case []interface{}:
+		if len(t) != 2 {
+			break
+		}
+		kind, _ := t[0].(string)
+		switch kind {
+		case "list", "set", "map":
+			return kind + "(" + typeString(t[1]) + ")"
+		case "object":
+			attrs, _ := t[1].(map[string]interface{})
+			names := make([]string, 0, len(attrs))
+			for name := range attrs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			parts := make([]string, len(names))
+			for i, name := range names {
+				parts[i] = name + "=" + typeString(attrs[name])
+			}
+			return "object({" + strings.Join(parts, ", ") + "})"
+		case "tuple":
+			elems, _ := t[1].([]interface{})
+			parts := make([]string, len(elems))
+			for i, elem := range elems {
+				parts[i] = typeString(elem)
+			}
+			return "tuple([" + strings.Join(parts, ", ") + "])"
+		}
+	}
+	data, _ := json.Marshal(raw)
+	return string(data)
+}
+
+func (p *TerraformPlugin) executeOutput(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tf.exec(ctx, "output", "-json", "-no-color")
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]struct {
+		Sensitive bool        `json:"sensitive"`
+		Type      interface{} `json:"type"`
+		Value     interface{} `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(res.stdout), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform output: %w", err)
+	}
+
+	includeSensitive, _ := params["include_sensitive"].(bool)
+	outputs := make(map[string]interface{}, len(raw))
+	types := make(map[string]interface{}, len(raw))
+	sensitive := []string{}
+	for name, o := range raw {
+		value := o.Value
+		if o.Sensitive {
+			sensitive = append(sensitive, name)
+			if !includeSensitive {
+				value = nil
+			}
+		}
+		outputs[name] = value
+		types[name] = typeString(o.Type)
+	}
+	sort.Strings(sensitive)
+
+	result := map[string]interface{}{
+		"outputs":   outputs,
+		"types":     types,
+		"sensitive": sensitive,
+		"count":     len(outputs),
+		"success":   true,
+	}
+	if name, ok := params["name"].(string); ok && name != "" {
+		value, found := outputs[name]
+		if !found {
+			return nil, fmt.Errorf("output %q not found", name)
+		}
+		result["value"] = value
+		result["type"] = types[name]
+	}
+	return result, nil
+}
+
+func (p *TerraformPlugin) executeStateList(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"state", "list"}
+	if id, ok := params["id"].(string); ok && id != "" {
+		args = append(args, "-id="+id)
+	}
+	res, err := tf.exec(ctx, append(args, stringList(params, "addresses")...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := nonEmptyLines(res.stdout)
+	return map[string]interface{}{
+		"resources": resources,
+		"count":     len(resources),
+		"success":   true,
+	}, nil
+}
+
+// stateModule is a module in `terraform show -json` state output.
+type stateModule struct {
+	Resources []struct {
+		Address         string                 `json:"address"`
+		Type            string                 `json:"type"`
+		Name            string                 `json:"name"`
+		ProviderName    string                 `json:"provider_name"`
+		Values          map[string]interface{} `json:"values"`
+		SensitiveValues map[string]interface{} `json:"sensitive_values"`
+	} `json:"resources"`
+	ChildModules []stateModule `json:"child_modules"`
+}
+
+func (p *TerraformPlugin) executeStateShow(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	address, ok := params["address"].(string)
+	if !ok || address == "" {
+		return nil, fmt.Errorf("address parameter is required")
+	}
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := tf.exec(ctx, "state", "show", "-no-color", address)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tf.exec(ctx, "show", "-json", "-no-color")
+	if err != nil {
+		return nil, err
+	}
+	var state struct {
+		Values struct {
+			RootModule stateModule `json:"root_module"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(res.stdout), &state); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform show output: %w", err)
+	}
+
+	includeSensitive, _ := params["include_sensitive"].(bool)
+	modules := []stateModule{state.Values.RootModule}
+	for len(modules) > 0 {
+		m := modules[0]
+		modules = append(modules[1:], m.ChildModules...)
+		for _, r := range m.Resources {
+			if r.Address != address {
+				continue
+			}
+			var attributes interface{} = r.Values
+			if !includeSensitive {
+				attributes = maskSensitive(attributes, r.SensitiveValues)
+			}
+			return map[string]interface{}{
+				"address":    address,
+				"attributes": attributes,
+				"type":       r.Type,
+				"name":       r.Name,
+				"provider":   r.ProviderName,
+				"output":     text.stdout,
+				"success":    true,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("resource %s not found in state", address)
+}
+
+// maskSensitive returns value with every leaf that marks flags as sensitive
+// replaced by null. marks mirrors the shape of value the way
+// `sensitive_values` does in `terraform show -json`: true for a sensitive
+// value, and nested objects or arrays where only some elements are.
+func maskSensitive(value, marks interface{}) interface{} {
+	if marks == true {
+		return nil
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m, _ := marks.(map[string]interface{})
+		for key, mark := range m {
+			if elem, ok := v[key]; ok {
+				v[key] = maskSensitive(elem, mark)
+			}
+		}
+	case []interface{}:
+		m, _ := marks.([]interface{})
+		for i := 0; i < len(m) && i < len(v); i++ {
+			v[i] = maskSensitive(v[i], m[i])
+		}
+	}
+	return value
+}
+
+func (p *TerraformPlugin) executeStateRm(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	addresses := stringList(params, "addresses")
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("addresses parameter is required")
+	}
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"state", "rm", "-no-color"}
+	dryRun, _ := params["dry_run"].(bool)
+	if dryRun {
+		args = append(args, "-dry-run")
+	}
+	res, err := tf.exec(ctx, append(args, addresses...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Terraform prints "Removed <addr>", or "Would remove <addr>" for a
+	// dry run, once per resource instance.
+	removed := []string{}
+	for _, line := range nonEmptyLines(res.stdout) {
+		for _, prefix := range []string{"Removed ", "Would remove "} {
+			if strings.HasPrefix(line, prefix) {
+				removed = append(removed, strings.TrimPrefix(line, prefix))
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"removed": removed,
+		"count":   len(removed),
+		"dry_run": dryRun,
+		"success": true,
+		"output":  res.stdout,
+	}, nil
+}
+
+func (p *TerraformPlugin) executeImport(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	address, ok := params["address"].(string)
+	if !ok || address == "" {
+		return nil, fmt.Errorf("address parameter is required")
+	}
+	id, ok := params["id"].(string)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("id parameter is required")
+	}
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := variableArgs(params)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"import", "-input=false", "-no-color"}, vars...)
+	res, err := tf.exec(ctx, append(args, address, id)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"address": address,
+		"id":      id,
+		"success": true,
+		"output":  res.stdout,
+		"message": fmt.Sprintf("Imported %s as %s", id, address),
+	}, nil
+}
+
+func nonEmptyLines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeShowTerraform answers `state show` with text and `show -json` with the
+// state fixture.
+func fakeShowTerraform(t *testing.T) {
+	t.Helper()
+	fixture, err := filepath.Abs(filepath.Join("testdata", "show.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	useFakeTerraform(t, `#!/bin/sh
+shift
+case "$1" in
+state) echo "# module.db.aws_db_instance.main:" ;;
+show) cat '`+fixture+`' ;;
+*) echo "unexpected command: $*" >&2; exit 1 ;;
+esac
+`)
+}
+
+func TestStateShowMasksNestedSensitiveValues(t *testing.T) {
+	fakeShowTerraform(t)
+
+	out, err := (&TerraformPlugin{}).Execute(context.Background(), "state_show", map[string]interface{}{
+		"working_dir": t.TempDir(),
+		"address":     "module.db.aws_db_instance.main",
+	})
+	if err != nil {
+		t.Fatalf("state_show: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"identifier": "orders",
+		"password":   nil,
+		"connection_info": map[string]interface{}{
+			"host":     "orders.example.internal",
+			"port":     float64(5432),
+			"password": nil,
+		},
+		"users": []interface{}{
+			map[string]interface{}{"name": "app", "token": nil},
+			map[string]interface{}{"name": "report", "token": nil},
+		},
+		"tags":         map[string]interface{}{"team": "payments"},
+		"replica_keys": nil,
+	}
+	if got := out["attributes"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("attributes = %#v\nwant %#v", got, want)
+	}
+	if out["type"] != "aws_db_instance" || out["name"] != "main" {
+		t.Errorf("type, name = %v, %v", out["type"], out["name"])
+	}
+}
+
+func TestStateShowIncludeSensitive(t *testing.T) {
+	fakeShowTerraform(t)
+
+	out, err := (&TerraformPlugin{}).Execute(context.Background(), "state_show", map[string]interface{}{
+		"working_dir":       t.TempDir(),
+		"address":           "module.db.aws_db_instance.main",
+		"include_sensitive": true,
+	})
+	if err != nil {
+		t.Fatalf("state_show: %v", err)
+	}
+	attributes := out["attributes"].(map[string]interface{})
+	info := attributes["connection_info"].(map[string]interface{})
+	if info["password"] != "hunter2" {
+		t.Errorf("connection_info.password = %v, want the raw value", info["password"])
+	}
+}
+
+func TestMaskSensitive(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		marks interface{}
+		want  interface{}
+	}{
+		{"no marks", map[string]interface{}{"a": "x"}, nil, map[string]interface{}{"a": "x"}},
+		{"whole value", "x", true, nil},
+		{"false mark", "x", false, "x"},
+		{"mark for a missing key", map[string]interface{}{"a": "x"}, map[string]interface{}{"b": true}, map[string]interface{}{"a": "x"}},
+		{"nested lists", []interface{}{[]interface{}{"x", "y"}}, []interface{}{[]interface{}{false, true}}, []interface{}{[]interface{}{"x", nil}}},
+		{"shorter marks", []interface{}{"x", "y"}, []interface{}{true}, []interface{}{nil, "y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maskSensitive(tt.value, tt.marks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("maskSensitive = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// withWorkspaceInput adds the workspace input shared by every action that
+// reads or changes state.
+func withWorkspaceInput(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["workspace"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Workspace to run in (default: the selected workspace)",
+		Required:    false,
+	}
+	return inputs
+}
+
+// withVariableInputs adds the inputs that set root module variables.
+func withVariableInputs(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["variables"] = plugin.InputSpec{
+		Type:        "object",
+		Description: "Variable values passed with -var; lists and maps are passed as HCL",
+		Required:    false,
+	}
+	inputs["var_file"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Path to variables file",
+		Required:    false,
+	}
+	inputs["var_files"] = plugin.InputSpec{
+		Type:        "array",
+		Description: "Variables files passed with -var-file, in order",
+		Required:    false,
+	}
+	return withWorkspaceInput(inputs)
+}
+
+// variableArgs builds -var-file and -var flags. Files come first so that
+// explicit variables override them, as on the command line.
+func variableArgs(params map[string]interface{}) ([]string, error) {
+	var args []string
+	if file, ok := params["var_file"].(string); ok && file != "" {
+		args = append(args, "-var-file="+file)
+	}
+	for _, file := range stringList(params, "var_files") {
+		args = append(args, "-var-file="+file)
+	}
+
+	vars, _ := params["variables"].(map[string]interface{})
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := variableValue(vars[name])
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", name, err)
+		}
+		args = append(args, "-var="+name+"="+value)
+	}
+	return args, nil
+}
+
+// variableValue renders a value for -var. Strings are passed verbatim;
+// numbers, bools, lists and maps use JSON, which terraform parses as HCL.
+func variableValue(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// backendConfigArgs builds -backend-config flags from either an object of
+// key/value settings or a list of files and key=value strings.
+func backendConfigArgs(params map[string]interface{}) []string {
+	var args []string
+	switch cfg := params["backend_config"].(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(cfg))
+		for k := range cfg {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value, _ := variableValue(cfg[k])
+			args = append(args, "-backend-config="+k+"="+value)
+		}
+	case string:
+		if cfg != "" {
+			args = append(args, "-backend-config="+cfg)
+		}
+	default:
+		for _, item := range stringList(params, "backend_config") {
+			args = append(args, "-backend-config="+item)
+		}
+	}
+	return args
+}
+
+// stringList reads an array parameter as strings.
+func stringList(params map[string]interface{}, name string) []string {
+	raw, ok := params[name].([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		out = append(out, fmt.Sprint(v))
+	}
+	return out
+}
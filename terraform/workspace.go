@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// workspaceActions declares the workspace_* actions.
+func workspaceActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "workspace_list",
+			Description: "List Terraform workspaces",
+			Inputs: map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+			},
+			Outputs: map[string]plugin.OutputSpec{
+				"workspaces": {
+					Type:        "array",
+					Description: "Workspace names",
+				},
+				"current": {
+					Type:        "string",
+					Description: "Selected workspace",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of workspaces",
+				},
+			},
+		},
+		{
+			Name:        "workspace_select",
+			Description: "Select a Terraform workspace",
+			Inputs: map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"name": {
+					Type:        "string",
+					Description: "Workspace name",
+					Required:    true,
+				},
+				"create": {
+					Type:        "boolean",
+					Description: "Create the workspace if it does not exist",
+					Required:    false,
+					Default:     false,
+				},
+			},
+			Outputs: map[string]plugin.OutputSpec{
+				"workspace": {
+					Type:        "string",
+					Description: "Selected workspace",
+				},
+				"created": {
+					Type:        "boolean",
+					Description: "Whether the workspace was created",
+				},
+			},
+		},
+		{
+			Name:        "workspace_new",
+			Description: "Create and select a Terraform workspace",
+			Inputs: map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"name": {
+					Type:        "string",
+					Description: "Workspace name",
+					Required:    true,
+				},
+			},
+			Outputs: map[string]plugin.OutputSpec{
+				"workspace": {
+					Type:        "string",
+					Description: "Created workspace",
+				},
+			},
+		},
+		{
+			Name:        "workspace_delete",
+			Description: "Delete a Terraform workspace",
+			Inputs: map[string]plugin.InputSpec{
+				"working_dir": {
+					Type:        "string",
+					Description: "Directory containing Terraform configuration",
+					Required:    false,
+					Default:     ".",
+				},
+				"name": {
+					Type:        "string",
+					Description: "Workspace name",
+					Required:    true,
+				},
+				"force": {
+					Type:        "boolean",
+					Description: "Delete even if the workspace still manages resources",
+					Required:    false,
+					Default:     false,
+				},
+			},
+			Outputs: map[string]plugin.OutputSpec{
+				"workspace": {
+					Type:        "string",
+					Description: "Deleted workspace",
+				},
+				"deleted": {
+					Type:        "boolean",
+					Description: "Whether the workspace existed and was deleted",
+				},
+			},
+		},
+	}
+}
+
+// listWorkspaces parses `terraform workspace list`, where the selected
+// workspace is marked with an asterisk.
+func (t *terraformCLI) listWorkspaces(ctx context.Context) ([]string, string, error) {
+	res, err := t.exec(ctx, "workspace", "list")
+	if err != nil {
+		return nil, "", err
+	}
+	var names []string
+	current := ""
+	for _, line := range strings.Split(res.stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+			current = line
+		}
+		if line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, current, nil
+}
+
+// ensureWorkspace creates the workspace if it does not exist yet and
+// reports whether it did. `terraform workspace new` also selects the new
+// workspace in .terraform/environment; the previous selection is restored
+// so that creating a workspace does not switch later steps that have no
+// workspace input.
+func (t *terraformCLI) ensureWorkspace(ctx context.Context, name string) (bool, error) {
+	names, current, err := t.listWorkspaces(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, n := range names {
+		if n == name {
+			return false, nil
+		}
+	}
+	if _, err := t.exec(ctx, "workspace", "new", "-no-color", name); err != nil {
+		return false, err
+	}
+	if current != "" && current != name {
+		if _, err := t.exec(ctx, "workspace", "select", "-no-color", current); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func workspaceName(params map[string]interface{}) (string, error) {
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return "", fmt.Errorf("name parameter is required")
+	}
+	return name, nil
+}
+
+func (p *TerraformPlugin) executeWorkspaceList(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+	names, current, err := tf.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"workspaces": names,
+		"current":    current,
+		"count":      len(names),
+		"success":    true,
+	}, nil
+}
+
+func (p *TerraformPlugin) executeWorkspaceSelect(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	name, err := workspaceName(params)
+	if err != nil {
+		return nil, err
+	}
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	created := false
+	if create, _ := params["create"].(bool); create {
+		if created, err = tf.ensureWorkspace(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tf.exec(ctx, "workspace", "select", "-no-color", name); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"workspace": name,
+		"created":   created,
+		"success":   true,
+		"message":   fmt.Sprintf("Switched to workspace %s", name),
+	}, nil
+}
+
+func (p *TerraformPlugin) executeWorkspaceNew(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	name, err := workspaceName(params)
+	if err != nil {
+		return nil, err
+	}
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tf.exec(ctx, "workspace", "new", "-no-color", name); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"workspace": name,
+		"success":   true,
+		"message":   fmt.Sprintf("Created and switched to workspace %s", name),
+	}, nil
+}
+
+func (p *TerraformPlugin) executeWorkspaceDelete(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	name, err := workspaceName(params)
+	if err != nil {
+		return nil, err
+	}
+	if name == "default" {
+		return nil, fmt.Errorf("the default workspace cannot be deleted")
+	}
+	tf, err := newTerraformCLI(params)
+	if err != nil {
+		return nil, err
+	}
+
+	names, current, err := tf.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, n := range names {
+		found = found || n == name
+	}
+	if !found {
+		return map[string]interface{}{
+			"workspace": name,
+			"deleted":   false,
+			"success":   true,
+			"message":   fmt.Sprintf("Workspace %s does not exist", name),
+		}, nil
+	}
+
+	// Terraform refuses to delete the selected workspace.
+	if current == name {
+		if _, err := tf.exec(ctx, "workspace", "select", "-no-color", "default"); err != nil {
+			return nil, err
+		}
+	}
+	args := []string{"workspace", "delete", "-no-color"}
+	if force, _ := params["force"].(bool); force {
+		args = append(args, "-force")
+	}
+	if _, err := tf.exec(ctx, append(args, name)...); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"workspace": name,
+		"deleted":   true,
+		"success":   true,
+		"message":   fmt.Sprintf("Deleted workspace %s", name),
+	}, nil
+}
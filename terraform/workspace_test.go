@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeWorkspaceTerraform is a terraform stand-in that keeps workspaces and
+// the selected workspace on disk the way the real CLI does.
+const fakeWorkspaceTerraform = `#!/bin/sh
+dir=${1#-chdir=}
+shift
+for last; do :; done
+current=$(cat "$dir/.terraform/environment" 2>/dev/null || echo default)
+case "$1 $2" in
+"init "*)
+	echo "Terraform has been successfully initialized!" ;;
+"workspace list")
+	for w in default $(cat "$dir/.workspaces" 2>/dev/null); do
+		if [ "$w" = "$current" ]; then echo "* $w"; else echo "  $w"; fi
+	done ;;
+"workspace new")
+	echo "$last" >> "$dir/.workspaces"
+	mkdir -p "$dir/.terraform"
+	echo "$last" > "$dir/.terraform/environment"
+	echo "Created and switched to workspace \"$last\"!" ;;
+"workspace select")
+	mkdir -p "$dir/.terraform"
+	echo "$last" > "$dir/.terraform/environment" ;;
+*)
+	echo "unexpected command: $*" >&2
+	exit 1 ;;
+esac
+`
+
+// useFakeTerraform puts script first in PATH as terraform.
+func useFakeTerraform(t *testing.T, script string) {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "terraform"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func selectedWorkspace(t *testing.T, dir string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, ".terraform", "environment"))
+	if os.IsNotExist(err) {
+		return "default"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestInitCreatesWorkspaceWithoutSelectingIt(t *testing.T) {
+	useFakeTerraform(t, fakeWorkspaceTerraform)
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".terraform"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".workspaces"), []byte("staging\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, ".terraform", "environment"), []byte("staging\n"), 0o644)
+
+	p := &TerraformPlugin{}
+	out, err := p.Execute(context.Background(), "init", map[string]interface{}{
+		"working_dir": dir,
+		"workspace":   "prod",
+	})
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if out["workspace_created"] != true {
+		t.Errorf("workspace_created = %v, want true", out["workspace_created"])
+	}
+	if got := selectedWorkspace(t, dir); got != "staging" {
+		t.Errorf("selected workspace = %s after init, want staging", got)
+	}
+
+	out, err = p.Execute(context.Background(), "init", map[string]interface{}{
+		"working_dir": dir,
+		"workspace":   "prod",
+	})
+	if err != nil {
+		t.Fatalf("second init: %v", err)
+	}
+	if out["workspace_created"] != false {
+		t.Errorf("second init workspace_created = %v, want false", out["workspace_created"])
+	}
+}
+
+func TestWorkspaceSelectCreateSelects(t *testing.T) {
+	useFakeTerraform(t, fakeWorkspaceTerraform)
+	dir := t.TempDir()
+
+	out, err := (&TerraformPlugin{}).Execute(context.Background(), "workspace_select", map[string]interface{}{
+		"working_dir": dir,
+		"name":        "prod",
+		"create":      true,
+	})
+	if err != nil {
+		t.Fatalf("workspace_select: %v", err)
+	}
+	if out["created"] != true {
+		t.Errorf("created = %v, want true", out["created"])
+	}
+	if got := selectedWorkspace(t, dir); got != "prod" {
+		t.Errorf("selected workspace = %s, want prod", got)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultJWTFile is where Kubernetes mounts the pod's service account token.
+const defaultJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// expiryMargin is how long before expiry a cached token stops being used,
+// capped at a third of the token's TTL.
+const expiryMargin = 30 * time.Second
+
+// authInfo is the auth block of a login or renewal response.
+type authInfo struct {
+	ClientToken   string   `json:"client_token"`
+	Accessor      string   `json:"accessor"`
+	Policies      []string `json:"policies"`
+	LeaseDuration int      `json:"lease_duration"`
+	Renewable     bool     `json:"renewable"`
+}
+
+// cachedToken is a token obtained by logging in. expires is zero for tokens
+// without a TTL.
+type cachedToken struct {
+	token   string
+	ttl     time.Duration
+	expires time.Time
+}
+
+func (t *cachedToken) margin() time.Duration {
+	if t.ttl/3 < expiryMargin {
+		return t.ttl / 3
+	}
+	return expiryMargin
+}
+
+func (t *cachedToken) valid() bool {
+	return t.expires.IsZero() || time.Until(t.expires) > t.margin()
+}
+
+// tokenCache holds login tokens for the lifetime of the plugin process, so
+// each step does not log in again, and keeps them renewed in the background
+// so that leases created with them are not revoked mid-workflow.
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*cachedToken
+}
+
+func (tc *tokenCache) get(key string) *cachedToken {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if t, ok := tc.tokens[key]; ok && t.valid() {
+		return t
+	}
+	return nil
+}
+
+func (tc *tokenCache) put(key string, t *cachedToken) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.tokens == nil {
+		tc.tokens = map[string]*cachedToken{}
+	}
+	tc.tokens[key] = t
+}
+
+// current reports whether t is still the cached token for key.
+func (tc *tokenCache) current(key string, t *cachedToken) bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.tokens[key] == t
+}
+
+func (tc *tokenCache) drop(key string, t *cachedToken) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.tokens[key] == t {
+		delete(tc.tokens, key)
+	}
+}
+
+// authMethod returns the auth_method parameter, or infers it from the
+// credentials given.
+func authMethod(params map[string]interface{}) string {
+	if m, ok := params["auth_method"].(string); ok && m != "" {
+		return m
+	}
+	switch {
+	case stringParam(params, "token", "") != "":
+		return "token"
+	case stringParam(params, "token_file", "") != "":
+		return "token_file"
+	case stringParam(params, "role_id", "") != "":
+		return "approle"
+	case stringParam(params, "username", "") != "":
+		return "userpass"
+	}
+	return "token"
+}
+
+// authenticate sets the client token. Static tokens come from token,
+// VAULT_TOKEN or ~/.vault-token, as with the vault CLI. token_file is read
+// on every call since agents rewrite it when they renew. AppRole,
+// Kubernetes and userpass logins are cached.
+func (p *VaultPlugin) authenticate(ctx context.Context, c *vaultClient, params map[string]interface{}) error {
+	method := authMethod(params)
+	switch method {
+	case "token":
+		c.token = stringParam(params, "token", "VAULT_TOKEN")
+		if c.token == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				data, _ := os.ReadFile(filepath.Join(home, ".vault-token"))
+				c.token = strings.TrimSpace(string(data))
+			}
+		}
+		if c.token == "" {
+			return fmt.Errorf("token parameter is required (or set auth_method, VAULT_TOKEN or token_file)")
+		}
+		return nil
+	case "token_file":
+		file := stringParam(params, "token_file", "")
+		if file == "" {
+			return fmt.Errorf("token_file parameter is required")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read token_file: %w", err)
+		}
+		c.token = strings.TrimSpace(string(data))
+		if c.token == "" {
+			return fmt.Errorf("token_file %s is empty", file)
+		}
+		return nil
+	}
+
+	mount := strings.Trim(stringParam(params, "auth_mount", ""), "/")
+	if mount == "" {
+		mount = method
+	}
+	path, body, err := loginRequest(method, mount, params)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.New()
+	for _, part := range []string{c.address, c.namespace, path} {
+		sum.Write([]byte(part + "\x00"))
+	}
+	for _, k := range []string{"role_id", "secret_id", "role", "jwt", "password"} {
+		sum.Write([]byte(fmt.Sprint(body[k]) + "\x00"))
+	}
+	key := hex.EncodeToString(sum.Sum(nil))
+
+	if t := p.tokens.get(key); t != nil {
+		c.token = t.token
+		return nil
+	}
+
+	s, err := c.do(ctx, http.MethodPost, path, nil, body)
+	if err != nil {
+		return fmt.Errorf("vault %s login failed: %w", method, err)
+	}
+	if s.Auth == nil || s.Auth.ClientToken == "" {
+		return fmt.Errorf("vault %s login returned no token", method)
+	}
+
+	t := &cachedToken{token: s.Auth.ClientToken, ttl: time.Duration(s.Auth.LeaseDuration) * time.Second}
+	if t.ttl > 0 {
+		t.expires = time.Now().Add(t.ttl)
+	}
+	p.tokens.put(key, t)
+	if s.Auth.Renewable && s.Auth.LeaseDuration > 0 {
+		renewer := *c
+		renewer.token = t.token
+		go p.renewToken(&renewer, key, t)
+	}
+	c.token = t.token
+	return nil
+}
+
+// loginRequest returns the login path and body for an auth method.
+func loginRequest(method, mount string, params map[string]interface{}) (string, map[string]interface{}, error) {
+	switch method {
+	case "approle":
+		roleID := stringParam(params, "role_id", "")
+		if roleID == "" {
+			return "", nil, fmt.Errorf("role_id parameter is required for approle auth")
+		}
+		body := map[string]interface{}{"role_id": roleID}
+		secretID, err := paramOrFile(params, "secret_id", "secret_id_file", "")
+		if err != nil {
+			return "", nil, err
+		}
+		if secretID != "" {
+			body["secret_id"] = secretID
+		}
+		return "auth/" + mount + "/login", body, nil
+	case "kubernetes":
+		role := stringParam(params, "role", "")
+		if role == "" {
+			return "", nil, fmt.Errorf("role parameter is required for kubernetes auth")
+		}
+		jwt, err := paramOrFile(params, "jwt", "jwt_file", defaultJWTFile)
+		if err != nil {
+			return "", nil, err
+		}
+		return "auth/" + mount + "/login", map[string]interface{}{"role": role, "jwt": jwt}, nil
+	case "userpass":
+		username := stringParam(params, "username", "")
+		if username == "" {
+			return "", nil, fmt.Errorf("username parameter is required for userpass auth")
+		}
+		password, err := paramOrFile(params, "password", "password_file", "")
+		if err != nil {
+			return "", nil, err
+		}
+		return "auth/" + mount + "/login/" + username, map[string]interface{}{"password": password}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported auth_method %q (token, token_file, approle, kubernetes or userpass)", method)
+}
+
+// paramOrFile reads a credential given either inline or as a file, with
+// defaultFile used when neither is set.
+func paramOrFile(params map[string]interface{}, name, fileParam, defaultFile string) (string, error) {
+	if v := stringParam(params, name, ""); v != "" {
+		return v, nil
+	}
+	file := stringParam(params, fileParam, "")
+	if file == "" {
+		file = defaultFile
+	}
+	if file == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", fileParam, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// renewToken renews a login token by its original TTL whenever two thirds
+// of the remaining TTL have passed, for as long as it is the cached token.
+// Once it cannot be renewed, or is about to reach its max TTL, it is dropped
+// so the next step logs in again.
+func (p *VaultPlugin) renewToken(c *vaultClient, key string, t *cachedToken) {
+	increment := map[string]interface{}{"increment": int(t.ttl.Seconds())}
+	wait := t.ttl * 2 / 3
+	for {
+		time.Sleep(wait)
+		if !p.tokens.current(key, t) {
+			return
+		}
+
+		s, err := c.do(context.Background(), http.MethodPost, "auth/token/renew-self", nil, increment)
+		if err != nil || s.Auth == nil {
+			p.tokens.drop(key, t)
+			return
+		}
+		ttl := time.Duration(s.Auth.LeaseDuration) * time.Second
+		if ttl <= t.margin() {
+			p.tokens.drop(key, t)
+			return
+		}
+		p.tokens.mu.Lock()
+		t.expires = time.Now().Add(ttl)
+		p.tokens.mu.Unlock()
+		wait = ttl * 2 / 3
+	}
+}
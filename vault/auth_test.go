@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loginReply routes a login path to a response carrying token.
+func (f *fakeVault) loginReply(path, token string, ttl int, renewable bool) {
+	f.handle(http.MethodPost, path, func(*vaultRequest) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"auth": map[string]interface{}{
+			"client_token":   token,
+			"accessor":       "accessor-" + token,
+			"policies":       []string{"default", "app"},
+			"lease_duration": ttl,
+			"renewable":      renewable,
+		}}
+	})
+}
+
+// readSecret runs a read of secret/app with params and returns the token
+// the read was sent with.
+func readSecret(t *testing.T, p *VaultPlugin, f *fakeVault, params map[string]interface{}) string {
+	t.Helper()
+	f.mount("secret/app", "secret/", "kv", "2")
+	f.reply(http.MethodGet, "secret/data/app", map[string]interface{}{
+		"data":     map[string]interface{}{"k": "v"},
+		"metadata": map[string]interface{}{"version": 1},
+	})
+	params["address"] = f.srv.URL
+	params["path"] = "secret/app"
+	if _, err := p.Execute(context.Background(), "read", params); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return f.last(t, http.MethodGet, "secret/data/app").header.Get("X-Vault-Token")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func countRequests(f *fakeVault, method, path string) int {
+	n := 0
+	for _, r := range f.seen() {
+		if r == method+" "+path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestAppRoleLoginIsCached(t *testing.T) {
+	f := newFakeVault(t)
+	f.loginReply("auth/ci-approle/login", "s.approle", 3600, false)
+	p := &VaultPlugin{}
+	params := func() map[string]interface{} {
+		return map[string]interface{}{
+			"auth_mount":     "/ci-approle/",
+			"role_id":        "role-123",
+			"secret_id_file": writeFile(t, "secret-id", "secret-456\n"),
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if token := readSecret(t, p, f, params()); token != "s.approle" {
+			t.Fatalf("read %d sent token %q, want the login token", i, token)
+		}
+	}
+	if n := countRequests(f, http.MethodPost, "auth/ci-approle/login"); n != 1 {
+		t.Errorf("logged in %d times, want 1", n)
+	}
+	body := f.last(t, http.MethodPost, "auth/ci-approle/login").body
+	if body["role_id"] != "role-123" || body["secret_id"] != "secret-456" {
+		t.Errorf("login body = %v", body)
+	}
+
+	// Different credentials are a different cache entry.
+	other := params()
+	other["role_id"] = "role-789"
+	readSecret(t, p, f, other)
+	if n := countRequests(f, http.MethodPost, "auth/ci-approle/login"); n != 2 {
+		t.Errorf("logged in %d times after changing role_id, want 2", n)
+	}
+}
+
+func TestExpiredLoginIsRenewedByLoggingIn(t *testing.T) {
+	f := newFakeVault(t)
+	f.loginReply("auth/userpass/login/alice", "s.alice", 60, false)
+	p := &VaultPlugin{}
+	params := func() map[string]interface{} {
+		return map[string]interface{}{"username": "alice", "password": "pw"}
+	}
+
+	readSecret(t, p, f, params())
+	p.tokens.mu.Lock()
+	for _, cached := range p.tokens.tokens {
+		cached.expires = time.Now().Add(5 * time.Second)
+	}
+	p.tokens.mu.Unlock()
+	readSecret(t, p, f, params())
+
+	if n := countRequests(f, http.MethodPost, "auth/userpass/login/alice"); n != 2 {
+		t.Errorf("logged in %d times, want a new login once the token is within its expiry margin", n)
+	}
+}
+
+func TestKubernetesLogin(t *testing.T) {
+	f := newFakeVault(t)
+	f.loginReply("auth/kubernetes/login", "s.k8s", 0, false)
+
+	token := readSecret(t, &VaultPlugin{}, f, map[string]interface{}{
+		"auth_method": "kubernetes",
+		"role":        "deployer",
+		"jwt_file":    writeFile(t, "token", "eyJhbGciOi.jwt\n"),
+	})
+	if token != "s.k8s" {
+		t.Errorf("read sent token %q", token)
+	}
+	body := f.last(t, http.MethodPost, "auth/kubernetes/login").body
+	if body["role"] != "deployer" || body["jwt"] != "eyJhbGciOi.jwt" {
+		t.Errorf("login body = %v", body)
+	}
+}
+
+func TestLoginFailure(t *testing.T) {
+	f := newFakeVault(t)
+	f.fail(http.MethodPost, "auth/userpass/login/alice", http.StatusBadRequest, "invalid username or password")
+
+	_, err := (&VaultPlugin{}).Execute(context.Background(), "read", map[string]interface{}{
+		"address":  f.srv.URL,
+		"path":     "secret/app",
+		"username": "alice",
+		"password": "wrong",
+	})
+	if err == nil || err.Error() != "vault userpass login failed: vault: invalid username or password" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestTokenFileIsReadEveryStep(t *testing.T) {
+	f := newFakeVault(t)
+	file := writeFile(t, "sink", "s.first\n")
+	p := &VaultPlugin{}
+
+	if token := readSecret(t, p, f, map[string]interface{}{"token_file": file}); token != "s.first" {
+		t.Errorf("first read sent %q", token)
+	}
+	os.WriteFile(file, []byte("s.second\n"), 0o600)
+	if token := readSecret(t, p, f, map[string]interface{}{"token_file": file}); token != "s.second" {
+		t.Errorf("second read sent %q, want the rewritten token", token)
+	}
+}
+
+func TestTokenFallsBackToVaultTokenFile(t *testing.T) {
+	f := newFakeVault(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.WriteFile(filepath.Join(home, ".vault-token"), []byte("s.home\n"), 0o600)
+
+	if token := readSecret(t, &VaultPlugin{}, f, map[string]interface{}{}); token != "s.home" {
+		t.Errorf("read sent %q, want the token from ~/.vault-token", token)
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	_, err := (&VaultPlugin{}).Execute(context.Background(), "read", map[string]interface{}{
+		"address": f.srv.URL,
+		"path":    "secret/app",
+	})
+	if err == nil || !strings.Contains(err.Error(), "token parameter is required") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestRenewTokenExtendsUntilRenewalFails(t *testing.T) {
+	f := newFakeVault(t)
+	renewals := 0
+	f.handle(http.MethodPost, "auth/token/renew-self", func(r *vaultRequest) (int, interface{}) {
+		f.mu.Lock()
+		renewals++
+		n := renewals
+		f.mu.Unlock()
+		if n > 1 {
+			return http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}}
+		}
+		return http.StatusOK, map[string]interface{}{"auth": map[string]interface{}{
+			"client_token":   "s.renewable",
+			"lease_duration": 1,
+			"renewable":      true,
+		}}
+	})
+
+	c, err := newClient(map[string]interface{}{"address": f.srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.token = "s.renewable"
+	p := &VaultPlugin{}
+	cached := &cachedToken{token: c.token, ttl: 300 * time.Millisecond, expires: time.Now().Add(300 * time.Millisecond)}
+	p.tokens.put("key", cached)
+
+	done := make(chan struct{})
+	go func() {
+		p.renewToken(c, "key", cached)
+		close(done)
+	}()
+
+	time.Sleep(400 * time.Millisecond)
+	if got := p.tokens.get("key"); got != cached {
+		t.Fatal("token was not kept after a successful renewal")
+	}
+	p.tokens.mu.Lock()
+	remaining := time.Until(cached.expires)
+	p.tokens.mu.Unlock()
+	if remaining < 500*time.Millisecond {
+		t.Errorf("expiry %v away after renewal, want about 1s", remaining)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("renewal loop did not stop after renew-self failed")
+	}
+	if p.tokens.current("key", cached) {
+		t.Error("token still cached after renewal failed")
+	}
+	if req := f.last(t, http.MethodPost, "auth/token/renew-self"); req.header.Get("X-Vault-Token") != "s.renewable" {
+		t.Errorf("renew-self sent token %q", req.header.Get("X-Vault-Token"))
+	}
+}
+
+func TestRenewTokenStopsWhenReplaced(t *testing.T) {
+	f := newFakeVault(t)
+	c, _ := newClient(map[string]interface{}{"address": f.srv.URL})
+	p := &VaultPlugin{}
+	cached := &cachedToken{token: "s.old", ttl: 150 * time.Millisecond, expires: time.Now().Add(150 * time.Millisecond)}
+	p.tokens.put("key", cached)
+	p.tokens.put("key", &cachedToken{token: "s.new"})
+
+	p.renewToken(c, "key", cached)
+	if n := countRequests(f, http.MethodPost, "auth/token/renew-self"); n != 0 {
+		t.Errorf("renewed a token that is no longer cached %d times", n)
+	}
+}
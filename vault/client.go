@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultVaultAddress = "http://localhost:8200"
+
+// apiError is an error response returned by Vault.
+type apiError struct {
+	status int
+	errors []string
+	// body is the raw response, for the few endpoints that send data along
+	// with an error status.
+	body []byte
+}
+
+func (e *apiError) Error() string {
+	if len(e.errors) == 0 {
+		return fmt.Sprintf("vault: %s", http.StatusText(e.status))
+	}
+	return fmt.Sprintf("vault: %s", strings.Join(e.errors, "; "))
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.status == http.StatusNotFound
+}
+
+// secret is the envelope Vault wraps every response in.
+type secret struct {
+	RequestID     string          `json:"request_id"`
+	LeaseID       string          `json:"lease_id"`
+	Renewable     bool            `json:"renewable"`
+	LeaseDuration int             `json:"lease_duration"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *authInfo       `json:"auth"`
+	Warnings      []string        `json:"warnings"`
+}
+
+// vaultClient is a minimal Vault HTTP API client.
+type vaultClient struct {
+	address   string
+	namespace string
+	token     string
+	http      *http.Client
+}
+
+// newClient builds a client from the connection parameters, falling back to
+// the environment variables the vault CLI reads: VAULT_ADDR,
+// VAULT_NAMESPACE, VAULT_CACERT, VAULT_CLIENT_CERT, VAULT_CLIENT_KEY,
+// VAULT_TLS_SERVER_NAME and VAULT_SKIP_VERIFY.
+func newClient(params map[string]interface{}) (*vaultClient, error) {
+	address := stringParam(params, "address", "VAULT_ADDR")
+	if address == "" {
+		address = defaultVaultAddress
+	}
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid vault address %q", address)
+	}
+
+	tlsConfig, err := vaultTLSConfig(params)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+
+	return &vaultClient{
+		address:   strings.TrimRight(address, "/"),
+		namespace: strings.Trim(stringParam(params, "namespace", "VAULT_NAMESPACE"), "/"),
+		http:      &http.Client{Transport: transport},
+	}, nil
+}
+
+// vaultTLSConfig loads the CA bundle and optional client certificate.
+func vaultTLSConfig(params map[string]interface{}) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: stringParam(params, "tls_server_name", "VAULT_TLS_SERVER_NAME"),
+	}
+
+	if skip, ok := params["tls_skip_verify"].(bool); ok {
+		cfg.InsecureSkipVerify = skip
+	} else if v := os.Getenv("VAULT_SKIP_VERIFY"); v != "" {
+		cfg.InsecureSkipVerify, _ = strconv.ParseBool(v)
+	}
+
+	if caFile := stringParam(params, "ca_cert", "VAULT_CACERT"); caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	certFile := stringParam(params, "client_cert", "VAULT_CLIENT_CERT")
+	keyFile := stringParam(params, "client_key", "VAULT_CLIENT_KEY")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load vault client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// request sends one API call to /v1/<path>. Responses with an error status
+// are turned into an *apiError carrying Vault's messages.
+func (c *vaultClient) request(ctx context.Context, method, path string, query url.Values, in interface{}) (*http.Response, error) {
+	target := c.address + "/v1/" + strings.TrimLeft(path, "/")
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Request", "true")
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("cannot connect to vault at %s: %w", c.address, err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var msg struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(data, &msg) != nil && len(data) > 0 {
+			msg.Errors = []string{strings.TrimSpace(string(data))}
+		}
+		return nil, &apiError{status: resp.StatusCode, errors: msg.Errors, body: data}
+	}
+	return resp, nil
+}
+
+// do sends in as a JSON body (when non-nil) and decodes the response
+// envelope. A 204 response yields an empty secret.
+func (c *vaultClient) do(ctx context.Context, method, path string, query url.Values, in interface{}) (*secret, error) {
+	resp, err := c.request(ctx, method, path, query, in)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out secret
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	return &out, nil
+}
+
+// read decodes the data of a response into out.
+func (c *vaultClient) read(ctx context.Context, method, path string, query url.Values, in, out interface{}) (*secret, error) {
+	s, err := c.do(ctx, method, path, query, in)
+	if err != nil {
+		return nil, err
+	}
+	if out != nil && len(s.Data) > 0 && string(s.Data) != "null" {
+		if err := json.Unmarshal(s.Data, out); err != nil {
+			return nil, fmt.Errorf("failed to decode vault response data: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// stringParam reads a string parameter, falling back to an environment
+// variable.
+func stringParam(params map[string]interface{}, name, env string) string {
+	if v, ok := params[name].(string); ok && v != "" {
+		return v
+	}
+	if env != "" {
+		return os.Getenv(env)
+	}
+	return ""
+}
+
+// intParam reads a numeric parameter, accepting the float64 values produced
+// by HCL as well as plain ints and numeric strings.
+func intParam(params map[string]interface{}, name string, def int) int {
+	switch v := params[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// stringList reads a parameter that may be given as a comma separated string
+// or as an array of values.
+func stringList(params map[string]interface{}, name string) []string {
+	switch v := params[name].(type) {
+	case string:
+		var out []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	}
+	return nil
+}
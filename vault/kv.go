@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kvMount is the secrets engine a path belongs to. version is 2 for KV v2,
+// 1 for KV v1 and 0 for engines that are not KV at all, whose paths are
+// used as given.
+type kvMount struct {
+	path    string
+	version int
+}
+
+type mountInfo struct {
+	Path    string            `json:"path"`
+	Type    string            `json:"type"`
+	Options map[string]string `json:"options"`
+}
+
+func (m mountInfo) kvVersion() int {
+	switch m.Type {
+	case "kv", "generic":
+		if m.Options["version"] == "2" {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// detectMount finds the mount holding path. The vault CLI asks
+// sys/internal/ui/mounts, which any token that can use the path may read;
+// tokens allowed to read sys/mounts fall back to matching the longest mount
+// prefix there. kv_version skips detection of the version, and of the mount
+// too when neither endpoint is readable, in which case the first path
+// segment is taken as the mount.
+func (c *vaultClient) detectMount(ctx context.Context, params map[string]interface{}, path string) (kvMount, error) {
+	path = strings.Trim(path, "/")
+	forced := intParam(params, "kv_version", 0)
+
+	var info mountInfo
+	_, err := c.read(ctx, http.MethodGet, "sys/internal/ui/mounts/"+path, nil, nil, &info)
+	if err != nil {
+		var mounts map[string]mountInfo
+		if _, listErr := c.read(ctx, http.MethodGet, "sys/mounts", nil, nil, &mounts); listErr == nil {
+			err = nil
+			for mountPath, m := range mounts {
+				if strings.HasPrefix(path+"/", mountPath) && len(mountPath) > len(info.Path) {
+					info = m
+					info.Path = mountPath
+				}
+			}
+		}
+	}
+	if err != nil && forced == 0 {
+		return kvMount{}, fmt.Errorf("cannot detect the secrets engine for %s, set kv_version: %w", path, err)
+	}
+
+	if info.Path == "" {
+		if forced == 0 {
+			return kvMount{}, fmt.Errorf("no secrets engine is mounted at %s", path)
+		}
+		info.Path = strings.SplitN(path, "/", 2)[0] + "/"
+	}
+	m := kvMount{path: info.Path, version: info.kvVersion()}
+	if forced != 0 {
+		m.version = forced
+	}
+	return m, nil
+}
+
+// apiPath maps a logical secret path to the API path of a KV v2 operation
+// (data, metadata, delete, destroy). KV v1 and other engines use the path
+// as given. Paths that already contain the data/ or metadata/ segment are
+// accepted, as the vault CLI does.
+func (m kvMount) apiPath(op, path string) string {
+	path = strings.Trim(path, "/")
+	if m.version != 2 {
+		return path
+	}
+	rel := strings.TrimPrefix(path+"/", m.path)
+	for _, prefix := range []string{"data/", "metadata/"} {
+		if strings.HasPrefix(rel, prefix) {
+			rel = strings.TrimPrefix(rel, prefix)
+			break
+		}
+	}
+	return strings.TrimSuffix(m.path+op+"/"+rel, "/")
+}
+
+// kvMetadata is the metadata KV v2 returns with each version.
+type kvMetadata struct {
+	CreatedTime    string            `json:"created_time"`
+	DeletionTime   string            `json:"deletion_time"`
+	Destroyed      bool              `json:"destroyed"`
+	Version        int               `json:"version"`
+	CustomMetadata map[string]string `json:"custom_metadata"`
+}
+
+func (m kvMetadata) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"created_time":    m.CreatedTime,
+		"deletion_time":   m.DeletionTime,
+		"destroyed":       m.Destroyed,
+		"version":         m.Version,
+		"custom_metadata": m.CustomMetadata,
+	}
+}
+
+// deletedVersion extracts the metadata Vault sends along with the 404 for a
+// KV v2 version that was deleted or destroyed. A 404 for a path that never
+// existed carries no metadata.
+func deletedVersion(err error) (kvMetadata, bool) {
+	apiErr, ok := err.(*apiError)
+	if !ok || apiErr.status != http.StatusNotFound {
+		return kvMetadata{}, false
+	}
+	var body struct {
+		Data struct {
+			Metadata kvMetadata `json:"metadata"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(apiErr.body, &body) != nil || body.Data.Metadata.Version == 0 {
+		return kvMetadata{}, false
+	}
+	return body.Data.Metadata, true
+}
+
+func deletedError(path string, m kvMetadata) error {
+	if m.Destroyed {
+		return fmt.Errorf("version %d of %s is destroyed", m.Version, path)
+	}
+	if m.DeletionTime != "" {
+		return fmt.Errorf("version %d of %s was deleted at %s", m.Version, path, m.DeletionTime)
+	}
+	return fmt.Errorf("version %d of %s is deleted or destroyed", m.Version, path)
+}
+
+func (p *VaultPlugin) executeRead(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	c, path, err := p.connect(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	mount, err := c.detectMount(ctx, params, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var query url.Values
+	if version := intParam(params, "version", 0); version > 0 {
+		query = url.Values{"version": {strconv.Itoa(version)}}
+	}
+
+	var data map[string]interface{}
+	var metadata kvMetadata
+	var s *secret
+	if mount.version == 2 {
+		var body struct {
+			Data     map[string]interface{} `json:"data"`
+			Metadata kvMetadata             `json:"metadata"`
+		}
+		s, err = c.read(ctx, http.MethodGet, mount.apiPath("data", path), query, nil, &body)
+		data, metadata = body.Data, body.Metadata
+	} else {
+		s, err = c.read(ctx, http.MethodGet, mount.apiPath("", path), nil, nil, &data)
+	}
+	if deleted, ok := deletedVersion(err); ok && mount.version == 2 {
+		return nil, deletedError(path, deleted)
+	}
+	if isNotFound(err) {
+		return nil, fmt.Errorf("no secret found at %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mount.version == 2 && data == nil {
+		return nil, deletedError(path, metadata)
+	}
+
+	return map[string]interface{}{
+		"data":           data,
+		"version":        metadata.Version,
+		"metadata":       metadata.toMap(),
+		"kv_version":     mount.version,
+		"lease_id":       s.LeaseID,
+		"lease_duration": s.LeaseDuration,
+		"path":           path,
+		"message":        fmt.Sprintf("Read %d keys from %s", len(data), path),
+	}, nil
+}
+
+func (p *VaultPlugin) executeWrite(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	c, path, err := p.connect(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := params["data"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("data parameter is required and must be an object")
+	}
+	mount, err := c.detectMount(ctx, params, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata kvMetadata
+	if mount.version == 2 {
+		body := map[string]interface{}{"data": data}
+		if _, ok := params["cas"]; ok {
+			body["options"] = map[string]interface{}{"cas": intParam(params, "cas", 0)}
+		}
+		_, err = c.read(ctx, http.MethodPost, mount.apiPath("data", path), nil, body, &metadata)
+	} else {
+		_, err = c.read(ctx, http.MethodPost, mount.apiPath("", path), nil, data, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Wrote %d keys to %s", len(data), path)
+	if mount.version == 2 {
+		message = fmt.Sprintf("Wrote version %d of %s", metadata.Version, path)
+	}
+	return map[string]interface{}{
+		"success":      true,
+		"version":      metadata.Version,
+		"created_time": metadata.CreatedTime,
+		"kv_version":   mount.version,
+		"path":         path,
+		"message":      message,
+	}, nil
+}
+
+func (p *VaultPlugin) executeDelete(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	c, path, err := p.connect(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	mount, err := c.detectMount(ctx, params, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	if raw, ok := params["versions"].([]interface{}); ok {
+		for _, v := range raw {
+			n, err := strconv.Atoi(fmt.Sprint(v))
+			if err != nil {
+				return nil, fmt.Errorf("invalid version %v", v)
+			}
+			versions = append(versions, n)
+		}
+	}
+	destroy, _ := params["destroy"].(bool)
+	deleteMetadata, _ := params["delete_metadata"].(bool)
+
+	var message string
+	switch {
+	case mount.version != 2:
+		_, err = c.do(ctx, http.MethodDelete, mount.apiPath("", path), nil, nil)
+		message = fmt.Sprintf("Deleted %s", path)
+	case deleteMetadata:
+		_, err = c.do(ctx, http.MethodDelete, mount.apiPath("metadata", path), nil, nil)
+		message = fmt.Sprintf("Deleted all versions and metadata of %s", path)
+	case destroy:
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("versions parameter is required to destroy secret versions")
+		}
+		_, err = c.do(ctx, http.MethodPost, mount.apiPath("destroy", path), nil, map[string]interface{}{"versions": versions})
+		message = fmt.Sprintf("Destroyed versions %v of %s", versions, path)
+	case len(versions) > 0:
+		_, err = c.do(ctx, http.MethodPost, mount.apiPath("delete", path), nil, map[string]interface{}{"versions": versions})
+		message = fmt.Sprintf("Deleted versions %v of %s", versions, path)
+	default:
+		_, err = c.do(ctx, http.MethodDelete, mount.apiPath("data", path), nil, nil)
+		message = fmt.Sprintf("Deleted the latest version of %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"deleted":    true,
+		"versions":   versions,
+		"kv_version": mount.version,
+		"path":       path,
+		"message":    message,
+	}, nil
+}
+
+func (p *VaultPlugin) executeList(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	c, path, err := p.connect(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	mount, err := c.detectMount(ctx, params, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		Keys []string `json:"keys"`
+	}
+	// Vault answers 404 for a path with nothing under it.
+	_, err = c.read(ctx, "LIST", mount.apiPath("metadata", path), nil, nil, &body)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	keys := body.Keys
+	if keys == nil {
+		keys = []string{}
+	}
+	sort.Strings(keys)
+
+	return map[string]interface{}{
+		"keys":       keys,
+		"count":      len(keys),
+		"kv_version": mount.version,
+		"path":       path,
+		"message":    fmt.Sprintf("Listed %d keys at %s", len(keys), path),
+	}, nil
+}
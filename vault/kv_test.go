@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// vaultRequest is one API call received by the fake server.
+type vaultRequest struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   map[string]interface{}
+}
+
+// vaultHandler answers a request with a status and a JSON response body.
+type vaultHandler func(r *vaultRequest) (int, interface{})
+
+// fakeVault is a Vault HTTP API stand-in. Routes are keyed by method and
+// path below /v1/; anything unrouted gets a 404 like an unknown Vault path.
+type fakeVault struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	routes   map[string]vaultHandler
+	requests []*vaultRequest
+}
+
+func newFakeVault(t *testing.T) *fakeVault {
+	t.Helper()
+	for _, env := range []string{"VAULT_ADDR", "VAULT_TOKEN", "VAULT_NAMESPACE", "VAULT_CACERT", "VAULT_SKIP_VERIFY"} {
+		t.Setenv(env, "")
+	}
+	f := &fakeVault{routes: map[string]vaultHandler{}}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeVault) serve(w http.ResponseWriter, r *http.Request) {
+	req := &vaultRequest{
+		method: r.Method,
+		path:   strings.TrimPrefix(r.URL.Path, "/v1/"),
+		query:  r.URL.Query(),
+		header: r.Header,
+	}
+	json.NewDecoder(r.Body).Decode(&req.body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	handler := f.routes[req.method+" "+req.path]
+	f.mu.Unlock()
+
+	status, body := http.StatusNotFound, interface{}(map[string]interface{}{"errors": []string{}})
+	if handler != nil {
+		status, body = handler(req)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if body != nil {
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+// handle routes method and path to fn.
+func (f *fakeVault) handle(method, path string, fn vaultHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes[method+" "+path] = fn
+}
+
+// reply routes method and path to a 200 response wrapping data.
+func (f *fakeVault) reply(method, path string, data interface{}) {
+	f.handle(method, path, func(*vaultRequest) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"data": data}
+	})
+}
+
+// fail routes method and path to an error response.
+func (f *fakeVault) fail(method, path string, status int, messages ...string) {
+	f.handle(method, path, func(*vaultRequest) (int, interface{}) {
+		return status, map[string]interface{}{"errors": messages}
+	})
+}
+
+// mount answers the sys/internal/ui/mounts lookup for path.
+func (f *fakeVault) mount(path, mountPath, engine, version string) {
+	f.reply(http.MethodGet, "sys/internal/ui/mounts/"+path, map[string]interface{}{
+		"path":    mountPath,
+		"type":    engine,
+		"options": map[string]interface{}{"version": version},
+	})
+}
+
+// seen lists the requests received so far as "METHOD path".
+func (f *fakeVault) seen() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]string, len(f.requests))
+	for i, r := range f.requests {
+		out[i] = r.method + " " + r.path
+	}
+	return out
+}
+
+// last returns the most recent request for method and path.
+func (f *fakeVault) last(t *testing.T, method, path string) *vaultRequest {
+	t.Helper()
+	f.mu.Lock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		if r := f.requests[i]; r.method == method && r.path == path {
+			f.mu.Unlock()
+			return r
+		}
+	}
+	f.mu.Unlock()
+	t.Fatalf("no %s %s request; saw %v", method, path, f.seen())
+	return nil
+}
+
+// params returns connection parameters for the fake server with a static
+// token, merged with extra.
+func (f *fakeVault) params(extra map[string]interface{}) map[string]interface{} {
+	params := map[string]interface{}{
+		"address": f.srv.URL,
+		"token":   "s.test",
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return params
+}
+
+// jsonEqual compares a decoded request body with want after a round trip
+// through JSON, so numbers compare as float64 on both sides.
+func jsonEqual(t *testing.T, got, want interface{}) bool {
+	t.Helper()
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var normalized interface{}
+	json.Unmarshal(data, &normalized)
+	data, _ = json.Marshal(got)
+	var gotNormalized interface{}
+	json.Unmarshal(data, &gotNormalized)
+	return reflect.DeepEqual(gotNormalized, normalized)
+}
+
+func TestReadKVv2(t *testing.T) {
+	f := newFakeVault(t)
+	f.mount("secret/myapp/config", "secret/", "kv", "2")
+	f.reply(http.MethodGet, "secret/data/myapp/config", map[string]interface{}{
+		"data": map[string]interface{}{"user": "app", "password": "hunter2"},
+		"metadata": map[string]interface{}{
+			"created_time":    "2024-05-01T10:00:00Z",
+			"deletion_time":   "",
+			"destroyed":       false,
+			"version":         3,
+			"custom_metadata": map[string]string{"owner": "payments"},
+		},
+	})
+
+	out, err := (&VaultPlugin{}).Execute(context.Background(), "read", f.params(map[string]interface{}{
+		"path":      "/secret/myapp/config/",
+		"version":   float64(3),
+		"namespace": "/team-a/",
+	}))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	req := f.last(t, http.MethodGet, "secret/data/myapp/config")
+	if req.query.Get("version") != "3" {
+		t.Errorf("version query = %q, want 3", req.query.Get("version"))
+	}
+	for _, r := range []*vaultRequest{f.last(t, http.MethodGet, "sys/internal/ui/mounts/secret/myapp/config"), req} {
+		if r.header.Get("X-Vault-Token") != "s.test" || r.header.Get("X-Vault-Namespace") != "team-a" {
+			t.Errorf("%s sent token %q, namespace %q", r.path, r.header.Get("X-Vault-Token"), r.header.Get("X-Vault-Namespace"))
+		}
+	}
+
+	if !reflect.DeepEqual(out["data"], map[string]interface{}{"user": "app", "password": "hunter2"}) {
+		t.Errorf("data = %v", out["data"])
+	}
+	if out["version"] != 3 || out["kv_version"] != 2 || out["path"] != "secret/myapp/config" {
+		t.Errorf("version, kv_version, path = %v, %v, %v", out["version"], out["kv_version"], out["path"])
+	}
+	metadata := out["metadata"].(map[string]interface{})
+	if metadata["created_time"] != "2024-05-01T10:00:00Z" || metadata["version"] != 3 ||
+		!reflect.DeepEqual(metadata["custom_metadata"], map[string]string{"owner": "payments"}) {
+		t.Errorf("metadata = %v", metadata)
+	}
+}
+
+func TestReadKVv1FallsBackToSysMounts(t *testing.T) {
+	f := newFakeVault(t)
+	f.fail(http.MethodGet, "sys/internal/ui/mounts/legacy/team/app", http.StatusForbidden, "permission denied")
+	f.reply(http.MethodGet, "sys/mounts", map[string]interface{}{
+		"legacy/":      map[string]interface{}{"type": "kv", "options": map[string]string{"version": "2"}},
+		"legacy/team/": map[string]interface{}{"type": "generic", "options": nil},
+		"sys/":         map[string]interface{}{"type": "system"},
+	})
+	f.reply(http.MethodGet, "legacy/team/app", map[string]interface{}{"token": "abc"})
+
+	out, err := (&VaultPlugin{}).Execute(context.Background(), "read", f.params(map[string]interface{}{
+		"path": "legacy/team/app",
+	}))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if out["kv_version"] != 1 || out["version"] != 0 {
+		t.Errorf("kv_version, version = %v, %v; want the longest mount, KV v1", out["kv_version"], out["version"])
+	}
+	if !reflect.DeepEqual(out["data"], map[string]interface{}{"token": "abc"}) {
+		t.Errorf("data = %v", out["data"])
+	}
+}
+
+func TestReadDetectionFailure(t *testing.T) {
+	f := newFakeVault(t)
+	f.fail(http.MethodGet, "sys/internal/ui/mounts/secret/app", http.StatusForbidden, "permission denied")
+	f.fail(http.MethodGet, "sys/mounts", http.StatusForbidden, "permission denied")
+
+	_, err := (&VaultPlugin{}).Execute(context.Background(), "read", f.params(map[string]interface{}{"path": "secret/app"}))
+	if err == nil || !strings.Contains(err.Error(), "set kv_version") || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("error = %v, want a hint to set kv_version", err)
+	}
+
+	// With kv_version set the first path segment is taken as the mount.
+	f.reply(http.MethodGet, "secret/data/app", map[string]interface{}{
+		"data":     map[string]interface{}{"k": "v"},
+		"metadata": map[string]interface{}{"version": 1},
+	})
+	out, err := (&VaultPlugin{}).Execute(context.Background(), "read", f.params(map[string]interface{}{
+		"path":       "secret/app",
+		"kv_version": float64(2),
+	}))
+	if err != nil {
+		t.Fatalf("read with kv_version: %v", err)
+	}
+	if out["version"] != 1 {
+		t.Errorf("version = %v, want 1", out["version"])
+	}
+}
+
+func TestReadMissingAndDeleted(t *testing.T) {
+	f := newFakeVault(t)
+	// Vault answers a deleted or destroyed version with a 404 that still
+	// carries the version's metadata, and a path that never existed with a
+	// bare 404.
+	gone := func(metadata map[string]interface{}) vaultHandler {
+		return func(*vaultRequest) (int, interface{}) {
+			return http.StatusNotFound, map[string]interface{}{
+				"data": map[string]interface{}{"data": nil, "metadata": metadata},
+			}
+		}
+	}
+	for _, path := range []string{"gone", "deleted", "destroyed", "old"} {
+		f.mount("secret/"+path, "secret/", "kv", "2")
+	}
+	f.fail(http.MethodGet, "secret/data/gone", http.StatusNotFound)
+	f.handle(http.MethodGet, "secret/data/deleted", gone(map[string]interface{}{"version": 4, "deletion_time": "2024-05-02T00:00:00Z"}))
+	f.handle(http.MethodGet, "secret/data/destroyed", gone(map[string]interface{}{"version": 2, "destroyed": true}))
+	// Older servers reply 200 with null data.
+	f.reply(http.MethodGet, "secret/data/old", map[string]interface{}{
+		"data":     nil,
+		"metadata": map[string]interface{}{"version": 3},
+	})
+
+	tests := map[string]string{
+		"secret/gone":      "no secret found at secret/gone",
+		"secret/deleted":   "version 4 of secret/deleted was deleted at 2024-05-02T00:00:00Z",
+		"secret/destroyed": "version 2 of secret/destroyed is destroyed",
+		"secret/old":       "version 3 of secret/old is deleted or destroyed",
+	}
+	p := &VaultPlugin{}
+	for path, want := range tests {
+		if _, err := p.Execute(context.Background(), "read", f.params(map[string]interface{}{"path": path})); err == nil || err.Error() != want {
+			t.Errorf("read %s: error = %v, want %q", path, err, want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	f := newFakeVault(t)
+	f.mount("secret/app", "secret/", "kv", "2")
+	f.mount("kv1/app", "kv1/", "kv", "1")
+	f.reply(http.MethodPost, "secret/data/app", map[string]interface{}{
+		"created_time": "2024-05-01T10:00:00Z",
+		"version":      5,
+	})
+	f.handle(http.MethodPost, "kv1/app", func(*vaultRequest) (int, interface{}) {
+		return http.StatusNoContent, nil
+	})
+	data := map[string]interface{}{"user": "app", "port": float64(5432)}
+
+	p := &VaultPlugin{}
+	out, err := p.Execute(context.Background(), "write", f.params(map[string]interface{}{
+		"path": "secret/app",
+		"data": data,
+		"cas":  float64(4),
+	}))
+	if err != nil {
+		t.Fatalf("write v2: %v", err)
+	}
+	body := f.last(t, http.MethodPost, "secret/data/app").body
+	if !jsonEqual(t, body, map[string]interface{}{"data": data, "options": map[string]interface{}{"cas": 4}}) {
+		t.Errorf("v2 body = %v", body)
+	}
+	if out["version"] != 5 || out["created_time"] != "2024-05-01T10:00:00Z" || out["message"] != "Wrote version 5 of secret/app" {
+		t.Errorf("v2 output = %v", out)
+	}
+
+	out, err = p.Execute(context.Background(), "write", f.params(map[string]interface{}{
+		"path": "kv1/app",
+		"data": data,
+	}))
+	if err != nil {
+		t.Fatalf("write v1: %v", err)
+	}
+	if body := f.last(t, http.MethodPost, "kv1/app").body; !jsonEqual(t, body, data) {
+		t.Errorf("v1 body = %v, want the data itself", body)
+	}
+	if out["version"] != 0 || out["kv_version"] != 1 {
+		t.Errorf("v1 output = %v", out)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		mount  string
+		kv     string
+		extra  map[string]interface{}
+		method string
+		api    string
+		body   interface{}
+	}{
+		{"latest version", "secret/app", "secret/", "2", nil, http.MethodDelete, "secret/data/app", nil},
+		{"versions", "secret/app", "secret/", "2", map[string]interface{}{"versions": []interface{}{float64(1), "2"}},
+			http.MethodPost, "secret/delete/app", map[string]interface{}{"versions": []int{1, 2}}},
+		{"destroy", "secret/app", "secret/", "2", map[string]interface{}{"versions": []interface{}{float64(3)}, "destroy": true},
+			http.MethodPost, "secret/destroy/app", map[string]interface{}{"versions": []int{3}}},
+		{"metadata", "secret/app", "secret/", "2", map[string]interface{}{"delete_metadata": true}, http.MethodDelete, "secret/metadata/app", nil},
+		{"data path given", "secret/data/app", "secret/", "2", nil, http.MethodDelete, "secret/data/app", nil},
+		{"kv v1", "kv1/app", "kv1/", "1", nil, http.MethodDelete, "kv1/app", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeVault(t)
+			f.mount(tt.path, tt.mount, "kv", tt.kv)
+			f.handle(tt.method, tt.api, func(*vaultRequest) (int, interface{}) {
+				return http.StatusNoContent, nil
+			})
+
+			params := f.params(tt.extra)
+			params["path"] = tt.path
+			out, err := (&VaultPlugin{}).Execute(context.Background(), "delete", params)
+			if err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if out["deleted"] != true {
+				t.Errorf("deleted = %v", out["deleted"])
+			}
+			if body := f.last(t, tt.method, tt.api).body; tt.body != nil && !jsonEqual(t, body, tt.body) {
+				t.Errorf("body = %v, want %v", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestDestroyRequiresVersions(t *testing.T) {
+	f := newFakeVault(t)
+	f.mount("secret/app", "secret/", "kv", "2")
+	_, err := (&VaultPlugin{}).Execute(context.Background(), "delete", f.params(map[string]interface{}{
+		"path":    "secret/app",
+		"destroy": true,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "versions parameter is required") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	f := newFakeVault(t)
+	f.mount("secret/apps", "secret/", "kv", "2")
+	f.mount("secret/empty", "secret/", "kv", "2")
+	f.reply("LIST", "secret/metadata/apps", map[string]interface{}{"keys": []string{"web", "api/", "batch"}})
+
+	p := &VaultPlugin{}
+	out, err := p.Execute(context.Background(), "list", f.params(map[string]interface{}{"path": "secret/apps"}))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if !reflect.DeepEqual(out["keys"], []string{"api/", "batch", "web"}) || out["count"] != 3 {
+		t.Errorf("keys = %v, count = %v", out["keys"], out["count"])
+	}
+
+	out, err = p.Execute(context.Background(), "list", f.params(map[string]interface{}{"path": "secret/empty"}))
+	if err != nil {
+		t.Fatalf("list of an empty path: %v", err)
+	}
+	if keys := out["keys"].([]string); keys == nil || len(keys) != 0 {
+		t.Errorf("keys = %#v, want an empty, non-nil slice", keys)
+	}
+}
+
+func TestAPIPath(t *testing.T) {
+	v2 := kvMount{path: "secret/", version: 2}
+	tests := []struct {
+		mount kvMount
+		op    string
+		path  string
+		want  string
+	}{
+		{v2, "data", "secret/app/db", "secret/data/app/db"},
+		{v2, "data", "secret/data/app/db", "secret/data/app/db"},
+		{v2, "data", "secret/metadata/app/db", "secret/data/app/db"},
+		{v2, "metadata", "secret/data/app", "secret/metadata/app"},
+		{v2, "metadata", "secret", "secret/metadata"},
+		{v2, "metadata", "secret/", "secret/metadata"},
+		{kvMount{path: "team/kv/", version: 2}, "destroy", "team/kv/app", "team/kv/destroy/app"},
+		{kvMount{path: "kv1/", version: 1}, "data", "kv1/app/", "kv1/app"},
+		{kvMount{path: "database/", version: 0}, "data", "database/creds/app", "database/creds/app"},
+	}
+	for _, tt := range tests {
+		if got := tt.mount.apiPath(tt.op, tt.path); got != tt.want {
+			t.Errorf("apiPath(%q, %q) on %s = %q, want %q", tt.op, tt.path, tt.mount.path, got, tt.want)
+		}
+	}
+}
+
+func TestAPIErrorMessages(t *testing.T) {
+	f := newFakeVault(t)
+	f.mount("secret/app", "secret/", "kv", "2")
+	f.fail(http.MethodPost, "secret/data/app", http.StatusBadRequest, "check-and-set parameter did not match the current version")
+
+	_, err := (&VaultPlugin{}).Execute(context.Background(), "write", f.params(map[string]interface{}{
+		"path": "secret/app",
+		"data": map[string]interface{}{"k": "v"},
+		"cas":  float64(1),
+	}))
+	if err == nil || err.Error() != "vault: check-and-set parameter did not match the current version" {
+		t.Errorf("error = %v", err)
+	}
+}
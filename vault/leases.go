@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// revokeTimeout bounds the requests revoke_on_cancel sends without the
+// step's context: the credentials request and the revocation after a cancel.
+const revokeTimeout = 30 * time.Second
+
+// leaseActions declares the dynamic credential and lease actions.
+func leaseActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "creds_generate",
+			Description: "Generate short-lived credentials from a secrets engine role",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"mount": {
+					Type:        "string",
+					Description: "Secrets engine mount (database, aws, rabbitmq, ...)",
+					Required:    false,
+					Default:     "database",
+				},
+				"role": {
+					Type:        "string",
+					Description: "Role to generate credentials for",
+					Required:    true,
+				},
+				"revoke_on_cancel": {
+					Type:        "boolean",
+					Description: "Revoke the lease if the step is cancelled before it returns the credentials, including while the request to Vault is in flight. Leases handed to later steps are not revoked; end them with lease_revoke",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"username": {
+					Type:        "string",
+					Description: "Generated username",
+				},
+				"password": {
+					Type:        "string",
+					Description: "Generated password",
+				},
+				"data": {
+					Type:        "object",
+					Description: "Everything the engine returned, for engines with other fields",
+				},
+				"lease_id": {
+					Type:        "string",
+					Description: "Lease of the credentials",
+				},
+				"ttl": {
+					Type:        "number",
+					Description: "Lease duration in seconds",
+				},
+				"renewable": {
+					Type:        "boolean",
+					Description: "Whether the lease can be renewed",
+				},
+				"expires": {
+					Type:        "string",
+					Description: "Lease expiry time (RFC 3339)",
+				},
+			},
+		},
+		{
+			Name:        "lease_renew",
+			Description: "Renew a lease",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"lease_id": {
+					Type:        "string",
+					Description: "Lease to renew",
+					Required:    true,
+				},
+				"increment": {
+					Type:        "string",
+					Description: "Requested extension, e.g. 1h or a number of seconds (default: the lease's TTL)",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"lease_id": {
+					Type:        "string",
+					Description: "Renewed lease",
+				},
+				"ttl": {
+					Type:        "number",
+					Description: "New lease duration in seconds",
+				},
+				"renewable": {
+					Type:        "boolean",
+					Description: "Whether the lease can be renewed again",
+				},
+				"expires": {
+					Type:        "string",
+					Description: "Lease expiry time (RFC 3339)",
+				},
+			},
+		},
+		{
+			Name:        "lease_revoke",
+			Description: "Revoke a lease, or every lease under a prefix",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"lease_id": {
+					Type:        "string",
+					Description: "Lease to revoke, or a lease prefix when prefix is true",
+					Required:    true,
+				},
+				"prefix": {
+					Type:        "boolean",
+					Description: "Revoke all leases whose ID starts with lease_id",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"revoked": {
+					Type:        "boolean",
+					Description: "Whether the revocation was accepted",
+				},
+			},
+		},
+	}
+}
+
+func (p *VaultPlugin) executeCredsGenerate(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	role := stringParam(params, "role", "")
+	if role == "" {
+		return nil, fmt.Errorf("role parameter is required")
+	}
+	mount := strings.Trim(stringParam(params, "mount", ""), "/")
+	if mount == "" {
+		mount = "database"
+	}
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// A cancel while the request is in flight would drop Vault's response
+	// and with it the only record of the lease, so with revoke_on_cancel the
+	// request runs to completion and the cancellation is acted on after.
+	revoke, _ := params["revoke_on_cancel"].(bool)
+	requestCtx := ctx
+	if revoke {
+		var cancel context.CancelFunc
+		requestCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), revokeTimeout)
+		defer cancel()
+	}
+
+	var data map[string]interface{}
+	s, err := c.read(requestCtx, http.MethodGet, mount+"/creds/"+role, nil, nil, &data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate credentials for %s/%s: %w", mount, role, err)
+	}
+
+	if revoke && ctx.Err() != nil {
+		if s.LeaseID == "" {
+			return nil, ctx.Err()
+		}
+		return nil, revokeCancelled(c, s.LeaseID, ctx.Err())
+	}
+
+	username, _ := data["username"].(string)
+	password, _ := data["password"].(string)
+	return map[string]interface{}{
+		"username":  username,
+		"password":  password,
+		"data":      data,
+		"lease_id":  s.LeaseID,
+		"ttl":       s.LeaseDuration,
+		"renewable": s.Renewable,
+		"expires":   leaseExpiry(s.LeaseDuration),
+		"message":   fmt.Sprintf("Generated credentials for %s/%s valid for %ds", mount, role, s.LeaseDuration),
+	}, nil
+}
+
+// revokeCancelled revokes the lease of credentials generated by a step that
+// was cancelled before it could return them, since no later step can revoke
+// a lease it never saw. The revocation uses its own context as the step's
+// is already done.
+func revokeCancelled(c *vaultClient, leaseID string, cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+	defer cancel()
+	if _, err := c.do(ctx, http.MethodPut, "sys/leases/revoke", nil, map[string]interface{}{"lease_id": leaseID}); err != nil {
+		return fmt.Errorf("step cancelled after generating credentials (%v) and revoking lease %s failed: %w", cause, leaseID, err)
+	}
+	return fmt.Errorf("step cancelled after generating credentials, lease %s revoked: %w", leaseID, cause)
+}
+
+func (p *VaultPlugin) executeLeaseRenew(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	leaseID := stringParam(params, "lease_id", "")
+	if leaseID == "" {
+		return nil, fmt.Errorf("lease_id parameter is required")
+	}
+	body := map[string]interface{}{"lease_id": leaseID}
+	switch increment := params["increment"].(type) {
+	case float64:
+		body["increment"] = int(increment)
+	case string:
+		if increment != "" {
+			body["increment"] = increment
+		}
+	}
+
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.do(ctx, http.MethodPut, "sys/leases/renew", nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to renew lease %s: %w", leaseID, err)
+	}
+
+	return map[string]interface{}{
+		"lease_id":  s.LeaseID,
+		"ttl":       s.LeaseDuration,
+		"renewable": s.Renewable,
+		"expires":   leaseExpiry(s.LeaseDuration),
+		"message":   fmt.Sprintf("Renewed lease %s for %ds", leaseID, s.LeaseDuration),
+	}, nil
+}
+
+func (p *VaultPlugin) executeLeaseRevoke(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	leaseID := stringParam(params, "lease_id", "")
+	if leaseID == "" {
+		return nil, fmt.Errorf("lease_id parameter is required")
+	}
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Revoked lease %s", leaseID)
+	if prefix, _ := params["prefix"].(bool); prefix {
+		_, err = c.do(ctx, http.MethodPut, "sys/leases/revoke-prefix/"+strings.Trim(leaseID, "/"), nil, nil)
+		message = fmt.Sprintf("Revoked all leases under %s", leaseID)
+	} else {
+		_, err = c.do(ctx, http.MethodPut, "sys/leases/revoke", nil, map[string]interface{}{"lease_id": leaseID})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke lease %s: %w", leaseID, err)
+	}
+
+	return map[string]interface{}{
+		"revoked": true,
+		"message": message,
+	}, nil
+}
+
+func leaseExpiry(ttl int) string {
+	return time.Now().Add(time.Duration(ttl) * time.Second).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// credsReply routes database/creds/readonly to a leased username and
+// password, calling before first when it is set.
+func (f *fakeVault) credsReply(before func()) {
+	f.handle(http.MethodGet, "database/creds/readonly", func(*vaultRequest) (int, interface{}) {
+		if before != nil {
+			before()
+		}
+		return http.StatusOK, map[string]interface{}{
+			"lease_id":       "database/creds/readonly/abc123",
+			"lease_duration": 3600,
+			"renewable":      true,
+			"data":           map[string]interface{}{"username": "v-approle-readonly-x1", "password": "A1a-secret"},
+		}
+	})
+}
+
+func TestCredsGenerate(t *testing.T) {
+	f := newFakeVault(t)
+	f.credsReply(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := (&VaultPlugin{}).Execute(ctx, "creds_generate", f.params(map[string]interface{}{
+		"role":             "readonly",
+		"revoke_on_cancel": true,
+	}))
+	if err != nil {
+		t.Fatalf("creds_generate: %v", err)
+	}
+	if out["username"] != "v-approle-readonly-x1" || out["password"] != "A1a-secret" ||
+		out["lease_id"] != "database/creds/readonly/abc123" || out["ttl"] != 3600 || out["renewable"] != true {
+		t.Errorf("output = %v", out)
+	}
+	expires, err := time.Parse(time.RFC3339, out["expires"].(string))
+	if err != nil || time.Until(expires) < 59*time.Minute {
+		t.Errorf("expires = %v", out["expires"])
+	}
+
+	// Later steps use the credentials after this step's context is done, so
+	// finishing the step must not revoke them.
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if n := countRequests(f, http.MethodPut, "sys/leases/revoke"); n != 0 {
+		t.Errorf("lease revoked %d times after the step returned", n)
+	}
+}
+
+func TestCredsGenerateRevokesWhenCancelled(t *testing.T) {
+	f := newFakeVault(t)
+	// The step is cancelled while Vault is still answering the request.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.credsReply(cancel)
+	f.handle(http.MethodPut, "sys/leases/revoke", func(*vaultRequest) (int, interface{}) {
+		return http.StatusNoContent, nil
+	})
+
+	_, err := (&VaultPlugin{}).Execute(ctx, "creds_generate", f.params(map[string]interface{}{
+		"role":             "readonly",
+		"revoke_on_cancel": true,
+	}))
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "lease database/creds/readonly/abc123 revoked") {
+		t.Fatalf("error = %v, want the cancellation and the revoked lease", err)
+	}
+	req := f.last(t, http.MethodPut, "sys/leases/revoke")
+	if req.body["lease_id"] != "database/creds/readonly/abc123" || req.header.Get("X-Vault-Token") != "s.test" {
+		t.Errorf("revoke request = %v, token %q", req.body, req.header.Get("X-Vault-Token"))
+	}
+}
+
+func TestCredsGenerateReportsRevokeFailure(t *testing.T) {
+	f := newFakeVault(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.credsReply(cancel)
+	f.fail(http.MethodPut, "sys/leases/revoke", http.StatusForbidden, "permission denied")
+
+	_, err := (&VaultPlugin{}).Execute(ctx, "creds_generate", f.params(map[string]interface{}{
+		"role":             "readonly",
+		"revoke_on_cancel": true,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "revoking lease database/creds/readonly/abc123 failed: vault: permission denied") {
+		t.Errorf("error = %v, want the revoke failure", err)
+	}
+}
+
+func TestCredsGenerateCancelledWithoutRevoke(t *testing.T) {
+	f := newFakeVault(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.credsReply(cancel)
+
+	_, err := (&VaultPlugin{}).Execute(ctx, "creds_generate", f.params(map[string]interface{}{"role": "readonly"}))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want the cancellation", err)
+	}
+	if n := countRequests(f, http.MethodPut, "sys/leases/revoke"); n != 0 {
+		t.Errorf("lease revoked %d times without revoke_on_cancel", n)
+	}
+}
+
+func TestLeaseRenew(t *testing.T) {
+	tests := []struct {
+		increment interface{}
+		want      interface{}
+	}{
+		{"15m", "15m"},
+		{float64(900), float64(900)},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		f := newFakeVault(t)
+		f.handle(http.MethodPut, "sys/leases/renew", func(r *vaultRequest) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{
+				"lease_id":       r.body["lease_id"],
+				"lease_duration": 900,
+				"renewable":      true,
+			}
+		})
+
+		params := f.params(map[string]interface{}{"lease_id": "database/creds/readonly/abc123"})
+		if tt.increment != nil {
+			params["increment"] = tt.increment
+		}
+		out, err := (&VaultPlugin{}).Execute(context.Background(), "lease_renew", params)
+		if err != nil {
+			t.Fatalf("lease_renew(%v): %v", tt.increment, err)
+		}
+		body := f.last(t, http.MethodPut, "sys/leases/renew").body
+		if body["lease_id"] != "database/creds/readonly/abc123" || body["increment"] != tt.want {
+			t.Errorf("increment %v: renew body = %v", tt.increment, body)
+		}
+		if out["lease_id"] != "database/creds/readonly/abc123" || out["ttl"] != 900 {
+			t.Errorf("increment %v: output = %v", tt.increment, out)
+		}
+	}
+}
+
+func TestLeaseRevoke(t *testing.T) {
+	f := newFakeVault(t)
+	for _, path := range []string{"sys/leases/revoke", "sys/leases/revoke-prefix/database/creds/readonly"} {
+		f.handle(http.MethodPut, path, func(*vaultRequest) (int, interface{}) {
+			return http.StatusNoContent, nil
+		})
+	}
+	p := &VaultPlugin{}
+
+	out, err := p.Execute(context.Background(), "lease_revoke", f.params(map[string]interface{}{
+		"lease_id": "database/creds/readonly/abc123",
+	}))
+	if err != nil {
+		t.Fatalf("lease_revoke: %v", err)
+	}
+	if body := f.last(t, http.MethodPut, "sys/leases/revoke").body; body["lease_id"] != "database/creds/readonly/abc123" || out["revoked"] != true {
+		t.Errorf("revoke body = %v, output = %v", body, out)
+	}
+
+	out, err = p.Execute(context.Background(), "lease_revoke", f.params(map[string]interface{}{
+		"lease_id": "/database/creds/readonly/",
+		"prefix":   true,
+	}))
+	if err != nil {
+		t.Fatalf("lease_revoke with prefix: %v", err)
+	}
+	if out["message"] != "Revoked all leases under /database/creds/readonly/" {
+		t.Errorf("message = %v", out["message"])
+	}
+
+	f.fail(http.MethodPut, "sys/leases/revoke", http.StatusBadRequest, "invalid lease ID")
+	_, err = p.Execute(context.Background(), "lease_revoke", f.params(map[string]interface{}{"lease_id": "bogus"}))
+	if err == nil || err.Error() != "failed to revoke lease bogus: vault: invalid lease ID" {
+		t.Errorf("error = %v", err)
+	}
+}
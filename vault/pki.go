@@ -0,0 +1,533 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// pkiActions declares the PKI secrets engine actions.
+func pkiActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "pki_issue",
+			Description: "Issue a certificate and private key from a PKI role",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"mount": {
+					Type:        "string",
+					Description: "PKI secrets engine mount",
+					Required:    false,
+					Default:     "pki",
+				},
+				"role": {
+					Type:        "string",
+					Description: "PKI role to issue against",
+					Required:    true,
+				},
+				"common_name": {
+					Type:        "string",
+					Description: "Certificate common name",
+					Required:    true,
+				},
+				"alt_names": {
+					Type:        "array",
+					Description: "DNS subject alternative names (array or comma separated string)",
+					Required:    false,
+				},
+				"ip_sans": {
+					Type:        "array",
+					Description: "IP subject alternative names",
+					Required:    false,
+				},
+				"ttl": {
+					Type:        "string",
+					Description: "Requested lifetime, e.g. 168h (default: the role's TTL)",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"certificate": {
+					Type:        "string",
+					Description: "PEM certificate",
+				},
+				"private_key": {
+					Type:        "string",
+					Description: "PEM private key",
+				},
+				"private_key_type": {
+					Type:        "string",
+					Description: "Key type, e.g. rsa or ec",
+				},
+				"issuing_ca": {
+					Type:        "string",
+					Description: "PEM certificate of the issuing CA",
+				},
+				"ca_chain": {
+					Type:        "array",
+					Description: "PEM certificates of the CA chain",
+				},
+				"full_chain": {
+					Type:        "string",
+					Description: "Certificate followed by the CA chain, as servers expect it",
+				},
+				"serial_number": {
+					Type:        "string",
+					Description: "Certificate serial number",
+				},
+				"expiration": {
+					Type:        "string",
+					Description: "Expiry time (RFC 3339)",
+				},
+				"expiration_unix": {
+					Type:        "number",
+					Description: "Expiry time as a Unix timestamp",
+				},
+			},
+		},
+		{
+			Name:        "pki_sign",
+			Description: "Sign a certificate signing request with a PKI role",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"mount": {
+					Type:        "string",
+					Description: "PKI secrets engine mount",
+					Required:    false,
+					Default:     "pki",
+				},
+				"role": {
+					Type:        "string",
+					Description: "PKI role to sign with",
+					Required:    true,
+				},
+				"csr": {
+					Type:        "string",
+					Description: "PEM certificate signing request",
+					Required:    false,
+				},
+				"csr_file": {
+					Type:        "string",
+					Description: "File holding the PEM certificate signing request",
+					Required:    false,
+				},
+				"common_name": {
+					Type:        "string",
+					Description: "Certificate common name (default: taken from the CSR)",
+					Required:    false,
+				},
+				"alt_names": {
+					Type:        "array",
+					Description: "DNS subject alternative names (array or comma separated string)",
+					Required:    false,
+				},
+				"ip_sans": {
+					Type:        "array",
+					Description: "IP subject alternative names",
+					Required:    false,
+				},
+				"ttl": {
+					Type:        "string",
+					Description: "Requested lifetime, e.g. 168h (default: the role's TTL)",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"certificate": {
+					Type:        "string",
+					Description: "PEM certificate",
+				},
+				"issuing_ca": {
+					Type:        "string",
+					Description: "PEM certificate of the issuing CA",
+				},
+				"ca_chain": {
+					Type:        "array",
+					Description: "PEM certificates of the CA chain",
+				},
+				"full_chain": {
+					Type:        "string",
+					Description: "Certificate followed by the CA chain, as servers expect it",
+				},
+				"serial_number": {
+					Type:        "string",
+					Description: "Certificate serial number",
+				},
+				"expiration": {
+					Type:        "string",
+					Description: "Expiry time (RFC 3339)",
+				},
+				"expiration_unix": {
+					Type:        "number",
+					Description: "Expiry time as a Unix timestamp",
+				},
+			},
+		},
+		{
+			Name:        "pki_revoke",
+			Description: "Revoke a certificate issued by a PKI mount",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"mount": {
+					Type:        "string",
+					Description: "PKI secrets engine mount",
+					Required:    false,
+					Default:     "pki",
+				},
+				"serial_number": {
+					Type:        "string",
+					Description: "Serial number of the certificate",
+					Required:    false,
+				},
+				"certificate": {
+					Type:        "string",
+					Description: "PEM certificate to revoke, instead of serial_number",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"revoked": {
+					Type:        "boolean",
+					Description: "Whether the certificate was revoked",
+				},
+				"serial_number": {
+					Type:        "string",
+					Description: "Serial number of the revoked certificate",
+				},
+				"revocation_time": {
+					Type:        "string",
+					Description: "Revocation time (RFC 3339)",
+				},
+			},
+		},
+		{
+			Name:        "pki_list_expiring",
+			Description: "List issued certificates that expire within a renewal window",
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
+				"mount": {
+					Type:        "string",
+					Description: "PKI secrets engine mount",
+					Required:    false,
+					Default:     "pki",
+				},
+				"within": {
+					Type:        "string",
+					Description: "Renewal window, e.g. 720h or 30d",
+					Required:    false,
+					Default:     "30d",
+				},
+				"include_expired": {
+					Type:        "boolean",
+					Description: "Also report certificates that have already expired",
+					Required:    false,
+					Default:     false,
+				},
+				"include_revoked": {
+					Type:        "boolean",
+					Description: "Also report revoked certificates",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"certificates": {
+					Type:        "array",
+					Description: "Expiring certificates with serial_number, common_name, alt_names, not_after, expires_in and expired, soonest first",
+				},
+				"count": {
+					Type:        "number",
+					Description: "Number of expiring certificates",
+				},
+				"checked": {
+					Type:        "number",
+					Description: "Number of certificates inspected",
+				},
+			},
+		},
+	}
+}
+
+// certResponse is the data returned by the issue and sign endpoints.
+type certResponse struct {
+	Certificate    string   `json:"certificate"`
+	IssuingCA      string   `json:"issuing_ca"`
+	CAChain        []string `json:"ca_chain"`
+	PrivateKey     string   `json:"private_key"`
+	PrivateKeyType string   `json:"private_key_type"`
+	SerialNumber   string   `json:"serial_number"`
+	Expiration     int64    `json:"expiration"`
+}
+
+func (r certResponse) toMap() map[string]interface{} {
+	chain := r.CAChain
+	if len(chain) == 0 && r.IssuingCA != "" {
+		chain = []string{r.IssuingCA}
+	}
+	fullChain := strings.Join(append([]string{r.Certificate}, chain...), "\n") + "\n"
+	return map[string]interface{}{
+		"certificate":     r.Certificate,
+		"issuing_ca":      r.IssuingCA,
+		"ca_chain":        chain,
+		"full_chain":      fullChain,
+		"serial_number":   r.SerialNumber,
+		"expiration":      time.Unix(r.Expiration, 0).UTC().Format(time.RFC3339),
+		"expiration_unix": r.Expiration,
+	}
+}
+
+// pkiMount returns the mount parameter.
+func pkiMount(params map[string]interface{}) string {
+	mount := strings.Trim(stringParam(params, "mount", ""), "/")
+	if mount == "" {
+		return "pki"
+	}
+	return mount
+}
+
+// certificateRequest builds the options shared by issue and sign.
+func certificateRequest(params map[string]interface{}) map[string]interface{} {
+	body := map[string]interface{}{}
+	if cn := stringParam(params, "common_name", ""); cn != "" {
+		body["common_name"] = cn
+	}
+	if names := stringList(params, "alt_names"); len(names) > 0 {
+		body["alt_names"] = strings.Join(names, ",")
+	}
+	if ips := stringList(params, "ip_sans"); len(ips) > 0 {
+		body["ip_sans"] = strings.Join(ips, ",")
+	}
+	if ttl := stringParam(params, "ttl", ""); ttl != "" {
+		body["ttl"] = ttl
+	}
+	return body
+}
+
+func (p *VaultPlugin) executePKIIssue(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	role := stringParam(params, "role", "")
+	if role == "" {
+		return nil, fmt.Errorf("role parameter is required")
+	}
+	if stringParam(params, "common_name", "") == "" {
+		return nil, fmt.Errorf("common_name parameter is required")
+	}
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var cert certResponse
+	if _, err := c.read(ctx, http.MethodPost, pkiMount(params)+"/issue/"+role, nil, certificateRequest(params), &cert); err != nil {
+		return nil, fmt.Errorf("failed to issue certificate: %w", err)
+	}
+
+	result := cert.toMap()
+	result["private_key"] = cert.PrivateKey
+	result["private_key_type"] = cert.PrivateKeyType
+	result["message"] = fmt.Sprintf("Issued certificate %s for %s, expires %s", cert.SerialNumber, params["common_name"], result["expiration"])
+	return result, nil
+}
+
+func (p *VaultPlugin) executePKISign(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	role := stringParam(params, "role", "")
+	if role == "" {
+		return nil, fmt.Errorf("role parameter is required")
+	}
+	csr := stringParam(params, "csr", "")
+	if file := stringParam(params, "csr_file", ""); csr == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csr_file: %w", err)
+		}
+		csr = string(data)
+	}
+	if csr == "" {
+		return nil, fmt.Errorf("csr or csr_file parameter is required")
+	}
+
+	body := certificateRequest(params)
+	body["csr"] = csr
+	if _, ok := body["common_name"]; !ok {
+		block, _ := pem.Decode([]byte(csr))
+		if block == nil {
+			return nil, fmt.Errorf("csr is not PEM encoded")
+		}
+		req, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid csr: %w", err)
+		}
+		body["common_name"] = req.Subject.CommonName
+	}
+
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	var cert certResponse
+	if _, err := c.read(ctx, http.MethodPost, pkiMount(params)+"/sign/"+role, nil, body, &cert); err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+
+	result := cert.toMap()
+	result["message"] = fmt.Sprintf("Signed certificate %s for %s, expires %s", cert.SerialNumber, body["common_name"], result["expiration"])
+	return result, nil
+}
+
+func (p *VaultPlugin) executePKIRevoke(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	serial := stringParam(params, "serial_number", "")
+	if pemCert := stringParam(params, "certificate", ""); serial == "" && pemCert != "" {
+		cert, err := parseCertificate(pemCert)
+		if err != nil {
+			return nil, err
+		}
+		serial = formatSerial(cert)
+	}
+	if serial == "" {
+		return nil, fmt.Errorf("serial_number or certificate parameter is required")
+	}
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		RevocationTime int64 `json:"revocation_time"`
+	}
+	if _, err := c.read(ctx, http.MethodPost, pkiMount(params)+"/revoke", nil, map[string]interface{}{"serial_number": serial}, &resp); err != nil {
+		return nil, fmt.Errorf("failed to revoke certificate %s: %w", serial, err)
+	}
+
+	revoked := time.Unix(resp.RevocationTime, 0).UTC().Format(time.RFC3339)
+	return map[string]interface{}{
+		"revoked":         true,
+		"serial_number":   serial,
+		"revocation_time": revoked,
+		"message":         fmt.Sprintf("Revoked certificate %s at %s", serial, revoked),
+	}, nil
+}
+
+func (p *VaultPlugin) executePKIListExpiring(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	within := stringParam(params, "within", "")
+	if within == "" {
+		within = "30d"
+	}
+	window, err := parseWindow(within)
+	if err != nil {
+		return nil, err
+	}
+	includeExpired, _ := params["include_expired"].(bool)
+	includeRevoked, _ := params["include_revoked"].(bool)
+
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	mount := pkiMount(params)
+
+	var list struct {
+		Keys []string `json:"keys"`
+	}
+	if _, err := c.read(ctx, "LIST", mount+"/certs", nil, nil, &list); err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+
+	now := time.Now()
+	deadline := now.Add(window)
+	var expiring []*x509.Certificate
+	serials := map[*x509.Certificate]string{}
+	for _, serial := range list.Keys {
+		var entry struct {
+			Certificate    string `json:"certificate"`
+			RevocationTime int64  `json:"revocation_time"`
+		}
+		if _, err := c.read(ctx, http.MethodGet, mount+"/cert/"+serial, nil, nil, &entry); err != nil {
+			return nil, fmt.Errorf("failed to read certificate %s: %w", serial, err)
+		}
+		if entry.RevocationTime > 0 && !includeRevoked {
+			continue
+		}
+		cert, err := parseCertificate(entry.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %s: %w", serial, err)
+		}
+		if cert.NotAfter.After(deadline) || (cert.NotAfter.Before(now) && !includeExpired) {
+			continue
+		}
+		expiring = append(expiring, cert)
+		serials[cert] = serial
+	}
+
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].NotAfter.Before(expiring[j].NotAfter)
+	})
+	certificates := make([]map[string]interface{}, 0, len(expiring))
+	for _, cert := range expiring {
+		altNames := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			altNames = append(altNames, ip.String())
+		}
+		certificates = append(certificates, map[string]interface{}{
+			"serial_number": serials[cert],
+			"common_name":   cert.Subject.CommonName,
+			"alt_names":     altNames,
+			"not_after":     cert.NotAfter.UTC().Format(time.RFC3339),
+			"expires_in":    int(cert.NotAfter.Sub(now).Seconds()),
+			"expired":       cert.NotAfter.Before(now),
+		})
+	}
+
+	return map[string]interface{}{
+		"certificates": certificates,
+		"count":        len(certificates),
+		"checked":      len(list.Keys),
+		"message":      fmt.Sprintf("%d of %d certificates expire within %s", len(certificates), len(list.Keys), within),
+	}, nil
+}
+
+func parseCertificate(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// formatSerial renders a serial number the way Vault does: colon separated
+// hex bytes.
+func formatSerial(cert *x509.Certificate) string {
+	hex := fmt.Sprintf("%x", cert.SerialNumber)
+	if len(hex)%2 == 1 {
+		hex = "0" + hex
+	}
+	parts := make([]string, 0, len(hex)/2)
+	for i := 0; i < len(hex); i += 2 {
+		parts = append(parts, hex[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// parseWindow parses a Go duration, also accepting a whole number of days
+// such as "30d".
+func parseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a self-signed PEM certificate.
+func testCertificate(t *testing.T, cn string, serial int64, notAfter time.Time, dnsNames []string, ips ...net.IP) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestPKIIssue(t *testing.T) {
+	f := newFakeVault(t)
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	f.reply(http.MethodPost, "pki-int/issue/web", map[string]interface{}{
+		"certificate":      "LEAF",
+		"issuing_ca":       "INTERMEDIATE",
+		"ca_chain":         []string{"INTERMEDIATE", "ROOT"},
+		"private_key":      "KEY",
+		"private_key_type": "ec",
+		"serial_number":    "3a:4f",
+		"expiration":       expires.Unix(),
+	})
+
+	out, err := (&VaultPlugin{}).Execute(context.Background(), "pki_issue", f.params(map[string]interface{}{
+		"mount":       "/pki-int/",
+		"role":        "web",
+		"common_name": "app.example.com",
+		"alt_names":   "www.example.com, api.example.com",
+		"ip_sans":     []interface{}{"10.0.0.5"},
+		"ttl":         "72h",
+	}))
+	if err != nil {
+		t.Fatalf("pki_issue: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"common_name": "app.example.com",
+		"alt_names":   "www.example.com,api.example.com",
+		"ip_sans":     "10.0.0.5",
+		"ttl":         "72h",
+	}
+	if body := f.last(t, http.MethodPost, "pki-int/issue/web").body; !reflect.DeepEqual(body, want) {
+		t.Errorf("issue body = %v, want %v", body, want)
+	}
+	if out["full_chain"] != "LEAF\nINTERMEDIATE\nROOT\n" {
+		t.Errorf("full_chain = %q", out["full_chain"])
+	}
+	if out["private_key"] != "KEY" || out["private_key_type"] != "ec" || out["serial_number"] != "3a:4f" {
+		t.Errorf("output = %v", out)
+	}
+	if out["expiration"] != "2030-01-02T03:04:05Z" || out["expiration_unix"] != expires.Unix() {
+		t.Errorf("expiration = %v, %v", out["expiration"], out["expiration_unix"])
+	}
+}
+
+func TestPKIIssueChainFallsBackToIssuingCA(t *testing.T) {
+	f := newFakeVault(t)
+	f.reply(http.MethodPost, "pki/issue/web", map[string]interface{}{
+		"certificate": "LEAF",
+		"issuing_ca":  "ROOT",
+	})
+
+	out, err := (&VaultPlugin{}).Execute(context.Background(), "pki_issue", f.params(map[string]interface{}{
+		"role":        "web",
+		"common_name": "app.example.com",
+	}))
+	if err != nil {
+		t.Fatalf("pki_issue: %v", err)
+	}
+	if !reflect.DeepEqual(out["ca_chain"], []string{"ROOT"}) || out["full_chain"] != "LEAF\nROOT\n" {
+		t.Errorf("ca_chain = %v, full_chain = %q", out["ca_chain"], out["full_chain"])
+	}
+}
+
+func TestPKISignTakesCommonNameFromCSR(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "build-agent.internal"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+
+	f := newFakeVault(t)
+	f.reply(http.MethodPost, "pki/sign/agents", map[string]interface{}{
+		"certificate":   "LEAF",
+		"issuing_ca":    "ROOT",
+		"serial_number": "01:02",
+	})
+
+	out, err := (&VaultPlugin{}).Execute(context.Background(), "pki_sign", f.params(map[string]interface{}{
+		"role":     "agents",
+		"csr_file": writeFile(t, "agent.csr", csr),
+	}))
+	if err != nil {
+		t.Fatalf("pki_sign: %v", err)
+	}
+	body := f.last(t, http.MethodPost, "pki/sign/agents").body
+	if body["common_name"] != "build-agent.internal" || body["csr"] != csr {
+		t.Errorf("sign body = %v", body)
+	}
+	if _, ok := out["private_key"]; ok {
+		t.Error("pki_sign returned a private_key")
+	}
+
+	_, err = (&VaultPlugin{}).Execute(context.Background(), "pki_sign", f.params(map[string]interface{}{
+		"role": "agents",
+		"csr":  "not a csr",
+	}))
+	if err == nil || err.Error() != "csr is not PEM encoded" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestPKIRevokeByCertificate(t *testing.T) {
+	f := newFakeVault(t)
+	revoked := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	f.reply(http.MethodPost, "pki/revoke", map[string]interface{}{"revocation_time": revoked.Unix()})
+
+	out, err := (&VaultPlugin{}).Execute(context.Background(), "pki_revoke", f.params(map[string]interface{}{
+		"certificate": testCertificate(t, "old.example.com", 0xabc01, revoked.Add(time.Hour), nil),
+	}))
+	if err != nil {
+		t.Fatalf("pki_revoke: %v", err)
+	}
+	if body := f.last(t, http.MethodPost, "pki/revoke").body; body["serial_number"] != "0a:bc:01" {
+		t.Errorf("revoke body = %v, want Vault's serial format", body)
+	}
+	if out["revoked"] != true || out["revocation_time"] != "2025-06-01T12:00:00Z" {
+		t.Errorf("output = %v", out)
+	}
+}
+
+func TestPKIListExpiring(t *testing.T) {
+	f := newFakeVault(t)
+	now := time.Now()
+	certs := map[string]struct {
+		pem     string
+		revoked int64
+	}{
+		"soon":    {testCertificate(t, "soon.example.com", 1, now.Add(10*24*time.Hour), []string{"soon.example.com"}, net.ParseIP("10.0.0.1")), 0},
+		"sooner":  {testCertificate(t, "sooner.example.com", 2, now.Add(2*24*time.Hour), nil), 0},
+		"later":   {testCertificate(t, "later.example.com", 3, now.Add(60*24*time.Hour), nil), 0},
+		"expired": {testCertificate(t, "expired.example.com", 4, now.Add(-24*time.Hour), nil), 0},
+		"revoked": {testCertificate(t, "revoked.example.com", 5, now.Add(5*24*time.Hour), nil), now.Unix()},
+	}
+	var keys []string
+	for serial, c := range certs {
+		keys = append(keys, serial)
+		f.reply(http.MethodGet, "pki/cert/"+serial, map[string]interface{}{
+			"certificate":     c.pem,
+			"revocation_time": c.revoked,
+		})
+	}
+	f.reply("LIST", "pki/certs", map[string]interface{}{"keys": keys})
+
+	list := func(extra map[string]interface{}) []string {
+		t.Helper()
+		out, err := (&VaultPlugin{}).Execute(context.Background(), "pki_list_expiring", f.params(extra))
+		if err != nil {
+			t.Fatalf("pki_list_expiring: %v", err)
+		}
+		if out["checked"] != len(certs) {
+			t.Errorf("checked = %v, want %d", out["checked"], len(certs))
+		}
+		var serials []string
+		for _, c := range out["certificates"].([]map[string]interface{}) {
+			serials = append(serials, c["serial_number"].(string))
+		}
+		return serials
+	}
+
+	if got := list(nil); !reflect.DeepEqual(got, []string{"sooner", "soon"}) {
+		t.Errorf("default window = %v, want soonest first", got)
+	}
+	if got := list(map[string]interface{}{"within": "72h"}); !reflect.DeepEqual(got, []string{"sooner"}) {
+		t.Errorf("72h window = %v", got)
+	}
+	got := list(map[string]interface{}{"within": "90d", "include_expired": true, "include_revoked": true})
+	if want := []string{"expired", "sooner", "revoked", "soon", "later"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("90d window with expired and revoked = %v, want %v", got, want)
+	}
+
+	out, _ := (&VaultPlugin{}).Execute(context.Background(), "pki_list_expiring", f.params(nil))
+	soon := out["certificates"].([]map[string]interface{})[1]
+	if soon["common_name"] != "soon.example.com" || !reflect.DeepEqual(soon["alt_names"], []string{"soon.example.com", "10.0.0.1"}) || soon["expired"] != false {
+		t.Errorf("certificate entry = %v", soon)
+	}
+}
+
+func TestPKIListExpiringEmptyMount(t *testing.T) {
+	f := newFakeVault(t)
+	out, err := (&VaultPlugin{}).Execute(context.Background(), "pki_list_expiring", f.params(nil))
+	if err != nil {
+		t.Fatalf("pki_list_expiring: %v", err)
+	}
+	if out["count"] != 0 || out["checked"] != 0 {
+		t.Errorf("output = %v", out)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d":   30 * 24 * time.Hour,
+		"720h":  720 * time.Hour,
+		"1h30m": 90 * time.Minute,
+	}
+	for in, want := range tests {
+		if got, err := parseWindow(in); err != nil || got != want {
+			t.Errorf("parseWindow(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"d", "1.5d", "soon"} {
+		if _, err := parseWindow(in); err == nil {
+			t.Errorf("parseWindow(%q) succeeded", in)
+		}
+	}
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
-	
+	"strings"
+
 	"github.com/corynth/corynth-dist/pkg/plugin"
 )
 
-type VaultPlugin struct{}
+type VaultPlugin struct {
+	tokens tokenCache
+}
 
 func (p *VaultPlugin) Metadata() plugin.Metadata {
 	return plugin.Metadata{
@@ -21,28 +24,22 @@ func (p *VaultPlugin) Metadata() plugin.Metadata {
 }
 
 func (p *VaultPlugin) Actions() []plugin.Action {
-	return []plugin.Action{
+	actions := []plugin.Action{
 		{
 			Name:        "read",
 			Description: "Read a secret from Vault",
-			Inputs: map[string]plugin.InputSpec{
-				"address": {
-					Type:        "string",
-					Description: "Vault server address",
-					Required:    false,
-					Default:     "http://localhost:8200",
-				},
-				"token": {
-					Type:        "string",
-					Description: "Vault authentication token",
-					Required:    true,
-				},
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
 				"path": {
 					Type:        "string",
 					Description: "Secret path (e.g., secret/myapp/config)",
 					Required:    true,
 				},
-			},
+				"version": {
+					Type:        "number",
+					Description: "KV v2 version to read (default: latest)",
+					Required:    false,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"data": {
 					Type:        "object",
@@ -50,25 +47,22 @@ func (p *VaultPlugin) Actions() []plugin.Action {
 				},
 				"version": {
 					Type:        "number",
-					Description: "Secret version",
+					Description: "Secret version (0 for KV v1)",
+				},
+				"metadata": {
+					Type:        "object",
+					Description: "KV v2 created_time, deletion_time, destroyed, version and custom_metadata",
+				},
+				"kv_version": {
+					Type:        "number",
+					Description: "KV engine version of the mount (0 when not a KV mount)",
 				},
 			},
 		},
 		{
 			Name:        "write",
 			Description: "Write a secret to Vault",
-			Inputs: map[string]plugin.InputSpec{
-				"address": {
-					Type:        "string",
-					Description: "Vault server address",
-					Required:    false,
-					Default:     "http://localhost:8200",
-				},
-				"token": {
-					Type:        "string",
-					Description: "Vault authentication token",
-					Required:    true,
-				},
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
 				"path": {
 					Type:        "string",
 					Description: "Secret path (e.g., secret/myapp/config)",
@@ -79,7 +73,12 @@ func (p *VaultPlugin) Actions() []plugin.Action {
 					Description: "Secret data to write",
 					Required:    true,
 				},
-			},
+				"cas": {
+					Type:        "number",
+					Description: "KV v2 check-and-set: only write if the current version matches (0 to only create)",
+					Required:    false,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"success": {
 					Type:        "boolean",
@@ -87,63 +86,66 @@ func (p *VaultPlugin) Actions() []plugin.Action {
 				},
 				"version": {
 					Type:        "number",
-					Description: "New secret version",
+					Description: "New secret version (0 for KV v1)",
+				},
+				"created_time": {
+					Type:        "string",
+					Description: "When the KV v2 version was created",
 				},
 			},
 		},
 		{
 			Name:        "delete",
 			Description: "Delete a secret from Vault",
-			Inputs: map[string]plugin.InputSpec{
-				"address": {
-					Type:        "string",
-					Description: "Vault server address",
-					Required:    false,
-					Default:     "http://localhost:8200",
-				},
-				"token": {
-					Type:        "string",
-					Description: "Vault authentication token",
-					Required:    true,
-				},
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
 				"path": {
 					Type:        "string",
 					Description: "Secret path to delete",
 					Required:    true,
 				},
-			},
+				"versions": {
+					Type:        "array",
+					Description: "KV v2 versions to delete (default: the latest)",
+					Required:    false,
+				},
+				"destroy": {
+					Type:        "boolean",
+					Description: "Permanently destroy the given KV v2 versions",
+					Required:    false,
+					Default:     false,
+				},
+				"delete_metadata": {
+					Type:        "boolean",
+					Description: "Remove every KV v2 version and the metadata",
+					Required:    false,
+					Default:     false,
+				},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"deleted": {
 					Type:        "boolean",
 					Description: "Whether the secret was deleted",
 				},
+				"versions": {
+					Type:        "array",
+					Description: "Versions deleted or destroyed",
+				},
 			},
 		},
 		{
 			Name:        "list",
 			Description: "List secrets at a path",
-			Inputs: map[string]plugin.InputSpec{
-				"address": {
-					Type:        "string",
-					Description: "Vault server address",
-					Required:    false,
-					Default:     "http://localhost:8200",
-				},
-				"token": {
-					Type:        "string",
-					Description: "Vault authentication token",
-					Required:    true,
-				},
+			Inputs: withConnectionInputs(map[string]plugin.InputSpec{
 				"path": {
 					Type:        "string",
 					Description: "Path to list (e.g., secret/myapp/)",
 					Required:    true,
 				},
-			},
+			}),
 			Outputs: map[string]plugin.OutputSpec{
 				"keys": {
 					Type:        "array",
-					Description: "List of secret keys",
+					Description: "List of secret keys; folders end with /",
 				},
 				"count": {
 					Type:        "number",
@@ -152,17 +154,130 @@ func (p *VaultPlugin) Actions() []plugin.Action {
 			},
 		},
 	}
+	actions = append(actions, pkiActions()...)
+	actions = append(actions, leaseActions()...)
+	return append(actions, transitActions()...)
+}
+
+// withConnectionInputs adds the inputs shared by every action that talks to
+// the Vault server.
+func withConnectionInputs(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["address"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Vault server address (default: VAULT_ADDR or http://localhost:8200)",
+		Required:    false,
+	}
+	inputs["auth_method"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "token, token_file, approle, kubernetes or userpass (default: inferred from the credentials given)",
+		Required:    false,
+	}
+	inputs["auth_mount"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Path the auth method is mounted at (default: the method name)",
+		Required:    false,
+	}
+	inputs["token"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Vault token (default: VAULT_TOKEN or ~/.vault-token)",
+		Required:    false,
+	}
+	inputs["token_file"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "File holding a token, e.g. a Vault Agent sink; read on every step",
+		Required:    false,
+	}
+	inputs["role_id"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "AppRole role ID",
+		Required:    false,
+	}
+	inputs["secret_id"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "AppRole secret ID",
+		Required:    false,
+	}
+	inputs["secret_id_file"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "File holding the AppRole secret ID",
+		Required:    false,
+	}
+	inputs["role"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Kubernetes auth role",
+		Required:    false,
+	}
+	inputs["jwt_file"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Service account token for Kubernetes auth (default: " + defaultJWTFile + ")",
+		Required:    false,
+	}
+	inputs["username"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Userpass username",
+		Required:    false,
+	}
+	inputs["password"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Userpass password",
+		Required:    false,
+	}
+	inputs["password_file"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "File holding the userpass password",
+		Required:    false,
+	}
+	inputs["namespace"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Vault Enterprise namespace (default: VAULT_NAMESPACE)",
+		Required:    false,
+	}
+	inputs["ca_cert"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "PEM CA bundle used to verify the server (default: VAULT_CACERT)",
+		Required:    false,
+	}
+	inputs["client_cert"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "PEM client certificate for mutual TLS (default: VAULT_CLIENT_CERT)",
+		Required:    false,
+	}
+	inputs["client_key"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "PEM client key for mutual TLS (default: VAULT_CLIENT_KEY)",
+		Required:    false,
+	}
+	inputs["tls_server_name"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Server name to verify the certificate against (default: VAULT_TLS_SERVER_NAME)",
+		Required:    false,
+	}
+	inputs["tls_skip_verify"] = plugin.InputSpec{
+		Type:        "boolean",
+		Description: "Skip server certificate verification (default: VAULT_SKIP_VERIFY)",
+		Required:    false,
+	}
+	inputs["kv_version"] = plugin.InputSpec{
+		Type:        "number",
+		Description: "KV engine version (1 or 2) when it cannot be detected from sys/mounts",
+		Required:    false,
+	}
+	return inputs
 }
 
 func (p *VaultPlugin) Validate(params map[string]interface{}) error {
-	if token, ok := params["token"].(string); ok && token == "" {
-		return fmt.Errorf("token is required")
+	if method, ok := params["auth_method"].(string); ok && method != "" {
+		switch method {
+		case "token", "token_file", "approle", "kubernetes", "userpass":
+		default:
+			return fmt.Errorf("unsupported auth_method %q", method)
+		}
 	}
-	
+
 	if path, ok := params["path"].(string); ok && path == "" {
 		return fmt.Errorf("path cannot be empty")
 	}
-	
+
 	return nil
 }
 
@@ -176,130 +291,60 @@ func (p *VaultPlugin) Execute(ctx context.Context, action string, params map[str
 		return p.executeDelete(ctx, params)
 	case "list":
 		return p.executeList(ctx, params)
+	case "pki_issue":
+		return p.executePKIIssue(ctx, params)
+	case "pki_sign":
+		return p.executePKISign(ctx, params)
+	case "pki_revoke":
+		return p.executePKIRevoke(ctx, params)
+	case "pki_list_expiring":
+		return p.executePKIListExpiring(ctx, params)
+	case "creds_generate":
+		return p.executeCredsGenerate(ctx, params)
+	case "lease_renew":
+		return p.executeLeaseRenew(ctx, params)
+	case "lease_revoke":
+		return p.executeLeaseRevoke(ctx, params)
+	case "transit_encrypt":
+		return p.executeTransitEncrypt(ctx, params)
+	case "transit_decrypt":
+		return p.executeTransitDecrypt(ctx, params)
+	case "transit_sign":
+		return p.executeTransitSign(ctx, params)
+	case "transit_verify":
+		return p.executeTransitVerify(ctx, params)
+	case "transit_rewrap":
+		return p.executeTransitRewrap(ctx, params)
+	case "transit_datakey":
+		return p.executeTransitDatakey(ctx, params)
 	default:
 		return nil, fmt.Errorf("unknown action: %s", action)
 	}
 }
 
-func (p *VaultPlugin) executeRead(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	token, ok := params["token"].(string)
-	if !ok || token == "" {
-		return nil, fmt.Errorf("token parameter is required")
-	}
-
-	path, ok := params["path"].(string)
-	if !ok || path == "" {
-		return nil, fmt.Errorf("path parameter is required")
+// client creates an authenticated client.
+func (p *VaultPlugin) client(ctx context.Context, params map[string]interface{}) (*vaultClient, error) {
+	c, err := newClient(params)
+	if err != nil {
+		return nil, err
 	}
-
-	address := "http://localhost:8200"
-	if addr, ok := params["address"].(string); ok {
-		address = addr
-	}
-
-	// In production, this would make HTTP requests to Vault API
-	// For demonstration, we'll return mock data
-	mockData := map[string]interface{}{
-		"username": "demo_user",
-		"password": "secret_password",
-		"api_key":  "mock_api_key_12345",
+	if err := p.authenticate(ctx, c, params); err != nil {
+		return nil, err
 	}
-
-	return map[string]interface{}{
-		"data":    mockData,
-		"version": 1,
-		"path":    path,
-		"message": fmt.Sprintf("Successfully read secret from %s at %s", path, address),
-	}, nil
+	return c, nil
 }
 
-func (p *VaultPlugin) executeWrite(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	token, ok := params["token"].(string)
-	if !ok || token == "" {
-		return nil, fmt.Errorf("token parameter is required")
-	}
-
+// connect creates a client for an action on a secret path.
+func (p *VaultPlugin) connect(ctx context.Context, params map[string]interface{}) (*vaultClient, string, error) {
 	path, ok := params["path"].(string)
-	if !ok || path == "" {
-		return nil, fmt.Errorf("path parameter is required")
-	}
-
-	_, ok = params["data"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("data parameter is required and must be an object")
-	}
-
-	address := "http://localhost:8200"
-	if addr, ok := params["address"].(string); ok {
-		address = addr
+	if !ok || strings.Trim(path, "/") == "" {
+		return nil, "", fmt.Errorf("path parameter is required")
 	}
-
-	// In production, this would make HTTP requests to Vault API
-	// For demonstration, we'll simulate success
-	return map[string]interface{}{
-		"success": true,
-		"version": 2,
-		"path":    path,
-		"message": fmt.Sprintf("Successfully wrote secret to %s at %s", path, address),
-	}, nil
-}
-
-func (p *VaultPlugin) executeDelete(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	token, ok := params["token"].(string)
-	if !ok || token == "" {
-		return nil, fmt.Errorf("token parameter is required")
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, "", err
 	}
-
-	path, ok := params["path"].(string)
-	if !ok || path == "" {
-		return nil, fmt.Errorf("path parameter is required")
-	}
-
-	address := "http://localhost:8200"
-	if addr, ok := params["address"].(string); ok {
-		address = addr
-	}
-
-	// In production, this would make HTTP requests to Vault API
-	// For demonstration, we'll simulate success
-	return map[string]interface{}{
-		"deleted": true,
-		"path":    path,
-		"message": fmt.Sprintf("Successfully deleted secret at %s from %s", path, address),
-	}, nil
-}
-
-func (p *VaultPlugin) executeList(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	token, ok := params["token"].(string)
-	if !ok || token == "" {
-		return nil, fmt.Errorf("token parameter is required")
-	}
-
-	path, ok := params["path"].(string)
-	if !ok || path == "" {
-		return nil, fmt.Errorf("path parameter is required")
-	}
-
-	address := "http://localhost:8200"
-	if addr, ok := params["address"].(string); ok {
-		address = addr
-	}
-
-	// In production, this would make HTTP requests to Vault API
-	// For demonstration, we'll return mock keys
-	mockKeys := []string{
-		"database-credentials",
-		"api-keys",
-		"certificates",
-		"app-config",
-	}
-
-	return map[string]interface{}{
-		"keys":    mockKeys,
-		"count":   len(mockKeys),
-		"path":    path,
-		"message": fmt.Sprintf("Listed %d secrets at %s from %s", len(mockKeys), path, address),
-	}, nil
+	return c, strings.Trim(path, "/"), nil
 }
 
-var ExportedPlugin plugin.Plugin = &VaultPlugin{}
\ No newline at end of file
+var ExportedPlugin plugin.Plugin = &VaultPlugin{}
@@ -28,16 +28,18 @@ workflow "certificate-management" {
 
   step "enable_pki_engine" {
     plugin = "vault"
-    action = "mount"
+    action = "write"
     
     params = {
-      addr  = var.vault_addr
-      token = var.vault_token
-      path  = "pki"
-      type  = "pki"
-      config = {
-        default_lease_ttl = "768h"
-        max_lease_ttl     = "8760h"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "sys/mounts/pki"
+      data = {
+        type = "pki"
+        config = {
+          default_lease_ttl = "768h"
+          max_lease_ttl     = "8760h"
+        }
       }
     }
   }
@@ -49,9 +51,9 @@ workflow "certificate-management" {
     depends_on = ["enable_pki_engine"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "pki/root/generate/internal"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "pki/root/generate/internal"
       data = {
         common_name = "Corynth Internal CA"
         ttl         = "8760h"
@@ -69,9 +71,9 @@ workflow "certificate-management" {
     depends_on = ["configure_ca"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "pki/config/urls"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "pki/config/urls"
       data = {
         issuing_certificates    = "${var.vault_addr}/v1/pki/ca"
         crl_distribution_points = "${var.vault_addr}/v1/pki/crl"
@@ -86,9 +88,9 @@ workflow "certificate-management" {
     depends_on = ["configure_ca_urls"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "pki/roles/${var.service_name}-role"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "pki/roles/${var.service_name}-role"
       data = {
         allowed_domains    = [var.domain_name, "*.${var.domain_name}"]
         allow_subdomains   = true
@@ -102,19 +104,18 @@ workflow "certificate-management" {
 
   step "issue_certificate" {
     plugin = "vault"
-    action = "write"
+    action = "pki_issue"
     
     depends_on = ["create_pki_role"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "pki/issue/${var.service_name}-role"
-      data = {
-        common_name = "${var.service_name}.${var.domain_name}"
-        alt_names   = "www.${var.service_name}.${var.domain_name},${var.service_name}-staging.${var.domain_name}"
-        ttl         = "168h"
-      }
+      address     = var.vault_addr
+      token       = var.vault_token
+      mount       = "pki"
+      role        = "${var.service_name}-role"
+      common_name = "${var.service_name}.${var.domain_name}"
+      alt_names   = ["www.${var.service_name}.${var.domain_name}", "${var.service_name}-staging.${var.domain_name}"]
+      ttl         = "168h"
     }
   }
 
@@ -154,11 +155,23 @@ workflow "certificate-management" {
     }
   }
 
+  step "save_full_chain" {
+    plugin = "file"
+    action = "write"
+    
+    depends_on = ["save_ca_certificate"]
+    
+    params = {
+      path    = "/tmp/${var.service_name}.${var.domain_name}.fullchain.crt"
+      content = "${issue_certificate.full_chain}"
+    }
+  }
+
   step "verify_certificate" {
     plugin = "shell"
     action = "exec"
     
-    depends_on = ["save_ca_certificate"]
+    depends_on = ["save_full_chain"]
     
     params = {
       command = <<-EOF
@@ -183,17 +196,40 @@ workflow "certificate-management" {
     depends_on = ["verify_certificate"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "secret/data/certificates/${var.service_name}"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "secret/certificates/${var.service_name}"
       data = {
         domain          = "${var.service_name}.${var.domain_name}"
         serial_number   = "${issue_certificate.serial_number}"
-        issued_date     = "$(date)"
         expiry_date     = "${issue_certificate.expiration}"
-        renewal_needed  = "$(date -d '+5 days' -d '${issue_certificate.expiration}')"
         managed_by      = "corynth-vault-plugin"
       }
     }
   }
+
+  step "find_expiring_certificates" {
+    plugin = "vault"
+    action = "pki_list_expiring"
+    
+    depends_on = ["create_renewal_reminder"]
+    
+    params = {
+      address = var.vault_addr
+      token   = var.vault_token
+      mount   = "pki"
+      within  = "30d"
+    }
+  }
+
+  step "report_expiring_certificates" {
+    plugin = "shell"
+    action = "exec"
+    
+    depends_on = ["find_expiring_certificates"]
+    
+    params = {
+      command = "echo '${find_expiring_certificates.message}'"
+    }
+  }
 }
\ No newline at end of file
@@ -0,0 +1,109 @@
+workflow "database-credentials" {
+  description = "Short-lived MySQL credentials from the Vault database secrets engine"
+  version     = "1.0.0"
+
+  variable "vault_addr" {
+    type        = string
+    default     = "http://localhost:8200"
+    description = "Vault server address"
+  }
+
+  variable "role_id" {
+    type        = string
+    default     = "corynth-reporting"
+    description = "AppRole role ID of the workflow"
+  }
+
+  variable "secret_id_file" {
+    type        = string
+    default     = "/etc/corynth/secret-id"
+    description = "File holding the AppRole secret ID"
+  }
+
+  variable "mysql_host" {
+    type        = string
+    default     = "localhost"
+    description = "MySQL server host"
+  }
+
+  variable "database_name" {
+    type        = string
+    default     = "reporting"
+    description = "Database to query"
+  }
+
+  # revoke_on_cancel only covers an abort while this step is running; once
+  # the credentials are handed on, revoke_db_credentials ends the lease.
+  step "generate_db_credentials" {
+    plugin = "vault"
+    action = "creds_generate"
+
+    params = {
+      address          = var.vault_addr
+      role_id          = var.role_id
+      secret_id_file   = var.secret_id_file
+      mount            = "database"
+      role             = "reporting-readonly"
+      revoke_on_cancel = true
+    }
+  }
+
+  step "count_orders" {
+    plugin = "mysql"
+    action = "query"
+
+    depends_on = ["generate_db_credentials"]
+
+    params = {
+      host     = var.mysql_host
+      database = var.database_name
+      user     = generate_db_credentials.username
+      password = generate_db_credentials.password
+      query    = "SELECT COUNT(*) AS orders FROM orders WHERE created_at > NOW() - INTERVAL 1 DAY"
+    }
+  }
+
+  step "extend_credentials" {
+    plugin = "vault"
+    action = "lease_renew"
+
+    depends_on = ["count_orders"]
+
+    params = {
+      address        = var.vault_addr
+      role_id        = var.role_id
+      secret_id_file = var.secret_id_file
+      lease_id       = generate_db_credentials.lease_id
+      increment      = "15m"
+    }
+  }
+
+  step "export_report" {
+    plugin = "mysql"
+    action = "query"
+
+    depends_on = ["extend_credentials"]
+
+    params = {
+      host     = var.mysql_host
+      database = var.database_name
+      user     = generate_db_credentials.username
+      password = generate_db_credentials.password
+      query    = "SELECT status, COUNT(*) AS total FROM orders GROUP BY status"
+    }
+  }
+
+  step "revoke_db_credentials" {
+    plugin = "vault"
+    action = "lease_revoke"
+
+    depends_on = ["export_report"]
+
+    params = {
+      address        = var.vault_addr
+      role_id        = var.role_id
+      secret_id_file = var.secret_id_file
+      lease_id       = generate_db_credentials.lease_id
+    }
+  }
+}
@@ -0,0 +1,95 @@
+workflow "encrypted-artefacts" {
+  description = "Encrypt and sign build artefacts with Vault transit before uploading them to S3"
+  version     = "1.0.0"
+
+  variable "vault_addr" {
+    type        = string
+    default     = "http://localhost:8200"
+    description = "Vault server address"
+  }
+
+  variable "artefact" {
+    type        = string
+    default     = "/tmp/build/app-1.4.2.tar.gz"
+    description = "Artefact to publish"
+  }
+
+  variable "bucket" {
+    type        = string
+    default     = "acme-release-artefacts"
+    description = "Destination S3 bucket"
+  }
+
+  step "encrypt_artefact" {
+    plugin = "vault"
+    action = "transit_encrypt"
+
+    params = {
+      address     = var.vault_addr
+      token_file  = "/var/run/vault/agent-token"
+      key         = "release-artefacts"
+      input_file  = var.artefact
+      output_file = "${var.artefact}.vault"
+    }
+  }
+
+  step "sign_artefact" {
+    plugin = "vault"
+    action = "transit_sign"
+
+    depends_on = ["encrypt_artefact"]
+
+    params = {
+      address        = var.vault_addr
+      token_file     = "/var/run/vault/agent-token"
+      key            = "release-signing"
+      input_file     = var.artefact
+      hash_algorithm = "sha2-256"
+    }
+  }
+
+  step "upload_artefact" {
+    plugin = "aws"
+    action = "s3_upload"
+
+    depends_on = ["sign_artefact"]
+
+    params = {
+      file_path    = "${var.artefact}.vault"
+      bucket       = var.bucket
+      key          = "releases/app-1.4.2.tar.gz.vault"
+      content_type = "text/plain"
+      metadata = {
+        transit-key         = "release-artefacts"
+        transit-key-version = "${encrypt_artefact.key_version}"
+        signature           = "${sign_artefact.signature}"
+      }
+    }
+  }
+
+  step "verify_roundtrip" {
+    plugin = "vault"
+    action = "transit_decrypt"
+
+    depends_on = ["upload_artefact"]
+
+    params = {
+      address         = var.vault_addr
+      token_file      = "/var/run/vault/agent-token"
+      key             = "release-artefacts"
+      ciphertext_file = "${var.artefact}.vault"
+      output_file     = "${var.artefact}.check"
+    }
+  }
+
+  step "compare_roundtrip" {
+    plugin = "shell"
+    action = "exec"
+
+    depends_on = ["verify_roundtrip"]
+
+    params = {
+      command = "cmp ${var.artefact} ${var.artefact}.check && rm ${var.artefact}.check"
+    }
+  }
+}
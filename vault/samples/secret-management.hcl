@@ -31,10 +31,10 @@ workflow "secret-management" {
     action = "mount"
     
     params = {
-      addr  = var.vault_addr
-      token = var.vault_token
-      path  = "secret"
-      type  = "kv-v2"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "secret"
+      type    = "kv-v2"
     }
   }
 
@@ -45,9 +45,9 @@ workflow "secret-management" {
     depends_on = ["enable_kv_engine"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "secret/data/${var.app_name}/${var.environment}/database"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "secret/${var.app_name}/${var.environment}/database"
       data = {
         username = "db_user"
         password = "supersecret123"
@@ -65,9 +65,9 @@ workflow "secret-management" {
     depends_on = ["store_database_credentials"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "secret/data/${var.app_name}/${var.environment}/api-keys"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "secret/${var.app_name}/${var.environment}/api-keys"
       data = {
         stripe_secret_key    = "sk_test_123456789"
         sendgrid_api_key     = "SG.123456789"
@@ -84,9 +84,9 @@ workflow "secret-management" {
     depends_on = ["store_api_keys"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "secret/data/${var.app_name}/${var.environment}/services"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "secret/${var.app_name}/${var.environment}/services"
       data = {
         redis_password       = "redis-password-123"
         elasticsearch_user   = "elastic"
@@ -104,9 +104,9 @@ workflow "secret-management" {
     depends_on = ["store_service_credentials"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "secret/data/${var.app_name}/${var.environment}/database"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "secret/${var.app_name}/${var.environment}/database"
     }
   }
 
@@ -117,9 +117,9 @@ workflow "secret-management" {
     depends_on = ["retrieve_database_config"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      path   = "secret/metadata/${var.app_name}/${var.environment}"
+      address = var.vault_addr
+      token   = var.vault_token
+      path    = "secret/${var.app_name}/${var.environment}"
     }
   }
 
@@ -130,10 +130,10 @@ workflow "secret-management" {
     depends_on = ["list_secret_paths"]
     
     params = {
-      addr   = var.vault_addr
-      token  = var.vault_token
-      name   = "${var.app_name}-${var.environment}-policy"
-      policy = <<-EOF
+      address = var.vault_addr
+      token   = var.vault_token
+      name    = "${var.app_name}-${var.environment}-policy"
+      policy  = <<-EOF
         # Allow reading secrets for specific app and environment
         path "secret/data/${var.app_name}/${var.environment}/*" {
           capabilities = ["read"]
@@ -0,0 +1,707 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/corynth/corynth-dist/pkg/plugin"
+)
+
+// withTransitInputs adds the inputs shared by every transit action.
+func withTransitInputs(inputs map[string]plugin.InputSpec) map[string]plugin.InputSpec {
+	inputs["mount"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Transit secrets engine mount",
+		Required:    false,
+		Default:     "transit",
+	}
+	inputs["key"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Name of the transit key",
+		Required:    true,
+	}
+	inputs["context"] = plugin.InputSpec{
+		Type:        "string",
+		Description: "Key derivation context, for derived keys",
+		Required:    false,
+	}
+	return withConnectionInputs(inputs)
+}
+
+// transitActions declares the transit secrets engine actions.
+func transitActions() []plugin.Action {
+	return []plugin.Action{
+		{
+			Name:        "transit_encrypt",
+			Description: "Encrypt data with a transit key",
+			Inputs: withTransitInputs(map[string]plugin.InputSpec{
+				"plaintext": {
+					Type:        "string",
+					Description: "Data to encrypt; base64 encoded by the plugin",
+					Required:    false,
+				},
+				"input_file": {
+					Type:        "string",
+					Description: "File to encrypt, instead of plaintext",
+					Required:    false,
+				},
+				"base64_encoded": {
+					Type:        "boolean",
+					Description: "plaintext is already base64 encoded",
+					Required:    false,
+					Default:     false,
+				},
+				"batch": {
+					Type:        "array",
+					Description: "Items to encrypt in one request: strings, or objects with plaintext and context",
+					Required:    false,
+				},
+				"key_version": {
+					Type:        "number",
+					Description: "Key version to encrypt with (default: latest)",
+					Required:    false,
+				},
+				"output_file": {
+					Type:        "string",
+					Description: "File to write the ciphertext to",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"ciphertext": {
+					Type:        "string",
+					Description: "Ciphertext (vault:v<N>:...)",
+				},
+				"key_version": {
+					Type:        "number",
+					Description: "Key version used",
+				},
+				"batch_results": {
+					Type:        "array",
+					Description: "Ciphertext and key_version of each batch item",
+				},
+			},
+		},
+		{
+			Name:        "transit_decrypt",
+			Description: "Decrypt transit ciphertext",
+			Inputs: withTransitInputs(map[string]plugin.InputSpec{
+				"ciphertext": {
+					Type:        "string",
+					Description: "Ciphertext to decrypt",
+					Required:    false,
+				},
+				"ciphertext_file": {
+					Type:        "string",
+					Description: "File holding the ciphertext, instead of ciphertext",
+					Required:    false,
+				},
+				"batch": {
+					Type:        "array",
+					Description: "Items to decrypt in one request: strings, or objects with ciphertext and context",
+					Required:    false,
+				},
+				"output_file": {
+					Type:        "string",
+					Description: "File to write the decrypted bytes to",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"plaintext": {
+					Type:        "string",
+					Description: "Decrypted data",
+				},
+				"plaintext_base64": {
+					Type:        "string",
+					Description: "Decrypted data, base64 encoded, for binary data",
+				},
+				"batch_results": {
+					Type:        "array",
+					Description: "plaintext and plaintext_base64 of each batch item",
+				},
+			},
+		},
+		{
+			Name:        "transit_sign",
+			Description: "Sign data with a transit key",
+			Inputs: withTransitInputs(map[string]plugin.InputSpec{
+				"input": {
+					Type:        "string",
+					Description: "Data to sign; base64 encoded by the plugin",
+					Required:    false,
+				},
+				"input_file": {
+					Type:        "string",
+					Description: "File to sign, instead of input",
+					Required:    false,
+				},
+				"base64_encoded": {
+					Type:        "boolean",
+					Description: "input is already base64 encoded",
+					Required:    false,
+					Default:     false,
+				},
+				"batch": {
+					Type:        "array",
+					Description: "Items to sign in one request: strings, or objects with input and context",
+					Required:    false,
+				},
+				"hash_algorithm": {
+					Type:        "string",
+					Description: "Hash algorithm, e.g. sha2-256 (default: sha2-256)",
+					Required:    false,
+				},
+				"signature_algorithm": {
+					Type:        "string",
+					Description: "RSA signature algorithm: pss or pkcs1v15",
+					Required:    false,
+				},
+				"prehashed": {
+					Type:        "boolean",
+					Description: "input is already a hash",
+					Required:    false,
+					Default:     false,
+				},
+				"key_version": {
+					Type:        "number",
+					Description: "Key version to sign with (default: latest)",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"signature": {
+					Type:        "string",
+					Description: "Signature (vault:v<N>:...)",
+				},
+				"key_version": {
+					Type:        "number",
+					Description: "Key version used",
+				},
+				"batch_results": {
+					Type:        "array",
+					Description: "signature and key_version of each batch item",
+				},
+			},
+		},
+		{
+			Name:        "transit_verify",
+			Description: "Verify a signature made with a transit key",
+			Inputs: withTransitInputs(map[string]plugin.InputSpec{
+				"input": {
+					Type:        "string",
+					Description: "Signed data; base64 encoded by the plugin",
+					Required:    false,
+				},
+				"input_file": {
+					Type:        "string",
+					Description: "Signed file, instead of input",
+					Required:    false,
+				},
+				"base64_encoded": {
+					Type:        "boolean",
+					Description: "input is already base64 encoded",
+					Required:    false,
+					Default:     false,
+				},
+				"signature": {
+					Type:        "string",
+					Description: "Signature to verify",
+					Required:    false,
+				},
+				"batch": {
+					Type:        "array",
+					Description: "Objects with input, signature and context to verify in one request",
+					Required:    false,
+				},
+				"hash_algorithm": {
+					Type:        "string",
+					Description: "Hash algorithm used when signing",
+					Required:    false,
+				},
+				"signature_algorithm": {
+					Type:        "string",
+					Description: "RSA signature algorithm used when signing",
+					Required:    false,
+				},
+				"prehashed": {
+					Type:        "boolean",
+					Description: "input is already a hash",
+					Required:    false,
+					Default:     false,
+				},
+				"fail_on_invalid": {
+					Type:        "boolean",
+					Description: "Fail the step when a signature is invalid",
+					Required:    false,
+					Default:     false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"valid": {
+					Type:        "boolean",
+					Description: "Whether every signature is valid",
+				},
+				"batch_results": {
+					Type:        "array",
+					Description: "valid for each batch item",
+				},
+			},
+		},
+		{
+			Name:        "transit_rewrap",
+			Description: "Re-encrypt ciphertext with the latest version of its key",
+			Inputs: withTransitInputs(map[string]plugin.InputSpec{
+				"ciphertext": {
+					Type:        "string",
+					Description: "Ciphertext to rewrap",
+					Required:    false,
+				},
+				"batch": {
+					Type:        "array",
+					Description: "Items to rewrap in one request: strings, or objects with ciphertext and context",
+					Required:    false,
+				},
+				"key_version": {
+					Type:        "number",
+					Description: "Key version to rewrap to (default: latest)",
+					Required:    false,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"ciphertext": {
+					Type:        "string",
+					Description: "Rewrapped ciphertext",
+				},
+				"key_version": {
+					Type:        "number",
+					Description: "Key version now used",
+				},
+				"batch_results": {
+					Type:        "array",
+					Description: "ciphertext and key_version of each batch item",
+				},
+			},
+		},
+		{
+			Name:        "transit_datakey",
+			Description: "Generate a data key for local envelope encryption",
+			Inputs: withTransitInputs(map[string]plugin.InputSpec{
+				"type": {
+					Type:        "string",
+					Description: "plaintext to also return the key itself, or wrapped for only the encrypted key",
+					Required:    false,
+					Default:     "plaintext",
+				},
+				"bits": {
+					Type:        "number",
+					Description: "Key size: 128, 256 or 512",
+					Required:    false,
+					Default:     256,
+				},
+			}),
+			Outputs: map[string]plugin.OutputSpec{
+				"ciphertext": {
+					Type:        "string",
+					Description: "Data key encrypted with the transit key; store it with the data",
+				},
+				"plaintext": {
+					Type:        "string",
+					Description: "Base64 data key, for type plaintext; never store it",
+				},
+				"key_version": {
+					Type:        "number",
+					Description: "Transit key version used",
+				},
+			},
+		},
+	}
+}
+
+// transitResult is one result of a transit operation, batched or not.
+type transitResult struct {
+	Ciphertext string `json:"ciphertext"`
+	Plaintext  string `json:"plaintext"`
+	Signature  string `json:"signature"`
+	Valid      bool   `json:"valid"`
+	KeyVersion int    `json:"key_version"`
+	Error      string `json:"error"`
+}
+
+type transitResponse struct {
+	transitResult
+	BatchResults []transitResult `json:"batch_results"`
+}
+
+// transitPath returns <mount>/<operation>/<key>.
+func transitPath(params map[string]interface{}, operation string) (string, error) {
+	key := strings.Trim(stringParam(params, "key", ""), "/")
+	if key == "" {
+		return "", fmt.Errorf("key parameter is required")
+	}
+	mount := strings.Trim(stringParam(params, "mount", ""), "/")
+	if mount == "" {
+		mount = "transit"
+	}
+	return mount + "/" + operation + "/" + key, nil
+}
+
+// encodeData base64 encodes data for transit unless it already is.
+func encodeData(data string, encoded bool) string {
+	if encoded {
+		return data
+	}
+	return base64.StdEncoding.EncodeToString([]byte(data))
+}
+
+// dataParam reads the data an operation works on from the named parameter
+// or from fileParam, base64 encoded.
+func dataParam(params map[string]interface{}, name, fileParam string) (string, error) {
+	encoded, _ := params["base64_encoded"].(bool)
+	if v, ok := params[name].(string); ok && v != "" {
+		return encodeData(v, encoded), nil
+	}
+	if file := stringParam(params, fileParam, ""); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", fileParam, err)
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return "", nil
+}
+
+// batchInput builds batch_input from the batch parameter. Items are strings
+// holding field, or objects. field (when encode is set) and context are
+// base64 encoded unless base64_encoded is set.
+func batchInput(params map[string]interface{}, field string, encode bool) ([]map[string]interface{}, error) {
+	raw, ok := params["batch"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	encoded, _ := params["base64_encoded"].(bool)
+	items := make([]map[string]interface{}, 0, len(raw))
+	for i, item := range raw {
+		entry := map[string]interface{}{}
+		switch v := item.(type) {
+		case string:
+			entry[field] = v
+		case map[string]interface{}:
+			for k, value := range v {
+				entry[k] = value
+			}
+		default:
+			return nil, fmt.Errorf("batch item %d must be a string or an object", i)
+		}
+		value, ok := entry[field].(string)
+		if !ok {
+			return nil, fmt.Errorf("batch item %d has no %s", i, field)
+		}
+		if encode {
+			entry[field] = encodeData(value, encoded)
+		}
+		if ctxValue, ok := entry["context"].(string); ok {
+			entry["context"] = encodeData(ctxValue, encoded)
+		}
+		items = append(items, entry)
+	}
+	return items, nil
+}
+
+// transitRequest sends a transit operation and fails on any batch item
+// error.
+func (p *VaultPlugin) transitRequest(ctx context.Context, params map[string]interface{}, operation string, body map[string]interface{}) (*transitResponse, error) {
+	path, err := transitPath(params, operation)
+	if err != nil {
+		return nil, err
+	}
+	if derivation := stringParam(params, "context", ""); derivation != "" {
+		encoded, _ := params["base64_encoded"].(bool)
+		body["context"] = encodeData(derivation, encoded)
+	}
+	if version := intParam(params, "key_version", 0); version > 0 {
+		body["key_version"] = version
+	}
+	c, err := p.client(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp transitResponse
+	if _, err := c.read(ctx, http.MethodPost, path, nil, body, &resp); err != nil {
+		return nil, fmt.Errorf("transit %s failed: %w", operation, err)
+	}
+	for i, r := range resp.BatchResults {
+		if r.Error != "" {
+			return nil, fmt.Errorf("transit %s failed for batch item %d: %s", operation, i, r.Error)
+		}
+	}
+	return &resp, nil
+}
+
+// requestBody builds the body of a request for one item, or for the batch
+// parameter when set.
+func requestBody(params map[string]interface{}, field, value string, encode bool) (map[string]interface{}, bool, error) {
+	batch, err := batchInput(params, field, encode)
+	if err != nil {
+		return nil, false, err
+	}
+	if batch != nil {
+		return map[string]interface{}{"batch_input": batch}, true, nil
+	}
+	if value == "" {
+		return nil, false, fmt.Errorf("%s or batch parameter is required", field)
+	}
+	return map[string]interface{}{field: value}, false, nil
+}
+
+func (p *VaultPlugin) executeTransitEncrypt(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	plaintext, err := dataParam(params, "plaintext", "input_file")
+	if err != nil {
+		return nil, err
+	}
+	body, isBatch, err := requestBody(params, "plaintext", plaintext, true)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.transitRequest(ctx, params, "encrypt", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if isBatch {
+		results := make([]map[string]interface{}, 0, len(resp.BatchResults))
+		for _, r := range resp.BatchResults {
+			results = append(results, map[string]interface{}{
+				"ciphertext":  r.Ciphertext,
+				"key_version": r.KeyVersion,
+			})
+		}
+		return map[string]interface{}{
+			"batch_results": results,
+			"message":       fmt.Sprintf("Encrypted %d items with %s", len(results), params["key"]),
+		}, nil
+	}
+
+	if file := stringParam(params, "output_file", ""); file != "" {
+		if err := os.WriteFile(file, []byte(resp.Ciphertext), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write output_file: %w", err)
+		}
+	}
+	return map[string]interface{}{
+		"ciphertext":  resp.Ciphertext,
+		"key_version": resp.KeyVersion,
+		"message":     fmt.Sprintf("Encrypted with %s version %d", params["key"], resp.KeyVersion),
+	}, nil
+}
+
+func (p *VaultPlugin) executeTransitDecrypt(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	ciphertext := stringParam(params, "ciphertext", "")
+	if file := stringParam(params, "ciphertext_file", ""); ciphertext == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ciphertext_file: %w", err)
+		}
+		ciphertext = strings.TrimSpace(string(data))
+	}
+	body, isBatch, err := requestBody(params, "ciphertext", ciphertext, false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.transitRequest(ctx, params, "decrypt", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if isBatch {
+		results := make([]map[string]interface{}, 0, len(resp.BatchResults))
+		for i, r := range resp.BatchResults {
+			decoded, err := base64.StdEncoding.DecodeString(r.Plaintext)
+			if err != nil {
+				return nil, fmt.Errorf("batch item %d: invalid plaintext encoding: %w", i, err)
+			}
+			results = append(results, map[string]interface{}{
+				"plaintext":        string(decoded),
+				"plaintext_base64": r.Plaintext,
+			})
+		}
+		return map[string]interface{}{
+			"batch_results": results,
+			"message":       fmt.Sprintf("Decrypted %d items with %s", len(results), params["key"]),
+		}, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plaintext encoding: %w", err)
+	}
+	if file := stringParam(params, "output_file", ""); file != "" {
+		if err := os.WriteFile(file, decoded, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write output_file: %w", err)
+		}
+	}
+	return map[string]interface{}{
+		"plaintext":        string(decoded),
+		"plaintext_base64": resp.Plaintext,
+		"message":          fmt.Sprintf("Decrypted %d bytes with %s", len(decoded), params["key"]),
+	}, nil
+}
+
+// signatureOptions copies the hashing options shared by sign and verify.
+func signatureOptions(params map[string]interface{}, body map[string]interface{}) {
+	for _, name := range []string{"hash_algorithm", "signature_algorithm"} {
+		if v := stringParam(params, name, ""); v != "" {
+			body[name] = v
+		}
+	}
+	if prehashed, _ := params["prehashed"].(bool); prehashed {
+		body["prehashed"] = true
+	}
+}
+
+func (p *VaultPlugin) executeTransitSign(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	input, err := dataParam(params, "input", "input_file")
+	if err != nil {
+		return nil, err
+	}
+	body, isBatch, err := requestBody(params, "input", input, true)
+	if err != nil {
+		return nil, err
+	}
+	signatureOptions(params, body)
+	resp, err := p.transitRequest(ctx, params, "sign", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if isBatch {
+		results := make([]map[string]interface{}, 0, len(resp.BatchResults))
+		for _, r := range resp.BatchResults {
+			results = append(results, map[string]interface{}{
+				"signature":   r.Signature,
+				"key_version": r.KeyVersion,
+			})
+		}
+		return map[string]interface{}{
+			"batch_results": results,
+			"message":       fmt.Sprintf("Signed %d items with %s", len(results), params["key"]),
+		}, nil
+	}
+	return map[string]interface{}{
+		"signature":   resp.Signature,
+		"key_version": resp.KeyVersion,
+		"message":     fmt.Sprintf("Signed with %s version %d", params["key"], resp.KeyVersion),
+	}, nil
+}
+
+func (p *VaultPlugin) executeTransitVerify(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	input, err := dataParam(params, "input", "input_file")
+	if err != nil {
+		return nil, err
+	}
+	body, isBatch, err := requestBody(params, "input", input, true)
+	if err != nil {
+		return nil, err
+	}
+	if !isBatch {
+		signature := stringParam(params, "signature", "")
+		if signature == "" {
+			return nil, fmt.Errorf("signature parameter is required")
+		}
+		body["signature"] = signature
+	}
+	signatureOptions(params, body)
+	resp, err := p.transitRequest(ctx, params, "verify", body)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := resp.Valid
+	var results []map[string]interface{}
+	invalid := 0
+	if isBatch {
+		valid = true
+		results = make([]map[string]interface{}, 0, len(resp.BatchResults))
+		for _, r := range resp.BatchResults {
+			results = append(results, map[string]interface{}{"valid": r.Valid})
+			if !r.Valid {
+				valid = false
+				invalid++
+			}
+		}
+	} else if !valid {
+		invalid = 1
+	}
+
+	if fail, _ := params["fail_on_invalid"].(bool); fail && !valid {
+		return nil, fmt.Errorf("%d signatures are invalid", invalid)
+	}
+	message := "Signature is valid"
+	if !valid {
+		message = fmt.Sprintf("%d signatures are invalid", invalid)
+	}
+	out := map[string]interface{}{
+		"valid":   valid,
+		"message": message,
+	}
+	if isBatch {
+		out["batch_results"] = results
+	}
+	return out, nil
+}
+
+func (p *VaultPlugin) executeTransitRewrap(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	body, isBatch, err := requestBody(params, "ciphertext", stringParam(params, "ciphertext", ""), false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.transitRequest(ctx, params, "rewrap", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if isBatch {
+		results := make([]map[string]interface{}, 0, len(resp.BatchResults))
+		for _, r := range resp.BatchResults {
+			results = append(results, map[string]interface{}{
+				"ciphertext":  r.Ciphertext,
+				"key_version": r.KeyVersion,
+			})
+		}
+		return map[string]interface{}{
+			"batch_results": results,
+			"message":       fmt.Sprintf("Rewrapped %d items with %s", len(results), params["key"]),
+		}, nil
+	}
+	return map[string]interface{}{
+		"ciphertext":  resp.Ciphertext,
+		"key_version": resp.KeyVersion,
+		"message":     fmt.Sprintf("Rewrapped to %s version %d", params["key"], resp.KeyVersion),
+	}, nil
+}
+
+func (p *VaultPlugin) executeTransitDatakey(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	keyType := stringParam(params, "type", "")
+	if keyType == "" {
+		keyType = "plaintext"
+	}
+	if keyType != "plaintext" && keyType != "wrapped" {
+		return nil, fmt.Errorf("type must be plaintext or wrapped, got %q", keyType)
+	}
+	body := map[string]interface{}{"bits": intParam(params, "bits", 256)}
+	resp, err := p.transitRequest(ctx, params, "datakey/"+keyType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"ciphertext":  resp.Ciphertext,
+		"plaintext":   resp.Plaintext,
+		"key_version": resp.KeyVersion,
+		"message":     fmt.Sprintf("Generated a %d-bit data key with %s version %d", body["bits"], params["key"], resp.KeyVersion),
+	}, nil
+}
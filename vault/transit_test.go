@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// transitEngine routes the transit endpoints of key on mount to a
+// reversible stand-in: ciphertext is "vault:v2:" followed by the base64
+// plaintext, and a signature is "vault:v2:sig:" followed by the base64
+// input. Items whose ciphertext lacks the prefix fail like Vault's do.
+func (f *fakeVault) transitEngine(mount, key string) {
+	item := func(op string, in map[string]interface{}) map[string]interface{} {
+		switch op {
+		case "encrypt":
+			return map[string]interface{}{"ciphertext": "vault:v2:" + in["plaintext"].(string), "key_version": 2}
+		case "decrypt":
+			ct, _ := in["ciphertext"].(string)
+			if !strings.HasPrefix(ct, "vault:v") {
+				return map[string]interface{}{"error": "invalid ciphertext: no prefix"}
+			}
+			return map[string]interface{}{"plaintext": ct[strings.LastIndex(ct, ":")+1:]}
+		case "rewrap":
+			ct := in["ciphertext"].(string)
+			return map[string]interface{}{"ciphertext": "vault:v2:" + ct[strings.LastIndex(ct, ":")+1:], "key_version": 2}
+		case "sign":
+			return map[string]interface{}{"signature": "vault:v2:sig:" + in["input"].(string), "key_version": 2}
+		case "verify":
+			return map[string]interface{}{"valid": in["signature"] == "vault:v2:sig:"+in["input"].(string)}
+		}
+		return nil
+	}
+	for _, op := range []string{"encrypt", "decrypt", "rewrap", "sign", "verify"} {
+		op := op
+		f.handle(http.MethodPost, mount+"/"+op+"/"+key, func(r *vaultRequest) (int, interface{}) {
+			batch, ok := r.body["batch_input"].([]interface{})
+			if !ok {
+				result := item(op, r.body)
+				if msg, failed := result["error"]; failed {
+					return http.StatusBadRequest, map[string]interface{}{"errors": []interface{}{msg}}
+				}
+				return http.StatusOK, map[string]interface{}{"data": result}
+			}
+			results := make([]interface{}, 0, len(batch))
+			for _, in := range batch {
+				results = append(results, item(op, in.(map[string]interface{})))
+			}
+			return http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"batch_results": results}}
+		})
+	}
+	f.reply(http.MethodPost, mount+"/datakey/plaintext/"+key, map[string]interface{}{
+		"plaintext": "ZGF0YWtleQ==", "ciphertext": "vault:v2:wrapped", "key_version": 2,
+	})
+	f.reply(http.MethodPost, mount+"/datakey/wrapped/"+key, map[string]interface{}{
+		"ciphertext": "vault:v2:wrapped", "key_version": 2,
+	})
+}
+
+// transit runs a transit action against key artefacts on the fake server.
+func transit(t *testing.T, f *fakeVault, action string, extra map[string]interface{}) (map[string]interface{}, error) {
+	t.Helper()
+	params := f.params(extra)
+	params["key"] = "artefacts"
+	return (&VaultPlugin{}).Execute(context.Background(), action, params)
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestTransitEncryptDecrypt(t *testing.T) {
+	f := newFakeVault(t)
+	f.transitEngine("transit", "artefacts")
+
+	out, err := transit(t, f, "transit_encrypt", map[string]interface{}{
+		"plaintext":   "hello world",
+		"context":     "tenant-a",
+		"key_version": float64(2),
+	})
+	if err != nil {
+		t.Fatalf("transit_encrypt: %v", err)
+	}
+	body := f.last(t, http.MethodPost, "transit/encrypt/artefacts").body
+	if !jsonEqual(t, body, map[string]interface{}{"plaintext": b64("hello world"), "context": b64("tenant-a"), "key_version": 2}) {
+		t.Errorf("encrypt body = %v", body)
+	}
+	if out["ciphertext"] != "vault:v2:"+b64("hello world") || out["key_version"] != 2 {
+		t.Errorf("encrypt output = %v", out)
+	}
+
+	out, err = transit(t, f, "transit_decrypt", map[string]interface{}{"ciphertext": out["ciphertext"]})
+	if err != nil {
+		t.Fatalf("transit_decrypt: %v", err)
+	}
+	if out["plaintext"] != "hello world" || out["plaintext_base64"] != b64("hello world") {
+		t.Errorf("decrypt output = %v", out)
+	}
+}
+
+func TestTransitBase64Encoded(t *testing.T) {
+	f := newFakeVault(t)
+	f.transitEngine("transit", "artefacts")
+
+	if _, err := transit(t, f, "transit_encrypt", map[string]interface{}{
+		"plaintext":      "aGk=",
+		"context":        "dGVuYW50",
+		"base64_encoded": true,
+	}); err != nil {
+		t.Fatalf("transit_encrypt: %v", err)
+	}
+	body := f.last(t, http.MethodPost, "transit/encrypt/artefacts").body
+	if body["plaintext"] != "aGk=" || body["context"] != "dGVuYW50" {
+		t.Errorf("encrypt body = %v, want the values as given", body)
+	}
+}
+
+func TestTransitFiles(t *testing.T) {
+	f := newFakeVault(t)
+	f.transitEngine("transit-prod", "artefacts")
+	dir := t.TempDir()
+	input := filepath.Join(dir, "artefact.bin")
+	encrypted := filepath.Join(dir, "artefact.enc")
+	decrypted := filepath.Join(dir, "artefact.out")
+	data := []byte{0x00, 0x01, 0xfe, 0xff}
+	os.WriteFile(input, data, 0o644)
+
+	if _, err := transit(t, f, "transit_encrypt", map[string]interface{}{
+		"mount":       "transit-prod",
+		"input_file":  input,
+		"output_file": encrypted,
+	}); err != nil {
+		t.Fatalf("transit_encrypt: %v", err)
+	}
+	ciphertext, _ := os.ReadFile(encrypted)
+	if string(ciphertext) != "vault:v2:AAH+/w==" {
+		t.Errorf("ciphertext file = %q", ciphertext)
+	}
+
+	// A trailing newline, as editors and shells add, is ignored.
+	os.WriteFile(encrypted, append(ciphertext, '\n'), 0o644)
+	out, err := transit(t, f, "transit_decrypt", map[string]interface{}{
+		"mount":           "transit-prod",
+		"ciphertext_file": encrypted,
+		"output_file":     decrypted,
+	})
+	if err != nil {
+		t.Fatalf("transit_decrypt: %v", err)
+	}
+	if got, _ := os.ReadFile(decrypted); !reflect.DeepEqual(got, data) {
+		t.Errorf("decrypted file = %v, want %v", got, data)
+	}
+	if out["plaintext_base64"] != "AAH+/w==" {
+		t.Errorf("plaintext_base64 = %v", out["plaintext_base64"])
+	}
+}
+
+func TestTransitBatch(t *testing.T) {
+	f := newFakeVault(t)
+	f.transitEngine("transit", "artefacts")
+
+	out, err := transit(t, f, "transit_encrypt", map[string]interface{}{
+		"batch": []interface{}{"a", map[string]interface{}{"plaintext": "b", "context": "c"}},
+	})
+	if err != nil {
+		t.Fatalf("transit_encrypt: %v", err)
+	}
+	body := f.last(t, http.MethodPost, "transit/encrypt/artefacts").body
+	want := map[string]interface{}{"batch_input": []interface{}{
+		map[string]interface{}{"plaintext": b64("a")},
+		map[string]interface{}{"plaintext": b64("b"), "context": b64("c")},
+	}}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("batch body = %v, want %v", body, want)
+	}
+	results := out["batch_results"].([]map[string]interface{})
+	if len(results) != 2 || results[1]["ciphertext"] != "vault:v2:"+b64("b") {
+		t.Errorf("batch_results = %v", results)
+	}
+
+	out, err = transit(t, f, "transit_decrypt", map[string]interface{}{
+		"batch": []interface{}{results[0]["ciphertext"], results[1]["ciphertext"]},
+	})
+	if err != nil {
+		t.Fatalf("transit_decrypt: %v", err)
+	}
+	decrypted := out["batch_results"].([]map[string]interface{})
+	if decrypted[0]["plaintext"] != "a" || decrypted[1]["plaintext"] != "b" {
+		t.Errorf("decrypted batch = %v", decrypted)
+	}
+
+	_, err = transit(t, f, "transit_decrypt", map[string]interface{}{
+		"batch": []interface{}{"vault:v2:" + b64("a"), "garbage"},
+	})
+	if err == nil || err.Error() != "transit decrypt failed for batch item 1: invalid ciphertext: no prefix" {
+		t.Errorf("error = %v", err)
+	}
+
+	_, err = transit(t, f, "transit_encrypt", map[string]interface{}{"batch": []interface{}{float64(1)}})
+	if err == nil || err.Error() != "batch item 0 must be a string or an object" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestTransitSignVerify(t *testing.T) {
+	f := newFakeVault(t)
+	f.transitEngine("transit", "artefacts")
+
+	out, err := transit(t, f, "transit_sign", map[string]interface{}{
+		"input":               "release-1.0.tar.gz digest",
+		"hash_algorithm":      "sha2-512",
+		"signature_algorithm": "pss",
+	})
+	if err != nil {
+		t.Fatalf("transit_sign: %v", err)
+	}
+	body := f.last(t, http.MethodPost, "transit/sign/artefacts").body
+	if body["hash_algorithm"] != "sha2-512" || body["signature_algorithm"] != "pss" || body["input"] != b64("release-1.0.tar.gz digest") {
+		t.Errorf("sign body = %v", body)
+	}
+	signature := out["signature"]
+
+	out, err = transit(t, f, "transit_verify", map[string]interface{}{
+		"input":     "release-1.0.tar.gz digest",
+		"signature": signature,
+	})
+	if err != nil {
+		t.Fatalf("transit_verify: %v", err)
+	}
+	if out["valid"] != true {
+		t.Errorf("valid = %v for the signed input", out["valid"])
+	}
+
+	out, err = transit(t, f, "transit_verify", map[string]interface{}{"input": "tampered", "signature": signature})
+	if err != nil || out["valid"] != false || out["message"] != "1 signatures are invalid" {
+		t.Errorf("tampered input: output = %v, error = %v", out, err)
+	}
+	_, err = transit(t, f, "transit_verify", map[string]interface{}{
+		"input":           "tampered",
+		"signature":       signature,
+		"fail_on_invalid": true,
+	})
+	if err == nil || err.Error() != "1 signatures are invalid" {
+		t.Errorf("fail_on_invalid error = %v", err)
+	}
+
+	_, err = transit(t, f, "transit_verify", map[string]interface{}{"input": "x"})
+	if err == nil || err.Error() != "signature parameter is required" {
+		t.Errorf("missing signature error = %v", err)
+	}
+}
+
+func TestTransitVerifyBatch(t *testing.T) {
+	f := newFakeVault(t)
+	f.transitEngine("transit", "artefacts")
+
+	out, err := transit(t, f, "transit_verify", map[string]interface{}{
+		"batch": []interface{}{
+			map[string]interface{}{"input": "x", "signature": "vault:v2:sig:" + b64("x")},
+			map[string]interface{}{"input": "y", "signature": "vault:v2:sig:forged"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("transit_verify: %v", err)
+	}
+	want := []map[string]interface{}{{"valid": true}, {"valid": false}}
+	if out["valid"] != false || !reflect.DeepEqual(out["batch_results"], want) {
+		t.Errorf("output = %v", out)
+	}
+}
+
+func TestTransitRewrap(t *testing.T) {
+	f := newFakeVault(t)
+	f.transitEngine("transit", "artefacts")
+
+	out, err := transit(t, f, "transit_rewrap", map[string]interface{}{
+		"ciphertext":  "vault:v1:" + b64("a"),
+		"key_version": float64(2),
+	})
+	if err != nil {
+		t.Fatalf("transit_rewrap: %v", err)
+	}
+	if body := f.last(t, http.MethodPost, "transit/rewrap/artefacts").body; body["ciphertext"] != "vault:v1:"+b64("a") || body["key_version"] != float64(2) {
+		t.Errorf("rewrap body = %v", body)
+	}
+	if out["ciphertext"] != "vault:v2:"+b64("a") || out["key_version"] != 2 {
+		t.Errorf("output = %v", out)
+	}
+
+	out, err = transit(t, f, "transit_rewrap", map[string]interface{}{"batch": []interface{}{"vault:v1:" + b64("b")}})
+	if err != nil {
+		t.Fatalf("batch transit_rewrap: %v", err)
+	}
+	if results := out["batch_results"].([]map[string]interface{}); results[0]["ciphertext"] != "vault:v2:"+b64("b") {
+		t.Errorf("batch_results = %v", results)
+	}
+}
+
+func TestTransitDatakey(t *testing.T) {
+	f := newFakeVault(t)
+	f.transitEngine("transit", "artefacts")
+
+	out, err := transit(t, f, "transit_datakey", map[string]interface{}{"bits": float64(512)})
+	if err != nil {
+		t.Fatalf("transit_datakey: %v", err)
+	}
+	if body := f.last(t, http.MethodPost, "transit/datakey/plaintext/artefacts").body; body["bits"] != float64(512) {
+		t.Errorf("datakey body = %v", body)
+	}
+	if out["plaintext"] != "ZGF0YWtleQ==" || out["ciphertext"] != "vault:v2:wrapped" {
+		t.Errorf("output = %v", out)
+	}
+
+	out, err = transit(t, f, "transit_datakey", map[string]interface{}{"type": "wrapped"})
+	if err != nil {
+		t.Fatalf("wrapped transit_datakey: %v", err)
+	}
+	if out["plaintext"] != "" || f.last(t, http.MethodPost, "transit/datakey/wrapped/artefacts").body["bits"] != float64(256) {
+		t.Errorf("wrapped output = %v", out)
+	}
+
+	if _, err := transit(t, f, "transit_datakey", map[string]interface{}{"type": "raw"}); err == nil {
+		t.Error("transit_datakey accepted type raw")
+	}
+}
+
+func TestTransitRequiresInput(t *testing.T) {
+	f := newFakeVault(t)
+	if _, err := transit(t, f, "transit_encrypt", map[string]interface{}{}); err == nil || err.Error() != "plaintext or batch parameter is required" {
+		t.Errorf("error = %v", err)
+	}
+	_, err := (&VaultPlugin{}).Execute(context.Background(), "transit_encrypt", f.params(map[string]interface{}{"plaintext": "x"}))
+	if err == nil || err.Error() != "key parameter is required" {
+		t.Errorf("error = %v", err)
+	}
+	if n := len(f.seen()); n != 0 {
+		t.Errorf("sent %d requests for invalid parameters", n)
+	}
+}